@@ -0,0 +1,91 @@
+package bblnazuredevops
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/babylonhealth/terraform-provider-bblnazuredevops/bblnazuredevops/internal/client"
+	"github.com/babylonhealth/terraform-provider-bblnazuredevops/bblnazuredevops/internal/client/logging"
+	"github.com/babylonhealth/terraform-provider-bblnazuredevops/bblnazuredevops/internal/client/ratelimit"
+	"github.com/babylonhealth/terraform-provider-bblnazuredevops/bblnazuredevops/internal/client/vault"
+	"github.com/babylonhealth/terraform-provider-bblnazuredevops/bblnazuredevops/internal/utils/secretmemo"
+)
+
+// Defaults of the provider configuration, shared by the SDKv2 and the framework provider
+const (
+	defaultCacheTTLSeconds       = 300
+	defaultMaxConcurrentRequests = 10
+	defaultRequestsPerSecond     = 20.0
+)
+
+// providerConfig is the configuration of the provider, read either by the SDKv2 or by the framework provider
+type providerConfig struct {
+	TerraformVersion      string
+	OrganizationURL       string
+	PersonalAccessToken   string
+	DefaultProject        string
+	SecretMemo            string
+	SecretMemoHMACKey     string
+	APIVersions           map[string]string
+	CacheTTL              time.Duration
+	MaxConcurrentRequests int
+	RequestsPerSecond     float64
+	// Vault is nil when the provider has no vault block
+	Vault *vault.Config
+}
+
+// clientFactory creates the client of a provider instance. The SDKv2 and the framework provider served by the
+// mux server are both configured by Terraform, they share the client created by the first of them so that all
+// resources use the same cache and rate limiter.
+type clientFactory struct {
+	mu     sync.Mutex
+	client *client.AggregatedClient
+}
+
+func newClientFactory() *clientFactory {
+	return &clientFactory{}
+}
+
+// Client returns the client of the provider instance, creating it from config on the first call
+func (f *clientFactory) Client(ctx context.Context, config providerConfig) (*client.AggregatedClient, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.client != nil {
+		return f.client, nil
+	}
+
+	terraformVersion := config.TerraformVersion
+	if terraformVersion == "" {
+		// Terraform 0.12 introduced this field to the protocol
+		// We can therefore assume that if it's missing it's 0.10 or 0.11
+		terraformVersion = "0.11+compatible"
+	}
+
+	// the strategy and the logger are used by diff suppression functions, which have no access to the client
+	memoStrategy, err := secretmemo.New(config.SecretMemo, config.SecretMemoHMACKey)
+	if err != nil {
+		return nil, err
+	}
+	secretmemo.SetStrategy(memoStrategy)
+	logging.SetProviderContext(ctx)
+
+	limiter := ratelimit.New(config.MaxConcurrentRequests, config.RequestsPerSecond)
+
+	azdoClient, err := client.GetAzdoClient(ctx, config.PersonalAccessToken, config.OrganizationURL, terraformVersion, config.APIVersions, config.CacheTTL, limiter)
+	if err != nil {
+		return nil, err
+	}
+	azdoClient.DefaultProject = config.DefaultProject
+
+	if config.Vault != nil {
+		azdoClient.VaultClient, err = vault.New(*config.Vault, nil)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	f.client = azdoClient
+	return f.client, nil
+}
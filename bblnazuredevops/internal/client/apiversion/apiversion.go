@@ -0,0 +1,386 @@
+package apiversion
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+	"github.com/microsoft/azure-devops-go-api/azuredevops/v6"
+)
+
+// acceptHeaderFormat is the Accept header sent by the hand written clients. Only the api-version part varies.
+const acceptHeaderFormat = "application/json;api-version=%s;excludeUrls=true;enumsAsNumbers=true;msDateFormat=true;noArrayWrap=true"
+
+// DefaultDiscoveryTimeout bounds the discovery of the resource locations, so that a hung server fails it instead of
+// blocking every request waiting for it
+const DefaultDiscoveryTimeout = 30 * time.Second
+
+// discoveryBackoff is how long the default versions are used after a failed discovery before it is tried again
+const discoveryBackoff = time.Minute
+
+// Examples of api-version: 5.1, 5.1-preview, 5.1-preview.1
+var apiVersionRegEx = regexp.MustCompile(`^(\d+)\.(\d+)(-preview(\.(\d+))?)?$`)
+
+// Resource describes a REST resource that is called without the Azure DevOps SDK and therefore needs its
+// api-version negotiated by the provider.
+type Resource struct {
+	// Key is the name used to override the version from the provider configuration
+	Key string
+	// LocationID is the id of the resource location as published by the server
+	LocationID uuid.UUID
+	// Area and ResourceName are used to find the location when the server does not publish the LocationID
+	Area         string
+	ResourceName string
+	// Default is the version requested from the server. It is sent as-is when negotiation is not possible.
+	Default string
+}
+
+type resourceValuesType struct {
+	CheckConfigurations Resource
+	HierarchyQuery      Resource
+	KubernetesResources Resource
+	PipelinePermissions Resource
+	SecurityRoles       Resource
+	ServiceEndpoints    Resource
+}
+
+// ResourceValues lists the resources called by the hand written clients
+var ResourceValues = resourceValuesType{
+	CheckConfigurations: Resource{
+		Key:          "checks",
+		LocationID:   uuid.MustParse("86c8381e-5aee-4cde-8ae4-25c0c7f5eaea"),
+		Area:         "PipelinesChecks",
+		ResourceName: "configurations",
+		Default:      "5.1-preview.1",
+	},
+	HierarchyQuery: Resource{
+		Key:          "contribution",
+		LocationID:   uuid.MustParse("3353e165-a11e-43aa-9d88-14f2bb09b6d9"),
+		Area:         "Contribution",
+		ResourceName: "HierarchyQuery",
+		Default:      "5.1-preview.1",
+	},
+	KubernetesResources: Resource{
+		Key:          "kubernetesresources",
+		LocationID:   uuid.MustParse("73fba52f-15ab-42b3-a538-ce67a9223a04"),
+		Area:         "distributedtask",
+		ResourceName: "kubernetes",
+		Default:      "6.0-preview.1",
+	},
+	PipelinePermissions: Resource{
+		Key:          "pipelinepermissions",
+		LocationID:   uuid.MustParse("b5b9a4a4-e6cd-4096-853c-ab7d8b0c4eb2"),
+		Area:         "pipelinePermissions",
+		ResourceName: "pipelinePermissions",
+		Default:      "5.1-preview.1",
+	},
+	SecurityRoles: Resource{
+		Key:          "securityroles",
+		LocationID:   uuid.MustParse("9461c234-c84c-4ed2-b918-2f0f92ad0a35"),
+		Area:         "securityroles",
+		ResourceName: "roleassignments",
+		Default:      "6.0-preview.1",
+	},
+	ServiceEndpoints: Resource{
+		Key:          "serviceendpoint",
+		LocationID:   uuid.MustParse("14e48fdc-2c8b-41ce-a0c3-e26f6cc55bd0"),
+		Area:         "serviceendpoint",
+		ResourceName: "endpoints",
+		Default:      "6.0-preview.4",
+	},
+}
+
+// Keys returns the names of all resources whose version can be overridden
+func Keys() []string {
+	return []string{
+		ResourceValues.CheckConfigurations.Key,
+		ResourceValues.HierarchyQuery.Key,
+		ResourceValues.KubernetesResources.Key,
+		ResourceValues.PipelinePermissions.Key,
+		ResourceValues.SecurityRoles.Key,
+		ResourceValues.ServiceEndpoints.Key,
+	}
+}
+
+// ValidateAPIVersion checks that version is a well formed api-version, e.g. 6.0 or 6.0-preview.4
+func ValidateAPIVersion(version string) error {
+	if !apiVersionRegEx.MatchString(version) {
+		return fmt.Errorf("invalid api-version %q, expected a version like 6.0, 6.0-preview or 6.0-preview.4", version)
+	}
+	return nil
+}
+
+// AcceptHeader builds the Accept header for the given api-version
+func AcceptHeader(version string) string {
+	return fmt.Sprintf(acceptHeaderFormat, version)
+}
+
+// Negotiator discovers the API versions supported by an Azure DevOps organization or an
+// Azure DevOps Server collection and picks a compatible version for each resource.
+//
+// A nil *Negotiator is valid and always returns the default version of a resource.
+type Negotiator struct {
+	baseUrl       string
+	authorization string
+	client        *http.Client
+	overrides     map[string]string
+
+	now func() time.Time
+
+	// locations is nil until the discovery succeeds. discovering is closed when the discovery in progress ends, and
+	// failedAt is the time of the last failed discovery, see discoverResourceLocations.
+	mu          sync.Mutex
+	locations   map[uuid.UUID]azuredevops.ApiResourceLocation
+	discovering chan struct{}
+	failedAt    time.Time
+}
+
+// NewNegotiator creates a Negotiator for the organization or collection at baseUrl. Versions present in
+// overrides, keyed by Resource.Key, are used without asking the server. A nil client times out after
+// DefaultDiscoveryTimeout.
+func NewNegotiator(baseUrl string, authorization string, client *http.Client, overrides map[string]string) *Negotiator {
+	if client == nil {
+		client = &http.Client{Timeout: DefaultDiscoveryTimeout}
+	}
+	return &Negotiator{
+		baseUrl:       strings.TrimRight(baseUrl, "/"),
+		authorization: authorization,
+		client:        client,
+		overrides:     overrides,
+		now:           time.Now,
+	}
+}
+
+// Version returns the api-version to use for the resource
+func (n *Negotiator) Version(ctx context.Context, resource Resource) string {
+	if n == nil {
+		return resource.Default
+	}
+	if version, ok := n.overrides[resource.Key]; ok && version != "" {
+		return version
+	}
+
+	location := findLocation(n.discoverResourceLocations(ctx), resource)
+	if location == nil {
+		return resource.Default
+	}
+
+	version, err := NegotiateVersion(location, resource.Default)
+	if err != nil {
+		tflog.Warn(ctx, "Unable to negotiate the API version", map[string]interface{}{
+			"resource": resource.Area + "/" + resource.ResourceName,
+			"error":    err.Error(),
+		})
+		return resource.Default
+	}
+	tflog.Debug(ctx, "Negotiated api-version", map[string]interface{}{
+		"resource":    resource.Area + "/" + resource.ResourceName,
+		"api_version": version,
+	})
+	return version
+}
+
+// AcceptHeader builds the Accept header for the resource with the negotiated api-version
+func (n *Negotiator) AcceptHeader(ctx context.Context, resource Resource) string {
+	return AcceptHeader(n.Version(ctx, resource))
+}
+
+// discoverResourceLocations returns the resource locations the server exposes, discovering them on the first call.
+// Concurrent calls wait for the discovery in progress instead of sending their own, without holding the lock. A
+// failed discovery makes the calls use the default versions for discoveryBackoff, then the next call tries again.
+func (n *Negotiator) discoverResourceLocations(ctx context.Context) map[uuid.UUID]azuredevops.ApiResourceLocation {
+	for {
+		n.mu.Lock()
+		if n.locations != nil || (!n.failedAt.IsZero() && n.now().Before(n.failedAt.Add(discoveryBackoff))) {
+			locations := n.locations
+			n.mu.Unlock()
+			return locations
+		}
+		if done := n.discovering; done != nil {
+			n.mu.Unlock()
+			select {
+			case <-done:
+				continue
+			case <-ctx.Done():
+				return nil
+			}
+		}
+		done := make(chan struct{})
+		n.discovering = done
+		n.mu.Unlock()
+
+		locations, err := n.getResourceLocations(ctx)
+
+		n.mu.Lock()
+		n.discovering = nil
+		if err != nil {
+			n.failedAt = n.now()
+			tflog.Warn(ctx, "Unable to discover the API versions supported by the server, falling back to the default versions", map[string]interface{}{
+				"url":   n.baseUrl,
+				"error": err.Error(),
+				"retry": discoveryBackoff.String(),
+			})
+			locations = nil
+		} else {
+			n.locations = locations
+		}
+		close(done)
+		n.mu.Unlock()
+		return locations
+	}
+}
+
+func findLocation(locations map[uuid.UUID]azuredevops.ApiResourceLocation, resource Resource) *azuredevops.ApiResourceLocation {
+	if location, ok := locations[resource.LocationID]; ok {
+		return &location
+	}
+	for _, location := range locations {
+		if location.Area == nil || location.ResourceName == nil {
+			continue
+		}
+		if strings.EqualFold(*location.Area, resource.Area) && strings.EqualFold(*location.ResourceName, resource.ResourceName) {
+			return &location
+		}
+	}
+	return nil
+}
+
+// getResourceLocations loads the resource locations, grouped by resource area, that the server exposes.
+// This works the same for Azure DevOps Services organizations and Azure DevOps Server collections.
+func (n *Negotiator) getResourceLocations(ctx context.Context) (map[uuid.UUID]azuredevops.ApiResourceLocation, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodOptions, n.baseUrl+"/_apis", nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", n.authorization)
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("resp status code from azure: %v", resp.StatusCode)
+	}
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	wrapper := struct {
+		Value []azuredevops.ApiResourceLocation `json:"value"`
+	}{}
+	if err := json.Unmarshal(body, &wrapper); err != nil {
+		return nil, err
+	}
+
+	locations := map[uuid.UUID]azuredevops.ApiResourceLocation{}
+	for _, location := range wrapper.Value {
+		if location.Id != nil {
+			locations[*location.Id] = location
+		}
+	}
+	return locations, nil
+}
+
+type version struct {
+	major int
+	minor int
+}
+
+func (v version) compareTo(other version) int {
+	if v.major != other.major {
+		return v.major - other.major
+	}
+	return v.minor - other.minor
+}
+
+func parseVersion(s string) (version, error) {
+	parts := strings.SplitN(s, ".", 2)
+	if len(parts) != 2 {
+		return version{}, fmt.Errorf("invalid version %q", s)
+	}
+	major, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return version{}, fmt.Errorf("invalid version %q", s)
+	}
+	minor, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return version{}, fmt.Errorf("invalid version %q", s)
+	}
+	return version{major: major, minor: minor}, nil
+}
+
+// NegotiateVersion returns the highest api-version that is supported by the location and not newer than the
+// requested one. This follows the same rules as the Azure DevOps SDK uses for its own clients.
+func NegotiateVersion(location *azuredevops.ApiResourceLocation, requested string) (string, error) {
+	matches := apiVersionRegEx.FindStringSubmatch(requested)
+	if matches == nil {
+		return "", fmt.Errorf("invalid api-version %q", requested)
+	}
+	if location.MinVersion == nil || location.MaxVersion == nil {
+		return requested, nil
+	}
+
+	requestedVersion, err := parseVersion(matches[1] + "." + matches[2])
+	if err != nil {
+		return "", err
+	}
+	minVersion, err := parseVersion(*location.MinVersion)
+	if err != nil {
+		return "", err
+	}
+	maxVersion, err := parseVersion(*location.MaxVersion)
+	if err != nil {
+		return "", err
+	}
+
+	if minVersion.compareTo(requestedVersion) > 0 {
+		// the server no longer supports the requested version; send it anyway and let the server answer
+		return requested, nil
+	}
+
+	releasedVersion := version{}
+	if location.ReleasedVersion != nil {
+		if releasedVersion, err = parseVersion(*location.ReleasedVersion); err != nil {
+			return "", err
+		}
+	}
+
+	if maxVersion.compareTo(requestedVersion) < 0 {
+		// the server is older than the client, negotiate down to the latest version of the server
+		negotiated := *location.MaxVersion
+		if releasedVersion.compareTo(maxVersion) < 0 {
+			negotiated += "-preview"
+		}
+		return negotiated, nil
+	}
+
+	negotiated := matches[1] + "." + matches[2]
+	if matches[3] != "" {
+		negotiated += "-preview"
+		if matches[5] != "" {
+			resourceVersion, _ := strconv.Atoi(matches[5])
+			if location.ResourceVersion != nil && *location.ResourceVersion < resourceVersion {
+				resourceVersion = *location.ResourceVersion
+			}
+			negotiated += "." + strconv.Itoa(resourceVersion)
+		}
+	} else if (releasedVersion == version{}) || releasedVersion.compareTo(requestedVersion) < 0 {
+		// the requested version has not been released by the server
+		negotiated += "-preview"
+	}
+	return negotiated, nil
+}
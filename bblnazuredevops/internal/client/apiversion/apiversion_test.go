@@ -0,0 +1,216 @@
+package apiversion
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/microsoft/azure-devops-go-api/azuredevops/v6"
+	"github.com/stretchr/testify/require"
+)
+
+func newLocation(minVersion string, maxVersion string, releasedVersion string, resourceVersion int) *azuredevops.ApiResourceLocation {
+	return &azuredevops.ApiResourceLocation{
+		MinVersion:      &minVersion,
+		MaxVersion:      &maxVersion,
+		ReleasedVersion: &releasedVersion,
+		ResourceVersion: &resourceVersion,
+	}
+}
+
+func TestNegotiateVersion(t *testing.T) {
+	tests := []struct {
+		name      string
+		location  *azuredevops.ApiResourceLocation
+		requested string
+		want      string
+		wantErr   bool
+	}{
+		{
+			name:      "Server supports the requested preview version",
+			location:  newLocation("5.0", "7.1", "0.0", 1),
+			requested: "6.0-preview.4",
+			want:      "6.0-preview.1",
+		},
+		{
+			name:      "Server is older than the requested version",
+			location:  newLocation("3.0", "5.1", "0.0", 1),
+			requested: "6.0-preview.4",
+			want:      "5.1-preview",
+		},
+		{
+			name:      "Server is older than the requested version and released it",
+			location:  newLocation("3.0", "5.1", "5.1", 1),
+			requested: "6.0",
+			want:      "5.1",
+		},
+		{
+			name:      "Requested version has not been released",
+			location:  newLocation("5.0", "7.1", "5.0", 1),
+			requested: "6.0",
+			want:      "6.0-preview",
+		},
+		{
+			name:      "Requested version has been released",
+			location:  newLocation("5.0", "7.1", "7.1", 1),
+			requested: "6.0",
+			want:      "6.0",
+		},
+		{
+			name:      "Server no longer supports the requested version",
+			location:  newLocation("6.0", "7.1", "7.1", 1),
+			requested: "5.1-preview.1",
+			want:      "5.1-preview.1",
+		},
+		{
+			name:      "Invalid requested version",
+			location:  newLocation("5.0", "7.1", "7.1", 1),
+			requested: "latest",
+			wantErr:   true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NegotiateVersion(tt.location, tt.requested)
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func getTestServer(t *testing.T, locations []azuredevops.ApiResourceLocation, calls *int) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*calls++
+		require.Equal(t, http.MethodOptions, r.Method)
+		require.Equal(t, "/collection/_apis", r.URL.Path)
+		require.Equal(t, "Basic token", r.Header.Get("Authorization"))
+
+		w.WriteHeader(http.StatusOK)
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"count": len(locations), "value": locations})
+	}))
+}
+
+func TestNegotiator_Version(t *testing.T) {
+	checks := ResourceValues.CheckConfigurations
+	serviceEndpoints := ResourceValues.ServiceEndpoints
+
+	checksLocation := *newLocation("5.0", "5.1", "0.0", 1)
+	checksLocation.Id = &checks.LocationID
+
+	area := "ServiceEndpoint"
+	resourceName := "Endpoints"
+	otherID := uuid.New()
+	serviceEndpointsLocation := *newLocation("4.0", "5.1", "5.0", 2)
+	serviceEndpointsLocation.Id = &otherID
+	serviceEndpointsLocation.Area = &area
+	serviceEndpointsLocation.ResourceName = &resourceName
+
+	calls := 0
+	ts := getTestServer(t, []azuredevops.ApiResourceLocation{checksLocation, serviceEndpointsLocation}, &calls)
+	defer ts.Close()
+
+	n := NewNegotiator(ts.URL+"/collection/", "Basic token", ts.Client(), map[string]string{
+		ResourceValues.HierarchyQuery.Key: "5.0-preview.1",
+	})
+
+	ctx := context.Background()
+	require.Equal(t, "5.1-preview.1", n.Version(ctx, checks))
+	require.Equal(t, "5.1-preview", n.Version(ctx, serviceEndpoints))
+	require.Equal(t, "5.0-preview.1", n.Version(ctx, ResourceValues.HierarchyQuery))
+	require.Equal(t, 1, calls, "the resource locations should only be discovered once")
+
+	require.Equal(t,
+		"application/json;api-version=5.1-preview;excludeUrls=true;enumsAsNumbers=true;msDateFormat=true;noArrayWrap=true",
+		n.AcceptHeader(ctx, serviceEndpoints))
+}
+
+func TestNegotiator_Version_FallsBackToDefault(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer ts.Close()
+
+	n := NewNegotiator(ts.URL, "Basic token", ts.Client(), nil)
+	require.Equal(t, ResourceValues.CheckConfigurations.Default, n.Version(context.Background(), ResourceValues.CheckConfigurations))
+
+	var nilNegotiator *Negotiator
+	require.Equal(t, ResourceValues.ServiceEndpoints.Default, nilNegotiator.Version(context.Background(), ResourceValues.ServiceEndpoints))
+}
+
+func TestValidateAPIVersion(t *testing.T) {
+	for _, version := range []string{"6.0", "6.0-preview", "6.0-preview.4"} {
+		require.NoError(t, ValidateAPIVersion(version))
+	}
+	for _, version := range []string{"", "6", "latest", "6.0-beta", "6.0-preview."} {
+		require.Error(t, ValidateAPIVersion(version))
+	}
+}
+
+// A failed discovery, e.g. with a cancelled context, is retried once the backoff has elapsed
+func TestNegotiator_Version_RetriesDiscovery(t *testing.T) {
+	checks := ResourceValues.CheckConfigurations
+	checksLocation := *newLocation("5.0", "5.1", "0.0", 1)
+	checksLocation.Id = &checks.LocationID
+
+	calls := 0
+	ts := getTestServer(t, []azuredevops.ApiResourceLocation{checksLocation}, &calls)
+	defer ts.Close()
+
+	n := NewNegotiator(ts.URL+"/collection", "Basic token", ts.Client(), nil)
+	now := time.Now()
+	n.now = func() time.Time { return now }
+
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	require.Equal(t, checks.Default, n.Version(cancelled, checks))
+	require.Equal(t, checks.Default, n.Version(context.Background(), checks), "the failure should be kept during the backoff")
+	require.Equal(t, 0, calls)
+
+	now = now.Add(discoveryBackoff)
+	require.Equal(t, "5.1-preview.1", n.Version(context.Background(), checks))
+	require.Equal(t, "5.1-preview.1", n.Version(context.Background(), checks))
+	require.Equal(t, 1, calls, "the resource locations should only be discovered once they are found")
+}
+
+// Concurrent calls share a single discovery
+func TestNegotiator_Version_SingleDiscovery(t *testing.T) {
+	checks := ResourceValues.CheckConfigurations
+	checksLocation := *newLocation("5.0", "5.1", "0.0", 1)
+	checksLocation.Id = &checks.LocationID
+
+	var calls int32
+	release := make(chan struct{})
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		<-release
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"count": 1, "value": []azuredevops.ApiResourceLocation{checksLocation}})
+	}))
+	defer ts.Close()
+
+	n := NewNegotiator(ts.URL, "Basic token", ts.Client(), nil)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			require.Equal(t, "5.1-preview.1", n.Version(context.Background(), checks))
+		}()
+	}
+	// give the calls time to queue up behind the first discovery
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	require.Equal(t, int32(1), atomic.LoadInt32(&calls))
+}
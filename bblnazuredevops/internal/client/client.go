@@ -3,13 +3,22 @@ package client
 import (
 	"context"
 	"fmt"
+	"github.com/babylonhealth/terraform-provider-bblnazuredevops/bblnazuredevops/internal/client/apiversion"
+	"github.com/babylonhealth/terraform-provider-bblnazuredevops/bblnazuredevops/internal/client/logging"
+	"github.com/babylonhealth/terraform-provider-bblnazuredevops/bblnazuredevops/internal/client/ratelimit"
+	"github.com/babylonhealth/terraform-provider-bblnazuredevops/bblnazuredevops/internal/client/vault"
 	"github.com/babylonhealth/terraform-provider-bblnazuredevops/bblnazuredevops/internal/service/checks/common/client"
 	"github.com/babylonhealth/terraform-provider-bblnazuredevops/bblnazuredevops/internal/service/githubapp/githubappclient"
-	"log"
+	"github.com/babylonhealth/terraform-provider-bblnazuredevops/bblnazuredevops/internal/service/pipelineauthorization/pipelinepermissionsclient"
+	"github.com/babylonhealth/terraform-provider-bblnazuredevops/bblnazuredevops/internal/service/securityroles/securityrolesclient"
+	"github.com/babylonhealth/terraform-provider-bblnazuredevops/bblnazuredevops/internal/utils/cache"
+	"net/http"
 	"os"
 	"strings"
+	"time"
 
 	"github.com/babylonhealth/terraform-provider-bblnazuredevops/version"
+	"github.com/hashicorp/terraform-plugin-log/tflog"
 	"github.com/microsoft/azure-devops-go-api/azuredevops/v6"
 	"github.com/microsoft/azure-devops-go-api/azuredevops/v6/build"
 	"github.com/microsoft/azure-devops-go-api/azuredevops/v6/core"
@@ -36,6 +45,7 @@ import (
 // Azure DevOps client.
 type AggregatedClient struct {
 	OrganizationURL               string
+	DefaultProject                string
 	CoreClient                    core.Client
 	BuildClient                   build.Client
 	GitReposClient                git.Client
@@ -54,12 +64,21 @@ type AggregatedClient struct {
 	ManualApprovalCheckClient     client.ManualApprovalClient
 	ExclusiveLockCheckClient      client.ExclusiveLockClient
 	GitAppClient                  githubappclient.GithubAppClient
+	PipelinePermissionsClient     pipelinepermissionsclient.PipelinePermissionsClient
+	SecurityRolesClient           securityrolesclient.SecurityRolesClient
+	APIVersionNegotiator          *apiversion.Negotiator
+	VaultClient                   *vault.Client
+	Cache                         *cache.Cache
 	Ctx                           context.Context
 }
 
-// GetAzdoClient builds and provides a connection to the Azure DevOps API
-func GetAzdoClient(azdoPAT string, organizationURL string, tfVersion string) (*AggregatedClient, error) {
-	ctx := context.Background()
+// GetAzdoClient builds and provides a connection to the Azure DevOps API. The organizationURL can either point
+// to an Azure DevOps Services organization or to an Azure DevOps Server collection. apiVersions overrides the
+// api-version negotiated for the resources that are not covered by the Azure DevOps SDK. Lookups shared by many
+// resources are cached for cacheTTL, a cacheTTL of zero disables the cache. Every request is sent through limiter.
+func GetAzdoClient(ctx context.Context, azdoPAT string, organizationURL string, tfVersion string, apiVersions map[string]string, cacheTTL time.Duration, limiter *ratelimit.Limiter) (*AggregatedClient, error) {
+	// the clients outlive the configure request, so only its values, e.g. the logger, are kept
+	ctx = context.WithoutCancel(ctx)
 
 	if strings.EqualFold(azdoPAT, "") {
 		return nil, fmt.Errorf("the personal access token is required")
@@ -70,99 +89,135 @@ func GetAzdoClient(azdoPAT string, organizationURL string, tfVersion string) (*A
 	}
 
 	connection := azuredevops.NewPatConnection(organizationURL, azdoPAT)
-	setUserAgent(connection, tfVersion)
+	setUserAgent(ctx, connection, tfVersion)
+
+	// all requests, whether sent by the SDK or by the clients below, go through the same limiter so that
+	// concurrent resources do not trip the throttling of Azure DevOps, and are logged to the subsystem of their client
+	transportFunc := func(subsystem string) http.RoundTripper {
+		return limiter.Transport(logging.NewTransport(subsystem, nil))
+	}
+	sdk := newSdkConnection(connection, transportFunc)
 
 	// client for these APIs (includes CRUD for AzDO projects...):
 	//	https://docs.microsoft.com/en-us/rest/api/azure/devops/core/?view=azure-devops-rest-5.1
-	coreClient, err := core.NewClient(ctx, connection)
+	coreSdkClient, err := sdk.GetClientByResourceAreaId(ctx, core.ResourceAreaId, logging.SubsystemSDK)
 	if err != nil {
-		log.Printf("getAzdoClient(): core.NewClient failed.")
+		tflog.Error(ctx, "getAzdoClient(): core.NewClient failed.", map[string]interface{}{"error": err.Error()})
 		return nil, err
 	}
+	coreClient := &core.ClientImpl{Client: coreSdkClient}
 
 	// client for these APIs (includes CRUD for AzDO build pipelines...):
 	//	https://docs.microsoft.com/en-us/rest/api/azure/devops/build/?view=azure-devops-rest-5.1
-	buildClient, err := build.NewClient(ctx, connection)
+	buildSdkClient, err := sdk.GetClientByResourceAreaId(ctx, build.ResourceAreaId, logging.SubsystemSDK)
 	if err != nil {
-		log.Printf("getAzdoClient(): build.NewClient failed.")
+		tflog.Error(ctx, "getAzdoClient(): build.NewClient failed.", map[string]interface{}{"error": err.Error()})
 		return nil, err
 	}
+	buildClient := &build.ClientImpl{Client: buildSdkClient}
 
 	// client for these APIs (monitor async operations...):
 	//	https://docs.microsoft.com/en-us/rest/api/azure/devops/operations/operations?view=azure-devops-rest-5.1
-	operationsClient := operations.NewClient(ctx, connection)
+	operationsClient := &operations.ClientImpl{Client: sdk.GetClientByUrl(connection.BaseUrl, logging.SubsystemSDK)}
 
 	// client for these APIs (includes CRUD for AzDO service endpoints a.k.a. service connections...):
 	//  https://docs.microsoft.com/en-us/rest/api/azure/devops/serviceendpoint/endpoints?view=azure-devops-rest-5.1
-	serviceEndpointClient, err := serviceendpoint.NewClient(ctx, connection)
+	serviceendpointSdkClient, err := sdk.GetClientByResourceAreaId(ctx, serviceendpoint.ResourceAreaId, logging.SubsystemServiceEndpoint)
 	if err != nil {
-		log.Printf("getAzdoClient(): serviceendpoint.NewClient failed.")
+		tflog.Error(ctx, "getAzdoClient(): serviceendpoint.NewClient failed.", map[string]interface{}{"error": err.Error()})
 		return nil, err
 	}
+	serviceEndpointClient := &serviceendpoint.ClientImpl{Client: serviceendpointSdkClient}
 
 	// client for these APIs (includes CRUD for AzDO variable groups):
-	taskagentClient, err := taskagent.NewClient(ctx, connection)
+	taskagentSdkClient, err := sdk.GetClientByResourceAreaId(ctx, taskagent.ResourceAreaId, logging.SubsystemSDK)
 	if err != nil {
-		log.Printf("getAzdoClient(): taskagent.NewClient failed.")
+		tflog.Error(ctx, "getAzdoClient(): taskagent.NewClient failed.", map[string]interface{}{"error": err.Error()})
 		return nil, err
 	}
+	taskagentClient := &taskagent.ClientImpl{Client: taskagentSdkClient}
 
 	// client for these APIs:
 	//	https://docs.microsoft.com/en-us/rest/api/azure/devops/git/?view=azure-devops-rest-5.1
-	gitReposClient, err := git.NewClient(ctx, connection)
+	gitSdkClient, err := sdk.GetClientByResourceAreaId(ctx, git.ResourceAreaId, logging.SubsystemSDK)
 	if err != nil {
-		log.Printf("getAzdoClient(): git.NewClient failed.")
+		tflog.Error(ctx, "getAzdoClient(): git.NewClient failed.", map[string]interface{}{"error": err.Error()})
 		return nil, err
 	}
+	gitReposClient := &git.ClientImpl{Client: gitSdkClient}
 
 	//  https://docs.microsoft.com/en-us/rest/api/azure/devops/graph/?view=azure-devops-rest-5.1
-	graphClient, err := graph.NewClient(ctx, connection)
+	graphSdkClient, err := sdk.GetClientByResourceAreaId(ctx, graph.ResourceAreaId, logging.SubsystemSDK)
 	if err != nil {
-		log.Printf("getAzdoClient(): graph.NewClient failed.")
+		tflog.Error(ctx, "getAzdoClient(): graph.NewClient failed.", map[string]interface{}{"error": err.Error()})
 		return nil, err
 	}
+	graphClient := &graph.ClientImpl{Client: graphSdkClient}
 
-	memberentitlementmanagementClient, err := memberentitlementmanagement.NewClient(ctx, connection)
+	memberentitlementmanagementSdkClient, err := sdk.GetClientByResourceAreaId(ctx, memberentitlementmanagement.ResourceAreaId, logging.SubsystemSDK)
 	if err != nil {
-		log.Printf("getAzdoClient(): memberentitlementmanagement.NewClient failed.")
+		tflog.Error(ctx, "getAzdoClient(): memberentitlementmanagement.NewClient failed.", map[string]interface{}{"error": err.Error()})
 		return nil, err
 	}
+	memberentitlementmanagementClient := &memberentitlementmanagement.ClientImpl{Client: memberentitlementmanagementSdkClient}
 
 	// https://docs.microsoft.com/en-us/rest/api/azure/devops/policy/configurations/create?view=azure-devops-rest-5.1
-	policyClient, err := policy.NewClient(ctx, connection)
+	policySdkClient, err := sdk.GetClientByResourceAreaId(ctx, policy.ResourceAreaId, logging.SubsystemSDK)
 	if err != nil {
-		log.Printf("getAzdoClient(): policy.NewClient failed.")
+		tflog.Error(ctx, "getAzdoClient(): policy.NewClient failed.", map[string]interface{}{"error": err.Error()})
 		return nil, err
 	}
+	policyClient := &policy.ClientImpl{Client: policySdkClient}
 
 	// client for these APIs (includes CRUD for AzDO release pipelines...):
 	//	https://docs.microsoft.com/en-us/rest/api/azure/devops/release/?view=azure-devops-rest-5.1
-	releaseClient, err := release.NewClient(ctx, connection)
+	releaseSdkClient, err := sdk.GetClientByResourceAreaId(ctx, release.ResourceAreaId, logging.SubsystemSDK)
 	if err != nil {
-		log.Printf("getAzdoClient(): release.NewClient failed.")
+		tflog.Error(ctx, "getAzdoClient(): release.NewClient failed.", map[string]interface{}{"error": err.Error()})
 		return nil, err
 	}
+	releaseClient := &release.ClientImpl{Client: releaseSdkClient}
 
-	securityClient := security.NewClient(ctx, connection)
-	identityClient, err := identity.NewClient(ctx, connection)
+	securityClient := &security.ClientImpl{Client: sdk.GetClientByUrl(connection.BaseUrl, logging.SubsystemSecurity)}
+	identitySdkClient, err := sdk.GetClientByResourceAreaId(ctx, identity.ResourceAreaId, logging.SubsystemSDK)
 	if err != nil {
-		log.Printf("getAzdoClient(): identity.NewClient failed.")
+		tflog.Error(ctx, "getAzdoClient(): identity.NewClient failed.", map[string]interface{}{"error": err.Error()})
 		return nil, err
 	}
+	identityClient := &identity.ClientImpl{Client: identitySdkClient}
 
-	featuremanagementClient := featuremanagement.NewClient(ctx, connection)
+	featuremanagementClient := &featuremanagement.ClientImpl{Client: sdk.GetClientByUrl(connection.BaseUrl, logging.SubsystemSDK)}
 
-	workitemtrackingClient, err := workitemtracking.NewClient(ctx, connection)
+	workitemtrackingSdkClient, err := sdk.GetClientByResourceAreaId(ctx, workitemtracking.ResourceAreaId, logging.SubsystemSDK)
 	if err != nil {
-		log.Printf("getAzdoClient(): workitemtracking.NewClient failed.")
+		tflog.Error(ctx, "getAzdoClient(): workitemtracking.NewClient failed.", map[string]interface{}{"error": err.Error()})
 		return nil, err
 	}
+	workitemtrackingClient := &workitemtracking.ClientImpl{Client: workitemtrackingSdkClient}
+
+	// the checks, GitHub App, pipeline permissions and security roles clients are not part of the SDK, so the api-version they use is negotiated with
+	// the organization or collection. This keeps them working against Azure DevOps Server. The resources calling
+	// REST resources the SDK does not cover negotiate their api-version with it too.
+	negotiator := apiversion.NewNegotiator(connection.BaseUrl, connection.AuthorizationString, &http.Client{Transport: transportFunc(logging.SubsystemSDK), Timeout: apiversion.DefaultDiscoveryTimeout}, apiVersions)
+
+	// a single cache is shared by all clients, so that every check of a resource is served by one HierarchyQuery
+	// no matter which kind of check reads it
+	providerCache := cache.New(cacheTTL)
+
+	checksOptions := []client.Option{
+		client.WithVersionNegotiator(negotiator),
+		client.WithCache(providerCache),
+		client.WithTransport(transportFunc(logging.SubsystemChecks)),
+	}
+	invokeChecksClient := client.NewClient(connection.BaseUrl, connection.AuthorizationString, connection.Timeout, checksOptions...)
+	manualApprovalClient := client.NewClient(connection.BaseUrl, connection.AuthorizationString, connection.Timeout, checksOptions...)
+	exclusiveLockClient := client.NewClient(connection.BaseUrl, connection.AuthorizationString, connection.Timeout, checksOptions...)
+
+	githubAppClient := githubappclient.NewGithubApp(connection.BaseUrl, connection.AuthorizationString, connection.Timeout, githubappclient.WithVersionNegotiator(negotiator), githubappclient.WithTransport(transportFunc(logging.SubsystemGithubApp)))
 
-	invokeChecksClient := client.NewClient(connection.BaseUrl, connection.AuthorizationString, connection.Timeout)
-	manualApprovalClient := client.NewClient(connection.BaseUrl, connection.AuthorizationString, connection.Timeout)
-	exclusiveLockClient := client.NewClient(connection.BaseUrl, connection.AuthorizationString, connection.Timeout)
+	pipelinePermissionsClient := pipelinepermissionsclient.NewPipelinePermissions(connection.BaseUrl, connection.AuthorizationString, connection.Timeout, pipelinepermissionsclient.WithVersionNegotiator(negotiator), pipelinepermissionsclient.WithTransport(transportFunc(logging.SubsystemPipelinePermissions)))
 
-	githubAppClient := githubappclient.NewGithubApp(connection.BaseUrl, connection.AuthorizationString, connection.Timeout)
+	securityRolesClient := securityrolesclient.NewSecurityRoles(connection.BaseUrl, connection.AuthorizationString, connection.Timeout, securityrolesclient.WithVersionNegotiator(negotiator), securityrolesclient.WithTransport(transportFunc(logging.SubsystemSecurityRoles)))
 
 	aggregatedClient := &AggregatedClient{
 		OrganizationURL:               organizationURL,
@@ -184,15 +239,19 @@ func GetAzdoClient(azdoPAT string, organizationURL string, tfVersion string) (*A
 		ManualApprovalCheckClient:     manualApprovalClient,
 		ExclusiveLockCheckClient:      exclusiveLockClient,
 		GitAppClient:                  githubAppClient,
+		PipelinePermissionsClient:     pipelinePermissionsClient,
+		SecurityRolesClient:           securityRolesClient,
+		APIVersionNegotiator:          negotiator,
+		Cache:                         providerCache,
 		Ctx:                           ctx,
 	}
 
-	log.Printf("getAzdoClient(): Created core, build, operations, and serviceendpoint clients successfully!")
+	tflog.Debug(ctx, "getAzdoClient(): Created core, build, operations, and serviceendpoint clients successfully!")
 	return aggregatedClient, nil
 }
 
 // setUserAgent set UserAgent for http headers
-func setUserAgent(connection *azuredevops.Connection, tfVersion string) {
+func setUserAgent(ctx context.Context, connection *azuredevops.Connection, tfVersion string) {
 	providerUserAgent := fmt.Sprintf("terraform-provider-azuredevops/%s", version.ProviderVersion)
 	connection.UserAgent = strings.TrimSpace(fmt.Sprintf("%s %s", connection.UserAgent, providerUserAgent))
 
@@ -201,5 +260,5 @@ func setUserAgent(connection *azuredevops.Connection, tfVersion string) {
 		connection.UserAgent = fmt.Sprintf("%s %s", connection.UserAgent, azureAgent)
 	}
 
-	log.Printf("[DEBUG] AzureRM Client User Agent: %s\n", connection.UserAgent)
+	tflog.Debug(ctx, "AzureRM Client User Agent", map[string]interface{}{"user_agent": connection.UserAgent})
 }
@@ -0,0 +1,86 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/babylonhealth/terraform-provider-bblnazuredevops/bblnazuredevops/internal/client/logging"
+	"github.com/google/uuid"
+	"github.com/microsoft/azure-devops-go-api/azuredevops/v6"
+)
+
+// transportFactory returns the http.RoundTripper used by the clients logging to subsystem
+type transportFactory func(subsystem string) http.RoundTripper
+
+// sdkConnection creates Azure DevOps SDK clients which send their requests through the transports of the provider.
+// It resolves resource areas the same way as azuredevops.Connection, which always uses its own http.Client.
+type sdkConnection struct {
+	connection    *azuredevops.Connection
+	transportFunc transportFactory
+
+	once      sync.Once
+	areaURLs  map[uuid.UUID]string
+	areaError error
+}
+
+func newSdkConnection(connection *azuredevops.Connection, transportFunc transportFactory) *sdkConnection {
+	return &sdkConnection{
+		connection:    connection,
+		transportFunc: transportFunc,
+	}
+}
+
+// GetClientByUrl returns a client for the API at baseUrl which logs to subsystem
+func (c *sdkConnection) GetClientByUrl(baseUrl string, subsystem string) azuredevops.Client {
+	httpClient := &http.Client{
+		Transport: c.transportFunc(subsystem),
+	}
+	if c.connection.Timeout != nil {
+		httpClient.Timeout = *c.connection.Timeout
+	}
+
+	normalizedUrl := strings.ToLower(strings.TrimRight(baseUrl, "/"))
+	return *azuredevops.NewClientWithOptions(c.connection, normalizedUrl, azuredevops.WithHTTPClient(httpClient))
+}
+
+// GetClientByResourceAreaId returns a client for the API of the resource area which logs to subsystem
+func (c *sdkConnection) GetClientByResourceAreaId(ctx context.Context, resourceAreaID uuid.UUID, subsystem string) (azuredevops.Client, error) {
+	c.once.Do(func() {
+		c.areaURLs, c.areaError = c.getResourceAreas(ctx)
+	})
+	if c.areaError != nil {
+		return azuredevops.Client{}, c.areaError
+	}
+
+	// on prem servers do not list any resource area, all of them are served by the collection
+	if len(c.areaURLs) == 0 {
+		return c.GetClientByUrl(c.connection.BaseUrl, subsystem), nil
+	}
+
+	areaURL, ok := c.areaURLs[resourceAreaID]
+	if !ok {
+		return azuredevops.Client{}, &azuredevops.ResourceAreaIdNotRegisteredError{
+			ResourceAreaId: resourceAreaID,
+			Url:            c.connection.BaseUrl,
+		}
+	}
+	return c.GetClientByUrl(areaURL, subsystem), nil
+}
+
+func (c *sdkConnection) getResourceAreas(ctx context.Context) (map[uuid.UUID]string, error) {
+	client := c.GetClientByUrl(c.connection.BaseUrl, logging.SubsystemSDK)
+	resourceAreaInfos, err := client.GetResourceAreas(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	areaURLs := map[uuid.UUID]string{}
+	for _, resourceArea := range *resourceAreaInfos {
+		if resourceArea.Id != nil && resourceArea.LocationUrl != nil {
+			areaURLs[*resourceArea.Id] = *resourceArea.LocationUrl
+		}
+	}
+	return areaURLs, nil
+}
@@ -0,0 +1,246 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+)
+
+// Subsystems of the provider logger. The level of each subsystem can be set with
+// TF_LOG_PROVIDER_BBLNAZUREDEVOPS_<SUBSYSTEM>, e.g. TF_LOG_PROVIDER_BBLNAZUREDEVOPS_CHECKS=trace
+const (
+	SubsystemCache               = "cache"
+	SubsystemChecks              = "checks"
+	SubsystemGithubApp           = "githubapp"
+	SubsystemPipelinePermissions = "pipelinepermissions"
+	SubsystemSecurity            = "security"
+	SubsystemSecrets             = "secrets"
+	SubsystemSecurityRoles       = "securityroles"
+	SubsystemServiceEndpoint     = "serviceendpoint"
+	SubsystemSDK                 = "sdk"
+)
+
+const levelEnvVar = "TF_LOG_PROVIDER_BBLNAZUREDEVOPS"
+
+// redacted replaces the values which must never be logged
+const redacted = "***"
+
+// maxLoggedBodyLength bounds the size of request and response bodies written to the trace log
+const maxLoggedBodyLength = 16 * 1024
+
+// sensitiveKeys are matched, case insensitively, against header names and JSON keys. Values of matching
+// keys are redacted before being logged.
+var sensitiveKeys = []string{
+	"authorization",
+	"password",
+	"secret",
+	"token",
+	"apikey",
+	"privatekey",
+	"serviceprincipalkey",
+	"certificate",
+	"cookie",
+}
+
+type subsystemKey string
+
+// providerContext is the context carrying the provider logger, see SetProviderContext
+var providerContext atomic.Value
+
+// SetProviderContext sets the context returned by ProviderContext, it is set when the provider is configured
+func SetProviderContext(ctx context.Context) {
+	providerContext.Store(ctx)
+}
+
+// ProviderContext returns the context carrying the provider logger, for the code which is called without a context,
+// e.g. the DiffSuppressFunc of an attribute. Logs are discarded until the provider is configured.
+func ProviderContext() context.Context {
+	if ctx, ok := providerContext.Load().(context.Context); ok {
+		return ctx
+	}
+	return context.Background()
+}
+
+// WithSubsystem returns a context carrying the logger of the subsystem. The logger is only created once
+// per context, so this can be called on every request.
+func WithSubsystem(ctx context.Context, subsystem string) context.Context {
+	if ctx.Value(subsystemKey(subsystem)) != nil {
+		return ctx
+	}
+	ctx = tflog.NewSubsystem(ctx, subsystem, tflog.WithLevelFromEnv(levelEnvVar, subsystem))
+	return context.WithValue(ctx, subsystemKey(subsystem), true)
+}
+
+// NewTransport returns a http.RoundTripper which logs every request sent through base to the subsystem.
+// The method, url, status code, latency and the ActivityId assigned by Azure DevOps are logged at debug
+// level, redacted headers and bodies at trace level. Headers and bodies are only read when the trace level
+// is enabled for the subsystem, see isTraceEnabled.
+func NewTransport(subsystem string, base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &transport{subsystem: subsystem, base: base, trace: isTraceEnabled(subsystem)}
+}
+
+type transport struct {
+	subsystem string
+	base      http.RoundTripper
+	trace     bool
+}
+
+// isTraceEnabled returns whether the trace logs of the subsystem are kept, as set by the first of
+// TF_LOG_PROVIDER_BBLNAZUREDEVOPS_<SUBSYSTEM>, TF_LOG_PROVIDER_BBLNAZUREDEVOPS, TF_LOG_PROVIDER and TF_LOG
+// which is set. Terraform drops the logs of the provider when none of them is set.
+func isTraceEnabled(subsystem string) bool {
+	for _, envVar := range []string{levelEnvVar + "_" + strings.ToUpper(subsystem), levelEnvVar, "TF_LOG_PROVIDER", "TF_LOG"} {
+		if level := strings.ToUpper(strings.TrimSpace(os.Getenv(envVar))); level != "" {
+			// TF_LOG=JSON is the trace level in JSON format
+			return level == "TRACE" || level == "JSON"
+		}
+	}
+	return false
+}
+
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx := WithSubsystem(req.Context(), t.subsystem)
+	fields := map[string]interface{}{
+		"http_method": req.Method,
+		"http_url":    req.URL.Redacted(),
+	}
+
+	if t.trace {
+		tflog.SubsystemTrace(ctx, t.subsystem, "Sending request to Azure DevOps", fields, map[string]interface{}{
+			"http_request_headers": RedactHeaders(req.Header),
+			"http_request_body":    requestBody(req),
+		})
+	}
+
+	start := time.Now()
+	resp, err := t.base.RoundTrip(req)
+	fields["http_duration_ms"] = time.Since(start).Milliseconds()
+	if err != nil {
+		tflog.SubsystemDebug(ctx, t.subsystem, "Request to Azure DevOps failed", fields, map[string]interface{}{
+			"error": err.Error(),
+		})
+		return resp, err
+	}
+
+	fields["http_status_code"] = resp.StatusCode
+	fields["activity_id"] = resp.Header.Get("ActivityId")
+	tflog.SubsystemDebug(ctx, t.subsystem, "Received response from Azure DevOps", fields)
+	if t.trace {
+		tflog.SubsystemTrace(ctx, t.subsystem, "Response body from Azure DevOps", fields, map[string]interface{}{
+			"http_response_headers": RedactHeaders(resp.Header),
+			"http_response_body":    responseBody(resp),
+		})
+	}
+
+	return resp, nil
+}
+
+// requestBody returns the redacted body of req without consuming it
+func requestBody(req *http.Request) string {
+	if req.Body == nil || req.Body == http.NoBody || req.GetBody == nil {
+		return ""
+	}
+	body, err := req.GetBody()
+	if err != nil {
+		return ""
+	}
+	defer body.Close()
+
+	b, err := ioutil.ReadAll(body)
+	if err != nil {
+		return ""
+	}
+	return RedactBody(b)
+}
+
+// responseBody returns the redacted body of resp and replaces it with an unread copy
+func responseBody(resp *http.Response) string {
+	if resp.Body == nil || resp.Body == http.NoBody {
+		return ""
+	}
+	b, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	resp.Body = ioutil.NopCloser(bytes.NewReader(b))
+	if err != nil {
+		return ""
+	}
+	return RedactBody(b)
+}
+
+func isSensitive(key string) bool {
+	key = strings.ToLower(key)
+	for _, sensitiveKey := range sensitiveKeys {
+		if strings.Contains(key, sensitiveKey) {
+			return true
+		}
+	}
+	return false
+}
+
+// RedactHeaders returns the headers with the values of sensitive headers, like Authorization, redacted
+func RedactHeaders(header http.Header) map[string]string {
+	headers := map[string]string{}
+	for key, values := range header {
+		if isSensitive(key) {
+			headers[key] = redacted
+			continue
+		}
+		headers[key] = strings.Join(values, ", ")
+	}
+	return headers
+}
+
+// RedactBody returns a JSON body with the values of sensitive fields, e.g. the password of a service endpoint,
+// redacted. Bodies which are not JSON are not logged at all, as they can not be redacted.
+func RedactBody(body []byte) string {
+	if len(bytes.TrimSpace(body)) == 0 {
+		return ""
+	}
+
+	var value interface{}
+	if err := json.Unmarshal(body, &value); err != nil {
+		return "<non JSON body omitted>"
+	}
+
+	b, err := json.Marshal(redactValue(value))
+	if err != nil {
+		return "<body omitted>"
+	}
+	if len(b) > maxLoggedBodyLength {
+		return string(b[:maxLoggedBodyLength]) + "...<truncated>"
+	}
+	return string(b)
+}
+
+func redactValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, item := range v {
+			// only leaves are redacted, so that e.g. the scheme of an authorization is still logged
+			if _, isString := item.(string); isString && isSensitive(key) {
+				v[key] = redacted
+				continue
+			}
+			v[key] = redactValue(item)
+		}
+		return v
+	case []interface{}:
+		for i, item := range v {
+			v[i] = redactValue(item)
+		}
+		return v
+	default:
+		return v
+	}
+}
@@ -0,0 +1,171 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-log/tflogtest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRedactBody(t *testing.T) {
+	body := `{
+		"name": "endpoint",
+		"authorization": {
+			"scheme": "UsernamePassword",
+			"parameters": {"username": "user", "password": "p4ssw0rd", "apitoken": "t0ken"}
+		},
+		"data": [{"clientSecret": "s3cret", "url": "https://example.com"}]
+	}`
+
+	var got map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(RedactBody([]byte(body))), &got))
+
+	authorization := got["authorization"].(map[string]interface{})
+	require.Equal(t, "UsernamePassword", authorization["scheme"])
+	parameters := authorization["parameters"].(map[string]interface{})
+	require.Equal(t, "user", parameters["username"])
+	require.Equal(t, redacted, parameters["password"])
+	require.Equal(t, redacted, parameters["apitoken"])
+
+	data := got["data"].([]interface{})[0].(map[string]interface{})
+	require.Equal(t, redacted, data["clientSecret"])
+	require.Equal(t, "https://example.com", data["url"])
+
+	require.Equal(t, "<non JSON body omitted>", RedactBody([]byte("password=p4ssw0rd")))
+	require.Equal(t, "", RedactBody(nil))
+}
+
+func TestRedactHeaders(t *testing.T) {
+	header := http.Header{}
+	header.Set("Authorization", "Basic c2VjcmV0")
+	header.Set("Accept", "application/json")
+
+	got := RedactHeaders(header)
+	require.Equal(t, redacted, got["Authorization"])
+	require.Equal(t, "application/json", got["Accept"])
+}
+
+func TestTransport_LogsRequests(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ActivityId", "3b7d9c4e-1111-2222-3333-444455556666")
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte(`{"id": "1", "password": "response-secret"}`))
+	}))
+	defer ts.Close()
+
+	var output bytes.Buffer
+	ctx := tflogtest.RootLogger(context.Background(), &output)
+	t.Setenv(levelEnvVar+"_CHECKS", "TRACE")
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, ts.URL+"/_apis/test", strings.NewReader(`{"password": "request-secret"}`))
+	require.NoError(t, err)
+	req.Header.Set("Authorization", "Basic c2VjcmV0")
+
+	client := &http.Client{Transport: NewTransport(SubsystemChecks, nil)}
+	resp, err := client.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	// the body is still readable by the caller
+	var body map[string]interface{}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+	require.Equal(t, "response-secret", body["password"])
+
+	logs := output.String()
+	require.Contains(t, logs, `"http_request_body":"{\"password\":\"***\"}"`)
+	require.Contains(t, logs, `"http_response_body":"{\"id\":\"1\",\"password\":\"***\"}"`)
+	require.NotContains(t, logs, "request-secret")
+	require.NotContains(t, logs, "response-secret")
+	require.NotContains(t, logs, "c2VjcmV0")
+
+	entries, err := tflogtest.MultilineJSONDecode(&output)
+	require.NoError(t, err)
+
+	var found bool
+	for _, entry := range entries {
+		if entry["@message"] != "Received response from Azure DevOps" {
+			continue
+		}
+		found = true
+		require.Equal(t, SubsystemChecks, entry["@module"].(string)[strings.LastIndex(entry["@module"].(string), ".")+1:])
+		require.Equal(t, http.MethodPost, entry["http_method"])
+		require.Equal(t, ts.URL+"/_apis/test", entry["http_url"])
+		require.Equal(t, float64(http.StatusOK), entry["http_status_code"])
+		require.Equal(t, "3b7d9c4e-1111-2222-3333-444455556666", entry["activity_id"])
+		require.Contains(t, entry, "http_duration_ms")
+	}
+	require.True(t, found, "the response was not logged: %s", logs)
+}
+
+func TestIsTraceEnabled(t *testing.T) {
+	tests := []struct {
+		name string
+		env  map[string]string
+		want bool
+	}{
+		{name: "unset", env: map[string]string{}, want: false},
+		{name: "subsystem", env: map[string]string{levelEnvVar + "_CHECKS": "trace"}, want: true},
+		{name: "subsystem overrides provider", env: map[string]string{levelEnvVar + "_CHECKS": "DEBUG", levelEnvVar: "TRACE"}, want: false},
+		{name: "provider", env: map[string]string{levelEnvVar: "TRACE"}, want: true},
+		{name: "provider overrides terraform", env: map[string]string{levelEnvVar: "INFO", "TF_LOG": "TRACE"}, want: false},
+		{name: "all providers", env: map[string]string{"TF_LOG_PROVIDER": "TRACE"}, want: true},
+		{name: "terraform", env: map[string]string{"TF_LOG": "DEBUG"}, want: false},
+		{name: "terraform json", env: map[string]string{"TF_LOG": "JSON"}, want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, envVar := range []string{levelEnvVar + "_CHECKS", levelEnvVar, "TF_LOG_PROVIDER", "TF_LOG"} {
+				t.Setenv(envVar, tt.env[envVar])
+			}
+			require.Equal(t, tt.want, isTraceEnabled(SubsystemChecks))
+		})
+	}
+}
+
+// The bodies are neither read nor logged when the trace level is not enabled
+func TestTransport_SkipsBodiesWithoutTrace(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"id": "1"}`))
+	}))
+	defer ts.Close()
+
+	var output bytes.Buffer
+	ctx := tflogtest.RootLogger(context.Background(), &output)
+	for _, envVar := range []string{levelEnvVar + "_CHECKS", levelEnvVar, "TF_LOG_PROVIDER", "TF_LOG"} {
+		t.Setenv(envVar, "")
+	}
+	t.Setenv(levelEnvVar+"_CHECKS", "DEBUG")
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, ts.URL+"/_apis/test", nil)
+	require.NoError(t, err)
+
+	var body io.ReadCloser
+	client := &http.Client{Transport: NewTransport(SubsystemChecks, roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		resp, err := http.DefaultTransport.RoundTrip(req)
+		if resp != nil {
+			body = resp.Body
+		}
+		return resp, err
+	}))}
+	resp, err := client.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	// the body of the response is the one of the base transport, not a copy
+	require.Same(t, body, resp.Body)
+	require.Contains(t, output.String(), "Received response from Azure DevOps")
+	require.NotContains(t, output.String(), "http_response_body")
+}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
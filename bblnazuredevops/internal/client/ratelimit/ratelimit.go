@@ -0,0 +1,269 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+)
+
+const (
+	// minRateFactor is the lowest fraction of the configured rate the limiter slows down to when throttled
+	minRateFactor = 0.1
+	// recoverRateFactor is the fraction of the configured rate regained after each response which is not throttled
+	recoverRateFactor = 0.05
+	// lowRemainingFactor is the fraction of X-RateLimit-Limit below which X-RateLimit-Remaining slows the limiter down
+	lowRemainingFactor = 0.1
+	// defaultRetryAfter is the pause applied when the server throttles a request without sending Retry-After
+	defaultRetryAfter = 5 * time.Second
+	// maxRetries is the number of times a throttled request is sent again
+	maxRetries = 3
+)
+
+// Limiter coordinates all requests sent to Azure DevOps by a provider instance. It bounds the number of
+// requests in flight, spreads requests with a token bucket and slows down when Azure DevOps reports that
+// the caller is being throttled, see
+// https://docs.microsoft.com/en-us/azure/devops/integrate/concepts/rate-limits
+//
+// A nil *Limiter is valid and does not limit anything.
+type Limiter struct {
+	inFlight chan struct{}
+
+	mu          sync.Mutex
+	rate        float64
+	currentRate float64
+	burst       float64
+	tokens      float64
+	last        time.Time
+	pausedUntil time.Time
+
+	now func() time.Time
+}
+
+// New creates a Limiter which allows maxInFlight concurrent requests and requestsPerSecond requests per second.
+// A value of zero disables the respective limit.
+func New(maxInFlight int, requestsPerSecond float64) *Limiter {
+	l := &Limiter{
+		rate:        requestsPerSecond,
+		currentRate: requestsPerSecond,
+		burst:       math.Max(1, math.Ceil(requestsPerSecond)),
+		now:         time.Now,
+	}
+	l.tokens = l.burst
+	l.last = l.now()
+	if maxInFlight > 0 {
+		l.inFlight = make(chan struct{}, maxInFlight)
+	}
+	return l
+}
+
+// Wait blocks until the request may be sent. The returned func must be called once the response has been received.
+func (l *Limiter) Wait(ctx context.Context) (func(), error) {
+	if l == nil {
+		return func() {}, nil
+	}
+
+	if l.inFlight != nil {
+		select {
+		case l.inFlight <- struct{}{}:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	release := func() {
+		if l.inFlight != nil {
+			<-l.inFlight
+		}
+	}
+
+	if delay := l.reserve(); delay > 0 {
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			release()
+			return nil, ctx.Err()
+		}
+	}
+	return release, nil
+}
+
+// reserve takes a token from the bucket and returns how long to wait until the token is available
+func (l *Limiter) reserve() time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	var delay time.Duration
+	if l.pausedUntil.After(now) {
+		delay = l.pausedUntil.Sub(now)
+	}
+	if l.rate <= 0 {
+		return delay
+	}
+
+	l.tokens = math.Min(l.burst, l.tokens+now.Sub(l.last).Seconds()*l.currentRate)
+	l.last = now
+	l.tokens--
+	if l.tokens < 0 {
+		// tokens go negative so that concurrent callers queue up one after the other
+		tokenDelay := time.Duration(-l.tokens / l.currentRate * float64(time.Second))
+		if tokenDelay > delay {
+			delay = tokenDelay
+		}
+	}
+	return delay
+}
+
+// Observe adapts the limiter to the throttling headers of a response and returns how long to wait before
+// retrying the request when it has been rejected by throttling.
+func (l *Limiter) Observe(resp *http.Response) time.Duration {
+	if l == nil || resp == nil {
+		return 0
+	}
+
+	ctx := context.Background()
+	if resp.Request != nil {
+		ctx = resp.Request.Context()
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	retryAfter, hasRetryAfter := parseRetryAfter(resp.Header.Get("Retry-After"), now)
+	if resp.StatusCode == http.StatusTooManyRequests && !hasRetryAfter {
+		retryAfter, hasRetryAfter = defaultRetryAfter, true
+	}
+
+	throttled := hasRetryAfter || resp.Header.Get("X-RateLimit-Delay") != ""
+	if remaining, limit := headerFloat(resp.Header, "X-RateLimit-Remaining"), headerFloat(resp.Header, "X-RateLimit-Limit"); limit > 0 && remaining >= 0 {
+		throttled = throttled || remaining < limit*lowRemainingFactor
+	}
+
+	if !throttled {
+		if l.currentRate < l.rate {
+			l.currentRate = math.Min(l.rate, l.currentRate+l.rate*recoverRateFactor)
+		}
+		return 0
+	}
+
+	if l.rate > 0 {
+		l.currentRate = math.Max(l.rate*minRateFactor, l.currentRate/2)
+	}
+	if hasRetryAfter && now.Add(retryAfter).After(l.pausedUntil) {
+		l.pausedUntil = now.Add(retryAfter)
+	}
+	tflog.Debug(ctx, "Azure DevOps is throttling requests, slowing down", map[string]interface{}{
+		"http_status_code":    resp.StatusCode,
+		"retry_after":         resp.Header.Get("Retry-After"),
+		"ratelimit_delay":     resp.Header.Get("X-RateLimit-Delay"),
+		"ratelimit_remaining": resp.Header.Get("X-RateLimit-Remaining"),
+		"requests_per_second": l.currentRate,
+	})
+
+	if resp.StatusCode == http.StatusTooManyRequests {
+		return retryAfter
+	}
+	return 0
+}
+
+func parseRetryAfter(value string, now time.Time) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.ParseFloat(value, 64); err == nil {
+		return time.Duration(seconds * float64(time.Second)), true
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		return date.Sub(now), true
+	}
+	return 0, false
+}
+
+func headerFloat(header http.Header, key string) float64 {
+	value, err := strconv.ParseFloat(header.Get(key), 64)
+	if err != nil {
+		return -1
+	}
+	return value
+}
+
+// Transport returns a http.RoundTripper which sends every request through the limiter. Requests rejected
+// with 429 Too Many Requests are sent again once the server allows it.
+func (l *Limiter) Transport(base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	if l == nil {
+		return base
+	}
+	return &transport{limiter: l, base: base}
+}
+
+type transport struct {
+	limiter *Limiter
+	base    http.RoundTripper
+}
+
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	for attempt := 0; ; attempt++ {
+		start := t.limiter.now()
+		release, err := t.limiter.Wait(req.Context())
+		if err != nil {
+			return nil, err
+		}
+		if waited := t.limiter.now().Sub(start); waited > time.Millisecond {
+			tflog.Debug(req.Context(), "Rate limiter delayed request", map[string]interface{}{
+				"http_method": req.Method,
+				"http_url":    req.URL.Redacted(),
+				"wait_ms":     waited.Milliseconds(),
+			})
+		}
+
+		resp, err := t.base.RoundTrip(req)
+		release()
+		if err != nil {
+			return resp, err
+		}
+
+		retryAfter := t.limiter.Observe(resp)
+		if retryAfter <= 0 || attempt >= maxRetries {
+			return resp, nil
+		}
+		retry, ok := rewind(req)
+		if !ok {
+			return resp, nil
+		}
+		tflog.Debug(req.Context(), "Request was throttled, retrying", map[string]interface{}{
+			"http_method": req.Method,
+			"http_url":    req.URL.Redacted(),
+			"attempt":     attempt + 1,
+			"max_retries": maxRetries,
+		})
+		resp.Body.Close()
+		req = retry
+	}
+}
+
+// rewind returns a copy of req which can be sent again. Returns false when the body can not be read again.
+func rewind(req *http.Request) (*http.Request, bool) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return req, true
+	}
+	if req.GetBody == nil {
+		return nil, false
+	}
+	body, err := req.GetBody()
+	if err != nil {
+		return nil, false
+	}
+	retry := req.Clone(req.Context())
+	retry.Body = body
+	return retry, true
+}
@@ -0,0 +1,137 @@
+package ratelimit
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLimiter_Wait_BoundsRequestsInFlight(t *testing.T) {
+	l := New(2, 0)
+
+	var inFlight, maxInFlight int32
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			release, err := l.Wait(context.Background())
+			require.NoError(t, err)
+			defer release()
+
+			current := atomic.AddInt32(&inFlight, 1)
+			for {
+				max := atomic.LoadInt32(&maxInFlight)
+				if current <= max || atomic.CompareAndSwapInt32(&maxInFlight, max, current) {
+					break
+				}
+			}
+			time.Sleep(10 * time.Millisecond)
+			atomic.AddInt32(&inFlight, -1)
+		}()
+	}
+	wg.Wait()
+
+	require.Equal(t, int32(2), maxInFlight)
+}
+
+func TestLimiter_Wait_CancelledContext(t *testing.T) {
+	l := New(1, 0)
+	release, err := l.Wait(context.Background())
+	require.NoError(t, err)
+	defer release()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err = l.Wait(ctx)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestLimiter_Reserve_TokenBucket(t *testing.T) {
+	now := time.Now()
+	l := New(0, 2)
+	l.now = func() time.Time { return now }
+	l.last = now
+
+	// the burst is available immediately, then requests are spread at the configured rate
+	require.Equal(t, time.Duration(0), l.reserve())
+	require.Equal(t, time.Duration(0), l.reserve())
+	require.Equal(t, 500*time.Millisecond, l.reserve())
+	require.Equal(t, time.Second, l.reserve())
+
+	now = now.Add(10 * time.Second)
+	require.Equal(t, time.Duration(0), l.reserve())
+}
+
+func TestLimiter_Observe(t *testing.T) {
+	now := time.Now()
+	l := New(0, 10)
+	l.now = func() time.Time { return now }
+
+	response := func(status int, headers map[string]string) *http.Response {
+		resp := &http.Response{StatusCode: status, Header: http.Header{}}
+		for k, v := range headers {
+			resp.Header.Set(k, v)
+		}
+		return resp
+	}
+
+	require.Equal(t, time.Duration(0), l.Observe(response(http.StatusOK, nil)))
+	require.Equal(t, 10.0, l.currentRate)
+
+	require.Equal(t, time.Duration(0), l.Observe(response(http.StatusOK, map[string]string{"X-RateLimit-Delay": "0.5"})))
+	require.Equal(t, 5.0, l.currentRate)
+
+	require.Equal(t, time.Duration(0), l.Observe(response(http.StatusOK, map[string]string{"X-RateLimit-Limit": "200", "X-RateLimit-Remaining": "10"})))
+	require.Equal(t, 2.5, l.currentRate)
+
+	require.Equal(t, 3*time.Second, l.Observe(response(http.StatusTooManyRequests, map[string]string{"Retry-After": "3"})))
+	require.Equal(t, 1.25, l.currentRate)
+	require.Equal(t, now.Add(3*time.Second), l.pausedUntil)
+	require.Equal(t, 3*time.Second, l.reserve())
+
+	require.Equal(t, defaultRetryAfter, l.Observe(response(http.StatusTooManyRequests, nil)))
+	require.Equal(t, 1.0, l.currentRate, "the rate does not drop below the minimum")
+
+	l.Observe(response(http.StatusOK, nil))
+	require.Equal(t, 1.5, l.currentRate, "the rate recovers once throttling stops")
+}
+
+func TestTransport_RetriesThrottledRequests(t *testing.T) {
+	var calls int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		require.Equal(t, "payload", string(body))
+		if atomic.AddInt32(&calls, 1) == 1 {
+			w.Header().Set("Retry-After", "0.01")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer ts.Close()
+
+	client := &http.Client{Transport: New(1, 0).Transport(nil)}
+	req, err := http.NewRequest(http.MethodPost, ts.URL, bytes.NewBufferString("payload"))
+	require.NoError(t, err)
+
+	resp, err := client.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, int32(2), calls)
+}
+
+func TestTransport_NilLimiter(t *testing.T) {
+	var l *Limiter
+	require.Equal(t, http.DefaultTransport, l.Transport(nil))
+}
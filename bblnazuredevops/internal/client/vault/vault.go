@@ -0,0 +1,246 @@
+package vault
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+)
+
+// Auth methods supported by the client
+const (
+	AuthMethodToken   = "token"
+	AuthMethodAppRole = "approle"
+)
+
+// defaultAppRoleMount is the path the AppRole auth method is mounted at by default
+const defaultAppRoleMount = "approle"
+
+// defaultTimeout bounds the requests of the default HTTP client, so that a hung Vault server fails the plan or the
+// apply instead of blocking it
+const defaultTimeout = 30 * time.Second
+
+// refSeparator separates the path of a secret from the key of the value in a reference, e.g. secret/data/azdo#password
+const refSeparator = "#"
+
+// Config is the configuration of the Vault client
+type Config struct {
+	// Address of the Vault server, e.g. https://vault.example.com:8200
+	Address string
+	// Namespace sent in X-Vault-Namespace, only used by Vault Enterprise
+	Namespace string
+	// AuthMethod is either AuthMethodToken or AuthMethodAppRole, it defaults to AuthMethodToken
+	AuthMethod string
+	// Token used by AuthMethodToken
+	Token string
+	// RoleID and SecretID used by AuthMethodAppRole
+	RoleID   string
+	SecretID string
+	// AuthMount is the path the AppRole auth method is mounted at, it defaults to approle
+	AuthMount string
+}
+
+// Client reads secrets from the KV secrets engine of Vault. Secrets are read when a resource is applied, they
+// are never cached by the client.
+type Client struct {
+	config     Config
+	httpClient *http.Client
+
+	mu    sync.Mutex
+	token string
+}
+
+type response struct {
+	Errors []string `json:"errors"`
+	Auth   *struct {
+		ClientToken string `json:"client_token"`
+	} `json:"auth"`
+	Data map[string]interface{} `json:"data"`
+}
+
+// statusError is returned by do when Vault answers with a status other than 2xx
+type statusError struct {
+	statusCode int
+	errors     []string
+}
+
+func (e *statusError) Error() string {
+	if len(e.errors) > 0 {
+		return fmt.Sprintf("status %d: %s", e.statusCode, strings.Join(e.errors, ", "))
+	}
+	return fmt.Sprintf("status %d", e.statusCode)
+}
+
+// isPermissionDenied returns whether err is the answer of Vault to a request with an expired or revoked token
+func isPermissionDenied(err error) bool {
+	var statusErr *statusError
+	return errors.As(err, &statusErr) && statusErr.statusCode == http.StatusForbidden
+}
+
+// New returns a client for the Vault server of config. A nil httpClient uses an HTTP client whose requests time out
+// after defaultTimeout.
+func New(config Config, httpClient *http.Client) (*Client, error) {
+	if config.Address == "" {
+		return nil, fmt.Errorf("the address of Vault is required")
+	}
+	if config.AuthMethod == "" {
+		config.AuthMethod = AuthMethodToken
+	}
+	if config.AuthMount == "" {
+		config.AuthMount = defaultAppRoleMount
+	}
+
+	switch config.AuthMethod {
+	case AuthMethodToken:
+		if config.Token == "" {
+			return nil, fmt.Errorf("a token is required by the %s auth method of Vault", AuthMethodToken)
+		}
+	case AuthMethodAppRole:
+		if config.RoleID == "" || config.SecretID == "" {
+			return nil, fmt.Errorf("a role_id and a secret_id are required by the %s auth method of Vault", AuthMethodAppRole)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported auth method of Vault %q, expected %s or %s", config.AuthMethod, AuthMethodToken, AuthMethodAppRole)
+	}
+
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: defaultTimeout}
+	}
+	config.Address = strings.TrimSuffix(config.Address, "/")
+	return &Client{config: config, httpClient: httpClient, token: config.Token}, nil
+}
+
+// ParseRef parses a reference to a value of a KV secret, <path>#<key>. The path is the API path of the secret,
+// e.g. secret/data/azdo for the KV version 2 secret azdo of the secret engine mounted at secret.
+func ParseRef(ref string) (string, string, error) {
+	parts := strings.Split(ref, refSeparator)
+	if len(parts) != 2 || strings.Trim(parts[0], "/") == "" || parts[1] == "" {
+		return "", "", fmt.Errorf("unexpected format of the Vault reference (%s), expected <path>%s<key>", ref, refSeparator)
+	}
+	return strings.Trim(parts[0], "/"), parts[1], nil
+}
+
+// ReadSecret returns the value referenced by ref, see ParseRef. Both versions of the KV secrets engine are supported.
+func (c *Client) ReadSecret(ctx context.Context, ref string) (string, error) {
+	path, key, err := ParseRef(ref)
+	if err != nil {
+		return "", err
+	}
+
+	token, err := c.getToken(ctx, "")
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := c.do(ctx, http.MethodGet, path, token, nil)
+	if isPermissionDenied(err) && c.config.AuthMethod == AuthMethodAppRole {
+		// the token of the AppRole login expires with its lease, the client logs in again once
+		if token, err = c.getToken(ctx, token); err != nil {
+			return "", err
+		}
+		resp, err = c.do(ctx, http.MethodGet, path, token, nil)
+	}
+	if err != nil {
+		return "", fmt.Errorf("reading the secret %s from Vault: %w", path, err)
+	}
+
+	data := resp.Data
+	// KV version 2 nests the values of the secret with its metadata
+	if nested, ok := data["data"].(map[string]interface{}); ok {
+		if _, ok := data["metadata"]; ok {
+			data = nested
+		}
+	}
+
+	value, ok := data[key]
+	if !ok {
+		return "", fmt.Errorf("the secret %s in Vault has no key %s", path, key)
+	}
+	secret, ok := value.(string)
+	if !ok {
+		return "", fmt.Errorf("the key %s of the secret %s in Vault is not a string", key, path)
+	}
+	return secret, nil
+}
+
+// getToken returns the token of the client, logging in with AppRole the first time it is called and when the token
+// is the expired one, which is then replaced unless another request already did
+func (c *Client) getToken(ctx context.Context, expired string) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.token != "" && c.token != expired {
+		return c.token, nil
+	}
+
+	body, err := json.Marshal(map[string]string{
+		"role_id":   c.config.RoleID,
+		"secret_id": c.config.SecretID,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := c.do(ctx, http.MethodPost, "auth/"+strings.Trim(c.config.AuthMount, "/")+"/login", "", body)
+	if err != nil {
+		return "", fmt.Errorf("logging in to Vault with %s: %w", AuthMethodAppRole, err)
+	}
+	if resp.Auth == nil || resp.Auth.ClientToken == "" {
+		return "", fmt.Errorf("logging in to Vault with %s: no token in the response", AuthMethodAppRole)
+	}
+
+	c.token = resp.Auth.ClientToken
+	return c.token, nil
+}
+
+// do sends a request to the API of Vault. Bodies are never logged, as they hold secrets.
+func (c *Client) do(ctx context.Context, method string, path string, token string, body []byte) (*response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, c.config.Address+"/v1/"+path, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("X-Vault-Token", token)
+	}
+	if c.config.Namespace != "" {
+		req.Header.Set("X-Vault-Namespace", c.config.Namespace)
+	}
+
+	httpResp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer httpResp.Body.Close()
+
+	tflog.Debug(ctx, "Vault request", map[string]interface{}{
+		"method":      method,
+		"path":        path,
+		"status_code": httpResp.StatusCode,
+	})
+
+	respBody, err := ioutil.ReadAll(httpResp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &response{}
+	if len(respBody) > 0 {
+		if err := json.Unmarshal(respBody, resp); err != nil {
+			return nil, fmt.Errorf("decoding the response of Vault (status %d): %w", httpResp.StatusCode, err)
+		}
+	}
+
+	if httpResp.StatusCode < 200 || httpResp.StatusCode > 299 {
+		return nil, &statusError{statusCode: httpResp.StatusCode, errors: resp.Errors}
+	}
+	return resp, nil
+}
@@ -0,0 +1,177 @@
+package vault
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// newTestServer returns a stand-in for Vault with a KV version 1 engine mounted at kv, a KV version 2 engine
+// mounted at secret and the AppRole auth method mounted at approle
+func newTestServer(t *testing.T, logins *int32) *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/auth/approle/login", func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(logins, 1)
+		var body map[string]string
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		if body["role_id"] != "role" || body["secret_id"] != "secret" {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, `{"errors":["invalid role or secret ID"]}`)
+			return
+		}
+		fmt.Fprint(w, `{"auth":{"client_token":"approle-token"}}`)
+	})
+	mux.HandleFunc("/v1/", func(w http.ResponseWriter, r *http.Request) {
+		if token := r.Header.Get("X-Vault-Token"); token != "root" && token != "approle-token" {
+			w.WriteHeader(http.StatusForbidden)
+			fmt.Fprint(w, `{"errors":["permission denied"]}`)
+			return
+		}
+		if r.Header.Get("X-Vault-Namespace") != "team" {
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"errors":[]}`)
+			return
+		}
+		switch r.URL.Path {
+		case "/v1/kv/azdo":
+			fmt.Fprint(w, `{"data":{"password":"kv1-password"}}`)
+		case "/v1/secret/data/azdo":
+			fmt.Fprint(w, `{"data":{"data":{"password":"kv2-password","count":1},"metadata":{"version":3}}}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"errors":[]}`)
+		}
+	})
+
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestClient_ReadSecret(t *testing.T) {
+	var logins int32
+	server := newTestServer(t, &logins)
+
+	c, err := New(Config{Address: server.URL + "/", Namespace: "team", Token: "root"}, server.Client())
+	require.NoError(t, err)
+
+	secret, err := c.ReadSecret(context.Background(), "kv/azdo#password")
+	require.NoError(t, err)
+	require.Equal(t, "kv1-password", secret)
+
+	secret, err = c.ReadSecret(context.Background(), "/secret/data/azdo/#password")
+	require.NoError(t, err)
+	require.Equal(t, "kv2-password", secret)
+
+	_, err = c.ReadSecret(context.Background(), "secret/data/azdo#missing")
+	require.EqualError(t, err, "the secret secret/data/azdo in Vault has no key missing")
+
+	_, err = c.ReadSecret(context.Background(), "secret/data/azdo#count")
+	require.EqualError(t, err, "the key count of the secret secret/data/azdo in Vault is not a string")
+
+	_, err = c.ReadSecret(context.Background(), "secret/data/missing#password")
+	require.EqualError(t, err, "reading the secret secret/data/missing from Vault: status 404")
+
+	require.Equal(t, int32(0), logins)
+}
+
+func TestClient_ReadSecret_AppRole(t *testing.T) {
+	var logins int32
+	server := newTestServer(t, &logins)
+
+	c, err := New(Config{Address: server.URL, Namespace: "team", AuthMethod: AuthMethodAppRole, RoleID: "role", SecretID: "secret"}, server.Client())
+	require.NoError(t, err)
+
+	for i := 0; i < 2; i++ {
+		secret, err := c.ReadSecret(context.Background(), "secret/data/azdo#password")
+		require.NoError(t, err)
+		require.Equal(t, "kv2-password", secret)
+	}
+	require.Equal(t, int32(1), logins)
+
+	c, err = New(Config{Address: server.URL, AuthMethod: AuthMethodAppRole, RoleID: "role", SecretID: "wrong"}, server.Client())
+	require.NoError(t, err)
+	_, err = c.ReadSecret(context.Background(), "secret/data/azdo#password")
+	require.EqualError(t, err, "logging in to Vault with approle: status 400: invalid role or secret ID")
+}
+
+func TestClient_ReadSecret_AppRoleTokenExpired(t *testing.T) {
+	var logins int32
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/auth/approle/login", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"auth":{"client_token":"token-%d"}}`, atomic.AddInt32(&logins, 1))
+	})
+	mux.HandleFunc("/v1/kv/azdo", func(w http.ResponseWriter, r *http.Request) {
+		// only the token of the last login is valid, the previous ones expired
+		if r.Header.Get("X-Vault-Token") != fmt.Sprintf("token-%d", atomic.LoadInt32(&logins)) {
+			w.WriteHeader(http.StatusForbidden)
+			fmt.Fprint(w, `{"errors":["permission denied"]}`)
+			return
+		}
+		fmt.Fprint(w, `{"data":{"password":"kv1-password"}}`)
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	c, err := New(Config{Address: server.URL, AuthMethod: AuthMethodAppRole, RoleID: "role", SecretID: "secret"}, server.Client())
+	require.NoError(t, err)
+
+	secret, err := c.ReadSecret(context.Background(), "kv/azdo#password")
+	require.NoError(t, err)
+	require.Equal(t, "kv1-password", secret)
+	require.Equal(t, int32(1), logins)
+
+	// another login expires the token of the client
+	atomic.AddInt32(&logins, 1)
+	secret, err = c.ReadSecret(context.Background(), "kv/azdo#password")
+	require.NoError(t, err)
+	require.Equal(t, "kv1-password", secret)
+	require.Equal(t, int32(3), logins)
+}
+
+func TestClient_ReadSecret_PermissionDenied(t *testing.T) {
+	var logins int32
+	server := newTestServer(t, &logins)
+
+	c, err := New(Config{Address: server.URL, Namespace: "team", Token: "other"}, server.Client())
+	require.NoError(t, err)
+
+	_, err = c.ReadSecret(context.Background(), "secret/data/azdo#password")
+	require.EqualError(t, err, "reading the secret secret/data/azdo from Vault: status 403: permission denied")
+}
+
+func TestNew_ValidatesConfig(t *testing.T) {
+	for _, config := range []Config{
+		{Token: "root"},
+		{Address: "http://vault"},
+		{Address: "http://vault", AuthMethod: AuthMethodAppRole, RoleID: "role"},
+		{Address: "http://vault", AuthMethod: "userpass", Token: "root"},
+	} {
+		_, err := New(config, nil)
+		require.Error(t, err, "config %+v should be invalid", config)
+	}
+}
+
+func TestNew_DefaultClientTimesOut(t *testing.T) {
+	c, err := New(Config{Address: "http://vault", Token: "root"}, nil)
+	require.NoError(t, err)
+	require.Equal(t, defaultTimeout, c.httpClient.Timeout)
+}
+
+func TestParseRef(t *testing.T) {
+	path, key, err := ParseRef("secret/data/azdo#password")
+	require.NoError(t, err)
+	require.Equal(t, "secret/data/azdo", path)
+	require.Equal(t, "password", key)
+
+	for _, ref := range []string{"", "secret/data/azdo", "#password", "secret/data/azdo#", "a#b#c"} {
+		_, _, err := ParseRef(ref)
+		require.Error(t, err, "reference %q should be invalid", ref)
+	}
+}
@@ -5,10 +5,12 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"github.com/babylonhealth/terraform-provider-bblnazuredevops/bblnazuredevops/internal/client/apiversion"
+	checkmodel "github.com/babylonhealth/terraform-provider-bblnazuredevops/bblnazuredevops/internal/service/checks/common/model"
 	exclusivelockmodel "github.com/babylonhealth/terraform-provider-bblnazuredevops/bblnazuredevops/internal/service/checks/exclusivelock/model"
 	invokerestapimodel "github.com/babylonhealth/terraform-provider-bblnazuredevops/bblnazuredevops/internal/service/checks/invokerestapi/model"
 	manualapprovalmodel "github.com/babylonhealth/terraform-provider-bblnazuredevops/bblnazuredevops/internal/service/checks/manualapproval/model"
-	"github.com/sirupsen/logrus"
+	"github.com/babylonhealth/terraform-provider-bblnazuredevops/bblnazuredevops/internal/utils/cache"
 	"io/ioutil"
 	"net/http"
 	"strconv"
@@ -19,6 +21,33 @@ type Client struct {
 	baseUrl       string
 	client        *http.Client
 	authorization string
+	negotiator    *apiversion.Negotiator
+	cache         *cache.Cache
+}
+
+// Option configures optional behaviour of a Client
+type Option func(*Client)
+
+// WithVersionNegotiator makes the client negotiate the api-version of every request with the server
+func WithVersionNegotiator(negotiator *apiversion.Negotiator) Option {
+	return func(c *Client) {
+		c.negotiator = negotiator
+	}
+}
+
+// WithTransport sends the requests of the client through transport
+func WithTransport(transport http.RoundTripper) Option {
+	return func(c *Client) {
+		c.client.Transport = transport
+	}
+}
+
+// WithCache makes the client share the checks of a resource between all its checks. Checks are cached per
+// project and resource, and invalidated whenever a check is written.
+func WithCache(c *cache.Cache) Option {
+	return func(client *Client) {
+		client.cache = c
+	}
 }
 
 type GetChecksPayload struct {
@@ -91,7 +120,22 @@ func (c *Client) GetExclusiveLockCheckByID(ctx context.Context, projectID string
 		checkID, resourceID, projectID)
 }
 
+func checksCacheKey(projectID string, resourceID string) string {
+	return cache.Key("checks", projectID, resourceID)
+}
+
 func (c *Client) getAllChecks(ctx context.Context, projectID string, resourceID string) ([]byte, error) {
+	respBytes, err := c.cache.GetOrLoad(ctx, checksCacheKey(projectID, resourceID), func() (interface{}, error) {
+		return c.queryAllChecks(ctx, projectID, resourceID)
+	})
+	if err != nil {
+		return []byte{}, err
+	}
+
+	return respBytes.([]byte), nil
+}
+
+func (c *Client) queryAllChecks(ctx context.Context, projectID string, resourceID string) ([]byte, error) {
 	payload := GetChecksPayload{}
 	payload.ContributionIds = []string{"ms.vss-pipelinechecks.checks-data-provider"}
 	payload.DataProviderContext.Properties.ResourceID = resourceID
@@ -103,7 +147,8 @@ func (c *Client) getAllChecks(ctx context.Context, projectID string, resourceID
 	}
 
 	url := "/_apis/Contribution/HierarchyQuery"
-	respBytes, err := c.SendRequest(ctx, "POST", url, string(jsonPayload))
+	acceptHeaders := c.negotiator.AcceptHeader(ctx, apiversion.ResourceValues.HierarchyQuery)
+	respBytes, err := c.SendRequest(ctx, "POST", url, string(jsonPayload), acceptHeaders)
 	if err != nil {
 		return []byte{}, err
 	}
@@ -169,7 +214,10 @@ func (c *Client) getExclusiveLockChecks(ctx context.Context, projectID string, r
 }
 
 func (c *Client) AddInvokeRestAPICheck(ctx context.Context, projectID string, resourceID string, check invokerestapimodel.InvokeRESTAPIValues) (invokerestapimodel.CheckConfiguration, error) {
-	restAPIPayload := populateInvokeRestAPIPayload(resourceID, check)
+	restAPIPayload, err := populateInvokeRestAPIPayload(resourceID, check)
+	if err != nil {
+		return invokerestapimodel.CheckConfiguration{}, err
+	}
 
 	jsonPayload, err := json.Marshal(restAPIPayload)
 	if err != nil {
@@ -177,7 +225,9 @@ func (c *Client) AddInvokeRestAPICheck(ctx context.Context, projectID string, re
 	}
 
 	url := fmt.Sprintf("/%s/_apis/pipelines/checks/configurations", projectID)
-	respBytes, err := c.SendRequest(ctx, "POST", url, string(jsonPayload))
+	acceptHeaders := c.negotiator.AcceptHeader(ctx, apiversion.ResourceValues.CheckConfigurations)
+	respBytes, err := c.SendRequest(ctx, "POST", url, string(jsonPayload), acceptHeaders)
+	c.cache.Invalidate(checksCacheKey(projectID, resourceID))
 	if err != nil {
 		return invokerestapimodel.CheckConfiguration{}, err
 	}
@@ -194,7 +244,10 @@ func (c *Client) AddInvokeRestAPICheck(ctx context.Context, projectID string, re
 
 func (c *Client) AddManualApprovalCheck(ctx context.Context, projectID string, resourceID string,
 	check manualapprovalmodel.ManualApprovalValues) (manualapprovalmodel.ManualApprovalCheckConfig, error) {
-	manualApproval := populateManualApprovalPayload(resourceID, check)
+	manualApproval, err := populateManualApprovalPayload(resourceID, check)
+	if err != nil {
+		return manualapprovalmodel.ManualApprovalCheckConfig{}, err
+	}
 
 	jsonPayload, err := json.Marshal(manualApproval)
 	if err != nil {
@@ -202,7 +255,9 @@ func (c *Client) AddManualApprovalCheck(ctx context.Context, projectID string, r
 	}
 
 	url := fmt.Sprintf("/%s/_apis/pipelines/checks/configurations", projectID)
-	respBytes, err := c.SendRequest(ctx, "POST", url, string(jsonPayload))
+	acceptHeaders := c.negotiator.AcceptHeader(ctx, apiversion.ResourceValues.CheckConfigurations)
+	respBytes, err := c.SendRequest(ctx, "POST", url, string(jsonPayload), acceptHeaders)
+	c.cache.Invalidate(checksCacheKey(projectID, resourceID))
 	if err != nil {
 		return manualapprovalmodel.ManualApprovalCheckConfig{}, err
 	}
@@ -219,7 +274,10 @@ func (c *Client) AddManualApprovalCheck(ctx context.Context, projectID string, r
 
 func (c *Client) AddExclusiveLockCheck(ctx context.Context, projectID string, resourceID string,
 	check exclusivelockmodel.ExclusiveLockValues) (exclusivelockmodel.ExclusiveLockCheckConfig, error) {
-	exclusiveLock := populateExclusiveLockPayload(resourceID, check)
+	exclusiveLock, err := populateExclusiveLockPayload(resourceID, check)
+	if err != nil {
+		return exclusivelockmodel.ExclusiveLockCheckConfig{}, err
+	}
 
 	jsonPayload, err := json.Marshal(exclusiveLock)
 	if err != nil {
@@ -227,7 +285,9 @@ func (c *Client) AddExclusiveLockCheck(ctx context.Context, projectID string, re
 	}
 
 	url := fmt.Sprintf("/%s/_apis/pipelines/checks/configurations", projectID)
-	respBytes, err := c.SendRequest(ctx, "POST", url, string(jsonPayload))
+	acceptHeaders := c.negotiator.AcceptHeader(ctx, apiversion.ResourceValues.CheckConfigurations)
+	respBytes, err := c.SendRequest(ctx, "POST", url, string(jsonPayload), acceptHeaders)
+	c.cache.Invalidate(checksCacheKey(projectID, resourceID))
 	if err != nil {
 		return exclusivelockmodel.ExclusiveLockCheckConfig{}, err
 	}
@@ -244,7 +304,10 @@ func (c *Client) AddExclusiveLockCheck(ctx context.Context, projectID string, re
 
 func (c *Client) UpdateManualApprovalCheck(ctx context.Context, projectID string, resourceID string, checkID string,
 	check manualapprovalmodel.ManualApprovalValues) (manualapprovalmodel.ManualApprovalCheckConfig, error) {
-	manualApproval := populateManualApprovalPayload(resourceID, check)
+	manualApproval, err := populateManualApprovalPayload(resourceID, check)
+	if err != nil {
+		return manualapprovalmodel.ManualApprovalCheckConfig{}, err
+	}
 	manualApproval.ID = checkID
 
 	jsonPayload, err := json.Marshal(manualApproval)
@@ -253,7 +316,9 @@ func (c *Client) UpdateManualApprovalCheck(ctx context.Context, projectID string
 	}
 
 	url := fmt.Sprintf("/%s/_apis/pipelines/checks/configurations/%s", projectID, checkID)
-	respBytes, err := c.SendRequest(ctx, "PATCH", url, string(jsonPayload))
+	acceptHeaders := c.negotiator.AcceptHeader(ctx, apiversion.ResourceValues.CheckConfigurations)
+	respBytes, err := c.SendRequest(ctx, "PATCH", url, string(jsonPayload), acceptHeaders)
+	c.cache.Invalidate(checksCacheKey(projectID, resourceID))
 	if err != nil {
 		return manualapprovalmodel.ManualApprovalCheckConfig{}, err
 	}
@@ -270,7 +335,10 @@ func (c *Client) UpdateManualApprovalCheck(ctx context.Context, projectID string
 
 func (c *Client) UpdateExclusiveLockCheck(ctx context.Context, projectID string, resourceID string, checkID string,
 	check exclusivelockmodel.ExclusiveLockValues) (exclusivelockmodel.ExclusiveLockCheckConfig, error) {
-	exclusiveLock := populateExclusiveLockPayload(resourceID, check)
+	exclusiveLock, err := populateExclusiveLockPayload(resourceID, check)
+	if err != nil {
+		return exclusivelockmodel.ExclusiveLockCheckConfig{}, err
+	}
 	exclusiveLock.ID = checkID
 
 	jsonPayload, err := json.Marshal(exclusiveLock)
@@ -279,7 +347,9 @@ func (c *Client) UpdateExclusiveLockCheck(ctx context.Context, projectID string,
 	}
 
 	url := fmt.Sprintf("/%s/_apis/pipelines/checks/configurations/%s", projectID, checkID)
-	respBytes, err := c.SendRequest(ctx, "PATCH", url, string(jsonPayload))
+	acceptHeaders := c.negotiator.AcceptHeader(ctx, apiversion.ResourceValues.CheckConfigurations)
+	respBytes, err := c.SendRequest(ctx, "PATCH", url, string(jsonPayload), acceptHeaders)
+	c.cache.Invalidate(checksCacheKey(projectID, resourceID))
 	if err != nil {
 		return exclusivelockmodel.ExclusiveLockCheckConfig{}, err
 	}
@@ -295,7 +365,10 @@ func (c *Client) UpdateExclusiveLockCheck(ctx context.Context, projectID string,
 }
 
 func (c *Client) UpdateCheck(ctx context.Context, projectID string, resourceID string, checkID string, check invokerestapimodel.InvokeRESTAPIValues) (invokerestapimodel.CheckConfiguration, error) {
-	restAPIPayload := populateInvokeRestAPIPayload(resourceID, check)
+	restAPIPayload, err := populateInvokeRestAPIPayload(resourceID, check)
+	if err != nil {
+		return invokerestapimodel.CheckConfiguration{}, err
+	}
 	restAPIPayload.ID = checkID
 
 	jsonPayload, err := json.Marshal(restAPIPayload)
@@ -304,7 +377,9 @@ func (c *Client) UpdateCheck(ctx context.Context, projectID string, resourceID s
 	}
 
 	url := fmt.Sprintf("/%s/_apis/pipelines/checks/configurations/%s", projectID, checkID)
-	respBytes, err := c.SendRequest(ctx, "PATCH", url, string(jsonPayload))
+	acceptHeaders := c.negotiator.AcceptHeader(ctx, apiversion.ResourceValues.CheckConfigurations)
+	respBytes, err := c.SendRequest(ctx, "PATCH", url, string(jsonPayload), acceptHeaders)
+	c.cache.Invalidate(checksCacheKey(projectID, resourceID))
 	if err != nil {
 		return invokerestapimodel.CheckConfiguration{}, err
 	}
@@ -319,15 +394,43 @@ func (c *Client) UpdateCheck(ctx context.Context, projectID string, resourceID s
 	return checkConf, nil
 }
 
+// GetCheckResource returns the resource protected by a check, for the checks whose resource is not known
+func (c *Client) GetCheckResource(ctx context.Context, projectID string, checkID string) (checkmodel.CheckResource, error) {
+	url := fmt.Sprintf("/%s/_apis/pipelines/checks/configurations/%s", projectID, checkID)
+	acceptHeaders := c.negotiator.AcceptHeader(ctx, apiversion.ResourceValues.CheckConfigurations)
+	respBytes, err := c.SendRequest(ctx, "GET", url, "", acceptHeaders)
+	if err != nil {
+		return checkmodel.CheckResource{}, err
+	}
+
+	checkConf := struct {
+		Resource checkmodel.CheckResource `json:"resource"`
+	}{}
+	if err := json.Unmarshal(respBytes, &checkConf); err != nil {
+		return checkmodel.CheckResource{}, err
+	}
+	if checkConf.Resource.ID == "" {
+		return checkmodel.CheckResource{}, fmt.Errorf("the check %s has no resource", checkID)
+	}
+
+	return checkConf.Resource, nil
+}
+
 func (c *Client) DeleteCheck(ctx context.Context, projectID string, checkID string) error {
 	url := fmt.Sprintf("/%s/_apis/pipelines/checks/configurations/%s", projectID, checkID)
-	_, err := c.SendRequest(ctx, "DELETE", url, "")
+	acceptHeaders := c.negotiator.AcceptHeader(ctx, apiversion.ResourceValues.CheckConfigurations)
+	_, err := c.SendRequest(ctx, "DELETE", url, "", acceptHeaders)
+	// the resource of the check is not known here, so drop the checks of every resource in the project
+	c.cache.InvalidatePrefix(checksCacheKey(projectID, ""))
 
 	return err
 }
 
-func populateInvokeRestAPIPayload(resourceID string, check invokerestapimodel.InvokeRESTAPIValues) invokerestapimodel.InvokeRestAPICheckPayload {
-	checkPayload := invokerestapimodel.NewInvokeRestCheckPayload()
+func populateInvokeRestAPIPayload(resourceID string, check invokerestapimodel.InvokeRESTAPIValues) (invokerestapimodel.InvokeRestAPICheckPayload, error) {
+	checkPayload, err := invokerestapimodel.NewInvokeRestCheckPayload()
+	if err != nil {
+		return invokerestapimodel.InvokeRestAPICheckPayload{}, err
+	}
 
 	checkPayload.Settings.DisplayName = check.DisplayName
 
@@ -339,7 +442,7 @@ func populateInvokeRestAPIPayload(resourceID string, check invokerestapimodel.In
 
 	headersBytes, err := json.Marshal(check.Headers)
 	if err != nil {
-		logrus.Fatal(err)
+		return invokerestapimodel.InvokeRestAPICheckPayload{}, fmt.Errorf("marshalling the headers of the check: %w", err)
 	}
 
 	checkPayload.Settings.Inputs.Headers = string(headersBytes)
@@ -352,12 +455,15 @@ func populateInvokeRestAPIPayload(resourceID string, check invokerestapimodel.In
 	checkPayload.Timeout = check.Timeout
 	checkPayload.Settings.RetryInterval = check.RetryInterval
 
-	return checkPayload
+	return checkPayload, nil
 }
 
 func populateManualApprovalPayload(resourceID string,
-	check manualapprovalmodel.ManualApprovalValues) manualapprovalmodel.ManualApprovalCheckPayload {
-	approval := manualapprovalmodel.NewManualApprovalCheckPayload()
+	check manualapprovalmodel.ManualApprovalValues) (manualapprovalmodel.ManualApprovalCheckPayload, error) {
+	approval, err := manualapprovalmodel.NewManualApprovalCheckPayload()
+	if err != nil {
+		return manualapprovalmodel.ManualApprovalCheckPayload{}, err
+	}
 	approval.Resource.ID = resourceID
 	approval.Settings.Instructions = check.Instructions
 	//Allow self approve is logical opposite of cannot request and approve.
@@ -384,19 +490,22 @@ func populateManualApprovalPayload(resourceID string,
 
 	approval.Settings.MinRequiredApprovers = check.MinimumApprovers
 
-	return approval
+	return approval, nil
 }
 
 func populateExclusiveLockPayload(resourceID string,
-	check exclusivelockmodel.ExclusiveLockValues) exclusivelockmodel.ExclusiveLockCheckPayload {
-	exclusiveLock := exclusivelockmodel.NewExclusiveLockCheckPayload()
+	check exclusivelockmodel.ExclusiveLockValues) (exclusivelockmodel.ExclusiveLockCheckPayload, error) {
+	exclusiveLock, err := exclusivelockmodel.NewExclusiveLockCheckPayload()
+	if err != nil {
+		return exclusivelockmodel.ExclusiveLockCheckPayload{}, err
+	}
 	exclusiveLock.Resource.ID = resourceID
 	exclusiveLock.Timeout = check.Timeout
 
-	return exclusiveLock
+	return exclusiveLock, nil
 }
 
-func (c *Client) SendRequest(ctx context.Context, httpMethod string, url string, jsonPayload string) ([]byte, error) {
+func (c *Client) SendRequest(ctx context.Context, httpMethod string, url string, jsonPayload string, acceptHeaders string) ([]byte, error) {
 	req, err := http.NewRequestWithContext(ctx, httpMethod, c.baseUrl+url, bytes.NewBufferString(jsonPayload))
 	if err != nil {
 		return []byte{}, err
@@ -404,7 +513,7 @@ func (c *Client) SendRequest(ctx context.Context, httpMethod string, url string,
 
 	req.Header.Set("Authorization", c.authorization)
 	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Accept", "application/json;api-version=5.1-preview.1;excludeUrls=true;enumsAsNumbers=true;msDateFormat=true;noArrayWrap=true")
+	req.Header.Set("Accept", acceptHeaders)
 
 	resp, err := c.client.Do(req)
 	if err != nil {
@@ -427,7 +536,7 @@ func (c *Client) SendRequest(ctx context.Context, httpMethod string, url string,
 	return body, nil
 }
 
-func NewClient(baseUrl string, auth string, timeout *time.Duration) *Client {
+func NewClient(baseUrl string, auth string, timeout *time.Duration, options ...Option) *Client {
 	defaultTime := time.Duration(60 * time.Second)
 	if timeout == nil {
 		timeout = &defaultTime
@@ -437,17 +546,22 @@ func NewClient(baseUrl string, auth string, timeout *time.Duration) *Client {
 		Timeout: *timeout,
 	}
 
-	return &Client{
+	c := &Client{
 		baseUrl:       baseUrl,
 		client:        client,
 		authorization: auth,
 	}
+	for _, option := range options {
+		option(c)
+	}
+	return c
 }
 
 type ManualApprovalClient interface {
 	GetManualApprovalCheckByID(ctx context.Context, projectID string, resourceID string, checkID int64) (manualapprovalmodel.ManualApprovalCheckConfig, bool, error)
 	AddManualApprovalCheck(ctx context.Context, projectID string, resourceID string, check manualapprovalmodel.ManualApprovalValues) (manualapprovalmodel.ManualApprovalCheckConfig, error)
 	UpdateManualApprovalCheck(ctx context.Context, projectID string, resourceID string, checkID string, check manualapprovalmodel.ManualApprovalValues) (manualapprovalmodel.ManualApprovalCheckConfig, error)
+	GetCheckResource(ctx context.Context, projectID string, checkID string) (checkmodel.CheckResource, error)
 	DeleteCheck(ctx context.Context, projectID string, checkID string) error
 }
 
@@ -455,6 +569,7 @@ type ExclusiveLockClient interface {
 	GetExclusiveLockCheckByID(ctx context.Context, projectID string, resourceID string, checkID int64) (exclusivelockmodel.ExclusiveLockCheckConfig, bool, error)
 	AddExclusiveLockCheck(ctx context.Context, projectID string, resourceID string, check exclusivelockmodel.ExclusiveLockValues) (exclusivelockmodel.ExclusiveLockCheckConfig, error)
 	UpdateExclusiveLockCheck(ctx context.Context, projectID string, resourceID string, checkID string, check exclusivelockmodel.ExclusiveLockValues) (exclusivelockmodel.ExclusiveLockCheckConfig, error)
+	GetCheckResource(ctx context.Context, projectID string, checkID string) (checkmodel.CheckResource, error)
 	DeleteCheck(ctx context.Context, projectID string, checkID string) error
 }
 
@@ -462,5 +577,6 @@ type InvokeClient interface {
 	GetInvokeRestAPICheckByID(ctx context.Context, projectID string, resourceID string, checkID int64) (invokerestapimodel.CheckConfigurationData, bool, error)
 	AddInvokeRestAPICheck(ctx context.Context, projectID string, resourceID string, check invokerestapimodel.InvokeRESTAPIValues) (invokerestapimodel.CheckConfiguration, error)
 	UpdateCheck(ctx context.Context, projectID string, resourceID string, checkID string, check invokerestapimodel.InvokeRESTAPIValues) (invokerestapimodel.CheckConfiguration, error)
+	GetCheckResource(ctx context.Context, projectID string, checkID string) (checkmodel.CheckResource, error)
 	DeleteCheck(ctx context.Context, projectID string, checkID string) error
 }
@@ -5,11 +5,12 @@ import (
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
+	checkmodel "github.com/babylonhealth/terraform-provider-bblnazuredevops/bblnazuredevops/internal/service/checks/common/model"
 	exclusivelockmodel "github.com/babylonhealth/terraform-provider-bblnazuredevops/bblnazuredevops/internal/service/checks/exclusivelock/model"
 	invokerestapimodel "github.com/babylonhealth/terraform-provider-bblnazuredevops/bblnazuredevops/internal/service/checks/invokerestapi/model"
 	manualapprovalmodel "github.com/babylonhealth/terraform-provider-bblnazuredevops/bblnazuredevops/internal/service/checks/manualapproval/model"
+	"github.com/babylonhealth/terraform-provider-bblnazuredevops/bblnazuredevops/internal/utils/cache"
 	"github.com/google/go-cmp/cmp"
-	"github.com/sirupsen/logrus"
 	"net/http"
 	"net/http/httptest"
 	"os"
@@ -113,7 +114,11 @@ func TestClient_AddCheck(t *testing.T) {
 			personalAccessToken := getAuthString()
 
 			duration := 60 * time.Second
-			ts := getTestServer(populateInvokeRestAPIPayload(tt.args.resourceID, tt.args.check))
+			payload, err := populateInvokeRestAPIPayload(tt.args.resourceID, tt.args.check)
+			if err != nil {
+				t.Fatal(err)
+			}
+			ts := getTestServer(payload)
 			defer ts.Close()
 
 			c := NewClient(ts.URL, personalAccessToken, &duration)
@@ -124,7 +129,7 @@ func TestClient_AddCheck(t *testing.T) {
 				return
 			}
 
-			expectedResp := populateCheckConf(tt.args.check)
+			expectedResp := populateCheckConf(t, tt.args.check)
 
 			if !reflect.DeepEqual(got, expectedResp) {
 				t.Errorf("AddInvokeRestAPICheck() got = %v, want %v", got, tt.want)
@@ -181,7 +186,11 @@ func TestClient_UpdateCheck(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			ts := getTestServer(populateInvokeRestAPIPayload(tt.args.resourceID, tt.args.check))
+			payload, err := populateInvokeRestAPIPayload(tt.args.resourceID, tt.args.check)
+			if err != nil {
+				t.Fatal(err)
+			}
+			ts := getTestServer(payload)
 			defer ts.Close()
 
 			duration := 60 * time.Second
@@ -192,7 +201,7 @@ func TestClient_UpdateCheck(t *testing.T) {
 				return
 			}
 
-			expectedResp := populateCheckConf(tt.args.check)
+			expectedResp := populateCheckConf(t, tt.args.check)
 
 			if diff := cmp.Diff(expectedResp, gotResp); diff != "" {
 				t.Errorf("mismatch (-want +got):\n%s", diff)
@@ -330,6 +339,87 @@ func TestClient_GetManualApprovalCheckByID(t *testing.T) {
 	}
 }
 
+func TestClient_GetChecks_Cached(t *testing.T) {
+	projectID := "4f7f5d92-0e11-4311-ac85-9972864acbc2"
+	resourceID := "02c325bc-f8ec-47cd-a466-374b2f8cd835"
+
+	hr := manualapprovalmodel.HeirarchyResp{}
+	hr.DataProviders.MsVssPipelinechecksChecksDataProvider.CheckConfigurationDataList = []manualapprovalmodel.CheckConfigurationData{
+		{CheckConfiguration: manualapprovalmodel.ManualApprovalCheckConfig{ID: 50}},
+		{CheckConfiguration: manualapprovalmodel.ManualApprovalCheckConfig{ID: 51}},
+	}
+
+	queries := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/_apis/Contribution/HierarchyQuery" {
+			queries++
+			_ = json.NewEncoder(w).Encode(hr)
+			return
+		}
+		fmt.Fprint(w, "{}")
+	}))
+	defer ts.Close()
+
+	duration := 60 * time.Second
+	c := NewClient(ts.URL, "", &duration, WithCache(cache.New(time.Minute)))
+	ctx := context.Background()
+
+	for _, checkID := range []int64{50, 51} {
+		_, found, err := c.GetManualApprovalCheckByID(ctx, projectID, resourceID, checkID)
+		if err != nil || !found {
+			t.Fatalf("GetManualApprovalCheckByID() found = %v, error = %v", found, err)
+		}
+	}
+	if queries != 1 {
+		t.Errorf("expected the checks of the resource to be queried once, got %d", queries)
+	}
+
+	if _, err := c.AddManualApprovalCheck(ctx, projectID, resourceID, manualapprovalmodel.ManualApprovalValues{}); err != nil {
+		t.Fatalf("AddManualApprovalCheck() error = %v", err)
+	}
+	_, _, _ = c.GetManualApprovalCheckByID(ctx, projectID, resourceID, 50)
+	if queries != 2 {
+		t.Errorf("expected adding a check to invalidate the cache, got %d queries", queries)
+	}
+
+	if err := c.DeleteCheck(ctx, projectID, "50"); err != nil {
+		t.Fatalf("DeleteCheck() error = %v", err)
+	}
+	_, _, _ = c.GetManualApprovalCheckByID(ctx, projectID, resourceID, 51)
+	if queries != 3 {
+		t.Errorf("expected deleting a check to invalidate the cache, got %d queries", queries)
+	}
+}
+
+func TestClient_GetCheckResource(t *testing.T) {
+	projectID := "4f7f5d92-0e11-4311-ac85-9972864acbc2"
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet || r.URL.Path != "/"+projectID+"/_apis/pipelines/checks/configurations/46" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, `{"id": 46, "resource": {"type": "endpoint", "id": "02c325bc-f8ec-47cd-a466-374b2f8cd835"}}`)
+	}))
+	defer ts.Close()
+
+	duration := 60 * time.Second
+	c := NewClient(ts.URL, "", &duration)
+
+	got, err := c.GetCheckResource(context.Background(), projectID, "46")
+	if err != nil {
+		t.Fatalf("GetCheckResource() error = %v", err)
+	}
+	want := checkmodel.CheckResource{Type: "endpoint", ID: "02c325bc-f8ec-47cd-a466-374b2f8cd835"}
+	if got != want {
+		t.Errorf("GetCheckResource() got = %v, want %v", got, want)
+	}
+
+	if _, err := c.GetCheckResource(context.Background(), projectID, "47"); err == nil {
+		t.Error("GetCheckResource() expected an error for an unknown check")
+	}
+}
+
 func TestClient_AddManualApprovalCheck(t *testing.T) {
 	type fields struct {
 		baseUrl       string
@@ -397,7 +487,11 @@ func TestClient_AddManualApprovalCheck(t *testing.T) {
 			personalAccessToken := getAuthString()
 
 			duration := 60 * time.Second
-			ts := getTestServer(populateManualApprovalPayload(tt.args.resourceID, tt.args.check))
+			payload, err := populateManualApprovalPayload(tt.args.resourceID, tt.args.check)
+			if err != nil {
+				t.Fatal(err)
+			}
+			ts := getTestServer(payload)
 			defer ts.Close()
 
 			c := NewClient(ts.URL, personalAccessToken, &duration)
@@ -556,7 +650,11 @@ func TestClient_AddExclusiveLockCheck(t *testing.T) {
 			personalAccessToken := getAuthString()
 
 			duration := 60 * time.Second
-			ts := getTestServer(populateExclusiveLockPayload(tt.args.resourceID, tt.args.check))
+			payload, err := populateExclusiveLockPayload(tt.args.resourceID, tt.args.check)
+			if err != nil {
+				t.Fatal(err)
+			}
+			ts := getTestServer(payload)
 			defer ts.Close()
 
 			c := NewClient(ts.URL, personalAccessToken, &duration)
@@ -656,16 +754,17 @@ func getTestServer(wantedResponse interface{}) *httptest.Server {
 	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		jsonResp, err := json.Marshal(wantedResponse)
 		if err != nil {
-			logrus.Fatalf("error setting up test server: %v", err)
+			http.Error(w, fmt.Sprintf("error setting up test server: %v", err), http.StatusInternalServerError)
+			return
 		}
 
-		fmt.Fprintf(w, string(jsonResp))
+		fmt.Fprint(w, string(jsonResp))
 	}))
 
 	return ts
 }
 
-func populateCheckConf(values invokerestapimodel.InvokeRESTAPIValues) invokerestapimodel.CheckConfiguration {
+func populateCheckConf(t *testing.T, values invokerestapimodel.InvokeRESTAPIValues) invokerestapimodel.CheckConfiguration {
 	conf := invokerestapimodel.CheckConfiguration{}
 	conf.Resource.Type = "endpoint"
 	conf.Resource.ID = "02c325bc-f8ec-47cd-a466-374b2f8cd835"
@@ -683,7 +782,7 @@ func populateCheckConf(values invokerestapimodel.InvokeRESTAPIValues) invokerest
 
 	headersBytes, err := json.Marshal(values.Headers)
 	if err != nil {
-		logrus.Fatal(err)
+		t.Fatal(err)
 	}
 
 	conf.Settings.Inputs.Headers = string(headersBytes)
@@ -3,47 +3,151 @@ package resource
 import (
 	"context"
 	"fmt"
+	"strconv"
+	"strings"
+
 	"github.com/babylonhealth/terraform-provider-bblnazuredevops/bblnazuredevops/internal/client"
-	"github.com/babylonhealth/terraform-provider-bblnazuredevops/bblnazuredevops/internal/service/checks/invokerestapi/model"
-	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
-	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/babylonhealth/terraform-provider-bblnazuredevops/bblnazuredevops/internal/utils/fwhelper"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/int64default"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/planmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/types"
 )
 
-func DeleteCheckContext(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
-	clients := m.(*client.AggregatedClient)
+// SchemaVersion is the version of the schema of the check resources. Version 0 is the schema of the SDKv2
+// implementation, whose state stored the empty string for optional attributes which were not set.
+const SchemaVersion = 1
+
+// CheckBase implements what the check resources have in common: the client, the project, import and delete
+type CheckBase struct {
+	Clients *client.AggregatedClient
+}
 
-	projectID := d.Get("project_id").(string)
-	checkId := d.Id()
+// Attributes returns the attributes shared by the check resources together with the given ones
+func Attributes(attributes map[string]schema.Attribute) map[string]schema.Attribute {
+	attributes["id"] = schema.StringAttribute{
+		Computed:      true,
+		PlanModifiers: []planmodifier.String{stringplanmodifier.UseStateForUnknown()},
+	}
+	attributes["project_id"] = fwhelper.ProjectIDAttribute()
+	attributes["resource_id"] = schema.StringAttribute{
+		Required:      true,
+		PlanModifiers: []planmodifier.String{stringplanmodifier.RequiresReplace()},
+		Description:   "The ID of the resource protected by the check.",
+	}
+	//ToDo: type will be either "endpoint", "queue" or "variablegroup"
+	// current implementation defaults to endpoint - service endpoint
+	attributes["type"] = schema.StringAttribute{
+		Required:    true,
+		Description: "The type of the resource protected by the check.",
+	}
+	// the SDKv2 implementation sent 0 when the timeout was not set, Computed is only required by the default
+	attributes["timeout"] = schema.Int64Attribute{
+		Optional:    true,
+		Computed:    true,
+		Default:     int64default.StaticInt64(0),
+		Description: "The timeout of the check in minutes, 0 when not set.",
+	}
+	return attributes
+}
 
-	return diag.FromErr(clients.InvokeCheckClient.DeleteCheck(ctx, projectID, checkId))
+// AttributesV0 returns the attributes of version 0 of the schema shared by the check resources together with
+// the given ones
+func AttributesV0(attributes map[string]schema.Attribute) map[string]schema.Attribute {
+	attributes["id"] = schema.StringAttribute{Computed: true}
+	attributes["project_id"] = schema.StringAttribute{Optional: true, Computed: true}
+	attributes["resource_id"] = schema.StringAttribute{Required: true}
+	attributes["type"] = schema.StringAttribute{Required: true}
+	attributes["timeout"] = schema.Int64Attribute{Optional: true}
+	return attributes
 }
 
-func buildInvokeRESTAPIValuesFromSchema(d *schema.ResourceData) model.InvokeRESTAPIValues {
-	timeout := d.Get("timeout").(int)
-	retryInterval := d.Get("retry_interval").(int)
+func (b *CheckBase) Configure(_ context.Context, req resource.ConfigureRequest, resp *resource.ConfigureResponse) {
+	b.Clients = fwhelper.Clients(req.ProviderData, &resp.Diagnostics)
+}
 
-	headersFromSchema := d.Get("headers").(map[string]interface{})
+func (b *CheckBase) ModifyPlan(ctx context.Context, req resource.ModifyPlanRequest, resp *resource.ModifyPlanResponse) {
+	fwhelper.ModifyPlanProjectID(ctx, b.Clients, req, resp)
+}
 
-	headersMap := map[string]string{}
+// ImportState imports a check by an ID of the form <project name or ID>/<resource ID>/<check ID>. The ID of the
+// SDKv2 check resources, <project name or ID>/<check ID>, is still accepted, the resource of the check is then
+// looked up.
+func (b *CheckBase) ImportState(ctx context.Context, req resource.ImportStateRequest, resp *resource.ImportStateResponse) {
+	projectNameOrID, resourceID, checkID, err := ParseImportID(req.ID)
+	if err != nil {
+		resp.Diagnostics.AddError("Invalid import ID", err.Error())
+		return
+	}
 
-	for k, v := range headersFromSchema {
-		s := fmt.Sprintf("%s", v)
-		headersMap[k] = s
+	projectID, err := fwhelper.ProjectID(b.Clients, types.StringValue(projectNameOrID))
+	if err != nil {
+		resp.Diagnostics.AddError("Error resolving the project", err.Error())
+		return
 	}
 
-	check := model.InvokeRESTAPIValues{
-		ServiceConnectionId: d.Get("service_connection_id").(string),
-		LinkedVariableGroup: d.Get("linked_variable_group").(string),
-		Timeout:             int64(timeout),
-		RetryInterval:       int64(retryInterval),
-		DisplayName:         d.Get("display_name").(string),
-		Method:              d.Get("method").(string),
-		UseCallback:         d.Get("use_callback").(bool),
-		Body:                d.Get("body").(string),
-		UrlSuffix:           d.Get("url_suffix").(string),
-		SuccessCriteria:     d.Get("success_criteria").(string),
-		Headers:             headersMap,
+	if resourceID == "" {
+		checkResource, err := b.Clients.InvokeCheckClient.GetCheckResource(ctx, projectID, checkID)
+		if err != nil {
+			resp.Diagnostics.AddError("Error looking up the resource of the check", err.Error())
+			return
+		}
+		resourceID = checkResource.ID
+		resp.Diagnostics.Append(resp.State.SetAttribute(ctx, path.Root("type"), checkResource.Type)...)
 	}
 
-	return check
+	resp.Diagnostics.Append(resp.State.SetAttribute(ctx, path.Root("project_id"), projectID)...)
+	resp.Diagnostics.Append(resp.State.SetAttribute(ctx, path.Root("resource_id"), resourceID)...)
+	resp.Diagnostics.Append(resp.State.SetAttribute(ctx, path.Root("id"), checkID)...)
+}
+
+// Delete deletes the check in the state
+func (b *CheckBase) Delete(ctx context.Context, req resource.DeleteRequest, resp *resource.DeleteResponse) {
+	var projectNameOrID, checkID types.String
+	resp.Diagnostics.Append(req.State.GetAttribute(ctx, path.Root("project_id"), &projectNameOrID)...)
+	resp.Diagnostics.Append(req.State.GetAttribute(ctx, path.Root("id"), &checkID)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	projectID, err := b.ProjectID(projectNameOrID)
+	if err != nil {
+		resp.Diagnostics.AddError("Error resolving the project", err.Error())
+		return
+	}
+
+	if err := b.Clients.InvokeCheckClient.DeleteCheck(ctx, projectID, checkID.ValueString()); err != nil {
+		resp.Diagnostics.AddError("Error deleting the check", err.Error())
+	}
+}
+
+// ProjectID returns the ID of the project of a check. The checks are always requested with the ID of their
+// project, as they are cached per project.
+func (b *CheckBase) ProjectID(projectNameOrID types.String) (string, error) {
+	return fwhelper.ProjectID(b.Clients, projectNameOrID)
+}
+
+// ParseImportID splits an import ID of the form <project name or ID>/<resource ID>/<check ID>, or
+// <project name or ID>/<check ID>, in which case the resource ID is empty
+func ParseImportID(id string) (string, string, string, error) {
+	parts := strings.Split(id, "/")
+	valid := len(parts) == 2 || len(parts) == 3 && parts[1] != ""
+	if len(parts) == 2 {
+		parts = []string{parts[0], "", parts[1]}
+	}
+	if !valid || parts[0] == "" || parts[2] == "" {
+		return "", "", "", fmt.Errorf("expected an import ID of the form <project name or ID>/<resource ID>/<check ID> or <project name or ID>/<check ID>, got %q", id)
+	}
+	if _, err := strconv.ParseInt(parts[2], 10, 64); err != nil {
+		return "", "", "", fmt.Errorf("the check ID %q must be an integer", parts[2])
+	}
+	return parts[0], parts[1], parts[2], nil
+}
+
+// CheckID parses the ID of a check
+func CheckID(id types.String) (int64, error) {
+	return strconv.ParseInt(id.ValueString(), 10, 64)
 }
@@ -0,0 +1,26 @@
+package resource
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseImportID(t *testing.T) {
+	project, resourceID, checkID, err := ParseImportID("my project/d8b3f1a2-8f3c-4a55-9c57-2b5b1e7e3f44/42")
+	require.NoError(t, err)
+	require.Equal(t, "my project", project)
+	require.Equal(t, "d8b3f1a2-8f3c-4a55-9c57-2b5b1e7e3f44", resourceID)
+	require.Equal(t, "42", checkID)
+
+	project, resourceID, checkID, err = ParseImportID("my project/42")
+	require.NoError(t, err)
+	require.Equal(t, "my project", project)
+	require.Empty(t, resourceID)
+	require.Equal(t, "42", checkID)
+
+	for _, id := range []string{"", "project", "/42", "project/check", "project//42", "project/resource/check", "project/resource/42/extra"} {
+		_, _, _, err := ParseImportID(id)
+		require.Error(t, err, "import ID %q should be invalid", id)
+	}
+}
@@ -2,8 +2,8 @@ package model
 
 import (
 	"encoding/json"
+	"fmt"
 	"github.com/babylonhealth/terraform-provider-bblnazuredevops/bblnazuredevops/internal/service/checks/common/model"
-	"github.com/sirupsen/logrus"
 )
 
 type CheckConfigurationData struct {
@@ -76,7 +76,7 @@ type ExclusiveLockCheckPayload struct {
 	ID       string                 `json:"id,omitempty"`
 }
 
-func NewExclusiveLockCheckPayload() ExclusiveLockCheckPayload {
+func NewExclusiveLockCheckPayload() (ExclusiveLockCheckPayload, error) {
 	jsonPayload := `{
     "type": {
         "id": "2EF31AD6-BAA0-403A-8B45-2CBC9B4E5563",
@@ -96,8 +96,8 @@ func NewExclusiveLockCheckPayload() ExclusiveLockCheckPayload {
 	err := json.Unmarshal([]byte(jsonPayload), &checkPayload)
 
 	if err != nil {
-		logrus.Fatal(err)
+		return ExclusiveLockCheckPayload{}, fmt.Errorf("parsing the default check payload: %w", err)
 	}
 
-	return checkPayload
+	return checkPayload, nil
 }
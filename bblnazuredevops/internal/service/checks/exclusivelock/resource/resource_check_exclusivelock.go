@@ -2,130 +2,158 @@ package resource
 
 import (
 	"context"
-	"fmt"
-	"github.com/babylonhealth/terraform-provider-bblnazuredevops/bblnazuredevops/internal/client"
-	"github.com/babylonhealth/terraform-provider-bblnazuredevops/bblnazuredevops/internal/service/checks/common/resource"
-	"github.com/babylonhealth/terraform-provider-bblnazuredevops/bblnazuredevops/internal/service/checks/exclusivelock/model"
-	"github.com/babylonhealth/terraform-provider-bblnazuredevops/bblnazuredevops/internal/utils/tfhelper"
-	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
-	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
 	"strconv"
+
+	checkresource "github.com/babylonhealth/terraform-provider-bblnazuredevops/bblnazuredevops/internal/service/checks/common/resource"
+	"github.com/babylonhealth/terraform-provider-bblnazuredevops/bblnazuredevops/internal/service/checks/exclusivelock/model"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/types"
 )
 
-// ResourceServiceEndpointDockerRegistry schema and implementation for docker registry service endpoint resource
-func ResourceCheckExclusiveLock() *schema.Resource {
-	r := &schema.Resource{
-		CreateContext: createCheck,
-		ReadContext:   readCheck,
-		UpdateContext: updateCheck,
-		DeleteContext: resource.DeleteCheckContext,
-	}
-	r.Schema = map[string]*schema.Schema{}
-	r.Schema["project_id"] = &schema.Schema{
-		Type:     schema.TypeString,
-		Required: true,
-		ForceNew: true,
-	}
-	r.Schema["resource_id"] = &schema.Schema{
-		Type:     schema.TypeString,
-		Required: true,
-		ForceNew: true,
-	}
+var (
+	_ resource.ResourceWithConfigure    = &checkExclusiveLockResource{}
+	_ resource.ResourceWithModifyPlan   = &checkExclusiveLockResource{}
+	_ resource.ResourceWithImportState  = &checkExclusiveLockResource{}
+	_ resource.ResourceWithUpgradeState = &checkExclusiveLockResource{}
+)
 
-	//ToDo: type will be either "endpoint" or "variablegroup"
-	// current implementation defaults to endpoint - service endpoint
-	// variablegroup support will be added in further ticket
-	r.Schema["type"] = &schema.Schema{
-		Type:     schema.TypeString,
-		Required: true,
-	}
+type checkExclusiveLockResource struct {
+	checkresource.CheckBase
+}
 
-	r.Schema["timeout"] = &schema.Schema{
-		Type:     schema.TypeInt,
-		Required: false,
-		Optional: true,
-	}
+type checkExclusiveLockModel struct {
+	ID         types.String `tfsdk:"id"`
+	ProjectID  types.String `tfsdk:"project_id"`
+	ResourceID types.String `tfsdk:"resource_id"`
+	Type       types.String `tfsdk:"type"`
+	Timeout    types.Int64  `tfsdk:"timeout"`
+}
 
-	r.Importer = tfhelper.ImportProjectQualifiedResourceUUID()
+// NewCheckExclusiveLockResource schema and implementation for the exclusive lock check resource
+func NewCheckExclusiveLockResource() resource.Resource {
+	return &checkExclusiveLockResource{}
+}
 
-	return r
+func (r *checkExclusiveLockResource) Metadata(_ context.Context, req resource.MetadataRequest, resp *resource.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_check_exclusivelock"
 }
 
-// See Resource documentation.
-func createCheck(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
-	clients := m.(*client.AggregatedClient)
+func (r *checkExclusiveLockResource) Schema(_ context.Context, _ resource.SchemaRequest, resp *resource.SchemaResponse) {
+	resp.Schema = schema.Schema{
+		Version:     checkresource.SchemaVersion,
+		Description: "Allows only a single run to use the protected resource at a time.",
+		Attributes:  checkresource.Attributes(map[string]schema.Attribute{}),
+	}
+}
 
-	projectID := d.Get("project_id").(string)
-	resourceID := d.Get("resource_id").(string)
+func (r *checkExclusiveLockResource) UpgradeState(_ context.Context) map[int64]resource.StateUpgrader {
+	return map[int64]resource.StateUpgrader{
+		0: {
+			PriorSchema: &schema.Schema{
+				Attributes: checkresource.AttributesV0(map[string]schema.Attribute{}),
+			},
+			// the exclusive lock check has no optional string attribute, its state is unchanged
+			StateUpgrader: func(ctx context.Context, req resource.UpgradeStateRequest, resp *resource.UpgradeStateResponse) {
+				var state checkExclusiveLockModel
+				resp.Diagnostics.Append(req.State.Get(ctx, &state)...)
+				if resp.Diagnostics.HasError() {
+					return
+				}
+				resp.Diagnostics.Append(resp.State.Set(ctx, state)...)
+			},
+		},
+	}
+}
 
-	check := buildExclusiveLockValuesFromSchema(d)
+// See Resource documentation.
+func (r *checkExclusiveLockResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
+	var plan checkExclusiveLockModel
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &plan)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
 
-	resp, err := clients.ExclusiveLockCheckClient.AddExclusiveLockCheck(ctx, projectID, resourceID, check)
+	projectID, err := r.ProjectID(plan.ProjectID)
 	if err != nil {
-		return diag.FromErr(err)
+		resp.Diagnostics.AddError("Error resolving the project", err.Error())
+		return
 	}
 
-	id := resp.ID
+	check, err := r.Clients.ExclusiveLockCheckClient.AddExclusiveLockCheck(ctx, projectID, plan.ResourceID.ValueString(), plan.values())
+	if err != nil {
+		resp.Diagnostics.AddError("Error creating the exclusive lock check", err.Error())
+		return
+	}
 
-	d.SetId(fmt.Sprintf("%v", id))
+	plan.ID = types.StringValue(strconv.FormatInt(check.ID, 10))
 
-	return nil
+	resp.Diagnostics.Append(resp.State.Set(ctx, plan)...)
 }
 
 // See Resource documentation.
-func readCheck(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
-	clients := m.(*client.AggregatedClient)
-
-	projectID := d.Get("project_id").(string)
-	resourceID := d.Get("resource_id").(string)
+func (r *checkExclusiveLockResource) Read(ctx context.Context, req resource.ReadRequest, resp *resource.ReadResponse) {
+	var state checkExclusiveLockModel
+	resp.Diagnostics.Append(req.State.Get(ctx, &state)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
 
-	checkId := d.Id()
+	projectID, err := r.ProjectID(state.ProjectID)
+	if err != nil {
+		resp.Diagnostics.AddError("Error resolving the project", err.Error())
+		return
+	}
+	// the state holds the ID of the project, which ModifyPlan keeps for a configuration naming the same project
+	state.ProjectID = types.StringValue(projectID)
 
-	idInt, err := strconv.ParseInt(checkId, 10, 0)
+	checkID, err := checkresource.CheckID(state.ID)
 	if err != nil {
-		return diag.FromErr(err)
+		resp.Diagnostics.AddError("Error parsing the check ID", err.Error())
+		return
 	}
 
-	checkConfig, found, err := clients.ExclusiveLockCheckClient.GetExclusiveLockCheckByID(ctx, projectID, resourceID, idInt)
+	checkConfig, found, err := r.Clients.ExclusiveLockCheckClient.GetExclusiveLockCheckByID(ctx, projectID, state.ResourceID.ValueString(), checkID)
 	if err != nil {
-		return diag.FromErr(err)
+		resp.Diagnostics.AddError("Error reading the exclusive lock check", err.Error())
+		return
 	}
 
 	if !found {
-		d.SetId("")
-		return nil
+		resp.State.RemoveResource(ctx)
+		return
 	}
 
-	d.Set("timeout", checkConfig.Timeout)
+	state.Timeout = types.Int64Value(checkConfig.Timeout)
 
-	return diag.FromErr(err)
+	resp.Diagnostics.Append(resp.State.Set(ctx, state)...)
 }
 
 // See Resource documentation.
-func updateCheck(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
-	clients := m.(*client.AggregatedClient)
-
-	projectID := d.Get("project_id").(string)
-	resourceID := d.Get("resource_id").(string)
-
-	check := buildExclusiveLockValuesFromSchema(d)
+func (r *checkExclusiveLockResource) Update(ctx context.Context, req resource.UpdateRequest, resp *resource.UpdateResponse) {
+	var plan checkExclusiveLockModel
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &plan)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
 
-	_, err := clients.ExclusiveLockCheckClient.UpdateExclusiveLockCheck(ctx, projectID, resourceID, d.Id(), check)
+	projectID, err := r.ProjectID(plan.ProjectID)
 	if err != nil {
-		return diag.FromErr(err)
+		resp.Diagnostics.AddError("Error resolving the project", err.Error())
+		return
 	}
 
-	//update ?
-	d.SetId(d.Id())
+	_, err = r.Clients.ExclusiveLockCheckClient.UpdateExclusiveLockCheck(ctx, projectID, plan.ResourceID.ValueString(), plan.ID.ValueString(), plan.values())
+	if err != nil {
+		resp.Diagnostics.AddError("Error updating the exclusive lock check", err.Error())
+		return
+	}
 
-	return nil
+	resp.Diagnostics.Append(resp.State.Set(ctx, plan)...)
 }
 
-func buildExclusiveLockValuesFromSchema(d *schema.ResourceData) model.ExclusiveLockValues {
-	timeout := d.Get("timeout").(int)
-	check := model.ExclusiveLockValues{
-		Timeout: int64(timeout),
+func (m checkExclusiveLockModel) values() model.ExclusiveLockValues {
+	return model.ExclusiveLockValues{
+		Timeout: m.Timeout.ValueInt64(),
 	}
-
-	return check
 }
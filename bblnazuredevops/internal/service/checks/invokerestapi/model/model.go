@@ -2,8 +2,8 @@ package model
 
 import (
 	"encoding/json"
+	"fmt"
 	"github.com/babylonhealth/terraform-provider-bblnazuredevops/bblnazuredevops/internal/service/checks/common/model"
-	"log"
 )
 
 type HierarchyResp struct {
@@ -100,7 +100,7 @@ type InvokeRestAPICheckPayload struct {
 	ID       string              `json:"id,omitempty"`
 }
 
-func NewInvokeRestCheckPayload() InvokeRestAPICheckPayload {
+func NewInvokeRestCheckPayload() (InvokeRestAPICheckPayload, error) {
 	jsonPayload := `{
     "type": {
         "id": "fe1de3ee-a436-41b4-bb20-f6eb4cb879a7",
@@ -139,10 +139,10 @@ func NewInvokeRestCheckPayload() InvokeRestAPICheckPayload {
 	err := json.Unmarshal([]byte(jsonPayload), &checkPayload)
 
 	if err != nil {
-		log.Fatal(err)
+		return InvokeRestAPICheckPayload{}, fmt.Errorf("parsing the default check payload: %w", err)
 	}
 
-	return checkPayload
+	return checkPayload, nil
 }
 
 type InvokeRESTAPIValues struct {
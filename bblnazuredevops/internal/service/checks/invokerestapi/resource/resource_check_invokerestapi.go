@@ -4,212 +4,295 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"github.com/babylonhealth/terraform-provider-bblnazuredevops/bblnazuredevops/internal/client"
-	"github.com/babylonhealth/terraform-provider-bblnazuredevops/bblnazuredevops/internal/service/checks/common/resource"
-	"github.com/babylonhealth/terraform-provider-bblnazuredevops/bblnazuredevops/internal/service/checks/invokerestapi/model"
-	"github.com/babylonhealth/terraform-provider-bblnazuredevops/bblnazuredevops/internal/utils/tfhelper"
-	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
-	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
 	"strconv"
+
+	checkresource "github.com/babylonhealth/terraform-provider-bblnazuredevops/bblnazuredevops/internal/service/checks/common/resource"
+	"github.com/babylonhealth/terraform-provider-bblnazuredevops/bblnazuredevops/internal/service/checks/invokerestapi/model"
+	"github.com/babylonhealth/terraform-provider-bblnazuredevops/bblnazuredevops/internal/utils/fwhelper"
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/int64default"
+	"github.com/hashicorp/terraform-plugin-framework/types"
 )
 
-// ResourceServiceEndpointDockerRegistry schema and implementation for docker registry service endpoint resource
-func ResourceCheckInvokeRestAPI() *schema.Resource {
-	r := &schema.Resource{
-		CreateContext: createCheck,
-		ReadContext:   readCheck,
-		UpdateContext: updateCheck,
-		DeleteContext: resource.DeleteCheckContext,
-	}
-	r.Schema = map[string]*schema.Schema{}
-	r.Schema["project_id"] = &schema.Schema{
-		Type:     schema.TypeString,
-		Required: true,
-		ForceNew: true,
-	}
-	r.Schema["resource_id"] = &schema.Schema{
-		Type:     schema.TypeString,
-		Required: true,
-		ForceNew: true,
-	}
-	r.Schema["service_connection_id"] = &schema.Schema{
-		Type:     schema.TypeString,
-		Required: true,
-	}
-	//ToDo: type will be either "endpoint" or "queue"
-	// current implementation defaults to endpoint - service endpoint
-	// queue support will be added in further ticket
-	r.Schema["type"] = &schema.Schema{
-		Type:     schema.TypeString,
-		Required: true,
-	}
+var (
+	_ resource.ResourceWithConfigure    = &checkInvokeRestAPIResource{}
+	_ resource.ResourceWithModifyPlan   = &checkInvokeRestAPIResource{}
+	_ resource.ResourceWithImportState  = &checkInvokeRestAPIResource{}
+	_ resource.ResourceWithUpgradeState = &checkInvokeRestAPIResource{}
+)
 
-	r.Schema["linked_variable_group"] = &schema.Schema{
-		Type:     schema.TypeString,
-		Optional: true,
-	}
-	r.Schema["timeout"] = &schema.Schema{
-		Type:     schema.TypeInt,
-		Required: false,
-		Optional: true,
-	}
-	r.Schema["retry_interval"] = &schema.Schema{
-		Type:     schema.TypeInt,
-		Optional: true,
-	}
-	r.Schema["display_name"] = &schema.Schema{
-		Type:     schema.TypeString,
-		Required: true,
-	}
+type checkInvokeRestAPIResource struct {
+	checkresource.CheckBase
+}
 
-	r.Schema["method"] = &schema.Schema{
-		Type:     schema.TypeString,
-		Required: true,
-	}
-	r.Schema["use_callback"] = &schema.Schema{
-		Type:     schema.TypeBool,
-		Required: true,
-	}
-	r.Schema["body"] = &schema.Schema{
-		Type:     schema.TypeString,
-		Optional: true,
-	}
-	r.Schema["url_suffix"] = &schema.Schema{
-		Type:     schema.TypeString,
-		Optional: true,
-	}
+type checkInvokeRestAPIModel struct {
+	ID                  types.String `tfsdk:"id"`
+	ProjectID           types.String `tfsdk:"project_id"`
+	ResourceID          types.String `tfsdk:"resource_id"`
+	Type                types.String `tfsdk:"type"`
+	Timeout             types.Int64  `tfsdk:"timeout"`
+	ServiceConnectionID types.String `tfsdk:"service_connection_id"`
+	LinkedVariableGroup types.String `tfsdk:"linked_variable_group"`
+	RetryInterval       types.Int64  `tfsdk:"retry_interval"`
+	DisplayName         types.String `tfsdk:"display_name"`
+	Method              types.String `tfsdk:"method"`
+	UseCallback         types.Bool   `tfsdk:"use_callback"`
+	Body                types.String `tfsdk:"body"`
+	URLSuffix           types.String `tfsdk:"url_suffix"`
+	SuccessCriteria     types.String `tfsdk:"success_criteria"`
+	Headers             types.Map    `tfsdk:"headers"`
+}
 
-	r.Schema["success_criteria"] = &schema.Schema{
-		Type:     schema.TypeString,
-		Optional: true,
-	}
-	r.Schema["headers"] = &schema.Schema{
-		Type:     schema.TypeMap,
-		Optional: true,
-	}
+// NewCheckInvokeRestAPIResource schema and implementation for the invoke REST API check resource
+func NewCheckInvokeRestAPIResource() resource.Resource {
+	return &checkInvokeRestAPIResource{}
+}
 
-	r.Importer = tfhelper.ImportProjectQualifiedResourceUUID()
+func (r *checkInvokeRestAPIResource) Metadata(_ context.Context, req resource.MetadataRequest, resp *resource.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_check_invokerestapi"
+}
 
-	return r
+func (r *checkInvokeRestAPIResource) Schema(_ context.Context, _ resource.SchemaRequest, resp *resource.SchemaResponse) {
+	resp.Schema = schema.Schema{
+		Version:     checkresource.SchemaVersion,
+		Description: "Calls a REST API through a service connection before a run can use the protected resource.",
+		Attributes: checkresource.Attributes(map[string]schema.Attribute{
+			"service_connection_id": schema.StringAttribute{
+				Required:    true,
+				Description: "The ID of the generic service connection the REST API is called through.",
+			},
+			"linked_variable_group": schema.StringAttribute{
+				Optional:    true,
+				Description: "The name of the variable group whose variables can be used in the request.",
+			},
+			"retry_interval": schema.Int64Attribute{
+				Optional:    true,
+				Computed:    true,
+				Default:     int64default.StaticInt64(0),
+				Description: "The time in minutes between evaluations of the check, 0 when not set.",
+			},
+			"display_name": schema.StringAttribute{
+				Required:    true,
+				Description: "The name of the check.",
+			},
+			"method": schema.StringAttribute{
+				Required:    true,
+				Description: "The HTTP method of the request.",
+			},
+			"use_callback": schema.BoolAttribute{
+				Required:    true,
+				Description: "Whether the API completes the check through a callback instead of through its response.",
+			},
+			"body": schema.StringAttribute{
+				Optional:    true,
+				Description: "The body of the request.",
+			},
+			"url_suffix": schema.StringAttribute{
+				Optional:    true,
+				Description: "The suffix appended to the url of the service connection.",
+			},
+			"success_criteria": schema.StringAttribute{
+				Optional:    true,
+				Description: "The expression evaluated against the response of the API when use_callback is false.",
+			},
+			"headers": schema.MapAttribute{
+				ElementType: types.StringType,
+				Optional:    true,
+				Description: "The headers of the request.",
+			},
+		}),
+	}
+}
+
+func (r *checkInvokeRestAPIResource) UpgradeState(_ context.Context) map[int64]resource.StateUpgrader {
+	return map[int64]resource.StateUpgrader{
+		0: {
+			PriorSchema: &schema.Schema{
+				Attributes: checkresource.AttributesV0(map[string]schema.Attribute{
+					"service_connection_id": schema.StringAttribute{Required: true},
+					"linked_variable_group": schema.StringAttribute{Optional: true},
+					"retry_interval":        schema.Int64Attribute{Optional: true},
+					"display_name":          schema.StringAttribute{Required: true},
+					"method":                schema.StringAttribute{Required: true},
+					"use_callback":          schema.BoolAttribute{Required: true},
+					"body":                  schema.StringAttribute{Optional: true},
+					"url_suffix":            schema.StringAttribute{Optional: true},
+					"success_criteria":      schema.StringAttribute{Optional: true},
+					"headers":               schema.MapAttribute{ElementType: types.StringType, Optional: true},
+				}),
+			},
+			StateUpgrader: func(ctx context.Context, req resource.UpgradeStateRequest, resp *resource.UpgradeStateResponse) {
+				var state checkInvokeRestAPIModel
+				resp.Diagnostics.Append(req.State.Get(ctx, &state)...)
+				if resp.Diagnostics.HasError() {
+					return
+				}
+				state.LinkedVariableGroup = fwhelper.StringOrNull(state.LinkedVariableGroup.ValueString())
+				state.Body = fwhelper.StringOrNull(state.Body.ValueString())
+				state.URLSuffix = fwhelper.StringOrNull(state.URLSuffix.ValueString())
+				state.SuccessCriteria = fwhelper.StringOrNull(state.SuccessCriteria.ValueString())
+				if len(state.Headers.Elements()) == 0 {
+					state.Headers = types.MapNull(types.StringType)
+				}
+				resp.Diagnostics.Append(resp.State.Set(ctx, state)...)
+			},
+		},
+	}
 }
 
 // See Resource documentation.
-func createCheck(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
-	clients := m.(*client.AggregatedClient)
+func (r *checkInvokeRestAPIResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
+	var plan checkInvokeRestAPIModel
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &plan)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
 
-	projectID := d.Get("project_id").(string)
-	resourceID := d.Get("resource_id").(string)
+	projectID, err := r.ProjectID(plan.ProjectID)
+	if err != nil {
+		resp.Diagnostics.AddError("Error resolving the project", err.Error())
+		return
+	}
 
-	check := buildInvokeRESTAPIValuesFromSchema(d)
+	values, diags := plan.values(ctx)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
 
-	resp, err := clients.InvokeCheckClient.AddInvokeRestAPICheck(ctx, projectID, resourceID, check)
+	check, err := r.Clients.InvokeCheckClient.AddInvokeRestAPICheck(ctx, projectID, plan.ResourceID.ValueString(), values)
 	if err != nil {
-		return diag.FromErr(err)
+		resp.Diagnostics.AddError("Error creating the invoke REST API check", err.Error())
+		return
 	}
 
-	id := resp.ID
-
-	d.SetId(fmt.Sprintf("%v", id))
+	plan.ID = types.StringValue(strconv.FormatInt(check.ID, 10))
 
-	return nil
+	resp.Diagnostics.Append(resp.State.Set(ctx, plan)...)
 }
 
 // See Resource documentation.
-func readCheck(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
-	clients := m.(*client.AggregatedClient)
-
-	projectId := d.Get("project_id").(string)
-	resourceId := d.Get("resource_id").(string)
-	id := d.Id()
-
-	idInt, err := strconv.ParseInt(id, 10, 0)
-	if err != nil {
-		return diag.FromErr(err)
+func (r *checkInvokeRestAPIResource) Read(ctx context.Context, req resource.ReadRequest, resp *resource.ReadResponse) {
+	var state checkInvokeRestAPIModel
+	resp.Diagnostics.Append(req.State.Get(ctx, &state)...)
+	if resp.Diagnostics.HasError() {
+		return
 	}
 
-	checkConfig, found, err := clients.InvokeCheckClient.GetInvokeRestAPICheckByID(ctx, projectId, resourceId, idInt)
+	projectID, err := r.ProjectID(state.ProjectID)
 	if err != nil {
-		return diag.FromErr(err)
+		resp.Diagnostics.AddError("Error resolving the project", err.Error())
+		return
 	}
+	// the state holds the ID of the project, which ModifyPlan keeps for a configuration naming the same project
+	state.ProjectID = types.StringValue(projectID)
 
-	if !found {
-		d.SetId("")
-		return nil
+	checkID, err := checkresource.CheckID(state.ID)
+	if err != nil {
+		resp.Diagnostics.AddError("Error parsing the check ID", err.Error())
+		return
 	}
 
-	useCallback, err := strconv.ParseBool(checkConfig.CheckConfiguration.Settings.Inputs.WaitForCompletion)
+	checkConfig, found, err := r.Clients.InvokeCheckClient.GetInvokeRestAPICheckByID(ctx, projectID, state.ResourceID.ValueString(), checkID)
 	if err != nil {
-		return diag.FromErr(err)
+		resp.Diagnostics.AddError("Error reading the invoke REST API check", err.Error())
+		return
 	}
 
-	d.Set("timeout", checkConfig.CheckConfiguration.Timeout)
-	d.Set("retry_interval", checkConfig.CheckConfiguration.Settings.RetryInterval)
-	d.Set("linked_variable_group", checkConfig.CheckConfiguration.Settings.LinkedVariableGroup)
+	if !found {
+		resp.State.RemoveResource(ctx)
+		return
+	}
 
-	d.Set("display_name", checkConfig.CheckConfiguration.Settings.DisplayName)
-	d.Set("method", checkConfig.CheckConfiguration.Settings.Inputs.Method)
-	d.Set("use_callback", useCallback)
-	d.Set("body", checkConfig.CheckConfiguration.Settings.Inputs.Body)
-	d.Set("url_suffix", checkConfig.CheckConfiguration.Settings.Inputs.URLSuffix)
-	d.Set("success_criteria", checkConfig.CheckConfiguration.Settings.Inputs.SuccessCriteria)
+	settings := checkConfig.CheckConfiguration.Settings
 
-	headersMap := map[string]interface{}{}
-	err = json.Unmarshal([]byte(checkConfig.CheckConfiguration.Settings.Inputs.Headers), &headersMap)
+	useCallback, err := strconv.ParseBool(settings.Inputs.WaitForCompletion)
 	if err != nil {
-		return diag.FromErr(err)
+		resp.Diagnostics.AddError("Error parsing the waitForCompletion input of the check", err.Error())
+		return
 	}
 
-	d.Set("headers", headersMap)
+	headers := map[string]interface{}{}
+	if err := json.Unmarshal([]byte(settings.Inputs.Headers), &headers); err != nil {
+		resp.Diagnostics.AddError("Error parsing the headers of the check", err.Error())
+		return
+	}
+	// keep an empty map configured by the user
+	if len(headers) > 0 || !state.Headers.IsNull() {
+		headerValues := map[string]string{}
+		for k, v := range headers {
+			headerValues[k] = fmt.Sprintf("%v", v)
+		}
+		headersMap, diags := types.MapValueFrom(ctx, types.StringType, headerValues)
+		resp.Diagnostics.Append(diags...)
+		if resp.Diagnostics.HasError() {
+			return
+		}
+		state.Headers = headersMap
+	}
 
-	return diag.FromErr(err)
+	state.Timeout = types.Int64Value(checkConfig.CheckConfiguration.Timeout)
+	state.RetryInterval = types.Int64Value(settings.RetryInterval)
+	state.LinkedVariableGroup = fwhelper.OptionalString(state.LinkedVariableGroup, settings.LinkedVariableGroup)
+	state.DisplayName = types.StringValue(settings.DisplayName)
+	state.Method = types.StringValue(settings.Inputs.Method)
+	state.UseCallback = types.BoolValue(useCallback)
+	state.Body = fwhelper.OptionalString(state.Body, settings.Inputs.Body)
+	state.URLSuffix = fwhelper.OptionalString(state.URLSuffix, settings.Inputs.URLSuffix)
+	state.SuccessCriteria = fwhelper.OptionalString(state.SuccessCriteria, settings.Inputs.SuccessCriteria)
+
+	resp.Diagnostics.Append(resp.State.Set(ctx, state)...)
 }
 
 // See Resource documentation.
-func updateCheck(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
-	clients := m.(*client.AggregatedClient)
+func (r *checkInvokeRestAPIResource) Update(ctx context.Context, req resource.UpdateRequest, resp *resource.UpdateResponse) {
+	var plan checkInvokeRestAPIModel
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &plan)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
 
-	projectID := d.Get("project_id").(string)
-	resourceID := d.Get("resource_id").(string)
+	projectID, err := r.ProjectID(plan.ProjectID)
+	if err != nil {
+		resp.Diagnostics.AddError("Error resolving the project", err.Error())
+		return
+	}
 
-	check := buildInvokeRESTAPIValuesFromSchema(d)
+	values, diags := plan.values(ctx)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
 
-	_, err := clients.InvokeCheckClient.UpdateCheck(ctx, projectID, resourceID, d.Id(), check)
+	_, err = r.Clients.InvokeCheckClient.UpdateCheck(ctx, projectID, plan.ResourceID.ValueString(), plan.ID.ValueString(), values)
 	if err != nil {
-		return diag.FromErr(err)
+		resp.Diagnostics.AddError("Error updating the invoke REST API check", err.Error())
+		return
 	}
 
-	//update ?
-	d.SetId(d.Id())
-
-	return nil
+	resp.Diagnostics.Append(resp.State.Set(ctx, plan)...)
 }
 
-func buildInvokeRESTAPIValuesFromSchema(d *schema.ResourceData) model.InvokeRESTAPIValues {
-	timeout := d.Get("timeout").(int)
-	retryInterval := d.Get("retry_interval").(int)
-
-	headersFromSchema := d.Get("headers").(map[string]interface{})
-
-	headersMap := map[string]string{}
-
-	for k, v := range headersFromSchema {
-		s := fmt.Sprintf("%s", v)
-		headersMap[k] = s
-	}
-
-	check := model.InvokeRESTAPIValues{
-		ServiceConnectionId: d.Get("service_connection_id").(string),
-		LinkedVariableGroup: d.Get("linked_variable_group").(string),
-		Timeout:             int64(timeout),
-		RetryInterval:       int64(retryInterval),
-		DisplayName:         d.Get("display_name").(string),
-		Method:              d.Get("method").(string),
-		UseCallback:         d.Get("use_callback").(bool),
-		Body:                d.Get("body").(string),
-		UrlSuffix:           d.Get("url_suffix").(string),
-		SuccessCriteria:     d.Get("success_criteria").(string),
-		Headers:             headersMap,
+func (m checkInvokeRestAPIModel) values(ctx context.Context) (model.InvokeRESTAPIValues, diag.Diagnostics) {
+	headers := map[string]string{}
+	var diags diag.Diagnostics
+	if !m.Headers.IsNull() {
+		diags = m.Headers.ElementsAs(ctx, &headers, false)
 	}
 
-	return check
+	return model.InvokeRESTAPIValues{
+		ServiceConnectionId: m.ServiceConnectionID.ValueString(),
+		LinkedVariableGroup: m.LinkedVariableGroup.ValueString(),
+		Timeout:             m.Timeout.ValueInt64(),
+		RetryInterval:       m.RetryInterval.ValueInt64(),
+		DisplayName:         m.DisplayName.ValueString(),
+		Method:              m.Method.ValueString(),
+		UseCallback:         m.UseCallback.ValueBool(),
+		Body:                m.Body.ValueString(),
+		UrlSuffix:           m.URLSuffix.ValueString(),
+		SuccessCriteria:     m.SuccessCriteria.ValueString(),
+		Headers:             headers,
+	}, diags
 }
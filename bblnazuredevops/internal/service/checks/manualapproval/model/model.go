@@ -2,8 +2,8 @@ package model
 
 import (
 	"encoding/json"
+	"fmt"
 	"github.com/babylonhealth/terraform-provider-bblnazuredevops/bblnazuredevops/internal/service/checks/common/model"
-	"github.com/sirupsen/logrus"
 )
 
 type CheckConfigurationData struct {
@@ -100,7 +100,7 @@ type ManualApprovalCheckPayload struct {
 	ID       string              `json:"id,omitempty"`
 }
 
-func NewManualApprovalCheckPayload() ManualApprovalCheckPayload {
+func NewManualApprovalCheckPayload() (ManualApprovalCheckPayload, error) {
 	jsonPayload := `{
     "type": {
         "id": "8C6F20A7-A545-4486-9777-F762FAFE0D4D",
@@ -127,8 +127,8 @@ func NewManualApprovalCheckPayload() ManualApprovalCheckPayload {
 	err := json.Unmarshal([]byte(jsonPayload), &checkPayload)
 
 	if err != nil {
-		logrus.Fatal(err)
+		return ManualApprovalCheckPayload{}, fmt.Errorf("parsing the default check payload: %w", err)
 	}
 
-	return checkPayload
+	return checkPayload, nil
 }
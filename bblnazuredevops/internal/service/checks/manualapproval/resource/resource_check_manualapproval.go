@@ -2,196 +2,245 @@ package resource
 
 import (
 	"context"
-	"fmt"
-	"github.com/babylonhealth/terraform-provider-bblnazuredevops/bblnazuredevops/internal/client"
-	"github.com/babylonhealth/terraform-provider-bblnazuredevops/bblnazuredevops/internal/service/checks/common/resource"
-	"github.com/babylonhealth/terraform-provider-bblnazuredevops/bblnazuredevops/internal/service/checks/manualapproval/model"
-	"github.com/babylonhealth/terraform-provider-bblnazuredevops/bblnazuredevops/internal/utils/tfhelper"
-	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
-	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
-	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
 	"strconv"
+
+	checkresource "github.com/babylonhealth/terraform-provider-bblnazuredevops/bblnazuredevops/internal/service/checks/common/resource"
+	"github.com/babylonhealth/terraform-provider-bblnazuredevops/bblnazuredevops/internal/service/checks/manualapproval/model"
+	"github.com/babylonhealth/terraform-provider-bblnazuredevops/bblnazuredevops/internal/utils/fwhelper"
+	"github.com/hashicorp/terraform-plugin-framework-validators/listvalidator"
+	"github.com/hashicorp/terraform-plugin-framework-validators/stringvalidator"
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/booldefault"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/int64default"
+	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
+	"github.com/hashicorp/terraform-plugin-framework/types"
 )
 
-// ResourceServiceEndpointDockerRegistry schema and implementation for docker registry service endpoint resource
-func ResourceCheckManualApproval() *schema.Resource {
-	r := &schema.Resource{
-		CreateContext: createCheck,
-		ReadContext:   readCheck,
-		UpdateContext: updateCheck,
-		DeleteContext: resource.DeleteCheckContext,
-	}
-	r.Schema = map[string]*schema.Schema{}
-	r.Schema["project_id"] = &schema.Schema{
-		Type:     schema.TypeString,
-		Required: true,
-		ForceNew: true,
-	}
-	r.Schema["resource_id"] = &schema.Schema{
-		Type:     schema.TypeString,
-		Required: true,
-		ForceNew: true,
-	}
-
-	//ToDo: type will be either "endpoint" or "queue"
-	// current implementation defaults to endpoint - service endpoint
-	// queue support will be added in further ticket
-	r.Schema["type"] = &schema.Schema{
-		Type:     schema.TypeString,
-		Required: true,
-	}
-
-	r.Schema["timeout"] = &schema.Schema{
-		Type:     schema.TypeInt,
-		Required: false,
-		Optional: true,
-	}
-
-	r.Schema["approvers"] = &schema.Schema{
-		Type:     schema.TypeList,
-		Required: true,
-		Elem: &schema.Schema{
-			Type:         schema.TypeString,
-			ValidateFunc: validation.NoZeroValues,
-		},
-	}
-	r.Schema["allow_self_approve"] = &schema.Schema{
-		Type:     schema.TypeBool,
-		Required: true,
-	}
-	r.Schema["approve_in_order"] = &schema.Schema{
-		Type:     schema.TypeBool,
-		Required: false,
-		Optional: true,
-		Default:  false,
-	}
-	r.Schema["minimum_approvers"] = &schema.Schema{
-		Type:     schema.TypeInt,
-		Required: false,
-		Optional: true,
-		Default:  0,
-	}
+var (
+	_ resource.ResourceWithConfigure    = &checkManualApprovalResource{}
+	_ resource.ResourceWithModifyPlan   = &checkManualApprovalResource{}
+	_ resource.ResourceWithImportState  = &checkManualApprovalResource{}
+	_ resource.ResourceWithUpgradeState = &checkManualApprovalResource{}
+)
 
-	r.Schema["instructions"] = &schema.Schema{
-		Type:     schema.TypeString,
-		Optional: true,
-	}
+// executionOrderInSequence is the execution order of checks whose approvers must approve in order
+const executionOrderInSequence = 2
 
-	r.Importer = tfhelper.ImportProjectQualifiedResourceUUID()
+type checkManualApprovalResource struct {
+	checkresource.CheckBase
+}
 
-	return r
+type checkManualApprovalModel struct {
+	ID               types.String `tfsdk:"id"`
+	ProjectID        types.String `tfsdk:"project_id"`
+	ResourceID       types.String `tfsdk:"resource_id"`
+	Type             types.String `tfsdk:"type"`
+	Timeout          types.Int64  `tfsdk:"timeout"`
+	Approvers        types.List   `tfsdk:"approvers"`
+	AllowSelfApprove types.Bool   `tfsdk:"allow_self_approve"`
+	ApproveInOrder   types.Bool   `tfsdk:"approve_in_order"`
+	MinimumApprovers types.Int64  `tfsdk:"minimum_approvers"`
+	Instructions     types.String `tfsdk:"instructions"`
 }
 
-// See Resource documentation.
-func createCheck(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
-	clients := m.(*client.AggregatedClient)
+// NewCheckManualApprovalResource schema and implementation for the manual approval check resource
+func NewCheckManualApprovalResource() resource.Resource {
+	return &checkManualApprovalResource{}
+}
 
-	projectID := d.Get("project_id").(string)
-	resourceID := d.Get("resource_id").(string)
+func (r *checkManualApprovalResource) Metadata(_ context.Context, req resource.MetadataRequest, resp *resource.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_check_manualapproval"
+}
+
+func (r *checkManualApprovalResource) Schema(_ context.Context, _ resource.SchemaRequest, resp *resource.SchemaResponse) {
+	resp.Schema = schema.Schema{
+		Version:     checkresource.SchemaVersion,
+		Description: "Requires approvals before a run can use the protected resource.",
+		Attributes: checkresource.Attributes(map[string]schema.Attribute{
+			"approvers": schema.ListAttribute{
+				ElementType: types.StringType,
+				Required:    true,
+				Validators: []validator.List{
+					listvalidator.SizeAtLeast(1),
+					listvalidator.ValueStringsAre(stringvalidator.LengthAtLeast(1)),
+				},
+				Description: "The IDs of the users and groups which can approve.",
+			},
+			"allow_self_approve": schema.BoolAttribute{
+				Required:    true,
+				Description: "Whether the requester of a run can approve it.",
+			},
+			"approve_in_order": schema.BoolAttribute{
+				Optional:    true,
+				Computed:    true,
+				Default:     booldefault.StaticBool(false),
+				Description: "Whether the approvers must approve in the order they are listed.",
+			},
+			"minimum_approvers": schema.Int64Attribute{
+				Optional:    true,
+				Computed:    true,
+				Default:     int64default.StaticInt64(0),
+				Description: "The minimum number of approvers which must approve, 0 requires all of them.",
+			},
+			"instructions": schema.StringAttribute{
+				Optional:    true,
+				Description: "The instructions shown to the approvers.",
+			},
+		}),
+	}
+}
+
+func (r *checkManualApprovalResource) UpgradeState(_ context.Context) map[int64]resource.StateUpgrader {
+	return map[int64]resource.StateUpgrader{
+		0: {
+			PriorSchema: &schema.Schema{
+				Attributes: checkresource.AttributesV0(map[string]schema.Attribute{
+					"approvers":          schema.ListAttribute{ElementType: types.StringType, Required: true},
+					"allow_self_approve": schema.BoolAttribute{Required: true},
+					"approve_in_order":   schema.BoolAttribute{Optional: true},
+					"minimum_approvers":  schema.Int64Attribute{Optional: true},
+					"instructions":       schema.StringAttribute{Optional: true},
+				}),
+			},
+			StateUpgrader: func(ctx context.Context, req resource.UpgradeStateRequest, resp *resource.UpgradeStateResponse) {
+				var state checkManualApprovalModel
+				resp.Diagnostics.Append(req.State.Get(ctx, &state)...)
+				if resp.Diagnostics.HasError() {
+					return
+				}
+				state.Instructions = fwhelper.StringOrNull(state.Instructions.ValueString())
+				resp.Diagnostics.Append(resp.State.Set(ctx, state)...)
+			},
+		},
+	}
+}
 
-	check := buildManualApprovalValuesFromSchema(d)
+// See Resource documentation.
+func (r *checkManualApprovalResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
+	var plan checkManualApprovalModel
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &plan)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
 
-	resp, err := clients.ManualApprovalCheckClient.AddManualApprovalCheck(ctx, projectID, resourceID, check)
+	projectID, err := r.ProjectID(plan.ProjectID)
 	if err != nil {
-		return diag.FromErr(err)
+		resp.Diagnostics.AddError("Error resolving the project", err.Error())
+		return
 	}
 
-	id := resp.ID
+	values, diags := plan.values(ctx)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
 
-	d.SetId(fmt.Sprintf("%v", id))
+	check, err := r.Clients.ManualApprovalCheckClient.AddManualApprovalCheck(ctx, projectID, plan.ResourceID.ValueString(), values)
+	if err != nil {
+		resp.Diagnostics.AddError("Error creating the manual approval check", err.Error())
+		return
+	}
+
+	plan.ID = types.StringValue(strconv.FormatInt(check.ID, 10))
 
-	return nil
+	resp.Diagnostics.Append(resp.State.Set(ctx, plan)...)
 }
 
 // See Resource documentation.
-func readCheck(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
-	clients := m.(*client.AggregatedClient)
-
-	projectID := d.Get("project_id").(string)
-	resourceID := d.Get("resource_id").(string)
+func (r *checkManualApprovalResource) Read(ctx context.Context, req resource.ReadRequest, resp *resource.ReadResponse) {
+	var state checkManualApprovalModel
+	resp.Diagnostics.Append(req.State.Get(ctx, &state)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
 
-	checkId := d.Id()
+	projectID, err := r.ProjectID(state.ProjectID)
+	if err != nil {
+		resp.Diagnostics.AddError("Error resolving the project", err.Error())
+		return
+	}
+	// the state holds the ID of the project, which ModifyPlan keeps for a configuration naming the same project
+	state.ProjectID = types.StringValue(projectID)
 
-	idInt, err := strconv.ParseInt(checkId, 10, 0)
+	checkID, err := checkresource.CheckID(state.ID)
 	if err != nil {
-		return diag.FromErr(err)
+		resp.Diagnostics.AddError("Error parsing the check ID", err.Error())
+		return
 	}
 
-	checkConfig, found, err := clients.ManualApprovalCheckClient.GetManualApprovalCheckByID(ctx, projectID, resourceID, idInt)
+	checkConfig, found, err := r.Clients.ManualApprovalCheckClient.GetManualApprovalCheckByID(ctx, projectID, state.ResourceID.ValueString(), checkID)
 	if err != nil {
-		return diag.FromErr(err)
+		resp.Diagnostics.AddError("Error reading the manual approval check", err.Error())
+		return
 	}
 
 	if !found {
-		d.SetId("")
-		return nil
+		resp.State.RemoveResource(ctx)
+		return
 	}
 
-	d.Set("timeout", checkConfig.Timeout)
-	d.Set("allow_self_approve", !checkConfig.Settings.RequesterCannotBeApprover)
-	d.Set("instructions", checkConfig.Settings.Instructions)
-
 	approvers := []string{}
-
 	for _, approver := range checkConfig.Settings.Approvers {
 		approvers = append(approvers, approver.ID)
 	}
-
-	d.Set("approvers", approvers)
-
-	d.Set("minimum_approvers", checkConfig.Settings.MinRequiredApprovers)
-
-	approveInOrder := false
-	if checkConfig.Settings.ExecutionOrder == 2 {
-		approveInOrder = true
+	approversList, diags := types.ListValueFrom(ctx, types.StringType, approvers)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
 	}
 
-	d.Set("approve_in_order", approveInOrder)
+	state.Timeout = types.Int64Value(checkConfig.Timeout)
+	state.Approvers = approversList
+	//Allow self approve is logical opposite of cannot request and approve.
+	state.AllowSelfApprove = types.BoolValue(!checkConfig.Settings.RequesterCannotBeApprover)
+	state.ApproveInOrder = types.BoolValue(checkConfig.Settings.ExecutionOrder == executionOrderInSequence)
+	state.MinimumApprovers = types.Int64Value(checkConfig.Settings.MinRequiredApprovers)
+	state.Instructions = fwhelper.OptionalString(state.Instructions, checkConfig.Settings.Instructions)
 
-	return diag.FromErr(err)
+	resp.Diagnostics.Append(resp.State.Set(ctx, state)...)
 }
 
 // See Resource documentation.
-func updateCheck(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
-	clients := m.(*client.AggregatedClient)
-
-	projectID := d.Get("project_id").(string)
-	resourceID := d.Get("resource_id").(string)
-
-	check := buildManualApprovalValuesFromSchema(d)
+func (r *checkManualApprovalResource) Update(ctx context.Context, req resource.UpdateRequest, resp *resource.UpdateResponse) {
+	var plan checkManualApprovalModel
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &plan)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
 
-	_, err := clients.ManualApprovalCheckClient.UpdateManualApprovalCheck(ctx, projectID, resourceID, d.Id(), check)
+	projectID, err := r.ProjectID(plan.ProjectID)
 	if err != nil {
-		return diag.FromErr(err)
+		resp.Diagnostics.AddError("Error resolving the project", err.Error())
+		return
 	}
 
-	//update ?
-	d.SetId(d.Id())
-
-	return nil
-}
-
-func buildManualApprovalValuesFromSchema(d *schema.ResourceData) model.ManualApprovalValues {
-	timeout := d.Get("timeout").(int)
-	minimumApprovers := d.Get("minimum_approvers").(int)
-
-	approversFromSchema := d.Get("approvers").([]interface{})
-
-	approversList := []string{}
-
-	for _, v := range approversFromSchema {
-		s := fmt.Sprintf("%s", v)
-		approversList = append(approversList, s)
+	values, diags := plan.values(ctx)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
 	}
 
-	check := model.ManualApprovalValues{
-		Approvers:         approversList,
-		Timeout:           int64(timeout),
-		AllowSelfApproval: d.Get("allow_self_approve").(bool),
-		Instructions:      d.Get("instructions").(string),
-		MinimumApprovers:  int64(minimumApprovers),
-		ApproveInOrder:    d.Get("approve_in_order").(bool),
+	_, err = r.Clients.ManualApprovalCheckClient.UpdateManualApprovalCheck(ctx, projectID, plan.ResourceID.ValueString(), plan.ID.ValueString(), values)
+	if err != nil {
+		resp.Diagnostics.AddError("Error updating the manual approval check", err.Error())
+		return
 	}
 
-	return check
+	resp.Diagnostics.Append(resp.State.Set(ctx, plan)...)
+}
+
+func (m checkManualApprovalModel) values(ctx context.Context) (model.ManualApprovalValues, diag.Diagnostics) {
+	approvers := []string{}
+	diags := m.Approvers.ElementsAs(ctx, &approvers, false)
+
+	return model.ManualApprovalValues{
+		Approvers:         approvers,
+		Timeout:           m.Timeout.ValueInt64(),
+		AllowSelfApproval: m.AllowSelfApprove.ValueBool(),
+		Instructions:      m.Instructions.ValueString(),
+		MinimumApprovers:  m.MinimumApprovers.ValueInt64(),
+		ApproveInOrder:    m.ApproveInOrder.ValueBool(),
+	}, diags
 }
@@ -0,0 +1,451 @@
+package environment
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+
+	"github.com/babylonhealth/terraform-provider-bblnazuredevops/bblnazuredevops/internal/client"
+	"github.com/babylonhealth/terraform-provider-bblnazuredevops/bblnazuredevops/internal/client/apiversion"
+	"github.com/babylonhealth/terraform-provider-bblnazuredevops/bblnazuredevops/internal/utils"
+	"github.com/babylonhealth/terraform-provider-bblnazuredevops/bblnazuredevops/internal/utils/converter"
+	"github.com/babylonhealth/terraform-provider-bblnazuredevops/bblnazuredevops/internal/utils/tfhelper"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+	"github.com/microsoft/azure-devops-go-api/azuredevops/v6/taskagent"
+)
+
+// ResourceEnvironment schema and implementation for the environments of YAML pipelines, with their Kubernetes
+// resources and the tags of their virtual machine resources. Virtual machines register themselves in an
+// environment, only their tags are managed.
+func ResourceEnvironment() *schema.Resource {
+	return &schema.Resource{
+		Create:        resourceEnvironmentCreate,
+		Read:          resourceEnvironmentRead,
+		Update:        resourceEnvironmentUpdate,
+		Delete:        resourceEnvironmentDelete,
+		CustomizeDiff: tfhelper.CustomizeDiffProjectID,
+		Importer: &schema.ResourceImporter{
+			State: importEnvironment,
+		},
+		Schema: map[string]*schema.Schema{
+			"project_id": tfhelper.ProjectIDSchema(),
+			"name": {
+				Type:         schema.TypeString,
+				Required:     true,
+				ValidateFunc: validation.StringIsNotWhiteSpace,
+				Description:  "The name of the environment.",
+			},
+			"description": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Description: "The description of the environment.",
+			},
+			"kubernetes_resource": {
+				Type:     schema.TypeSet,
+				Optional: true,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"id": {
+							Type:        schema.TypeInt,
+							Computed:    true,
+							Description: "The ID of the Kubernetes resource in the environment.",
+						},
+						"name": {
+							Type:         schema.TypeString,
+							Required:     true,
+							ValidateFunc: validation.StringIsNotWhiteSpace,
+							Description:  "The name of the Kubernetes resource.",
+						},
+						"namespace": {
+							Type:         schema.TypeString,
+							Required:     true,
+							ValidateFunc: validation.StringIsNotWhiteSpace,
+							Description:  "The namespace of the cluster the pipelines deploy to.",
+						},
+						"cluster_name": {
+							Type:        schema.TypeString,
+							Optional:    true,
+							Description: "The name of the cluster.",
+						},
+						"service_endpoint_id": {
+							Type:         schema.TypeString,
+							Required:     true,
+							ValidateFunc: validation.IsUUID,
+							Description:  "The ID of the Kubernetes service endpoint the pipelines connect to the cluster with.",
+						},
+						"tags": {
+							Type:     schema.TypeSet,
+							Optional: true,
+							Elem: &schema.Schema{
+								Type:         schema.TypeString,
+								ValidateFunc: validation.StringIsNotWhiteSpace,
+							},
+							Description: "The tags of the Kubernetes resource.",
+						},
+					},
+				},
+				Description: "The Kubernetes namespaces of the environment. Kubernetes resources cannot be updated, a changed resource is replaced.",
+			},
+			"virtual_machine": {
+				Type:     schema.TypeSet,
+				Optional: true,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"resource_id": {
+							Type:         schema.TypeInt,
+							Required:     true,
+							ValidateFunc: validation.IntAtLeast(1),
+							Description:  "The ID of the virtual machine resource registered in the environment.",
+						},
+						"tags": {
+							Type:     schema.TypeSet,
+							Optional: true,
+							Elem: &schema.Schema{
+								Type:         schema.TypeString,
+								ValidateFunc: validation.StringIsNotWhiteSpace,
+							},
+							Description: "The tags of the virtual machine.",
+						},
+					},
+				},
+				Description: "The tags of the virtual machines registered in the environment. The tags of the virtual machines removed from the configuration are cleared.",
+			},
+		},
+	}
+}
+
+func resourceEnvironmentCreate(d *schema.ResourceData, m interface{}) error {
+	clients := m.(*client.AggregatedClient)
+
+	projectID, err := tfhelper.ResolveProjectID(d, m)
+	if err != nil {
+		return err
+	}
+
+	environment, err := clients.TaskAgentClient.AddEnvironment(clients.Ctx, taskagent.AddEnvironmentArgs{
+		Project: converter.String(projectID),
+		EnvironmentCreateParameter: &taskagent.EnvironmentCreateParameter{
+			Name:        converter.String(d.Get("name").(string)),
+			Description: converter.String(d.Get("description").(string)),
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("error creating environment %s: %+v", d.Get("name").(string), err)
+	}
+	d.SetId(strconv.Itoa(*environment.Id))
+
+	for _, resource := range d.Get("kubernetes_resource").(*schema.Set).List() {
+		if err := addKubernetesResource(clients, projectID, *environment.Id, resource.(map[string]interface{})); err != nil {
+			return err
+		}
+	}
+	for _, machine := range d.Get("virtual_machine").(*schema.Set).List() {
+		if err := updateVirtualMachineTags(clients, projectID, *environment.Id, machine.(map[string]interface{})); err != nil {
+			return err
+		}
+	}
+	return resourceEnvironmentRead(d, m)
+}
+
+func resourceEnvironmentRead(d *schema.ResourceData, m interface{}) error {
+	clients := m.(*client.AggregatedClient)
+
+	projectID, environmentID, err := tfhelper.ParseProjectIDAndResourceID(d)
+	if err != nil {
+		return fmt.Errorf("error parsing the environment ID %s: %+v", d.Id(), err)
+	}
+
+	expands := taskagent.EnvironmentExpandsValues.ResourceReferences
+	environment, err := clients.TaskAgentClient.GetEnvironmentById(clients.Ctx, taskagent.GetEnvironmentByIdArgs{
+		Project:       converter.String(projectID),
+		EnvironmentId: converter.Int(environmentID),
+		Expands:       &expands,
+	})
+	if err != nil {
+		if utils.ResponseWasNotFound(err) {
+			d.SetId("")
+			return nil
+		}
+		return fmt.Errorf("error reading environment %d: %+v", environmentID, err)
+	}
+	if environment == nil || environment.Id == nil {
+		d.SetId("")
+		return nil
+	}
+
+	d.Set("name", converter.ToString(environment.Name, ""))
+	d.Set("description", converter.ToString(environment.Description, ""))
+
+	// only the virtual machines of the configuration are read, others register themselves in the environment
+	managedMachines := map[int]bool{}
+	for _, machine := range d.Get("virtual_machine").(*schema.Set).List() {
+		managedMachines[machine.(map[string]interface{})["resource_id"].(int)] = true
+	}
+
+	kubernetesResources := []interface{}{}
+	machines := []interface{}{}
+	if environment.Resources != nil {
+		for _, reference := range *environment.Resources {
+			if reference.Id == nil || reference.Type == nil {
+				continue
+			}
+			switch *reference.Type {
+			case taskagent.EnvironmentResourceTypeValues.Kubernetes:
+				resource, err := clients.TaskAgentClient.GetKubernetesResource(clients.Ctx, taskagent.GetKubernetesResourceArgs{
+					Project:       converter.String(projectID),
+					EnvironmentId: converter.Int(environmentID),
+					ResourceId:    reference.Id,
+				})
+				if err != nil {
+					return fmt.Errorf("error reading Kubernetes resource %d of environment %d: %+v", *reference.Id, environmentID, err)
+				}
+				kubernetesResources = append(kubernetesResources, flattenKubernetesResource(resource))
+			case taskagent.EnvironmentResourceTypeValues.VirtualMachine:
+				if managedMachines[*reference.Id] {
+					machines = append(machines, map[string]interface{}{
+						"resource_id": *reference.Id,
+						"tags":        flattenTags(reference.Tags),
+					})
+				}
+			}
+		}
+	}
+	if err := d.Set("kubernetes_resource", kubernetesResources); err != nil {
+		return err
+	}
+	return d.Set("virtual_machine", machines)
+}
+
+func resourceEnvironmentUpdate(d *schema.ResourceData, m interface{}) error {
+	clients := m.(*client.AggregatedClient)
+
+	projectID, environmentID, err := tfhelper.ParseProjectIDAndResourceID(d)
+	if err != nil {
+		return fmt.Errorf("error parsing the environment ID %s: %+v", d.Id(), err)
+	}
+
+	if d.HasChanges("name", "description") {
+		_, err := clients.TaskAgentClient.UpdateEnvironment(clients.Ctx, taskagent.UpdateEnvironmentArgs{
+			Project:       converter.String(projectID),
+			EnvironmentId: converter.Int(environmentID),
+			EnvironmentUpdateParameter: &taskagent.EnvironmentUpdateParameter{
+				Name:        converter.String(d.Get("name").(string)),
+				Description: converter.String(d.Get("description").(string)),
+			},
+		})
+		if err != nil {
+			return fmt.Errorf("error updating environment %d: %+v", environmentID, err)
+		}
+	}
+
+	if d.HasChange("kubernetes_resource") {
+		// Kubernetes resources cannot be updated, the changed ones are deleted and added again
+		oldResources, newResources := d.GetChange("kubernetes_resource")
+		for _, resource := range oldResources.(*schema.Set).Difference(newResources.(*schema.Set)).List() {
+			if err := deleteKubernetesResource(clients, projectID, environmentID, resource.(map[string]interface{})["id"].(int)); err != nil {
+				return err
+			}
+		}
+		for _, resource := range newResources.(*schema.Set).Difference(oldResources.(*schema.Set)).List() {
+			if err := addKubernetesResource(clients, projectID, environmentID, resource.(map[string]interface{})); err != nil {
+				return err
+			}
+		}
+	}
+
+	if d.HasChange("virtual_machine") {
+		oldMachines, newMachines := d.GetChange("virtual_machine")
+		managed := map[int]bool{}
+		for _, machine := range newMachines.(*schema.Set).List() {
+			managed[machine.(map[string]interface{})["resource_id"].(int)] = true
+		}
+		for _, machine := range oldMachines.(*schema.Set).List() {
+			resourceID := machine.(map[string]interface{})["resource_id"].(int)
+			if managed[resourceID] {
+				continue
+			}
+			err := updateVirtualMachineTags(clients, projectID, environmentID, map[string]interface{}{
+				"resource_id": resourceID,
+				"tags":        schema.NewSet(schema.HashString, nil),
+			})
+			if err != nil {
+				return err
+			}
+		}
+		for _, machine := range newMachines.(*schema.Set).Difference(oldMachines.(*schema.Set)).List() {
+			if err := updateVirtualMachineTags(clients, projectID, environmentID, machine.(map[string]interface{})); err != nil {
+				return err
+			}
+		}
+	}
+	return resourceEnvironmentRead(d, m)
+}
+
+func resourceEnvironmentDelete(d *schema.ResourceData, m interface{}) error {
+	clients := m.(*client.AggregatedClient)
+
+	projectID, environmentID, err := tfhelper.ParseProjectIDAndResourceID(d)
+	if err != nil {
+		return fmt.Errorf("error parsing the environment ID %s: %+v", d.Id(), err)
+	}
+
+	// an environment with resources cannot be deleted
+	for _, resource := range d.Get("kubernetes_resource").(*schema.Set).List() {
+		if err := deleteKubernetesResource(clients, projectID, environmentID, resource.(map[string]interface{})["id"].(int)); err != nil {
+			return err
+		}
+	}
+
+	err = clients.TaskAgentClient.DeleteEnvironment(clients.Ctx, taskagent.DeleteEnvironmentArgs{
+		Project:       converter.String(projectID),
+		EnvironmentId: converter.Int(environmentID),
+	})
+	if err != nil && !utils.ResponseWasNotFound(err) {
+		return fmt.Errorf("error deleting environment %d: %+v", environmentID, err)
+	}
+
+	d.SetId("")
+	return nil
+}
+
+// importEnvironment imports an environment by an ID like <project name or ID>/<environment ID>. The virtual
+// machines are not imported, their tags are only managed once they are configured.
+func importEnvironment(d *schema.ResourceData, m interface{}) ([]*schema.ResourceData, error) {
+	projectNameOrID, environmentID, err := tfhelper.ParseImportedID(d.Id())
+	if err != nil {
+		return nil, err
+	}
+
+	projectID, err := tfhelper.GetRealProjectId(projectNameOrID, m)
+	if err != nil {
+		return nil, err
+	}
+
+	d.Set("project_id", projectID)
+	d.SetId(strconv.Itoa(environmentID))
+	return []*schema.ResourceData{d}, nil
+}
+
+// addKubernetesResource adds a Kubernetes resource, linked to an existing service endpoint, to the environment.
+// The parameters of AddKubernetesResource have no service endpoint, so the request is sent by the SDK client of
+// the TaskAgentClient with the parameters of a resource using an existing endpoint.
+func addKubernetesResource(clients *client.AggregatedClient, projectID string, environmentID int, resource map[string]interface{}) error {
+	taskAgentClient, ok := clients.TaskAgentClient.(*taskagent.ClientImpl)
+	if !ok {
+		return fmt.Errorf("the task agent client cannot add Kubernetes resources linked to a service endpoint")
+	}
+
+	tags := expandTags(resource["tags"].(*schema.Set))
+	body, err := json.Marshal(taskagent.KubernetesResourceCreateParametersExistingEndpoint{
+		Name:              converter.String(resource["name"].(string)),
+		Namespace:         converter.String(resource["namespace"].(string)),
+		ClusterName:       converter.String(resource["cluster_name"].(string)),
+		ServiceEndpointId: converter.UUID(resource["service_endpoint_id"].(string)),
+		Tags:              &tags,
+	})
+	if err != nil {
+		return err
+	}
+
+	// the api-version of the resource is negotiated like the ones of the clients the SDK does not cover
+	kubernetes := apiversion.ResourceValues.KubernetesResources
+	routeValues := map[string]string{
+		"project":       projectID,
+		"environmentId": strconv.Itoa(environmentID),
+	}
+	resp, err := taskAgentClient.Client.Send(clients.Ctx, http.MethodPost, kubernetes.LocationID, clients.APIVersionNegotiator.Version(clients.Ctx, kubernetes), routeValues, nil, bytes.NewReader(body), "application/json", "application/json", nil)
+	if err == nil {
+		var added taskagent.KubernetesResource
+		err = taskAgentClient.Client.UnmarshalBody(resp, &added)
+	}
+	if err != nil {
+		return fmt.Errorf("error adding Kubernetes resource %s to environment %d: %+v", resource["name"].(string), environmentID, err)
+	}
+	return nil
+}
+
+func deleteKubernetesResource(clients *client.AggregatedClient, projectID string, environmentID int, resourceID int) error {
+	err := clients.TaskAgentClient.DeleteKubernetesResource(clients.Ctx, taskagent.DeleteKubernetesResourceArgs{
+		Project:       converter.String(projectID),
+		EnvironmentId: converter.Int(environmentID),
+		ResourceId:    converter.Int(resourceID),
+	})
+	if err != nil && !utils.ResponseWasNotFound(err) {
+		return fmt.Errorf("error deleting Kubernetes resource %d of environment %d: %+v", resourceID, environmentID, err)
+	}
+	return nil
+}
+
+// updateVirtualMachineTags sets the tags of the machines of a virtual machine resource
+func updateVirtualMachineTags(clients *client.AggregatedClient, projectID string, environmentID int, machine map[string]interface{}) error {
+	resourceID := machine["resource_id"].(int)
+	machines, err := clients.TaskAgentClient.GetVirtualMachines(clients.Ctx, taskagent.GetVirtualMachinesArgs{
+		Project:       converter.String(projectID),
+		EnvironmentId: converter.Int(environmentID),
+		ResourceId:    converter.Int(resourceID),
+	})
+	if err != nil {
+		return fmt.Errorf("error reading virtual machine resource %d of environment %d: %+v", resourceID, environmentID, err)
+	}
+	if machines == nil || len(machines.Value) == 0 {
+		return fmt.Errorf("the virtual machine resource %d is not registered in environment %d", resourceID, environmentID)
+	}
+
+	tags := expandTags(machine["tags"].(*schema.Set))
+	updates := []taskagent.VirtualMachine{}
+	for _, virtualMachine := range machines.Value {
+		updates = append(updates, taskagent.VirtualMachine{
+			Id:   virtualMachine.Id,
+			Tags: &tags,
+		})
+	}
+	_, err = clients.TaskAgentClient.UpdateVirtualMachines(clients.Ctx, taskagent.UpdateVirtualMachinesArgs{
+		Project:       converter.String(projectID),
+		EnvironmentId: converter.Int(environmentID),
+		ResourceId:    converter.Int(resourceID),
+		Machines:      &updates,
+	})
+	if err != nil {
+		return fmt.Errorf("error updating the tags of virtual machine resource %d of environment %d: %+v", resourceID, environmentID, err)
+	}
+	return nil
+}
+
+func flattenKubernetesResource(resource *taskagent.KubernetesResource) map[string]interface{} {
+	serviceEndpointID := ""
+	if resource.ServiceEndpointId != nil {
+		serviceEndpointID = resource.ServiceEndpointId.String()
+	}
+	id := 0
+	if resource.Id != nil {
+		id = *resource.Id
+	}
+	return map[string]interface{}{
+		"id":                  id,
+		"name":                converter.ToString(resource.Name, ""),
+		"namespace":           converter.ToString(resource.Namespace, ""),
+		"cluster_name":        converter.ToString(resource.ClusterName, ""),
+		"service_endpoint_id": serviceEndpointID,
+		"tags":                flattenTags(resource.Tags),
+	}
+}
+
+func expandTags(tags *schema.Set) []string {
+	values := tfhelper.ExpandStringSet(tags)
+	sort.Strings(values)
+	return values
+}
+
+func flattenTags(tags *[]string) []interface{} {
+	values := []interface{}{}
+	if tags != nil {
+		for _, tag := range *tags {
+			values = append(values, tag)
+		}
+	}
+	return values
+}
@@ -0,0 +1,270 @@
+package environment
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/babylonhealth/terraform-provider-bblnazuredevops/azdosdkmocks"
+	"github.com/babylonhealth/terraform-provider-bblnazuredevops/bblnazuredevops/internal/client"
+	"github.com/babylonhealth/terraform-provider-bblnazuredevops/bblnazuredevops/internal/client/apiversion"
+	"github.com/babylonhealth/terraform-provider-bblnazuredevops/bblnazuredevops/internal/utils/converter"
+	"github.com/golang/mock/gomock"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/microsoft/azure-devops-go-api/azuredevops/v6"
+	"github.com/microsoft/azure-devops-go-api/azuredevops/v6/taskagent"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testProjectID     = "4f7f5d92-0e11-4311-ac85-9972864acbc2"
+	testEnvironmentID = 7
+	testEndpointID    = "627166ad-752b-47f7-a115-7bdcb385931e"
+)
+
+func newTestEnvironmentData(t *testing.T, machines ...interface{}) *schema.ResourceData {
+	d := schema.TestResourceDataRaw(t, ResourceEnvironment().Schema, map[string]interface{}{
+		"project_id":      testProjectID,
+		"name":            "production",
+		"virtual_machine": machines,
+	})
+	d.SetId("7")
+	return d
+}
+
+func newTestEnvironment(resources ...taskagent.EnvironmentResourceReference) *taskagent.EnvironmentInstance {
+	return &taskagent.EnvironmentInstance{
+		Id:          converter.Int(testEnvironmentID),
+		Name:        converter.String("production"),
+		Description: converter.String("Production clusters and machines"),
+		Resources:   &resources,
+	}
+}
+
+// Only the virtual machines of the configuration are read, other machines register themselves in the environment
+func TestEnvironment_Read(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	taskAgentClient := azdosdkmocks.NewMockTaskagentClient(ctrl)
+	clients := &client.AggregatedClient{TaskAgentClient: taskAgentClient, Ctx: context.Background()}
+
+	kubernetes := taskagent.EnvironmentResourceTypeValues.Kubernetes
+	virtualMachine := taskagent.EnvironmentResourceTypeValues.VirtualMachine
+	expands := taskagent.EnvironmentExpandsValues.ResourceReferences
+	taskAgentClient.EXPECT().
+		GetEnvironmentById(clients.Ctx, taskagent.GetEnvironmentByIdArgs{
+			Project:       converter.String(testProjectID),
+			EnvironmentId: converter.Int(testEnvironmentID),
+			Expands:       &expands,
+		}).
+		Return(newTestEnvironment(
+			taskagent.EnvironmentResourceReference{Id: converter.Int(1), Type: &kubernetes},
+			taskagent.EnvironmentResourceReference{Id: converter.Int(2), Type: &virtualMachine, Tags: &[]string{"web"}},
+			taskagent.EnvironmentResourceReference{Id: converter.Int(3), Type: &virtualMachine, Tags: &[]string{"db"}},
+		), nil).
+		Times(1)
+	taskAgentClient.EXPECT().
+		GetKubernetesResource(clients.Ctx, taskagent.GetKubernetesResourceArgs{
+			Project:       converter.String(testProjectID),
+			EnvironmentId: converter.Int(testEnvironmentID),
+			ResourceId:    converter.Int(1),
+		}).
+		Return(&taskagent.KubernetesResource{
+			Id:                converter.Int(1),
+			Name:              converter.String("payments"),
+			Namespace:         converter.String("payments"),
+			ClusterName:       converter.String("prod-eu"),
+			ServiceEndpointId: converter.UUID(testEndpointID),
+			Tags:              &[]string{"eu"},
+		}, nil).
+		Times(1)
+
+	d := newTestEnvironmentData(t, map[string]interface{}{"resource_id": 2, "tags": []interface{}{"web"}})
+	require.NoError(t, resourceEnvironmentRead(d, clients))
+
+	require.Equal(t, "7", d.Id())
+	require.Equal(t, "Production clusters and machines", d.Get("description"))
+
+	resources := d.Get("kubernetes_resource").(*schema.Set).List()
+	require.Len(t, resources, 1)
+	resource := resources[0].(map[string]interface{})
+	require.Equal(t, 1, resource["id"])
+	require.Equal(t, "prod-eu", resource["cluster_name"])
+	require.Equal(t, testEndpointID, resource["service_endpoint_id"])
+	require.Equal(t, []interface{}{"eu"}, resource["tags"].(*schema.Set).List())
+
+	machines := d.Get("virtual_machine").(*schema.Set).List()
+	require.Len(t, machines, 1)
+	require.Equal(t, 2, machines[0].(map[string]interface{})["resource_id"])
+}
+
+func TestEnvironment_ReadNotFound(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	taskAgentClient := azdosdkmocks.NewMockTaskagentClient(ctrl)
+	clients := &client.AggregatedClient{TaskAgentClient: taskAgentClient, Ctx: context.Background()}
+
+	taskAgentClient.EXPECT().
+		GetEnvironmentById(clients.Ctx, gomock.Any()).
+		Return(nil, azuredevops.WrappedError{StatusCode: converter.Int(http.StatusNotFound)}).
+		Times(1)
+
+	d := newTestEnvironmentData(t)
+	require.NoError(t, resourceEnvironmentRead(d, clients))
+	require.Empty(t, d.Id())
+}
+
+func TestEnvironment_UpdateVirtualMachineTags(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	taskAgentClient := azdosdkmocks.NewMockTaskagentClient(ctrl)
+	clients := &client.AggregatedClient{TaskAgentClient: taskAgentClient, Ctx: context.Background()}
+
+	taskAgentClient.EXPECT().
+		GetVirtualMachines(clients.Ctx, taskagent.GetVirtualMachinesArgs{
+			Project:       converter.String(testProjectID),
+			EnvironmentId: converter.Int(testEnvironmentID),
+			ResourceId:    converter.Int(2),
+		}).
+		Return(&taskagent.GetVirtualMachinesResponseValue{Value: []taskagent.VirtualMachine{{Id: converter.Int(12)}}}, nil).
+		Times(1)
+	taskAgentClient.EXPECT().
+		UpdateVirtualMachines(clients.Ctx, taskagent.UpdateVirtualMachinesArgs{
+			Project:       converter.String(testProjectID),
+			EnvironmentId: converter.Int(testEnvironmentID),
+			ResourceId:    converter.Int(2),
+			Machines:      &[]taskagent.VirtualMachine{{Id: converter.Int(12), Tags: &[]string{"api", "web"}}},
+		}).
+		Return(&[]taskagent.VirtualMachine{}, nil).
+		Times(1)
+
+	machine := map[string]interface{}{"resource_id": 2, "tags": schema.NewSet(schema.HashString, []interface{}{"web", "api"})}
+	require.NoError(t, updateVirtualMachineTags(clients, testProjectID, testEnvironmentID, machine))
+}
+
+func TestEnvironment_UpdateVirtualMachineTags_NotRegistered(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	taskAgentClient := azdosdkmocks.NewMockTaskagentClient(ctrl)
+	clients := &client.AggregatedClient{TaskAgentClient: taskAgentClient, Ctx: context.Background()}
+
+	taskAgentClient.EXPECT().
+		GetVirtualMachines(clients.Ctx, gomock.Any()).
+		Return(&taskagent.GetVirtualMachinesResponseValue{}, nil).
+		Times(1)
+
+	machine := map[string]interface{}{"resource_id": 2, "tags": schema.NewSet(schema.HashString, nil)}
+	err := updateVirtualMachineTags(clients, testProjectID, testEnvironmentID, machine)
+	require.EqualError(t, err, "the virtual machine resource 2 is not registered in environment 7")
+}
+
+// The Kubernetes resources are deleted before the environment
+func TestEnvironment_Delete(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	taskAgentClient := azdosdkmocks.NewMockTaskagentClient(ctrl)
+	clients := &client.AggregatedClient{TaskAgentClient: taskAgentClient, Ctx: context.Background()}
+
+	d := newTestEnvironmentData(t)
+	require.NoError(t, d.Set("kubernetes_resource", []interface{}{flattenKubernetesResource(&taskagent.KubernetesResource{
+		Id:                converter.Int(1),
+		Name:              converter.String("payments"),
+		Namespace:         converter.String("payments"),
+		ServiceEndpointId: converter.UUID(testEndpointID),
+	})}))
+
+	gomock.InOrder(
+		taskAgentClient.EXPECT().
+			DeleteKubernetesResource(clients.Ctx, taskagent.DeleteKubernetesResourceArgs{
+				Project:       converter.String(testProjectID),
+				EnvironmentId: converter.Int(testEnvironmentID),
+				ResourceId:    converter.Int(1),
+			}).
+			Return(nil),
+		taskAgentClient.EXPECT().
+			DeleteEnvironment(clients.Ctx, taskagent.DeleteEnvironmentArgs{
+				Project:       converter.String(testProjectID),
+				EnvironmentId: converter.Int(testEnvironmentID),
+			}).
+			Return(nil),
+	)
+
+	require.NoError(t, resourceEnvironmentDelete(d, clients))
+	require.Empty(t, d.Id())
+}
+
+// The Kubernetes resources are linked to their service endpoint, which the parameters of the SDK leave out
+func TestEnvironment_AddKubernetesResource(t *testing.T) {
+	kubernetes := apiversion.ResourceValues.KubernetesResources
+	minVersion, maxVersion, releasedVersion := "5.0", "6.0", "0.0"
+	routeTemplate := "{project}/_apis/distributedtask/environments/{environmentId}/providers/kubernetes/{resourceId}"
+	location := azuredevops.ApiResourceLocation{
+		Id:              &kubernetes.LocationID,
+		Area:            &kubernetes.Area,
+		ResourceName:    &kubernetes.ResourceName,
+		RouteTemplate:   &routeTemplate,
+		MinVersion:      &minVersion,
+		MaxVersion:      &maxVersion,
+		ReleasedVersion: &releasedVersion,
+		ResourceVersion: converter.Int(1),
+	}
+
+	var parameters map[string]interface{}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodOptions {
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"count": 1, "value": []azuredevops.ApiResourceLocation{location}})
+			return
+		}
+		require.Equal(t, http.MethodPost, r.Method)
+		require.Contains(t, r.Header.Get("Accept"), "api-version=5.1-preview.1")
+		require.Equal(t, "/"+testProjectID+"/_apis/distributedtask/environments/7/providers/kubernetes", r.URL.Path)
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&parameters))
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"id": 1, "name": "payments"})
+	}))
+	defer ts.Close()
+
+	connection := azuredevops.NewPatConnection(ts.URL, "token")
+	// the api-version of the resource is negotiated, here overridden by the provider configuration
+	clients := &client.AggregatedClient{
+		TaskAgentClient:      &taskagent.ClientImpl{Client: *azuredevops.NewClient(connection, ts.URL)},
+		APIVersionNegotiator: apiversion.NewNegotiator(ts.URL, "", nil, map[string]string{kubernetes.Key: "5.1-preview.1"}),
+		Ctx:                  context.Background(),
+	}
+
+	resource := map[string]interface{}{
+		"name":                "payments",
+		"namespace":           "payments",
+		"cluster_name":        "prod-eu",
+		"service_endpoint_id": testEndpointID,
+		"tags":                schema.NewSet(schema.HashString, []interface{}{"eu"}),
+	}
+	require.NoError(t, addKubernetesResource(clients, testProjectID, testEnvironmentID, resource))
+	require.Equal(t, map[string]interface{}{
+		"name":              "payments",
+		"namespace":         "payments",
+		"clusterName":       "prod-eu",
+		"serviceEndpointId": testEndpointID,
+		"tags":              []interface{}{"eu"},
+	}, parameters)
+}
+
+func TestEnvironment_Import(t *testing.T) {
+	d := schema.TestResourceDataRaw(t, ResourceEnvironment().Schema, map[string]interface{}{})
+	d.SetId(testProjectID + "/7")
+
+	_, err := importEnvironment(d, &client.AggregatedClient{Ctx: context.Background()})
+	require.NoError(t, err)
+	require.Equal(t, "7", d.Id())
+	require.Equal(t, testProjectID, d.Get("project_id"))
+
+	d.SetId(testProjectID + "/production")
+	_, err = importEnvironment(d, &client.AggregatedClient{Ctx: context.Background()})
+	require.Error(t, err)
+}
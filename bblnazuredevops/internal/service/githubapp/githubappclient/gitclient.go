@@ -2,22 +2,25 @@ package githubappclient
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"net/http"
 	"time"
+
+	"github.com/babylonhealth/terraform-provider-bblnazuredevops/bblnazuredevops/internal/client/apiversion"
 )
 
 type GithubAppClient interface {
-	GetGithubAppByID(projectID string, connectionID string) (GetGithubAppResponse, bool, error)
-	AddGithubApp(projectID string, repo string, connectionID string) (string, error)
-	DeleteGithubApp(projectID string, connectionID string) error
+	GetGithubAppByID(ctx context.Context, projectID string, connectionID string) (GetGithubAppResponse, bool, error)
+	AddGithubApp(ctx context.Context, projectID string, repo string, connectionID string) (string, error)
+	DeleteGithubApp(ctx context.Context, projectID string, connectionID string) error
 }
 
 // NewGithubApp will return a GithubApp struct. This is used to create service connections based on Github Apps
 // Using Github Apps is preferred to PATs as there are rate limits on PAT connections
-func NewGithubApp(baseUrl string, auth string, timeout *time.Duration) *GithubApp {
+func NewGithubApp(baseUrl string, auth string, timeout *time.Duration, options ...Option) *GithubApp {
 	defaultTime := time.Duration(60 * time.Second)
 	if timeout == nil {
 		timeout = &defaultTime
@@ -27,11 +30,32 @@ func NewGithubApp(baseUrl string, auth string, timeout *time.Duration) *GithubAp
 		Timeout: *timeout,
 	}
 
-	return &GithubApp{
+	g := &GithubApp{
 		baseUrl:       baseUrl,
 		client:        client,
 		authorization: auth,
 	}
+	for _, option := range options {
+		option(g)
+	}
+	return g
+}
+
+// Option configures optional behaviour of a GithubApp client
+type Option func(*GithubApp)
+
+// WithVersionNegotiator makes the client negotiate the api-version of every request with the server
+func WithVersionNegotiator(negotiator *apiversion.Negotiator) Option {
+	return func(g *GithubApp) {
+		g.negotiator = negotiator
+	}
+}
+
+// WithTransport sends the requests of the client through transport
+func WithTransport(transport http.RoundTripper) Option {
+	return func(g *GithubApp) {
+		g.client.Transport = transport
+	}
 }
 
 func NewGitHubAppPayload(projectID string, repo string, connectionID string) GitHubAppPayload {
@@ -66,7 +90,7 @@ func NewGetGithubAppPayload(projectID string, connectionID string) GitHubAppPayl
 	}
 }
 
-func (g *GithubApp) GetGithubAppByID(projectID string, connectionID string) (GetGithubAppResponse, bool, error) {
+func (g *GithubApp) GetGithubAppByID(ctx context.Context, projectID string, connectionID string) (GetGithubAppResponse, bool, error) {
 	payload := NewGetGithubAppPayload(projectID, connectionID)
 
 	payloadJson, err := json.Marshal(payload)
@@ -74,10 +98,10 @@ func (g *GithubApp) GetGithubAppByID(projectID string, connectionID string) (Get
 		return GetGithubAppResponse{}, false, err
 	}
 
-	acceptHeaders := "application/json;api-version=5.1-preview.1;excludeUrls=true;enumsAsNumbers=true;msDateFormat=true;noArrayWrap=true"
+	acceptHeaders := g.negotiator.AcceptHeader(ctx, apiversion.ResourceValues.HierarchyQuery)
 
 	url := "/_apis/Contribution/HierarchyQuery"
-	resp, err := g.SendRequest("POST", url, string(payloadJson), acceptHeaders)
+	resp, err := g.SendRequest(ctx, "POST", url, string(payloadJson), acceptHeaders)
 	if err != nil {
 		return GetGithubAppResponse{}, false, err
 	}
@@ -94,13 +118,13 @@ func (g *GithubApp) GetGithubAppByID(projectID string, connectionID string) (Get
 
 	if addAppResp.DataProviders.MsVssServiceEndpointsWebServiceEndpointsDetailsDataProvider.
 		ServiceEndpoint.Authorization.Scheme != "InstallationToken" {
-		fmt.Errorf("service connection is not github app")
+		return GetGithubAppResponse{}, false, fmt.Errorf("service connection is not github app")
 	}
 
 	return addAppResp, true, err
 }
 
-func (g *GithubApp) AddGithubApp(projectID string, repo string, connectionID string) (string, error) {
+func (g *GithubApp) AddGithubApp(ctx context.Context, projectID string, repo string, connectionID string) (string, error) {
 	payload := NewGitHubAppPayload(projectID, repo, connectionID)
 
 	payloadJson, err := json.Marshal(payload)
@@ -108,10 +132,10 @@ func (g *GithubApp) AddGithubApp(projectID string, repo string, connectionID str
 		return "", err
 	}
 
-	acceptHeaders := "application/json;api-version=5.1-preview.1;excludeUrls=true;enumsAsNumbers=true;msDateFormat=true;noArrayWrap=true"
+	acceptHeaders := g.negotiator.AcceptHeader(ctx, apiversion.ResourceValues.HierarchyQuery)
 
 	url := "/_apis/Contribution/HierarchyQuery"
-	resp, err := g.SendRequest("POST", url, string(payloadJson), acceptHeaders)
+	resp, err := g.SendRequest(ctx, "POST", url, string(payloadJson), acceptHeaders)
 	if err != nil {
 		return "", err
 	}
@@ -125,17 +149,17 @@ func (g *GithubApp) AddGithubApp(projectID string, repo string, connectionID str
 	return addAppResp.DataProviders.MsVssBuildWebAppServiceconnectionsRecommendationDataProvider.CommonConnectionID, nil
 }
 
-func (g *GithubApp) DeleteGithubApp(projectID string, connectionID string) error {
+func (g *GithubApp) DeleteGithubApp(ctx context.Context, projectID string, connectionID string) error {
 	url := fmt.Sprintf("/_apis/serviceendpoint/endpoints/%s?projectIds=%s", connectionID, projectID)
 
-	acceptHeaders := "application/json;api-version=6.0-preview.4;excludeUrls=true;enumsAsNumbers=true;msDateFormat=true;noArrayWrap=true"
-	_, err := g.SendRequest("DELETE", url, "", acceptHeaders)
+	acceptHeaders := g.negotiator.AcceptHeader(ctx, apiversion.ResourceValues.ServiceEndpoints)
+	_, err := g.SendRequest(ctx, "DELETE", url, "", acceptHeaders)
 
 	return err
 }
 
-func (c *GithubApp) SendRequest(httpMethod string, url string, jsonPayload string, acceptHeaders string) ([]byte, error) {
-	req, err := http.NewRequest(httpMethod,
+func (c *GithubApp) SendRequest(ctx context.Context, httpMethod string, url string, jsonPayload string, acceptHeaders string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, httpMethod,
 		c.baseUrl+url,
 		bytes.NewBufferString(jsonPayload))
 	if err != nil {
@@ -1,10 +1,10 @@
 package githubappclient
 
 import (
+	"context"
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
-	"github.com/sirupsen/logrus"
 	"net/http"
 	"net/http/httptest"
 	"os"
@@ -45,7 +45,7 @@ func TestGitApp_AddGithubApp(t *testing.T) {
 
 			g.baseUrl = ts.URL
 
-			got, err := g.AddGithubApp(tt.args.projectID, tt.args.repo, tt.args.connectionID)
+			got, err := g.AddGithubApp(context.Background(), tt.args.projectID, tt.args.repo, tt.args.connectionID)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("AddGithubApp() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -81,7 +81,7 @@ func TestGitApp_DeleteGihubApp(t *testing.T) {
 			defer ts.Close()
 			g.baseUrl = ts.URL
 
-			if err := g.DeleteGithubApp(tt.args.projectID, tt.args.connectionID); (err != nil) != tt.wantErr {
+			if err := g.DeleteGithubApp(context.Background(), tt.args.projectID, tt.args.connectionID); (err != nil) != tt.wantErr {
 				t.Errorf("DeleteGithubApp() error = %v, wantErr %v", err, tt.wantErr)
 			}
 		})
@@ -130,7 +130,7 @@ func TestGitApp_GetGithubAppByID(t *testing.T) {
 			defer ts.Close()
 			g.baseUrl = ts.URL
 
-			got, got1, err := g.GetGithubAppByID(tt.args.projectID, tt.args.connectionID)
+			got, got1, err := g.GetGithubAppByID(context.Background(), tt.args.projectID, tt.args.connectionID)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("GetGithubAppByID() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -149,10 +149,11 @@ func getTestServer(wantedResponse interface{}) *httptest.Server {
 	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		jsonResp, err := json.Marshal(wantedResponse)
 		if err != nil {
-			logrus.Fatalf("error setting up test server: %v", err)
+			http.Error(w, fmt.Sprintf("error setting up test server: %v", err), http.StatusInternalServerError)
+			return
 		}
 
-		fmt.Fprintf(w, string(jsonResp))
+		fmt.Fprint(w, string(jsonResp))
 	}))
 
 	return ts
@@ -1,6 +1,10 @@
 package githubappclient
 
-import "net/http"
+import (
+	"net/http"
+
+	"github.com/babylonhealth/terraform-provider-bblnazuredevops/bblnazuredevops/internal/client/apiversion"
+)
 
 type GitHubAppPayload struct {
 	ContributionIds     []string            `json:"contributionIds"`
@@ -45,6 +49,7 @@ type GithubApp struct {
 	baseUrl       string
 	client        *http.Client
 	authorization string
+	negotiator    *apiversion.Negotiator
 }
 
 type GetGithubAppResponse struct {
@@ -9,17 +9,15 @@ import (
 
 func ResourceGithubApp() *schema.Resource {
 	r := &schema.Resource{
-		Create: createGithubApp,
-		Read:   getGitHubApp,
-		Update: updateApp,
-		Delete: deleteApp,
-		Exists: gitHubAppExists,
+		Create:        createGithubApp,
+		Read:          getGitHubApp,
+		Update:        updateApp,
+		Delete:        deleteApp,
+		Exists:        gitHubAppExists,
+		CustomizeDiff: tfhelper.CustomizeDiffProjectID,
 	}
 	r.Schema = map[string]*schema.Schema{}
-	r.Schema["project_id"] = &schema.Schema{
-		Type:     schema.TypeString,
-		Required: true,
-	}
+	r.Schema["project_id"] = tfhelper.ProjectIDSchema()
 	r.Schema["connection_id"] = &schema.Schema{
 		Type:     schema.TypeString,
 		Required: true,
@@ -43,11 +41,14 @@ func ResourceGithubApp() *schema.Resource {
 func createGithubApp(d *schema.ResourceData, m interface{}) error {
 	clients := m.(*client.AggregatedClient)
 
-	projectID := d.Get("project_id").(string)
+	projectID, err := tfhelper.ResolveProjectID(d, m)
+	if err != nil {
+		return err
+	}
 	connectionID := d.Get("connection_id").(string)
 	repo := d.Get("repo").(string)
 
-	appId, err := clients.GitAppClient.AddGithubApp(projectID, repo, connectionID)
+	appId, err := clients.GitAppClient.AddGithubApp(clients.Ctx, projectID, repo, connectionID)
 	if err != nil {
 		return fmt.Errorf("error creating Github App in Azure DevOps: %+v", err)
 	}
@@ -64,7 +65,7 @@ func deleteApp(d *schema.ResourceData, m interface{}) error {
 	projectID := d.Get("project_id").(string)
 	connectionID := d.Get("app_id").(string)
 
-	err := clients.GitAppClient.DeleteGithubApp(projectID, connectionID)
+	err := clients.GitAppClient.DeleteGithubApp(clients.Ctx, projectID, connectionID)
 
 	return err
 }
@@ -75,7 +76,7 @@ func gitHubAppExists(d *schema.ResourceData, m interface{}) (bool, error) {
 	projectID := d.Get("project_id").(string)
 	id := d.Get("app_id").(string)
 
-	_, found, err := clients.GitAppClient.GetGithubAppByID(projectID, id)
+	_, found, err := clients.GitAppClient.GetGithubAppByID(clients.Ctx, projectID, id)
 	if err != nil {
 		return false, err
 	}
@@ -89,7 +90,7 @@ func getGitHubApp(d *schema.ResourceData, m interface{}) error {
 	projectID := d.Get("project_id").(string)
 	connectionID := d.Get("app_id").(string)
 
-	resp, _, err := clients.GitAppClient.GetGithubAppByID(projectID, connectionID)
+	resp, _, err := clients.GitAppClient.GetGithubAppByID(clients.Ctx, projectID, connectionID)
 	if err != nil {
 		return err
 	}
@@ -0,0 +1,187 @@
+package permissions
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/babylonhealth/terraform-provider-bblnazuredevops/bblnazuredevops/internal/client"
+	"github.com/babylonhealth/terraform-provider-bblnazuredevops/bblnazuredevops/internal/client/logging"
+	securityhelper "github.com/babylonhealth/terraform-provider-bblnazuredevops/bblnazuredevops/internal/service/permissions/utils"
+	"github.com/babylonhealth/terraform-provider-bblnazuredevops/bblnazuredevops/internal/utils/cache"
+	"github.com/babylonhealth/terraform-provider-bblnazuredevops/bblnazuredevops/internal/utils/converter"
+	"github.com/babylonhealth/terraform-provider-bblnazuredevops/bblnazuredevops/internal/utils/tfhelper"
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+	"github.com/microsoft/azure-devops-go-api/azuredevops/v6/taskagent"
+)
+
+// ResourceEnvironmentPermissions schema and implementation for the permissions on the environments of a project,
+// or on a single environment
+func ResourceEnvironmentPermissions() *schema.Resource {
+	return &schema.Resource{
+		Create:        resourceEnvironmentPermissionsCreateOrUpdate,
+		Read:          resourceEnvironmentPermissionsRead,
+		Update:        resourceEnvironmentPermissionsCreateOrUpdate,
+		Delete:        resourceEnvironmentPermissionsDelete,
+		CustomizeDiff: tfhelper.CustomizeDiffProjectID,
+		Importer: &schema.ResourceImporter{
+			State: importEnvironmentPermissions,
+		},
+		Schema: securityhelper.CreatePermissionResourceSchema(map[string]*schema.Schema{
+			"project_id": tfhelper.ProjectIDSchema(),
+			"environment": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				ForceNew:     true,
+				ValidateFunc: validation.StringIsNotWhiteSpace,
+				Description:  "The name or ID of the environment. The permissions apply to all environments of the project when it is not set.",
+			},
+		}),
+	}
+}
+
+func resourceEnvironmentPermissionsCreateOrUpdate(d *schema.ResourceData, m interface{}) error {
+	clients := m.(*client.AggregatedClient)
+
+	if _, err := tfhelper.ResolveProjectID(d, m); err != nil {
+		return err
+	}
+
+	sn, err := securityhelper.NewSecurityNamespace(d, clients, securityhelper.SecurityNamespaceIDValues.Environment, createEnvironmentToken)
+	if err != nil {
+		return err
+	}
+
+	if err := securityhelper.SetPrincipalPermissions(d, sn, nil, false); err != nil {
+		return err
+	}
+
+	return resourceEnvironmentPermissionsRead(d, m)
+}
+
+func resourceEnvironmentPermissionsRead(d *schema.ResourceData, m interface{}) error {
+	clients := m.(*client.AggregatedClient)
+
+	sn, err := securityhelper.NewSecurityNamespace(d, clients, securityhelper.SecurityNamespaceIDValues.Environment, createEnvironmentToken)
+	if err != nil {
+		return err
+	}
+
+	principalPermissions, err := securityhelper.GetPrincipalPermissions(d, sn)
+	if err != nil {
+		return err
+	}
+	if principalPermissions == nil {
+		d.SetId("")
+		ctx := logging.WithSubsystem(clients.Ctx, logging.SubsystemSecurity)
+		tflog.SubsystemInfo(ctx, logging.SubsystemSecurity, "The permissions of the ACL token are not found, removing them from the state", map[string]interface{}{"acl_token": sn.GetToken()})
+		return nil
+	}
+
+	d.Set("permissions", principalPermissions.Permissions)
+	return nil
+}
+
+func resourceEnvironmentPermissionsDelete(d *schema.ResourceData, m interface{}) error {
+	clients := m.(*client.AggregatedClient)
+
+	sn, err := securityhelper.NewSecurityNamespace(d, clients, securityhelper.SecurityNamespaceIDValues.Environment, createEnvironmentToken)
+	if err != nil {
+		return err
+	}
+
+	if err := securityhelper.SetPrincipalPermissions(d, sn, &securityhelper.PermissionTypeValues.NotSet, true); err != nil {
+		return err
+	}
+	d.SetId("")
+	return nil
+}
+
+// importEnvironmentPermissions imports the permissions of a principal with an ID like <project name or ID>/<principal>,
+// or <project name or ID>/<environment name or ID>/<principal> for the permissions on a single environment. The
+// permissions which are set for the principal are read back.
+func importEnvironmentPermissions(d *schema.ResourceData, m interface{}) ([]*schema.ResourceData, error) {
+	parts := strings.Split(d.Id(), "/")
+	if len(parts) < 2 || len(parts) > 3 || parts[0] == "" || parts[len(parts)-1] == "" {
+		return nil, fmt.Errorf("unexpected format of ID (%s), expected <project name or ID>/<principal> or <project name or ID>/<environment name or ID>/<principal>", d.Id())
+	}
+
+	projectID, err := tfhelper.GetRealProjectId(parts[0], m)
+	if err != nil {
+		return nil, err
+	}
+	principal := parts[len(parts)-1]
+	if len(parts) == 3 {
+		if parts[1] == "" {
+			return nil, fmt.Errorf("unexpected format of ID (%s), the environment is empty", d.Id())
+		}
+		d.Set("environment", parts[1])
+	}
+
+	d.Set("project_id", projectID)
+	d.Set("principal", principal)
+	d.Set("replace", true)
+
+	clients, _ := m.(*client.AggregatedClient)
+	token, err := createEnvironmentToken(d, clients)
+	if err != nil {
+		return nil, err
+	}
+	d.SetId(fmt.Sprintf("%s/%s", token, principal))
+	return []*schema.ResourceData{d}, nil
+}
+
+// createEnvironmentToken returns the token of the project, Environments/<project ID>, or of the environment,
+// Environments/<project ID>/<environment ID>. Environments configured by name are looked up by their name.
+func createEnvironmentToken(d *schema.ResourceData, clients *client.AggregatedClient) (string, error) {
+	projectID, ok := d.GetOk("project_id")
+	if !ok {
+		return "", fmt.Errorf("failed to get 'project_id' from schema")
+	}
+
+	aclToken := fmt.Sprintf("Environments/%s", projectID.(string))
+	if environment, ok := d.GetOk("environment"); ok {
+		environmentID, err := resolveEnvironmentID(clients, projectID.(string), environment.(string))
+		if err != nil {
+			return "", err
+		}
+		aclToken = fmt.Sprintf("%s/%d", aclToken, environmentID)
+	}
+	return aclToken, nil
+}
+
+// resolveEnvironmentID returns the ID of the environment with the given name or ID. The IDs of the names are cached
+// for all resources of the provider.
+func resolveEnvironmentID(clients *client.AggregatedClient, projectID string, nameOrID string) (int, error) {
+	if environmentID, err := strconv.Atoi(nameOrID); err == nil {
+		return environmentID, nil
+	}
+	if clients == nil {
+		return 0, fmt.Errorf("the environment %s cannot be looked up by name without a client", nameOrID)
+	}
+
+	// environment names are case insensitive
+	environmentID, err := clients.Cache.GetOrLoad(clients.Ctx, cache.Key("environments", projectID, strings.ToLower(nameOrID)), func() (interface{}, error) {
+		environments, err := clients.TaskAgentClient.GetEnvironments(clients.Ctx, taskagent.GetEnvironmentsArgs{
+			Project: converter.String(projectID),
+			Name:    converter.String(nameOrID),
+		})
+		if err != nil {
+			return nil, err
+		}
+		if environments != nil {
+			for _, environment := range environments.Value {
+				if environment.Id != nil && strings.EqualFold(converter.ToString(environment.Name, ""), nameOrID) {
+					return *environment.Id, nil
+				}
+			}
+		}
+		return nil, fmt.Errorf("the environment %s does not exist in project %s", nameOrID, projectID)
+	})
+	if err != nil {
+		return 0, fmt.Errorf("error looking up environment %s: %+v", nameOrID, err)
+	}
+	return environmentID.(int), nil
+}
@@ -0,0 +1,221 @@
+package permissions
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/babylonhealth/terraform-provider-bblnazuredevops/azdosdkmocks"
+	"github.com/babylonhealth/terraform-provider-bblnazuredevops/bblnazuredevops/internal/client"
+	securityhelper "github.com/babylonhealth/terraform-provider-bblnazuredevops/bblnazuredevops/internal/service/permissions/utils"
+	"github.com/babylonhealth/terraform-provider-bblnazuredevops/bblnazuredevops/internal/utils/cache"
+	"github.com/babylonhealth/terraform-provider-bblnazuredevops/bblnazuredevops/internal/utils/converter"
+	"github.com/golang/mock/gomock"
+	"github.com/google/uuid"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/microsoft/azure-devops-go-api/azuredevops/v6/identity"
+	"github.com/microsoft/azure-devops-go-api/azuredevops/v6/security"
+	"github.com/microsoft/azure-devops-go-api/azuredevops/v6/taskagent"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	testEnvironmentProjectID  = "9083e944-8e9e-405e-960a-c80180aa71e6"
+	testEnvironmentPrincipal  = "vssgp.Uy0xLTktMTU1MTM3NDI0NS0yNDU1NjAwOTc1LTI4NjEzNDU5NS0yODk0NDM2NzIyLTIyNTMwNDg3NzAtMS0zNzc2MDEyNDM3LTI1MjAyNTQ3OTAtMjYxOTIwMDAzOS0yNTg5OTY1NzE4"
+	testEnvironmentDescriptor = "Microsoft.TeamFoundation.Identity;S-1-9-1551374245-2455600975-286134595-2894436722-2253048770-1-3776012437-2520254790-2619200039-2589965718"
+)
+
+var testEnvironmentActions = []security.ActionDefinition{
+	{Name: converter.String("View"), Bit: converter.Int(1)},
+	{Name: converter.String("Manage"), Bit: converter.Int(2)},
+	{Name: converter.String("ManageHistory"), Bit: converter.Int(4)},
+	{Name: converter.String("Administer"), Bit: converter.Int(8)},
+	{Name: converter.String("Use"), Bit: converter.Int(16)},
+	{Name: converter.String("Create"), Bit: converter.Int(32)},
+}
+
+func newTestEnvironmentClients(ctrl *gomock.Controller) (*client.AggregatedClient, *azdosdkmocks.MockSecurityClient, *azdosdkmocks.MockIdentityClient, *azdosdkmocks.MockTaskagentClient) {
+	securityClient := azdosdkmocks.NewMockSecurityClient(ctrl)
+	identityClient := azdosdkmocks.NewMockIdentityClient(ctrl)
+	taskAgentClient := azdosdkmocks.NewMockTaskagentClient(ctrl)
+	return &client.AggregatedClient{
+		SecurityClient:  securityClient,
+		IdentityClient:  identityClient,
+		TaskAgentClient: taskAgentClient,
+		Cache:           cache.New(time.Minute),
+		Ctx:             context.Background(),
+	}, securityClient, identityClient, taskAgentClient
+}
+
+func TestEnvironmentPermissions_CreateEnvironmentToken(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	clients, _, _, taskAgentClient := newTestEnvironmentClients(ctrl)
+	taskAgentClient.EXPECT().
+		GetEnvironments(clients.Ctx, taskagent.GetEnvironmentsArgs{
+			Project: converter.String(testEnvironmentProjectID),
+			Name:    converter.String("Production"),
+		}).
+		Return(&taskagent.GetEnvironmentsResponseValue{Value: []taskagent.EnvironmentInstance{
+			{Id: converter.Int(7), Name: converter.String("production")},
+		}}, nil).
+		Times(1)
+
+	d := schema.TestResourceDataRaw(t, ResourceEnvironmentPermissions().Schema, map[string]interface{}{
+		"project_id": testEnvironmentProjectID,
+	})
+	token, err := createEnvironmentToken(d, clients)
+	assert.Nil(t, err)
+	assert.Equal(t, "Environments/"+testEnvironmentProjectID, token)
+
+	d.Set("environment", "12")
+	token, err = createEnvironmentToken(d, clients)
+	assert.Nil(t, err)
+	assert.Equal(t, "Environments/"+testEnvironmentProjectID+"/12", token)
+
+	// the ID of the name is looked up once
+	d.Set("environment", "Production")
+	for i := 0; i < 2; i++ {
+		token, err = createEnvironmentToken(d, clients)
+		assert.Nil(t, err)
+		assert.Equal(t, "Environments/"+testEnvironmentProjectID+"/7", token)
+	}
+
+	d = schema.TestResourceDataRaw(t, ResourceEnvironmentPermissions().Schema, nil)
+	token, err = createEnvironmentToken(d, clients)
+	assert.Empty(t, token)
+	assert.EqualError(t, err, "failed to get 'project_id' from schema")
+}
+
+func TestEnvironmentPermissions_CreateEnvironmentToken_UnknownEnvironment(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	clients, _, _, taskAgentClient := newTestEnvironmentClients(ctrl)
+	taskAgentClient.EXPECT().
+		GetEnvironments(clients.Ctx, gomock.Any()).
+		Return(&taskagent.GetEnvironmentsResponseValue{}, nil).
+		Times(1)
+
+	d := schema.TestResourceDataRaw(t, ResourceEnvironmentPermissions().Schema, map[string]interface{}{
+		"project_id":  testEnvironmentProjectID,
+		"environment": "staging",
+	})
+	_, err := createEnvironmentToken(d, clients)
+	assert.EqualError(t, err, "error looking up environment staging: the environment staging does not exist in project "+testEnvironmentProjectID)
+}
+
+// The permissions are set on the token of the environment and read back
+func TestEnvironmentPermissions_Create(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	clients, securityClient, identityClient, _ := newTestEnvironmentClients(ctrl)
+	namespaceID := uuid.UUID(securityhelper.SecurityNamespaceIDValues.Environment)
+	token := "Environments/" + testEnvironmentProjectID + "/7"
+
+	securityClient.EXPECT().
+		QuerySecurityNamespaces(clients.Ctx, security.QuerySecurityNamespacesArgs{SecurityNamespaceId: &namespaceID}).
+		Return(&[]security.SecurityNamespaceDescription{{NamespaceId: &namespaceID, Actions: &testEnvironmentActions}}, nil).
+		Times(1)
+	identityClient.EXPECT().
+		ReadIdentities(clients.Ctx, identity.ReadIdentitiesArgs{SubjectDescriptors: converter.String(testEnvironmentPrincipal)}).
+		Return(&[]identity.Identity{{
+			Descriptor:        converter.String(testEnvironmentDescriptor),
+			SubjectDescriptor: converter.String(testEnvironmentPrincipal),
+		}}, nil).
+		Times(2)
+
+	aces := map[string]security.AccessControlEntry{}
+	securityClient.EXPECT().
+		QueryAccessControlLists(clients.Ctx, gomock.Any()).
+		DoAndReturn(func(_ context.Context, args security.QueryAccessControlListsArgs) (*[]security.AccessControlList, error) {
+			assert.Equal(t, token, *args.Token)
+			assert.Equal(t, namespaceID, *args.SecurityNamespaceId)
+			return &[]security.AccessControlList{{Token: converter.String(token), AcesDictionary: &aces}}, nil
+		}).
+		Times(2)
+	securityClient.EXPECT().
+		SetAccessControlEntries(clients.Ctx, gomock.Any()).
+		DoAndReturn(func(_ context.Context, args security.SetAccessControlEntriesArgs) (*[]security.AccessControlEntry, error) {
+			body, err := json.Marshal(args.Container)
+			assert.Nil(t, err)
+			var container struct {
+				Token                string                        `json:"token"`
+				AccessControlEntries []security.AccessControlEntry `json:"accessControlEntries"`
+			}
+			assert.Nil(t, json.Unmarshal(body, &container))
+			assert.Equal(t, token, container.Token)
+			for _, ace := range container.AccessControlEntries {
+				aces[*ace.Descriptor] = ace
+			}
+			return &container.AccessControlEntries, nil
+		}).
+		Times(1)
+
+	d := schema.TestResourceDataRaw(t, ResourceEnvironmentPermissions().Schema, map[string]interface{}{
+		"project_id":  testEnvironmentProjectID,
+		"environment": "7",
+		"principal":   testEnvironmentPrincipal,
+		"permissions": map[string]interface{}{"View": "allow", "Use": "allow", "Administer": "deny"},
+	})
+	assert.Nil(t, resourceEnvironmentPermissionsCreateOrUpdate(d, clients))
+
+	assert.Equal(t, token+"/"+testEnvironmentPrincipal, d.Id())
+	assert.Equal(t, 17, *aces[testEnvironmentDescriptor].Allow)
+	assert.Equal(t, 8, *aces[testEnvironmentDescriptor].Deny)
+	assert.Equal(t, map[string]interface{}{"View": "allow", "Use": "allow", "Administer": "deny"}, d.Get("permissions"))
+}
+
+func TestEnvironmentPermissions_Import(t *testing.T) {
+	tests := []struct {
+		name            string
+		id              string
+		wantID          string
+		wantEnvironment string
+		wantErr         bool
+	}{
+		{
+			name:   "project",
+			id:     testEnvironmentProjectID + "/" + testEnvironmentPrincipal,
+			wantID: "Environments/" + testEnvironmentProjectID + "/" + testEnvironmentPrincipal,
+		},
+		{
+			name:            "environment",
+			id:              testEnvironmentProjectID + "/7/" + testEnvironmentPrincipal,
+			wantID:          "Environments/" + testEnvironmentProjectID + "/7/" + testEnvironmentPrincipal,
+			wantEnvironment: "7",
+		},
+		{
+			name:    "no principal",
+			id:      testEnvironmentProjectID,
+			wantErr: true,
+		},
+		{
+			name:    "empty environment",
+			id:      testEnvironmentProjectID + "//" + testEnvironmentPrincipal,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := schema.TestResourceDataRaw(t, ResourceEnvironmentPermissions().Schema, nil)
+			d.SetId(tt.id)
+
+			imported, err := importEnvironmentPermissions(d, nil)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.Nil(t, err)
+			assert.Len(t, imported, 1)
+			assert.Equal(t, tt.wantID, d.Id())
+			assert.Equal(t, testEnvironmentProjectID, d.Get("project_id"))
+			assert.Equal(t, tt.wantEnvironment, d.Get("environment"))
+			assert.Equal(t, testEnvironmentPrincipal, d.Get("principal"))
+			assert.Equal(t, true, d.Get("replace"))
+		})
+	}
+}
@@ -3,26 +3,23 @@ package permissions
 import (
 	"fmt"
 	"github.com/babylonhealth/terraform-provider-bblnazuredevops/bblnazuredevops/internal/client"
+	"github.com/babylonhealth/terraform-provider-bblnazuredevops/bblnazuredevops/internal/client/logging"
 	securityhelper "github.com/babylonhealth/terraform-provider-bblnazuredevops/bblnazuredevops/internal/service/permissions/utils"
+	"github.com/babylonhealth/terraform-provider-bblnazuredevops/bblnazuredevops/internal/utils/tfhelper"
+	"github.com/hashicorp/terraform-plugin-log/tflog"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
-	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
-	"log"
 )
 
 // ResourcePipelinePermissions schema and implementation for project permission resource
 func ResourcePipelinePermissions() *schema.Resource {
 	return &schema.Resource{
-		Create: resourcePipelinePermissionsCreateOrUpdate,
-		Read:   resourcePipelinePermissionsRead,
-		Update: resourcePipelinePermissionsCreateOrUpdate,
-		Delete: resourcePipelinePermissionsDelete,
+		Create:        resourcePipelinePermissionsCreateOrUpdate,
+		Read:          resourcePipelinePermissionsRead,
+		Update:        resourcePipelinePermissionsCreateOrUpdate,
+		Delete:        resourcePipelinePermissionsDelete,
+		CustomizeDiff: tfhelper.CustomizeDiffProjectID,
 		Schema: securityhelper.CreatePermissionResourceSchema(map[string]*schema.Schema{
-			"project_id": {
-				Type:         schema.TypeString,
-				ValidateFunc: validation.IsUUID,
-				Required:     true,
-				ForceNew:     true,
-			},
+			"project_id": tfhelper.ProjectIDSchema(),
 			"build_id": {
 				Type:     schema.TypeString,
 				Required: false,
@@ -40,6 +37,10 @@ func ResourcePipelinePermissions() *schema.Resource {
 func resourcePipelinePermissionsCreateOrUpdate(d *schema.ResourceData, m interface{}) error {
 	clients := m.(*client.AggregatedClient)
 
+	if _, err := tfhelper.ResolveProjectID(d, m); err != nil {
+		return err
+	}
+
 	sn, err := securityhelper.NewSecurityNamespace(d, clients, securityhelper.SecurityNamespaceIDValues.Build, createBuildTokenBH)
 	if err != nil {
 		return err
@@ -66,7 +67,8 @@ func resourcePipelinePermissionsRead(d *schema.ResourceData, m interface{}) erro
 	}
 	if principalPermissions == nil {
 		d.SetId("")
-		log.Printf("[INFO] Permissions for ACL token %q not found. Removing from state", sn.GetToken())
+		ctx := logging.WithSubsystem(clients.Ctx, logging.SubsystemSecurity)
+		tflog.SubsystemInfo(ctx, logging.SubsystemSecurity, "The permissions of the ACL token are not found, removing them from the state", map[string]interface{}{"acl_token": sn.GetToken()})
 		return nil
 	}
 
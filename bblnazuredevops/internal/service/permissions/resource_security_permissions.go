@@ -0,0 +1,162 @@
+package permissions
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/babylonhealth/terraform-provider-bblnazuredevops/bblnazuredevops/internal/client"
+	"github.com/babylonhealth/terraform-provider-bblnazuredevops/bblnazuredevops/internal/client/logging"
+	securityhelper "github.com/babylonhealth/terraform-provider-bblnazuredevops/bblnazuredevops/internal/service/permissions/utils"
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+)
+
+// ResourceSecurityPermissions schema and implementation for the permissions on a raw token of any security namespace,
+// for the namespaces without a typed permissions resource
+func ResourceSecurityPermissions() *schema.Resource {
+	return &schema.Resource{
+		Create:        resourceSecurityPermissionsCreateOrUpdate,
+		Read:          resourceSecurityPermissionsRead,
+		Update:        resourceSecurityPermissionsCreateOrUpdate,
+		Delete:        resourceSecurityPermissionsDelete,
+		CustomizeDiff: customizeDiffSecurityPermissions,
+		Importer: &schema.ResourceImporter{
+			State: importSecurityPermissions,
+		},
+		Schema: securityhelper.CreatePermissionResourceSchema(map[string]*schema.Schema{
+			"namespace": {
+				Type:         schema.TypeString,
+				Required:     true,
+				ForceNew:     true,
+				ValidateFunc: validateSecurityNamespace,
+				Description:  "The security namespace, by its name, e.g. Environment or GitRepositories, or by its ID.",
+			},
+			"token": {
+				Type:         schema.TypeString,
+				Required:     true,
+				ForceNew:     true,
+				ValidateFunc: validation.StringIsNotWhiteSpace,
+				Description:  "The token of the resource in the security namespace, e.g. repoV2/<project ID>/<repository ID>.",
+			},
+		}),
+	}
+}
+
+func validateSecurityNamespace(i interface{}, key string) ([]string, []error) {
+	if _, err := securityhelper.ParseSecurityNamespaceID(i.(string)); err != nil {
+		return nil, []error{fmt.Errorf("%s: %+v", key, err)}
+	}
+	return nil, nil
+}
+
+// customizeDiffSecurityPermissions rejects the permissions which are not actions of the security namespace at plan
+// time, rather than when they are applied
+func customizeDiffSecurityPermissions(_ context.Context, d *schema.ResourceDiff, m interface{}) error {
+	clients, ok := m.(*client.AggregatedClient)
+	if !ok || !d.NewValueKnown("namespace") || !d.NewValueKnown("permissions") {
+		return nil
+	}
+
+	namespaceID, err := securityhelper.ParseSecurityNamespaceID(d.Get("namespace").(string))
+	if err != nil {
+		return err
+	}
+	return securityhelper.ValidatePermissions(clients, namespaceID, d.Get("permissions").(map[string]interface{}))
+}
+
+func resourceSecurityPermissionsCreateOrUpdate(d *schema.ResourceData, m interface{}) error {
+	clients := m.(*client.AggregatedClient)
+
+	sn, err := newSecurityPermissionsNamespace(d, clients)
+	if err != nil {
+		return err
+	}
+
+	if err := securityhelper.SetPrincipalPermissions(d, sn, nil, false); err != nil {
+		return err
+	}
+
+	return resourceSecurityPermissionsRead(d, m)
+}
+
+func resourceSecurityPermissionsRead(d *schema.ResourceData, m interface{}) error {
+	clients := m.(*client.AggregatedClient)
+
+	sn, err := newSecurityPermissionsNamespace(d, clients)
+	if err != nil {
+		return err
+	}
+
+	principalPermissions, err := securityhelper.GetPrincipalPermissions(d, sn)
+	if err != nil {
+		return err
+	}
+	if principalPermissions == nil {
+		d.SetId("")
+		ctx := logging.WithSubsystem(clients.Ctx, logging.SubsystemSecurity)
+		tflog.SubsystemInfo(ctx, logging.SubsystemSecurity, "The permissions of the ACL token are not found, removing them from the state", map[string]interface{}{"acl_token": sn.GetToken()})
+		return nil
+	}
+
+	d.Set("permissions", principalPermissions.Permissions)
+	return nil
+}
+
+func resourceSecurityPermissionsDelete(d *schema.ResourceData, m interface{}) error {
+	clients := m.(*client.AggregatedClient)
+
+	sn, err := newSecurityPermissionsNamespace(d, clients)
+	if err != nil {
+		return err
+	}
+
+	if err := securityhelper.SetPrincipalPermissions(d, sn, &securityhelper.PermissionTypeValues.NotSet, true); err != nil {
+		return err
+	}
+	d.SetId("")
+	return nil
+}
+
+// importSecurityPermissions imports the permissions of a principal with an ID like <namespace>/<token>/<principal>.
+// The token may contain slashes, the namespace is the first part of the ID and the principal the last one. The
+// permissions which are set for the principal are read back.
+func importSecurityPermissions(d *schema.ResourceData, _ interface{}) ([]*schema.ResourceData, error) {
+	namespaceEnd := strings.Index(d.Id(), "/")
+	principalStart := strings.LastIndex(d.Id(), "/")
+	if namespaceEnd <= 0 || principalStart <= namespaceEnd+1 || principalStart == len(d.Id())-1 {
+		return nil, fmt.Errorf("unexpected format of ID (%s), expected <namespace>/<token>/<principal>", d.Id())
+	}
+
+	namespace := d.Id()[:namespaceEnd]
+	token := d.Id()[namespaceEnd+1 : principalStart]
+	principal := d.Id()[principalStart+1:]
+	if _, err := securityhelper.ParseSecurityNamespaceID(namespace); err != nil {
+		return nil, err
+	}
+
+	d.Set("namespace", namespace)
+	d.Set("token", token)
+	d.Set("principal", principal)
+	d.Set("replace", true)
+	d.SetId(fmt.Sprintf("%s/%s", token, principal))
+	return []*schema.ResourceData{d}, nil
+}
+
+func newSecurityPermissionsNamespace(d *schema.ResourceData, clients *client.AggregatedClient) (*securityhelper.SecurityNamespace, error) {
+	namespaceID, err := securityhelper.ParseSecurityNamespaceID(d.Get("namespace").(string))
+	if err != nil {
+		return nil, err
+	}
+	return securityhelper.NewSecurityNamespace(d, clients, namespaceID, createSecurityPermissionsToken)
+}
+
+// createSecurityPermissionsToken returns the raw token of the resource data
+func createSecurityPermissionsToken(d *schema.ResourceData, _ *client.AggregatedClient) (string, error) {
+	token, ok := d.GetOk("token")
+	if !ok {
+		return "", fmt.Errorf("failed to get 'token' from schema")
+	}
+	return token.(string), nil
+}
@@ -0,0 +1,108 @@
+package permissions
+
+import (
+	"context"
+	"testing"
+
+	securityhelper "github.com/babylonhealth/terraform-provider-bblnazuredevops/bblnazuredevops/internal/service/permissions/utils"
+	"github.com/golang/mock/gomock"
+	"github.com/google/uuid"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
+	"github.com/microsoft/azure-devops-go-api/azuredevops/v6/security"
+	"github.com/stretchr/testify/assert"
+)
+
+const testRepositoryToken = "repoV2/9083e944-8e9e-405e-960a-c80180aa71e6/1ceae7ff-565c-4cdf-9214-6e2246cba764"
+
+func TestSecurityPermissions_ParseSecurityNamespaceID(t *testing.T) {
+	id, err := securityhelper.ParseSecurityNamespaceID("environment")
+	assert.Nil(t, err)
+	assert.Equal(t, securityhelper.SecurityNamespaceIDValues.Environment, id)
+
+	id, err = securityhelper.ParseSecurityNamespaceID("GitRepositories")
+	assert.Nil(t, err)
+	assert.Equal(t, securityhelper.SecurityNamespaceIDValues.GitRepositories, id)
+
+	id, err = securityhelper.ParseSecurityNamespaceID("83d4c2e6-e57d-4d6e-892b-b87222b7ad20")
+	assert.Nil(t, err)
+	assert.Equal(t, securityhelper.SecurityNamespaceIDValues.Environment, id)
+
+	_, err = securityhelper.ParseSecurityNamespaceID("Repositories")
+	assert.EqualError(t, err, `unknown security namespace "Repositories", expected the name of a namespace, e.g. Environment or GitRepositories, or its ID`)
+}
+
+// The actions of the permissions are checked against the actions of the namespace, which are looked up once
+func TestSecurityPermissions_CustomizeDiff(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	clients, securityClient, _, _ := newTestEnvironmentClients(ctrl)
+	namespaceID := uuid.UUID(securityhelper.SecurityNamespaceIDValues.Environment)
+	securityClient.EXPECT().
+		QuerySecurityNamespaces(clients.Ctx, security.QuerySecurityNamespacesArgs{SecurityNamespaceId: &namespaceID}).
+		Return(&[]security.SecurityNamespaceDescription{{NamespaceId: &namespaceID, Actions: &testEnvironmentActions}}, nil).
+		Times(1)
+
+	tests := []struct {
+		name        string
+		permissions map[string]interface{}
+		wantErr     string
+	}{
+		{
+			name:        "valid",
+			permissions: map[string]interface{}{"View": "allow", "Administer": "Deny", "Use": "notset"},
+		},
+		{
+			name:        "unknown action",
+			permissions: map[string]interface{}{"View": "allow", "Approve": "allow"},
+			wantErr:     "the permission Approve is not an action of the security namespace 83d4c2e6-e57d-4d6e-892b-b87222b7ad20, expected one of [Administer, Create, Manage, ManageHistory, Use, View]",
+		},
+		{
+			name:        "invalid value",
+			permissions: map[string]interface{}{"View": "grant"},
+			wantErr:     `invalid value "grant" of the permission View, expected one of allow, deny or notset`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := map[string]interface{}{
+				"namespace":   "Environment",
+				"token":       "Environments/" + testEnvironmentProjectID,
+				"principal":   testEnvironmentPrincipal,
+				"permissions": tt.permissions,
+			}
+			_, err := ResourceSecurityPermissions().Diff(context.Background(), nil, terraform.NewResourceConfigRaw(config), clients)
+			if tt.wantErr == "" {
+				assert.Nil(t, err)
+			} else {
+				assert.EqualError(t, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSecurityPermissions_Import(t *testing.T) {
+	d := schema.TestResourceDataRaw(t, ResourceSecurityPermissions().Schema, nil)
+	d.SetId("GitRepositories/" + testRepositoryToken + "/" + testEnvironmentPrincipal)
+
+	imported, err := importSecurityPermissions(d, nil)
+	assert.Nil(t, err)
+	assert.Len(t, imported, 1)
+	assert.Equal(t, testRepositoryToken+"/"+testEnvironmentPrincipal, d.Id())
+	assert.Equal(t, "GitRepositories", d.Get("namespace"))
+	assert.Equal(t, testRepositoryToken, d.Get("token"))
+	assert.Equal(t, testEnvironmentPrincipal, d.Get("principal"))
+	assert.Equal(t, true, d.Get("replace"))
+
+	for _, id := range []string{
+		"GitRepositories/" + testEnvironmentPrincipal,
+		"GitRepositories/" + testRepositoryToken + "/",
+		"/" + testRepositoryToken + "/" + testEnvironmentPrincipal,
+		"Repositories/" + testRepositoryToken + "/" + testEnvironmentPrincipal,
+	} {
+		d.SetId(id)
+		_, err := importSecurityPermissions(d, nil)
+		assert.Error(t, err, id)
+	}
+}
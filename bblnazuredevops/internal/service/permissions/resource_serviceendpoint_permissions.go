@@ -0,0 +1,145 @@
+package permissions
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/babylonhealth/terraform-provider-bblnazuredevops/bblnazuredevops/internal/client"
+	"github.com/babylonhealth/terraform-provider-bblnazuredevops/bblnazuredevops/internal/client/logging"
+	securityhelper "github.com/babylonhealth/terraform-provider-bblnazuredevops/bblnazuredevops/internal/service/permissions/utils"
+	"github.com/babylonhealth/terraform-provider-bblnazuredevops/bblnazuredevops/internal/utils/tfhelper"
+	"github.com/hashicorp/go-uuid"
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+)
+
+// ResourceServiceEndpointPermissions schema and implementation for the permissions on the service endpoints of a
+// project, or on a single service endpoint
+func ResourceServiceEndpointPermissions() *schema.Resource {
+	return &schema.Resource{
+		Create:        resourceServiceEndpointPermissionsCreateOrUpdate,
+		Read:          resourceServiceEndpointPermissionsRead,
+		Update:        resourceServiceEndpointPermissionsCreateOrUpdate,
+		Delete:        resourceServiceEndpointPermissionsDelete,
+		CustomizeDiff: tfhelper.CustomizeDiffProjectID,
+		Importer: &schema.ResourceImporter{
+			State: importServiceEndpointPermissions,
+		},
+		Schema: securityhelper.CreatePermissionResourceSchema(map[string]*schema.Schema{
+			"project_id": tfhelper.ProjectIDSchema(),
+			"serviceendpoint_id": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				ForceNew:     true,
+				ValidateFunc: validation.IsUUID,
+				Description:  "The ID of the service endpoint. The permissions apply to all service endpoints of the project when it is not set.",
+			},
+		}),
+	}
+}
+
+func resourceServiceEndpointPermissionsCreateOrUpdate(d *schema.ResourceData, m interface{}) error {
+	clients := m.(*client.AggregatedClient)
+
+	if _, err := tfhelper.ResolveProjectID(d, m); err != nil {
+		return err
+	}
+
+	sn, err := securityhelper.NewSecurityNamespace(d, clients, securityhelper.SecurityNamespaceIDValues.ServiceEndpoints, createServiceEndpointToken)
+	if err != nil {
+		return err
+	}
+
+	if err := securityhelper.SetPrincipalPermissions(d, sn, nil, false); err != nil {
+		return err
+	}
+
+	return resourceServiceEndpointPermissionsRead(d, m)
+}
+
+func resourceServiceEndpointPermissionsRead(d *schema.ResourceData, m interface{}) error {
+	clients := m.(*client.AggregatedClient)
+
+	sn, err := securityhelper.NewSecurityNamespace(d, clients, securityhelper.SecurityNamespaceIDValues.ServiceEndpoints, createServiceEndpointToken)
+	if err != nil {
+		return err
+	}
+
+	principalPermissions, err := securityhelper.GetPrincipalPermissions(d, sn)
+	if err != nil {
+		return err
+	}
+	if principalPermissions == nil {
+		d.SetId("")
+		ctx := logging.WithSubsystem(clients.Ctx, logging.SubsystemSecurity)
+		tflog.SubsystemInfo(ctx, logging.SubsystemSecurity, "The permissions of the ACL token are not found, removing them from the state", map[string]interface{}{"acl_token": sn.GetToken()})
+		return nil
+	}
+
+	d.Set("permissions", principalPermissions.Permissions)
+	return nil
+}
+
+func resourceServiceEndpointPermissionsDelete(d *schema.ResourceData, m interface{}) error {
+	clients := m.(*client.AggregatedClient)
+
+	sn, err := securityhelper.NewSecurityNamespace(d, clients, securityhelper.SecurityNamespaceIDValues.ServiceEndpoints, createServiceEndpointToken)
+	if err != nil {
+		return err
+	}
+
+	if err := securityhelper.SetPrincipalPermissions(d, sn, &securityhelper.PermissionTypeValues.NotSet, true); err != nil {
+		return err
+	}
+	d.SetId("")
+	return nil
+}
+
+// importServiceEndpointPermissions imports the permissions of a principal with an ID like <project name or ID>/<principal>,
+// or <project name or ID>/<service endpoint ID>/<principal> for the permissions on a single service endpoint. The
+// permissions which are set for the principal are read back.
+func importServiceEndpointPermissions(d *schema.ResourceData, m interface{}) ([]*schema.ResourceData, error) {
+	parts := strings.SplitN(d.Id(), "/", 3)
+	if len(parts) < 2 || parts[0] == "" || parts[1] == "" {
+		return nil, fmt.Errorf("unexpected format of ID (%s), expected <project name or ID>/<principal> or <project name or ID>/<service endpoint ID>/<principal>", d.Id())
+	}
+
+	projectID, err := tfhelper.GetRealProjectId(parts[0], m)
+	if err != nil {
+		return nil, err
+	}
+	principal := strings.Join(parts[1:], "/")
+	if len(parts) == 3 {
+		if _, err := uuid.ParseUUID(parts[1]); err == nil {
+			d.Set("serviceendpoint_id", parts[1])
+			principal = parts[2]
+		}
+	}
+
+	d.Set("project_id", projectID)
+	d.Set("principal", principal)
+	d.Set("replace", true)
+
+	token, err := createServiceEndpointToken(d, nil)
+	if err != nil {
+		return nil, err
+	}
+	d.SetId(fmt.Sprintf("%s/%s", token, principal))
+	return []*schema.ResourceData{d}, nil
+}
+
+// createServiceEndpointToken returns the token of the project, endpoints/<project ID>, or of the service endpoint,
+// endpoints/<project ID>/<service endpoint ID>
+func createServiceEndpointToken(d *schema.ResourceData, clients *client.AggregatedClient) (string, error) {
+	projectID, ok := d.GetOk("project_id")
+	if !ok {
+		return "", fmt.Errorf("failed to get 'project_id' from schema")
+	}
+
+	aclToken := fmt.Sprintf("endpoints/%s", projectID.(string))
+	if serviceEndpointID, ok := d.GetOk("serviceendpoint_id"); ok {
+		aclToken = fmt.Sprintf("%s/%s", aclToken, serviceEndpointID.(string))
+	}
+	return aclToken, nil
+}
@@ -0,0 +1,79 @@
+package permissions
+
+import (
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	testServiceEndpointProjectID = "9083e944-8e9e-405e-960a-c80180aa71e6"
+	testServiceEndpointID        = "1ceae7ff-565c-4cdf-9214-6e2246cba764"
+	testServiceEndpointPrincipal = "vssgp.Uy0xLTktMTU1MTM3NDI0NS0yNDU1NjAwOTc1LTI4NjEzNDU5NS0yODk0NDM2NzIyLTIyNTMwNDg3NzAtMS0zNzc2MDEyNDM3LTI1MjAyNTQ3OTAtMjYxOTIwMDAzOS0yNTg5OTY1NzE4"
+)
+
+func TestServiceEndpointPermissions_CreateServiceEndpointToken(t *testing.T) {
+	d := schema.TestResourceDataRaw(t, ResourceServiceEndpointPermissions().Schema, map[string]interface{}{
+		"project_id": testServiceEndpointProjectID,
+	})
+	token, err := createServiceEndpointToken(d, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, "endpoints/"+testServiceEndpointProjectID, token)
+
+	d.Set("serviceendpoint_id", testServiceEndpointID)
+	token, err = createServiceEndpointToken(d, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, "endpoints/"+testServiceEndpointProjectID+"/"+testServiceEndpointID, token)
+
+	d = schema.TestResourceDataRaw(t, ResourceServiceEndpointPermissions().Schema, nil)
+	token, err = createServiceEndpointToken(d, nil)
+	assert.Empty(t, token)
+	assert.EqualError(t, err, "failed to get 'project_id' from schema")
+}
+
+func TestServiceEndpointPermissions_Import(t *testing.T) {
+	tests := []struct {
+		name                  string
+		id                    string
+		wantID                string
+		wantServiceEndpointID string
+		wantErr               bool
+	}{
+		{
+			name:   "project",
+			id:     testServiceEndpointProjectID + "/" + testServiceEndpointPrincipal,
+			wantID: "endpoints/" + testServiceEndpointProjectID + "/" + testServiceEndpointPrincipal,
+		},
+		{
+			name:                  "service endpoint",
+			id:                    testServiceEndpointProjectID + "/" + testServiceEndpointID + "/" + testServiceEndpointPrincipal,
+			wantID:                "endpoints/" + testServiceEndpointProjectID + "/" + testServiceEndpointID + "/" + testServiceEndpointPrincipal,
+			wantServiceEndpointID: testServiceEndpointID,
+		},
+		{
+			name:    "no principal",
+			id:      testServiceEndpointProjectID,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := schema.TestResourceDataRaw(t, ResourceServiceEndpointPermissions().Schema, nil)
+			d.SetId(tt.id)
+
+			imported, err := importServiceEndpointPermissions(d, nil)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.Nil(t, err)
+			assert.Len(t, imported, 1)
+			assert.Equal(t, tt.wantID, d.Id())
+			assert.Equal(t, testServiceEndpointProjectID, d.Get("project_id"))
+			assert.Equal(t, tt.wantServiceEndpointID, d.Get("serviceendpoint_id"))
+			assert.Equal(t, testServiceEndpointPrincipal, d.Get("principal"))
+			assert.Equal(t, true, d.Get("replace"))
+		})
+	}
+}
@@ -5,6 +5,7 @@ package utils
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"strings"
 	"testing"
@@ -71,7 +72,7 @@ func TestClassificationNode_CreateIterationToken_HandleError(t *testing.T) {
 			StructureGroup: &workitemtracking.TreeStructureGroupValues.Iterations,
 			Depth:          converter.Int(1),
 		}).
-		Return(nil, fmt.Errorf(errMsg)).
+		Return(nil, errors.New(errMsg)).
 		Times(1)
 
 	token, err := CreateClassificationNodeSecurityToken(clients.Ctx, clients.WorkItemTrackingClient, workitemtracking.TreeStructureGroupValues.Iterations, iterationProjectID, "/")
@@ -113,7 +114,7 @@ func TestClassificationNode_CreateIterationToken_HandleErrorInPath(t *testing.T)
 			StructureGroup: &workitemtracking.TreeStructureGroupValues.Iterations,
 			Depth:          converter.Int(1),
 		}).
-		Return(nil, fmt.Errorf(errMsg)).
+		Return(nil, errors.New(errMsg)).
 		Times(1)
 
 	token, err := CreateClassificationNodeSecurityToken(clients.Ctx, clients.WorkItemTrackingClient, workitemtracking.TreeStructureGroupValues.Iterations, iterationProjectID, "/iteration")
@@ -3,13 +3,17 @@ package utils
 import (
 	"context"
 	"fmt"
-	"log"
+	"reflect"
+	"sort"
 	"strings"
 
 	"github.com/ahmetb/go-linq"
 	"github.com/babylonhealth/terraform-provider-bblnazuredevops/bblnazuredevops/internal/client"
+	"github.com/babylonhealth/terraform-provider-bblnazuredevops/bblnazuredevops/internal/client/logging"
+	"github.com/babylonhealth/terraform-provider-bblnazuredevops/bblnazuredevops/internal/utils/cache"
 	"github.com/babylonhealth/terraform-provider-bblnazuredevops/bblnazuredevops/internal/utils/converter"
 	"github.com/google/uuid"
+	"github.com/hashicorp/terraform-plugin-log/tflog"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
 	"github.com/microsoft/azure-devops-go-api/azuredevops/v6/identity"
 	"github.com/microsoft/azure-devops-go-api/azuredevops/v6/security"
@@ -164,6 +168,59 @@ var SecurityNamespaceIDValues = securityNamespaceIDValuesType{
 	VersionControlItems:            SecurityNamespaceID(uuid.MustParse("a39371cf-0841-4c16-bbd3-276e341bc052")),
 }
 
+// ParseSecurityNamespaceID returns the ID of a security namespace given by its name in SecurityNamespaceIDValues,
+// which is case insensitive, or by its ID
+func ParseSecurityNamespaceID(nameOrID string) (SecurityNamespaceID, error) {
+	if id, err := uuid.Parse(nameOrID); err == nil {
+		return SecurityNamespaceID(id), nil
+	}
+
+	values := reflect.ValueOf(SecurityNamespaceIDValues)
+	for i := 0; i < values.NumField(); i++ {
+		if strings.EqualFold(values.Type().Field(i).Name, nameOrID) {
+			return values.Field(i).Interface().(SecurityNamespaceID), nil
+		}
+	}
+	return SecurityNamespaceID(uuid.Nil), fmt.Errorf("unknown security namespace %q, expected the name of a namespace, e.g. Environment or GitRepositories, or its ID", nameOrID)
+}
+
+// ValidatePermissions checks the actions of the permissions against the actions defined by the security namespace,
+// and their values against the permission types
+func ValidatePermissions(clients *client.AggregatedClient, namespaceID SecurityNamespaceID, permissions map[string]interface{}) error {
+	sn := &SecurityNamespace{
+		context:        clients.Ctx,
+		namespaceID:    uuid.UUID(namespaceID),
+		securityClient: clients.SecurityClient,
+		cache:          clients.Cache,
+	}
+	actions, err := sn.getActionDefinitions()
+	if err != nil {
+		return err
+	}
+
+	names := []string{}
+	for name := range *actions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	keys := []string{}
+	for key := range permissions {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if _, ok := (*actions)[key]; !ok {
+			return fmt.Errorf("the permission %s is not an action of the security namespace %s, expected one of [%s]", key, uuid.UUID(namespaceID), strings.Join(names, ", "))
+		}
+		value := permissions[key].(string)
+		if !strings.EqualFold(value, string(PermissionTypeValues.Allow)) && !strings.EqualFold(value, string(PermissionTypeValues.Deny)) && !strings.EqualFold(value, string(PermissionTypeValues.NotSet)) {
+			return fmt.Errorf("invalid value %q of the permission %s, expected one of allow, deny or notset", value, key)
+		}
+	}
+	return nil
+}
+
 // PrincipalPermission describes permissions of a principal
 type PrincipalPermission struct {
 	SubjectDescriptor string
@@ -182,6 +239,7 @@ type SecurityNamespace struct {
 	context        context.Context
 	securityClient security.Client
 	identityClient identity.Client
+	cache          *cache.Cache
 	actions        *map[string]security.ActionDefinition
 	token          string
 }
@@ -205,6 +263,7 @@ func NewSecurityNamespace(d *schema.ResourceData, clients *client.AggregatedClie
 	sn.namespaceID = uuid.UUID(namespaceID)
 	sn.securityClient = clients.SecurityClient
 	sn.identityClient = clients.IdentityClient
+	sn.cache = clients.Cache
 	token, err := tokenCreator(d, clients)
 	if err != nil {
 		return nil, err
@@ -213,6 +272,11 @@ func NewSecurityNamespace(d *schema.ResourceData, clients *client.AggregatedClie
 	return sn, nil
 }
 
+func (sn *SecurityNamespace) logTrace(msg string, fields map[string]interface{}) {
+	ctx := logging.WithSubsystem(sn.context, logging.SubsystemSecurity)
+	tflog.SubsystemTrace(ctx, logging.SubsystemSecurity, msg, fields)
+}
+
 // GetToken return namespace tokens
 func (sn *SecurityNamespace) GetToken() string {
 	return sn.token
@@ -220,25 +284,36 @@ func (sn *SecurityNamespace) GetToken() string {
 
 func (sn *SecurityNamespace) getActionDefinitions() (*map[string]security.ActionDefinition, error) {
 	if sn.actions == nil {
-		secns, err := sn.securityClient.QuerySecurityNamespaces(sn.context, security.QuerySecurityNamespacesArgs{
-			SecurityNamespaceId: &sn.namespaceID,
+		// the action definitions of a namespace never change, so they are shared by all resources of the provider
+		actions, err := sn.cache.GetOrLoad(sn.context, cache.Key("security", "namespaces", sn.namespaceID.String()), func() (interface{}, error) {
+			return sn.queryActionDefinitions()
 		})
 		if err != nil {
 			return nil, err
 		}
-		if secns == nil || len(*secns) <= 0 || (*secns)[0].Actions == nil || len(*(*secns)[0].Actions) <= 0 {
-			return nil, fmt.Errorf("Failed to load security namespace definition with id [%s]", sn.namespaceID)
-		}
-
-		actionMap := map[string]security.ActionDefinition{}
-		for _, action := range *(*secns)[0].Actions {
-			actionMap[*action.Name] = action
-		}
-		sn.actions = &actionMap
+		sn.actions = actions.(*map[string]security.ActionDefinition)
 	}
 	return sn.actions, nil
 }
 
+func (sn *SecurityNamespace) queryActionDefinitions() (*map[string]security.ActionDefinition, error) {
+	secns, err := sn.securityClient.QuerySecurityNamespaces(sn.context, security.QuerySecurityNamespacesArgs{
+		SecurityNamespaceId: &sn.namespaceID,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if secns == nil || len(*secns) <= 0 || (*secns)[0].Actions == nil || len(*(*secns)[0].Actions) <= 0 {
+		return nil, fmt.Errorf("Failed to load security namespace definition with id [%s]", sn.namespaceID)
+	}
+
+	actionMap := map[string]security.ActionDefinition{}
+	for _, action := range *(*secns)[0].Actions {
+		actionMap[*action.Name] = action
+	}
+	return &actionMap, nil
+}
+
 func (sn *SecurityNamespace) getAccessControlList(descriptorList *[]string) (*security.AccessControlList, error) {
 	var descriptors *string = nil
 	if descriptorList != nil && len(*descriptorList) > 0 {
@@ -356,18 +431,18 @@ func (sn *SecurityNamespace) SetPrincipalPermissions(permissionList *[]SetPrinci
 			return fmt.Errorf("Unable to resolve id descriptor for principal [%s]", subjectDescriptor)
 		}
 
-		log.Printf("[TRACE]Checking ACE list for descriptor [%s]", subjectDescriptor)
+		sn.logTrace("Checking ACE list for descriptor", map[string]interface{}{"descriptor": subjectDescriptor})
 		var aceItem *security.AccessControlEntry
 		ace, update := aceMap[*desc.Descriptor]
 		if !update {
-			log.Printf("[TRACE]Creating new ACE for subject [%s]", subjectDescriptor)
+			sn.logTrace("Creating new ACE for subject", map[string]interface{}{"descriptor": subjectDescriptor})
 			aceItem = new(security.AccessControlEntry)
 			aceItem.Allow = new(int)
 			aceItem.Deny = new(int)
 			aceItem.Descriptor = desc.Descriptor
 		} else {
 			// update existing ACE for principal
-			log.Printf("[TRACE]Updating ACE for descriptor [%s]", *desc.Descriptor)
+			sn.logTrace("Updating ACE for descriptor", map[string]interface{}{"descriptor": *desc.Descriptor})
 			aceItem = &ace
 		}
 
@@ -506,7 +581,7 @@ func (sn *SecurityNamespace) RemovePrincipalPermissions(principal *[]string) err
 			return r.(string) + "," + desc
 		}).(string)
 
-	log.Printf("[TRACE]RemovePrincipalPermissions: removing the following principals from the ACL %s", val)
+	sn.logTrace("RemovePrincipalPermissions: removing principals from the ACL", map[string]interface{}{"descriptors": val, "token": sn.token})
 	bRet, err := sn.securityClient.RemoveAccessControlEntries(sn.context, security.RemoveAccessControlEntriesArgs{
 		SecurityNamespaceId: &sn.namespaceID,
 		Token:               &sn.token,
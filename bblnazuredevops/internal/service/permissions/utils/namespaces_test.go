@@ -6,6 +6,7 @@ package utils
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"reflect"
 	"sort"
@@ -317,7 +318,7 @@ func TestSecurityNamespace_GetActionDefinitions_HandleError(t *testing.T) {
 	securityClient.
 		EXPECT().
 		QuerySecurityNamespaces(clients.Ctx, gomock.Any()).
-		Return(nil, fmt.Errorf(errMsg)).
+		Return(nil, errors.New(errMsg)).
 		Times(1)
 
 	defs, err := sn.getActionDefinitions()
@@ -458,7 +459,7 @@ func TestSecurityNamespace_GetAccessControlList_HandleError(t *testing.T) {
 	securityClient.
 		EXPECT().
 		QueryAccessControlLists(clients.Ctx, gomock.Any()).
-		Return(nil, fmt.Errorf(errMsg)).
+		Return(nil, errors.New(errMsg)).
 		Times(1)
 
 	acl, err := sn.getAccessControlList(&descriptorList)
@@ -614,7 +615,7 @@ func TestSecurityNamespaces_GetIndentitiesFromSubjects_HandleError(t *testing.T)
 	identityClient.
 		EXPECT().
 		ReadIdentities(clients.Ctx, gomock.Any()).
-		Return(nil, fmt.Errorf(errMsg)).
+		Return(nil, errors.New(errMsg)).
 		Times(1)
 
 	idList, err := sn.getIdentitiesFromSubjects(&subjectDescriptorList)
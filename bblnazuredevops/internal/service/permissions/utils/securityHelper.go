@@ -47,17 +47,15 @@ func SetPrincipalPermissions(d *schema.ResourceData, sn *SecurityNamespace, forc
 	return nil
 }
 
-// GetPrincipalPermissions gets permissions for a specific security namespac
+// GetPrincipalPermissions gets permissions for a specific security namespac. Only the managed actions are returned,
+// or the actions which are allowed or denied when no action is managed yet, e.g. when the resource is imported.
 func GetPrincipalPermissions(d *schema.ResourceData, sn *SecurityNamespace) (*PrincipalPermission, error) {
 	principal, ok := d.GetOk("principal")
 	if !ok {
 		return nil, fmt.Errorf("Failed to get 'principal' from schema")
 	}
 
-	permissions, ok := d.GetOk("permissions")
-	if !ok {
-		return nil, fmt.Errorf("Failed to get 'permissions' from schema")
-	}
+	permissions, isManaged := d.GetOk("permissions")
 
 	principalList := []string{*converter.StringFromInterface(principal)}
 	principalPermissions, err := sn.GetPrincipalPermissions(&principalList)
@@ -70,8 +68,12 @@ func GetPrincipalPermissions(d *schema.ResourceData, sn *SecurityNamespace) (*Pr
 	if len(*principalPermissions) != 1 {
 		return nil, fmt.Errorf("Failed to retrieve current permissions for principal [%s]", principalList[0])
 	}
-	for key := range ((*principalPermissions)[0]).Permissions {
-		if _, ok := permissions.(map[string]interface{})[string(key)]; !ok {
+	for key, value := range ((*principalPermissions)[0]).Permissions {
+		if !isManaged {
+			if value == PermissionTypeValues.NotSet {
+				delete(((*principalPermissions)[0]).Permissions, key)
+			}
+		} else if _, ok := permissions.(map[string]interface{})[string(key)]; !ok {
 			delete(((*principalPermissions)[0]).Permissions, key)
 		}
 	}
@@ -0,0 +1,94 @@
+//go:build (all || utils || securitynamespaces) && !exclude_securitynamespaces
+// +build all utils securitynamespaces
+// +build !exclude_securitynamespaces
+
+package utils
+
+import (
+	"context"
+	"testing"
+
+	"github.com/babylonhealth/terraform-provider-bblnazuredevops/azdosdkmocks"
+	"github.com/babylonhealth/terraform-provider-bblnazuredevops/bblnazuredevops/internal/client"
+	"github.com/babylonhealth/terraform-provider-bblnazuredevops/bblnazuredevops/internal/utils/converter"
+	"github.com/golang/mock/gomock"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/microsoft/azure-devops-go-api/azuredevops/v6/identity"
+	"github.com/microsoft/azure-devops-go-api/azuredevops/v6/security"
+	"github.com/stretchr/testify/require"
+)
+
+// Only the managed actions are read back, or the allowed and denied ones when no action is managed yet
+func TestGetPrincipalPermissions_ManagedActions(t *testing.T) {
+	principal := projectIdentityList[1]
+	tests := []struct {
+		name        string
+		permissions map[string]interface{}
+		want        map[ActionName]PermissionType
+	}{
+		{
+			name:        "managed actions",
+			permissions: map[string]interface{}{"GENERIC_READ": "allow", "START_BUILD": "allow"},
+			want:        map[ActionName]PermissionType{"GENERIC_READ": PermissionTypeValues.NotSet, "START_BUILD": PermissionTypeValues.Allow},
+		},
+		{
+			name: "imported",
+			want: map[ActionName]PermissionType{
+				"ADMINISTER_BUILD":  PermissionTypeValues.Allow,
+				"START_BUILD":       PermissionTypeValues.Allow,
+				"EDIT_BUILD_STATUS": PermissionTypeValues.Deny,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			securityClient := azdosdkmocks.NewMockSecurityClient(ctrl)
+			identityClient := azdosdkmocks.NewMockIdentityClient(ctrl)
+			clients := &client.AggregatedClient{
+				SecurityClient: securityClient,
+				IdentityClient: identityClient,
+				Ctx:            context.Background(),
+			}
+
+			securityClient.EXPECT().
+				QuerySecurityNamespaces(clients.Ctx, gomock.Any()).
+				Return(&securityNamespaceDescriptionProject, nil).
+				Times(1)
+			identityClient.EXPECT().
+				ReadIdentities(clients.Ctx, gomock.Any()).
+				Return(&[]identity.Identity{principal}, nil).
+				Times(1)
+			securityClient.EXPECT().
+				QueryAccessControlLists(clients.Ctx, gomock.Any()).
+				Return(&[]security.AccessControlList{{
+					AcesDictionary: &map[string]security.AccessControlEntry{
+						*principal.Descriptor: {
+							Descriptor: principal.Descriptor,
+							Allow:      converter.Int(48),
+							Deny:       converter.Int(64),
+						},
+					},
+					Token: converter.String("@@accTest@@"),
+				}}, nil).
+				Times(1)
+
+			config := map[string]interface{}{"principal": *principal.SubjectDescriptor}
+			if tt.permissions != nil {
+				config["permissions"] = tt.permissions
+			}
+			d := schema.TestResourceDataRaw(t, CreatePermissionResourceSchema(map[string]*schema.Schema{}), config)
+			sn, err := NewSecurityNamespace(d, clients, SecurityNamespaceIDValues.Project, func(d *schema.ResourceData, clients *client.AggregatedClient) (string, error) {
+				return "@@accTest@@", nil
+			})
+			require.NoError(t, err)
+
+			got, err := GetPrincipalPermissions(d, sn)
+			require.NoError(t, err)
+			require.Equal(t, *principal.SubjectDescriptor, got.SubjectDescriptor)
+			require.Equal(t, tt.want, got.Permissions)
+		})
+	}
+}
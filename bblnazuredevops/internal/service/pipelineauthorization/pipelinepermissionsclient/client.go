@@ -0,0 +1,144 @@
+package pipelinepermissionsclient
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/babylonhealth/terraform-provider-bblnazuredevops/bblnazuredevops/internal/client/apiversion"
+)
+
+type PipelinePermissionsClient interface {
+	GetPipelinePermissions(ctx context.Context, projectID string, resourceType string, resourceID string) (ResourcePipelinePermissions, bool, error)
+	UpdatePipelinePermissions(ctx context.Context, projectID string, permissions ResourcePipelinePermissions) (ResourcePipelinePermissions, error)
+}
+
+// NewPipelinePermissions returns a client of the pipelinePermissions API, which authorizes pipelines to use
+// protected resources like service endpoints, agent queues or variable groups
+func NewPipelinePermissions(baseUrl string, auth string, timeout *time.Duration, options ...Option) *PipelinePermissions {
+	defaultTime := time.Duration(60 * time.Second)
+	if timeout == nil {
+		timeout = &defaultTime
+	}
+
+	client := &http.Client{
+		Timeout: *timeout,
+	}
+
+	p := &PipelinePermissions{
+		baseUrl:       baseUrl,
+		client:        client,
+		authorization: auth,
+	}
+	for _, option := range options {
+		option(p)
+	}
+	return p
+}
+
+// Option configures optional behaviour of a PipelinePermissions client
+type Option func(*PipelinePermissions)
+
+// WithVersionNegotiator makes the client negotiate the api-version of every request with the server
+func WithVersionNegotiator(negotiator *apiversion.Negotiator) Option {
+	return func(p *PipelinePermissions) {
+		p.negotiator = negotiator
+	}
+}
+
+// WithTransport sends the requests of the client through transport
+func WithTransport(transport http.RoundTripper) Option {
+	return func(p *PipelinePermissions) {
+		p.client.Transport = transport
+	}
+}
+
+// statusError is returned by SendRequest when Azure DevOps answers with an error status
+type statusError struct {
+	StatusCode int
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("resp status code from azure 400 or above: %v", e.StatusCode)
+}
+
+func pipelinePermissionsURL(projectID string, resourceType string, resourceID string) string {
+	return fmt.Sprintf("/%s/_apis/pipelines/pipelinePermissions/%s/%s", url.PathEscape(projectID), url.PathEscape(resourceType), url.PathEscape(resourceID))
+}
+
+// GetPipelinePermissions returns the pipelines authorized to use the resource. The boolean is false when the
+// resource does not exist.
+func (p *PipelinePermissions) GetPipelinePermissions(ctx context.Context, projectID string, resourceType string, resourceID string) (ResourcePipelinePermissions, bool, error) {
+	acceptHeaders := p.negotiator.AcceptHeader(ctx, apiversion.ResourceValues.PipelinePermissions)
+	resp, err := p.SendRequest(ctx, "GET", pipelinePermissionsURL(projectID, resourceType, resourceID), "", acceptHeaders)
+	if err != nil {
+		if statusErr, ok := err.(*statusError); ok && statusErr.StatusCode == http.StatusNotFound {
+			return ResourcePipelinePermissions{}, false, nil
+		}
+		return ResourcePipelinePermissions{}, false, err
+	}
+
+	permissions := ResourcePipelinePermissions{}
+	if err := json.Unmarshal(resp, &permissions); err != nil {
+		return ResourcePipelinePermissions{}, false, err
+	}
+	return permissions, true, nil
+}
+
+// UpdatePipelinePermissions authorizes, or stops authorizing, the pipelines of permissions. Pipelines which are
+// not listed keep their authorization.
+func (p *PipelinePermissions) UpdatePipelinePermissions(ctx context.Context, projectID string, permissions ResourcePipelinePermissions) (ResourcePipelinePermissions, error) {
+	payloadJson, err := json.Marshal(permissions)
+	if err != nil {
+		return ResourcePipelinePermissions{}, err
+	}
+
+	acceptHeaders := p.negotiator.AcceptHeader(ctx, apiversion.ResourceValues.PipelinePermissions)
+	resp, err := p.SendRequest(ctx, "PATCH", pipelinePermissionsURL(projectID, permissions.Resource.Type, permissions.Resource.ID), string(payloadJson), acceptHeaders)
+	if err != nil {
+		return ResourcePipelinePermissions{}, err
+	}
+
+	updated := ResourcePipelinePermissions{}
+	if err := json.Unmarshal(resp, &updated); err != nil {
+		return ResourcePipelinePermissions{}, err
+	}
+	return updated, nil
+}
+
+func (p *PipelinePermissions) SendRequest(ctx context.Context, httpMethod string, url string, jsonPayload string, acceptHeaders string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, httpMethod, p.baseUrl+url, bytes.NewBufferString(jsonPayload))
+	if err != nil {
+		return []byte{}, err
+	}
+
+	req.Header.Set("Authorization", p.authorization)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", acceptHeaders)
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return []byte{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == 203 {
+		return []byte{}, fmt.Errorf("resp status code from azure 203 - need auth")
+	}
+
+	if resp.StatusCode > 399 {
+		return []byte{}, &statusError{StatusCode: resp.StatusCode}
+	}
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return []byte{}, err
+	}
+
+	return body, nil
+}
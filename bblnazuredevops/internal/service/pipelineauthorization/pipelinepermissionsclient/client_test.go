@@ -0,0 +1,83 @@
+package pipelinepermissionsclient
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const testProjectID = "4f7f5d92-0e11-4311-ac85-9972864acbc2"
+
+func TestPipelinePermissions_GetPipelinePermissions(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, http.MethodGet, r.Method)
+		switch r.URL.Path {
+		case "/" + testProjectID + "/_apis/pipelines/pipelinePermissions/endpoint/627166ad-752b-47f7-a115-7bdcb385931e":
+			fmt.Fprint(w, `{"resource":{"type":"endpoint","id":"627166ad-752b-47f7-a115-7bdcb385931e"},"allPipelines":{"authorized":false},"pipelines":[{"id":12,"authorized":true},{"id":13,"authorized":false}]}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer ts.Close()
+
+	p := NewPipelinePermissions(ts.URL, "Basic token", nil)
+
+	got, found, err := p.GetPipelinePermissions(context.Background(), testProjectID, ResourceTypeEndpoint, "627166ad-752b-47f7-a115-7bdcb385931e")
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, ResourcePipelinePermissions{
+		Resource:     Resource{Type: ResourceTypeEndpoint, ID: "627166ad-752b-47f7-a115-7bdcb385931e"},
+		AllPipelines: &Permission{Authorized: false},
+		Pipelines: []PipelinePermission{
+			{ID: 12, Authorized: true},
+			{ID: 13, Authorized: false},
+		},
+	}, got)
+
+	_, found, err = p.GetPipelinePermissions(context.Background(), testProjectID, ResourceTypeQueue, "42")
+	require.NoError(t, err)
+	require.False(t, found)
+}
+
+func TestPipelinePermissions_UpdatePipelinePermissions(t *testing.T) {
+	var sent ResourcePipelinePermissions
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, http.MethodPatch, r.Method)
+		require.Equal(t, "/"+testProjectID+"/_apis/pipelines/pipelinePermissions/variablegroup/7", r.URL.Path)
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&sent))
+		require.NoError(t, json.NewEncoder(w).Encode(sent))
+	}))
+	defer ts.Close()
+
+	p := NewPipelinePermissions(ts.URL, "Basic token", nil)
+
+	permissions := ResourcePipelinePermissions{
+		Resource: Resource{Type: ResourceTypeVariableGroup, ID: "7"},
+		Pipelines: []PipelinePermission{
+			{ID: 12, Authorized: true},
+		},
+	}
+	got, err := p.UpdatePipelinePermissions(context.Background(), testProjectID, permissions)
+	require.NoError(t, err)
+	require.Equal(t, permissions, sent)
+	require.Equal(t, permissions, got)
+}
+
+func TestPipelinePermissions_UpdatePipelinePermissions_Error(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer ts.Close()
+
+	p := NewPipelinePermissions(ts.URL, "Basic token", nil)
+
+	_, err := p.UpdatePipelinePermissions(context.Background(), testProjectID, ResourcePipelinePermissions{
+		Resource: Resource{Type: ResourceTypeEndpoint, ID: "627166ad-752b-47f7-a115-7bdcb385931e"},
+	})
+	require.EqualError(t, err, "resp status code from azure 400 or above: 403")
+}
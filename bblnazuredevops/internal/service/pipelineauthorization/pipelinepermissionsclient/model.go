@@ -0,0 +1,60 @@
+package pipelinepermissionsclient
+
+import (
+	"net/http"
+
+	"github.com/babylonhealth/terraform-provider-bblnazuredevops/bblnazuredevops/internal/client/apiversion"
+)
+
+// Types of the protected resources pipelines are authorized to use
+const (
+	ResourceTypeEndpoint      = "endpoint"
+	ResourceTypeQueue         = "queue"
+	ResourceTypeEnvironment   = "environment"
+	ResourceTypeVariableGroup = "variablegroup"
+	ResourceTypeSecureFile    = "securefile"
+	ResourceTypeRepository    = "repository"
+)
+
+// ResourceTypes returns all types of protected resources
+func ResourceTypes() []string {
+	return []string{
+		ResourceTypeEndpoint,
+		ResourceTypeQueue,
+		ResourceTypeEnvironment,
+		ResourceTypeVariableGroup,
+		ResourceTypeSecureFile,
+		ResourceTypeRepository,
+	}
+}
+
+type PipelinePermissions struct {
+	baseUrl       string
+	client        *http.Client
+	authorization string
+	negotiator    *apiversion.Negotiator
+}
+
+// ResourcePipelinePermissions lists the pipelines authorized to use a protected resource
+type ResourcePipelinePermissions struct {
+	Resource     Resource             `json:"resource"`
+	AllPipelines *Permission          `json:"allPipelines,omitempty"`
+	Pipelines    []PipelinePermission `json:"pipelines"`
+}
+
+type Resource struct {
+	Type string `json:"type"`
+	ID   string `json:"id"`
+	Name string `json:"name,omitempty"`
+}
+
+type Permission struct {
+	Authorized   bool   `json:"authorized"`
+	AuthorizedOn string `json:"authorizedOn,omitempty"`
+}
+
+type PipelinePermission struct {
+	ID           int    `json:"id"`
+	Authorized   bool   `json:"authorized"`
+	AuthorizedOn string `json:"authorizedOn,omitempty"`
+}
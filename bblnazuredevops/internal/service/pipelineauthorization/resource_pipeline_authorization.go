@@ -0,0 +1,235 @@
+package pipelineauthorization
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/babylonhealth/terraform-provider-bblnazuredevops/bblnazuredevops/internal/client"
+	"github.com/babylonhealth/terraform-provider-bblnazuredevops/bblnazuredevops/internal/service/pipelineauthorization/pipelinepermissionsclient"
+	"github.com/babylonhealth/terraform-provider-bblnazuredevops/bblnazuredevops/internal/utils/tfhelper"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+)
+
+// ResourcePipelineAuthorization schema and implementation for the authorization of pipelines to use a protected
+// resource. The pipelines of the resource are the authorized ones, others are revoked when the resource is applied.
+func ResourcePipelineAuthorization() *schema.Resource {
+	return &schema.Resource{
+		Create:        resourcePipelineAuthorizationCreateOrUpdate,
+		Read:          resourcePipelineAuthorizationRead,
+		Update:        resourcePipelineAuthorizationCreateOrUpdate,
+		Delete:        resourcePipelineAuthorizationDelete,
+		CustomizeDiff: tfhelper.CustomizeDiffProjectID,
+		Importer: &schema.ResourceImporter{
+			State: importPipelineAuthorization,
+		},
+		Schema: map[string]*schema.Schema{
+			"project_id": tfhelper.ProjectIDSchema(),
+			"resource_type": {
+				Type:         schema.TypeString,
+				Required:     true,
+				ForceNew:     true,
+				ValidateFunc: validation.StringInSlice(pipelinepermissionsclient.ResourceTypes(), false),
+				Description:  fmt.Sprintf("The type of the protected resource, one of %s.", strings.Join(pipelinepermissionsclient.ResourceTypes(), ", ")),
+			},
+			"resource_id": {
+				Type:         schema.TypeString,
+				Required:     true,
+				ForceNew:     true,
+				ValidateFunc: validation.StringIsNotWhiteSpace,
+				Description:  "The ID of the protected resource. The ID of a repository is prefixed with the ID of its project, <project ID>.<repository ID>, when it is not in the project of the authorization.",
+			},
+			"all_pipelines": {
+				Type:         schema.TypeBool,
+				Optional:     true,
+				ExactlyOneOf: []string{"all_pipelines", "pipeline_ids"},
+				Description:  "Whether all pipelines of the project are authorized to use the resource.",
+			},
+			"pipeline_ids": {
+				Type:         schema.TypeSet,
+				Optional:     true,
+				ExactlyOneOf: []string{"all_pipelines", "pipeline_ids"},
+				Elem: &schema.Schema{
+					Type:         schema.TypeInt,
+					ValidateFunc: validation.IntAtLeast(1),
+				},
+				Description: "The IDs of the pipelines authorized to use the resource.",
+			},
+		},
+	}
+}
+
+func resourcePipelineAuthorizationCreateOrUpdate(d *schema.ResourceData, m interface{}) error {
+	clients := m.(*client.AggregatedClient)
+
+	projectID, err := tfhelper.ResolveProjectID(d, m)
+	if err != nil {
+		return err
+	}
+	resourceType := d.Get("resource_type").(string)
+	resourceID := apiResourceID(projectID, resourceType, d.Get("resource_id").(string))
+
+	current, _, err := clients.PipelinePermissionsClient.GetPipelinePermissions(clients.Ctx, projectID, resourceType, resourceID)
+	if err != nil {
+		return fmt.Errorf("error reading the pipelines authorized to use %s %s: %+v", resourceType, resourceID, err)
+	}
+
+	permissions := expandPipelineAuthorization(d, resourceType, resourceID, current)
+	if _, err := clients.PipelinePermissionsClient.UpdatePipelinePermissions(clients.Ctx, projectID, permissions); err != nil {
+		return fmt.Errorf("error authorizing pipelines to use %s %s: %+v", resourceType, resourceID, err)
+	}
+
+	d.SetId(fmt.Sprintf("%s/%s/%s", projectID, resourceType, d.Get("resource_id").(string)))
+	return resourcePipelineAuthorizationRead(d, m)
+}
+
+func resourcePipelineAuthorizationRead(d *schema.ResourceData, m interface{}) error {
+	clients := m.(*client.AggregatedClient)
+
+	projectID := d.Get("project_id").(string)
+	resourceType := d.Get("resource_type").(string)
+	resourceID := apiResourceID(projectID, resourceType, d.Get("resource_id").(string))
+
+	permissions, found, err := clients.PipelinePermissionsClient.GetPipelinePermissions(clients.Ctx, projectID, resourceType, resourceID)
+	if err != nil {
+		return fmt.Errorf("error reading the pipelines authorized to use %s %s: %+v", resourceType, resourceID, err)
+	}
+	if !found {
+		d.SetId("")
+		return nil
+	}
+
+	flattenPipelineAuthorization(d, permissions)
+	return nil
+}
+
+func resourcePipelineAuthorizationDelete(d *schema.ResourceData, m interface{}) error {
+	clients := m.(*client.AggregatedClient)
+
+	projectID := d.Get("project_id").(string)
+	resourceType := d.Get("resource_type").(string)
+	resourceID := apiResourceID(projectID, resourceType, d.Get("resource_id").(string))
+
+	permissions := pipelinepermissionsclient.ResourcePipelinePermissions{
+		Resource: pipelinepermissionsclient.Resource{
+			Type: resourceType,
+			ID:   resourceID,
+		},
+		Pipelines: []pipelinepermissionsclient.PipelinePermission{},
+	}
+	if d.Get("all_pipelines").(bool) {
+		permissions.AllPipelines = &pipelinepermissionsclient.Permission{Authorized: false}
+	}
+	for _, pipelineID := range expandPipelineIDs(d) {
+		permissions.Pipelines = append(permissions.Pipelines, pipelinepermissionsclient.PipelinePermission{
+			ID:         pipelineID,
+			Authorized: false,
+		})
+	}
+
+	if permissions.AllPipelines != nil || len(permissions.Pipelines) > 0 {
+		if _, err := clients.PipelinePermissionsClient.UpdatePipelinePermissions(clients.Ctx, projectID, permissions); err != nil {
+			return fmt.Errorf("error revoking the authorization of pipelines to use %s %s: %+v", resourceType, resourceID, err)
+		}
+	}
+
+	d.SetId("")
+	return nil
+}
+
+// importPipelineAuthorization imports an authorization by an ID like <project name or ID>/<resource type>/<resource ID>
+func importPipelineAuthorization(d *schema.ResourceData, m interface{}) ([]*schema.ResourceData, error) {
+	projectNameOrID, resourceType, resourceID, err := parseImportID(d.Id())
+	if err != nil {
+		return nil, err
+	}
+
+	projectID, err := tfhelper.GetRealProjectId(projectNameOrID, m)
+	if err != nil {
+		return nil, err
+	}
+
+	d.Set("project_id", projectID)
+	d.Set("resource_type", resourceType)
+	d.Set("resource_id", resourceID)
+	d.SetId(fmt.Sprintf("%s/%s/%s", projectID, resourceType, resourceID))
+	return []*schema.ResourceData{d}, nil
+}
+
+func parseImportID(id string) (string, string, string, error) {
+	parts := strings.SplitN(id, "/", 3)
+	if len(parts) != 3 || parts[0] == "" || parts[2] == "" {
+		return "", "", "", fmt.Errorf("unexpected format of the ID (%s), expected <project>/<resource type>/<resource ID>", id)
+	}
+
+	for _, resourceType := range pipelinepermissionsclient.ResourceTypes() {
+		if parts[1] == resourceType {
+			return parts[0], parts[1], parts[2], nil
+		}
+	}
+	return "", "", "", fmt.Errorf("unsupported resource type %q, expected one of %s", parts[1], strings.Join(pipelinepermissionsclient.ResourceTypes(), ", "))
+}
+
+// apiResourceID returns the ID of the resource in the pipelinePermissions API. Repositories are identified by
+// the ID of their project and their own ID.
+func apiResourceID(projectID string, resourceType string, resourceID string) string {
+	if resourceType == pipelinepermissionsclient.ResourceTypeRepository && !strings.Contains(resourceID, ".") {
+		return projectID + "." + resourceID
+	}
+	return resourceID
+}
+
+// expandPipelineAuthorization authorizes the pipelines of the resource data and revokes the authorization of the
+// other pipelines which are currently authorized
+func expandPipelineAuthorization(d *schema.ResourceData, resourceType string, resourceID string, current pipelinepermissionsclient.ResourcePipelinePermissions) pipelinepermissionsclient.ResourcePipelinePermissions {
+	permissions := pipelinepermissionsclient.ResourcePipelinePermissions{
+		Resource: pipelinepermissionsclient.Resource{
+			Type: resourceType,
+			ID:   resourceID,
+		},
+		AllPipelines: &pipelinepermissionsclient.Permission{
+			Authorized: d.Get("all_pipelines").(bool),
+		},
+		Pipelines: []pipelinepermissionsclient.PipelinePermission{},
+	}
+
+	authorized := map[int]bool{}
+	for _, pipelineID := range expandPipelineIDs(d) {
+		authorized[pipelineID] = true
+		permissions.Pipelines = append(permissions.Pipelines, pipelinepermissionsclient.PipelinePermission{
+			ID:         pipelineID,
+			Authorized: true,
+		})
+	}
+	for _, pipeline := range current.Pipelines {
+		if pipeline.Authorized && !authorized[pipeline.ID] {
+			permissions.Pipelines = append(permissions.Pipelines, pipelinepermissionsclient.PipelinePermission{
+				ID:         pipeline.ID,
+				Authorized: false,
+			})
+		}
+	}
+	return permissions
+}
+
+func expandPipelineIDs(d *schema.ResourceData) []int {
+	pipelineIDs := []int{}
+	for _, pipelineID := range d.Get("pipeline_ids").(*schema.Set).List() {
+		pipelineIDs = append(pipelineIDs, pipelineID.(int))
+	}
+	sort.Ints(pipelineIDs)
+	return pipelineIDs
+}
+
+func flattenPipelineAuthorization(d *schema.ResourceData, permissions pipelinepermissionsclient.ResourcePipelinePermissions) {
+	d.Set("all_pipelines", permissions.AllPipelines != nil && permissions.AllPipelines.Authorized)
+
+	pipelineIDs := []interface{}{}
+	for _, pipeline := range permissions.Pipelines {
+		if pipeline.Authorized {
+			pipelineIDs = append(pipelineIDs, pipeline.ID)
+		}
+	}
+	d.Set("pipeline_ids", pipelineIDs)
+}
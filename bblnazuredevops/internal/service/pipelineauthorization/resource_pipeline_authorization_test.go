@@ -0,0 +1,161 @@
+package pipelineauthorization
+
+import (
+	"context"
+	"testing"
+
+	"github.com/babylonhealth/terraform-provider-bblnazuredevops/bblnazuredevops/internal/client"
+	"github.com/babylonhealth/terraform-provider-bblnazuredevops/bblnazuredevops/internal/service/pipelineauthorization/pipelinepermissionsclient"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testProjectID  = "4f7f5d92-0e11-4311-ac85-9972864acbc2"
+	testEndpointID = "627166ad-752b-47f7-a115-7bdcb385931e"
+)
+
+// fakePipelinePermissionsClient keeps the permissions of a single resource
+type fakePipelinePermissionsClient struct {
+	permissions pipelinepermissionsclient.ResourcePipelinePermissions
+	updates     []pipelinepermissionsclient.ResourcePipelinePermissions
+}
+
+func (f *fakePipelinePermissionsClient) GetPipelinePermissions(_ context.Context, _ string, resourceType string, resourceID string) (pipelinepermissionsclient.ResourcePipelinePermissions, bool, error) {
+	if resourceType != f.permissions.Resource.Type || resourceID != f.permissions.Resource.ID {
+		return pipelinepermissionsclient.ResourcePipelinePermissions{}, false, nil
+	}
+	return f.permissions, true, nil
+}
+
+func (f *fakePipelinePermissionsClient) UpdatePipelinePermissions(_ context.Context, _ string, permissions pipelinepermissionsclient.ResourcePipelinePermissions) (pipelinepermissionsclient.ResourcePipelinePermissions, error) {
+	f.updates = append(f.updates, permissions)
+
+	if permissions.AllPipelines != nil {
+		f.permissions.AllPipelines = permissions.AllPipelines
+	}
+	pipelines := []pipelinepermissionsclient.PipelinePermission{}
+	updated := map[int]bool{}
+	for _, pipeline := range permissions.Pipelines {
+		updated[pipeline.ID] = true
+		pipelines = append(pipelines, pipeline)
+	}
+	for _, pipeline := range f.permissions.Pipelines {
+		if !updated[pipeline.ID] {
+			pipelines = append(pipelines, pipeline)
+		}
+	}
+	f.permissions.Pipelines = pipelines
+	return f.permissions, nil
+}
+
+func newTestClients(fake *fakePipelinePermissionsClient) *client.AggregatedClient {
+	return &client.AggregatedClient{
+		PipelinePermissionsClient: fake,
+		Ctx:                       context.Background(),
+	}
+}
+
+// Pipelines authorized outside of Terraform are revoked, so that the resource converges to its configuration
+func TestPipelineAuthorization_CreateRevokesOtherPipelines(t *testing.T) {
+	fake := &fakePipelinePermissionsClient{
+		permissions: pipelinepermissionsclient.ResourcePipelinePermissions{
+			Resource: pipelinepermissionsclient.Resource{Type: pipelinepermissionsclient.ResourceTypeEndpoint, ID: testEndpointID},
+			Pipelines: []pipelinepermissionsclient.PipelinePermission{
+				{ID: 5, Authorized: true},
+				{ID: 6, Authorized: false},
+			},
+		},
+	}
+
+	d := schema.TestResourceDataRaw(t, ResourcePipelineAuthorization().Schema, map[string]interface{}{
+		"project_id":    testProjectID,
+		"resource_type": pipelinepermissionsclient.ResourceTypeEndpoint,
+		"resource_id":   testEndpointID,
+		"pipeline_ids":  []interface{}{12, 3},
+	})
+
+	require.NoError(t, resourcePipelineAuthorizationCreateOrUpdate(d, newTestClients(fake)))
+	require.Equal(t, []pipelinepermissionsclient.ResourcePipelinePermissions{
+		{
+			Resource:     pipelinepermissionsclient.Resource{Type: pipelinepermissionsclient.ResourceTypeEndpoint, ID: testEndpointID},
+			AllPipelines: &pipelinepermissionsclient.Permission{Authorized: false},
+			Pipelines: []pipelinepermissionsclient.PipelinePermission{
+				{ID: 3, Authorized: true},
+				{ID: 12, Authorized: true},
+				{ID: 5, Authorized: false},
+			},
+		},
+	}, fake.updates)
+
+	require.Equal(t, testProjectID+"/endpoint/"+testEndpointID, d.Id())
+	require.False(t, d.Get("all_pipelines").(bool))
+	require.ElementsMatch(t, []interface{}{3, 12}, d.Get("pipeline_ids").(*schema.Set).List())
+}
+
+func TestPipelineAuthorization_ReadReportsDrift(t *testing.T) {
+	fake := &fakePipelinePermissionsClient{
+		permissions: pipelinepermissionsclient.ResourcePipelinePermissions{
+			Resource:     pipelinepermissionsclient.Resource{Type: pipelinepermissionsclient.ResourceTypeRepository, ID: testProjectID + "." + testEndpointID},
+			AllPipelines: &pipelinepermissionsclient.Permission{Authorized: true},
+			Pipelines: []pipelinepermissionsclient.PipelinePermission{
+				{ID: 7, Authorized: true},
+			},
+		},
+	}
+
+	d := schema.TestResourceDataRaw(t, ResourcePipelineAuthorization().Schema, map[string]interface{}{
+		"project_id":    testProjectID,
+		"resource_type": pipelinepermissionsclient.ResourceTypeRepository,
+		"resource_id":   testEndpointID,
+		"pipeline_ids":  []interface{}{12},
+	})
+	d.SetId(testProjectID + "/repository/" + testEndpointID)
+
+	require.NoError(t, resourcePipelineAuthorizationRead(d, newTestClients(fake)))
+	require.True(t, d.Get("all_pipelines").(bool))
+	require.Equal(t, []interface{}{7}, d.Get("pipeline_ids").(*schema.Set).List())
+
+	fake.permissions.Resource.ID = "other"
+	require.NoError(t, resourcePipelineAuthorizationRead(d, newTestClients(fake)))
+	require.Equal(t, "", d.Id())
+}
+
+func TestPipelineAuthorization_DeleteRevokesAuthorizedPipelines(t *testing.T) {
+	fake := &fakePipelinePermissionsClient{
+		permissions: pipelinepermissionsclient.ResourcePipelinePermissions{
+			Resource: pipelinepermissionsclient.Resource{Type: pipelinepermissionsclient.ResourceTypeQueue, ID: "42"},
+		},
+	}
+
+	d := schema.TestResourceDataRaw(t, ResourcePipelineAuthorization().Schema, map[string]interface{}{
+		"project_id":    testProjectID,
+		"resource_type": pipelinepermissionsclient.ResourceTypeQueue,
+		"resource_id":   "42",
+		"all_pipelines": true,
+	})
+	d.SetId(testProjectID + "/queue/42")
+
+	require.NoError(t, resourcePipelineAuthorizationDelete(d, newTestClients(fake)))
+	require.Equal(t, []pipelinepermissionsclient.ResourcePipelinePermissions{
+		{
+			Resource:     pipelinepermissionsclient.Resource{Type: pipelinepermissionsclient.ResourceTypeQueue, ID: "42"},
+			AllPipelines: &pipelinepermissionsclient.Permission{Authorized: false},
+			Pipelines:    []pipelinepermissionsclient.PipelinePermission{},
+		},
+	}, fake.updates)
+	require.Equal(t, "", d.Id())
+}
+
+func TestParseImportID(t *testing.T) {
+	project, resourceType, resourceID, err := parseImportID("my project/variablegroup/12")
+	require.NoError(t, err)
+	require.Equal(t, "my project", project)
+	require.Equal(t, pipelinepermissionsclient.ResourceTypeVariableGroup, resourceType)
+	require.Equal(t, "12", resourceID)
+
+	for _, id := range []string{"", "project/endpoint", "project/endpoint/", "/endpoint/12", "project/pipeline/12"} {
+		_, _, _, err := parseImportID(id)
+		require.Error(t, err, "import ID %q should be invalid", id)
+	}
+}
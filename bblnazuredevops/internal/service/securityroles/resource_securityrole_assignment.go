@@ -0,0 +1,366 @@
+package securityroles
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/babylonhealth/terraform-provider-bblnazuredevops/bblnazuredevops/internal/client"
+	"github.com/babylonhealth/terraform-provider-bblnazuredevops/bblnazuredevops/internal/service/securityroles/securityrolesclient"
+	"github.com/babylonhealth/terraform-provider-bblnazuredevops/bblnazuredevops/internal/utils/converter"
+	"github.com/babylonhealth/terraform-provider-bblnazuredevops/bblnazuredevops/internal/utils/tfhelper"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/customdiff"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+	"github.com/microsoft/azure-devops-go-api/azuredevops/v6/identity"
+)
+
+// ResourceSecurityRoleAssignment schema and implementation for the roles of principals on the service endpoints,
+// environments, library or agent queues of a project. In the authoritative mode the assignments of the resource are
+// the only ones, others are removed when the resource is applied. Roles inherited from the project are never changed.
+func ResourceSecurityRoleAssignment() *schema.Resource {
+	return &schema.Resource{
+		Create:        resourceSecurityRoleAssignmentCreateOrUpdate,
+		Read:          resourceSecurityRoleAssignmentRead,
+		Update:        resourceSecurityRoleAssignmentCreateOrUpdate,
+		Delete:        resourceSecurityRoleAssignmentDelete,
+		CustomizeDiff: customdiff.All(tfhelper.CustomizeDiffProjectID, customizeDiffUniquePrincipals),
+		Importer: &schema.ResourceImporter{
+			State: importSecurityRoleAssignment,
+		},
+		Schema: map[string]*schema.Schema{
+			"project_id": tfhelper.ProjectIDSchema(),
+			"scope": {
+				Type:         schema.TypeString,
+				Required:     true,
+				ForceNew:     true,
+				ValidateFunc: validation.StringInSlice(securityrolesclient.Scopes(), false),
+				Description:  fmt.Sprintf("The scope of the roles, one of %s.", strings.Join(securityrolesclient.Scopes(), ", ")),
+			},
+			"resource_id": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				ForceNew:     true,
+				ValidateFunc: validation.StringIsNotWhiteSpace,
+				Description:  "The ID of the service endpoint, environment, variable group or agent queue. The roles apply to all resources of the scope in the project when it is not set.",
+			},
+			"authoritative": {
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     false,
+				Description: "Whether the assignments are the only roles assigned on the resource. Otherwise the roles assigned outside of Terraform are kept.",
+			},
+			"assignment": {
+				Type:     schema.TypeSet,
+				Optional: true,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"principal": {
+							Type:         schema.TypeString,
+							Required:     true,
+							ValidateFunc: validation.StringIsNotWhiteSpace,
+							Description:  "The subject descriptor of the user or group.",
+						},
+						"role_name": {
+							Type:         schema.TypeString,
+							Required:     true,
+							ValidateFunc: validation.StringInSlice(securityrolesclient.Roles(), false),
+							Description:  fmt.Sprintf("The role of the principal, one of %s.", strings.Join(securityrolesclient.Roles(), ", ")),
+						},
+					},
+				},
+				Description: "The roles assigned to principals on the resource.",
+			},
+		},
+	}
+}
+
+// customizeDiffUniquePrincipals rejects principals assigned more than one role, a principal has a single role on
+// a resource
+func customizeDiffUniquePrincipals(_ context.Context, d *schema.ResourceDiff, _ interface{}) error {
+	if !d.NewValueKnown("assignment") {
+		return nil
+	}
+
+	principals := map[string]bool{}
+	for _, assignment := range d.Get("assignment").(*schema.Set).List() {
+		principal := assignment.(map[string]interface{})["principal"].(string)
+		if principal == "" {
+			continue
+		}
+		if principals[principal] {
+			return fmt.Errorf("the principal %s is assigned more than one role, a principal has a single role on a resource", principal)
+		}
+		principals[principal] = true
+	}
+	return nil
+}
+
+func resourceSecurityRoleAssignmentCreateOrUpdate(d *schema.ResourceData, m interface{}) error {
+	clients := m.(*client.AggregatedClient)
+
+	projectID, err := tfhelper.ResolveProjectID(d, m)
+	if err != nil {
+		return err
+	}
+	scope := d.Get("scope").(string)
+	resourceID := apiResourceID(projectID, scope, d.Get("resource_id").(string))
+
+	roles := expandRoleAssignments(d.Get("assignment").(*schema.Set))
+	oldAssignments, _ := d.GetChange("assignment")
+	removedPrincipals := []string{}
+	for principal := range expandRoleAssignments(oldAssignments.(*schema.Set)) {
+		if _, ok := roles[principal]; !ok {
+			removedPrincipals = append(removedPrincipals, principal)
+		}
+	}
+
+	identityIDs, err := resolveIdentityIDs(clients, append(sortedPrincipals(roles), removedPrincipals...))
+	if err != nil {
+		return err
+	}
+
+	assignments := []securityrolesclient.UserRoleAssignment{}
+	assigned := map[string]bool{}
+	for _, principal := range sortedPrincipals(roles) {
+		assigned[identityIDs[principal]] = true
+		assignments = append(assignments, securityrolesclient.UserRoleAssignment{
+			RoleName: roles[principal],
+			UserID:   identityIDs[principal],
+		})
+	}
+
+	removed := []string{}
+	for _, principal := range removedPrincipals {
+		removed = append(removed, identityIDs[principal])
+	}
+	if d.Get("authoritative").(bool) {
+		current, err := clients.SecurityRolesClient.GetRoleAssignments(clients.Ctx, scope, resourceID)
+		if err != nil {
+			return fmt.Errorf("error reading the roles assigned on %s %s: %+v", scope, resourceID, err)
+		}
+		for _, assignment := range current {
+			id := strings.ToLower(assignment.Identity.ID)
+			if assignment.Access == securityrolesclient.RoleAccessAssigned && !assigned[id] {
+				removed = append(removed, id)
+			}
+		}
+	}
+
+	if len(assignments) > 0 {
+		if err := clients.SecurityRolesClient.SetRoleAssignments(clients.Ctx, scope, resourceID, assignments); err != nil {
+			return fmt.Errorf("error assigning roles on %s %s: %+v", scope, resourceID, err)
+		}
+	}
+	if len(removed) > 0 {
+		if err := clients.SecurityRolesClient.RemoveRoleAssignments(clients.Ctx, scope, resourceID, removed); err != nil {
+			return fmt.Errorf("error removing roles assigned on %s %s: %+v", scope, resourceID, err)
+		}
+	}
+
+	d.SetId(securityRoleAssignmentID(projectID, scope, d.Get("resource_id").(string)))
+	return resourceSecurityRoleAssignmentRead(d, m)
+}
+
+func resourceSecurityRoleAssignmentRead(d *schema.ResourceData, m interface{}) error {
+	clients := m.(*client.AggregatedClient)
+
+	projectID := d.Get("project_id").(string)
+	scope := d.Get("scope").(string)
+	resourceID := apiResourceID(projectID, scope, d.Get("resource_id").(string))
+
+	current, err := clients.SecurityRolesClient.GetRoleAssignments(clients.Ctx, scope, resourceID)
+	if err != nil {
+		return fmt.Errorf("error reading the roles assigned on %s %s: %+v", scope, resourceID, err)
+	}
+
+	ids := []string{}
+	for _, assignment := range current {
+		if assignment.Access == securityrolesclient.RoleAccessAssigned {
+			ids = append(ids, assignment.Identity.ID)
+		}
+	}
+	principals, err := resolveSubjectDescriptors(clients, ids)
+	if err != nil {
+		return err
+	}
+
+	// in the additive mode, only the roles of the managed principals are read back
+	managed := expandRoleAssignments(d.Get("assignment").(*schema.Set))
+	isAuthoritative := d.Get("authoritative").(bool)
+
+	assignments := []interface{}{}
+	for _, assignment := range current {
+		principal, ok := principals[strings.ToLower(assignment.Identity.ID)]
+		if assignment.Access != securityrolesclient.RoleAccessAssigned || !ok {
+			continue
+		}
+		if _, isManaged := managed[principal]; !isAuthoritative && !isManaged {
+			continue
+		}
+		assignments = append(assignments, map[string]interface{}{
+			"principal": principal,
+			"role_name": assignment.Role.Name,
+		})
+	}
+	return d.Set("assignment", assignments)
+}
+
+func resourceSecurityRoleAssignmentDelete(d *schema.ResourceData, m interface{}) error {
+	clients := m.(*client.AggregatedClient)
+
+	projectID := d.Get("project_id").(string)
+	scope := d.Get("scope").(string)
+	resourceID := apiResourceID(projectID, scope, d.Get("resource_id").(string))
+
+	principals := sortedPrincipals(expandRoleAssignments(d.Get("assignment").(*schema.Set)))
+	if len(principals) > 0 {
+		identityIDs, err := resolveIdentityIDs(clients, principals)
+		if err != nil {
+			return err
+		}
+		removed := []string{}
+		for _, principal := range principals {
+			removed = append(removed, identityIDs[principal])
+		}
+		if err := clients.SecurityRolesClient.RemoveRoleAssignments(clients.Ctx, scope, resourceID, removed); err != nil {
+			return fmt.Errorf("error removing roles assigned on %s %s: %+v", scope, resourceID, err)
+		}
+	}
+
+	d.SetId("")
+	return nil
+}
+
+// importSecurityRoleAssignment imports the roles assigned on a resource by an ID like <project name or ID>/<scope>, or
+// <project name or ID>/<scope>/<resource ID>. The resource is imported in the authoritative mode, so that all
+// assigned roles are read back.
+func importSecurityRoleAssignment(d *schema.ResourceData, m interface{}) ([]*schema.ResourceData, error) {
+	parts := strings.SplitN(d.Id(), "/", 3)
+	if len(parts) < 2 || parts[0] == "" || (len(parts) == 3 && parts[2] == "") {
+		return nil, fmt.Errorf("unexpected format of the ID (%s), expected <project>/<scope> or <project>/<scope>/<resource ID>", d.Id())
+	}
+	if !isScope(parts[1]) {
+		return nil, fmt.Errorf("unsupported scope %q, expected one of %s", parts[1], strings.Join(securityrolesclient.Scopes(), ", "))
+	}
+
+	projectID, err := tfhelper.GetRealProjectId(parts[0], m)
+	if err != nil {
+		return nil, err
+	}
+
+	resourceID := ""
+	if len(parts) == 3 {
+		resourceID = parts[2]
+	}
+	d.Set("project_id", projectID)
+	d.Set("scope", parts[1])
+	d.Set("resource_id", resourceID)
+	d.Set("authoritative", true)
+	d.SetId(securityRoleAssignmentID(projectID, parts[1], resourceID))
+	return []*schema.ResourceData{d}, nil
+}
+
+func isScope(scope string) bool {
+	for _, s := range securityrolesclient.Scopes() {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+func securityRoleAssignmentID(projectID string, scope string, resourceID string) string {
+	if resourceID == "" {
+		return fmt.Sprintf("%s/%s", projectID, scope)
+	}
+	return fmt.Sprintf("%s/%s/%s", projectID, scope, resourceID)
+}
+
+// apiResourceID returns the ID of the resource in the securityroles API. Resources are identified by the ID of their
+// project and their own ID, joined by $ in the library and by _ otherwise. The project itself stands for all resources
+// of the scope, the library of a project is identified by the variable group 0.
+func apiResourceID(projectID string, scope string, resourceID string) string {
+	if scope == securityrolesclient.ScopeLibrary {
+		if resourceID == "" {
+			resourceID = "0"
+		}
+		return projectID + "$" + resourceID
+	}
+	if resourceID == "" {
+		return projectID
+	}
+	return projectID + "_" + resourceID
+}
+
+// expandRoleAssignments returns the role of each principal of the assignments
+func expandRoleAssignments(assignments *schema.Set) map[string]string {
+	roles := map[string]string{}
+	for _, assignment := range assignments.List() {
+		values := assignment.(map[string]interface{})
+		roles[values["principal"].(string)] = values["role_name"].(string)
+	}
+	return roles
+}
+
+func sortedPrincipals(roles map[string]string) []string {
+	principals := []string{}
+	for principal := range roles {
+		principals = append(principals, principal)
+	}
+	sort.Strings(principals)
+	return principals
+}
+
+// resolveIdentityIDs returns the ID of the identity of each principal, the securityroles API only knows identities
+func resolveIdentityIDs(clients *client.AggregatedClient, principals []string) (map[string]string, error) {
+	identityIDs := map[string]string{}
+	if len(principals) == 0 {
+		return identityIDs, nil
+	}
+
+	identities, err := clients.IdentityClient.ReadIdentities(clients.Ctx, identity.ReadIdentitiesArgs{
+		SubjectDescriptors: converter.String(strings.Join(principals, ",")),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error reading the identities of the principals: %+v", err)
+	}
+	if identities != nil {
+		for _, id := range *identities {
+			if id.SubjectDescriptor != nil && id.Id != nil {
+				identityIDs[*id.SubjectDescriptor] = strings.ToLower(id.Id.String())
+			}
+		}
+	}
+	for _, principal := range principals {
+		if _, ok := identityIDs[principal]; !ok {
+			return nil, fmt.Errorf("the principal %s has no identity in Azure DevOps", principal)
+		}
+	}
+	return identityIDs, nil
+}
+
+// resolveSubjectDescriptors returns the subject descriptor of each identity. Identities which are unknown, e.g.
+// deleted users, are left out.
+func resolveSubjectDescriptors(clients *client.AggregatedClient, identityIDs []string) (map[string]string, error) {
+	principals := map[string]string{}
+	if len(identityIDs) == 0 {
+		return principals, nil
+	}
+
+	identities, err := clients.IdentityClient.ReadIdentities(clients.Ctx, identity.ReadIdentitiesArgs{
+		IdentityIds: converter.String(strings.Join(identityIDs, ",")),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error reading the identities of the role assignments: %+v", err)
+	}
+	if identities != nil {
+		for _, id := range *identities {
+			if id.SubjectDescriptor != nil && id.Id != nil {
+				principals[strings.ToLower(id.Id.String())] = *id.SubjectDescriptor
+			}
+		}
+	}
+	return principals, nil
+}
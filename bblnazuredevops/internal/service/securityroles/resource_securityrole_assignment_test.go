@@ -0,0 +1,245 @@
+package securityroles
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/babylonhealth/terraform-provider-bblnazuredevops/azdosdkmocks"
+	"github.com/babylonhealth/terraform-provider-bblnazuredevops/bblnazuredevops/internal/client"
+	"github.com/babylonhealth/terraform-provider-bblnazuredevops/bblnazuredevops/internal/service/securityroles/securityrolesclient"
+	"github.com/babylonhealth/terraform-provider-bblnazuredevops/bblnazuredevops/internal/utils/converter"
+	"github.com/golang/mock/gomock"
+	"github.com/google/uuid"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
+	"github.com/microsoft/azure-devops-go-api/azuredevops/v6/identity"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testProjectID  = "4f7f5d92-0e11-4311-ac85-9972864acbc2"
+	testEndpointID = "627166ad-752b-47f7-a115-7bdcb385931e"
+)
+
+var testIdentities = map[string]string{
+	"vssgp.deployers":  "b555cec9-60cf-4f6e-9626-670f964945c5",
+	"vssgp.developers": "3e0ea031-f36c-4c43-ab70-34769dc5ba3a",
+	"aad.someone":      "9b7e8a4c-5f0c-4d3c-8d2a-0e8f6c1b2a3d",
+}
+
+// fakeSecurityRolesClient keeps the role assignments of a single resource
+type fakeSecurityRolesClient struct {
+	assignments []securityrolesclient.RoleAssignment
+	set         [][]securityrolesclient.UserRoleAssignment
+	removed     [][]string
+}
+
+func (f *fakeSecurityRolesClient) GetRoleAssignments(_ context.Context, _ string, _ string) ([]securityrolesclient.RoleAssignment, error) {
+	return f.assignments, nil
+}
+
+func (f *fakeSecurityRolesClient) SetRoleAssignments(_ context.Context, _ string, _ string, assignments []securityrolesclient.UserRoleAssignment) error {
+	f.set = append(f.set, assignments)
+	for _, assignment := range assignments {
+		f.remove(assignment.UserID)
+		f.assignments = append(f.assignments, securityrolesclient.RoleAssignment{
+			Access:   securityrolesclient.RoleAccessAssigned,
+			Identity: securityrolesclient.IdentityRef{ID: assignment.UserID},
+			Role:     securityrolesclient.Role{Name: assignment.RoleName},
+		})
+	}
+	return nil
+}
+
+func (f *fakeSecurityRolesClient) RemoveRoleAssignments(_ context.Context, _ string, _ string, identityIDs []string) error {
+	f.removed = append(f.removed, identityIDs)
+	for _, id := range identityIDs {
+		f.remove(id)
+	}
+	return nil
+}
+
+func (f *fakeSecurityRolesClient) remove(id string) {
+	assignments := []securityrolesclient.RoleAssignment{}
+	for _, assignment := range f.assignments {
+		if assignment.Identity.ID != id || assignment.Access != securityrolesclient.RoleAccessAssigned {
+			assignments = append(assignments, assignment)
+		}
+	}
+	f.assignments = assignments
+}
+
+// readIdentities answers the identity lookups by subject descriptors or identity IDs from testIdentities
+func readIdentities(_ context.Context, args identity.ReadIdentitiesArgs) (*[]identity.Identity, error) {
+	identities := []identity.Identity{}
+	for descriptor, id := range testIdentities {
+		if (args.SubjectDescriptors != nil && containsValue(*args.SubjectDescriptors, descriptor)) ||
+			(args.IdentityIds != nil && containsValue(*args.IdentityIds, id)) {
+			identityID := uuid.MustParse(id)
+			identities = append(identities, identity.Identity{Id: &identityID, SubjectDescriptor: converter.String(descriptor)})
+		}
+	}
+	return &identities, nil
+}
+
+func containsValue(values string, value string) bool {
+	for _, v := range strings.Split(values, ",") {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func newTestClients(t *testing.T, fake *fakeSecurityRolesClient) *client.AggregatedClient {
+	ctrl := gomock.NewController(t)
+	t.Cleanup(ctrl.Finish)
+
+	identityClient := azdosdkmocks.NewMockIdentityClient(ctrl)
+	identityClient.EXPECT().ReadIdentities(gomock.Any(), gomock.Any()).DoAndReturn(readIdentities).AnyTimes()
+	return &client.AggregatedClient{
+		SecurityRolesClient: fake,
+		IdentityClient:      identityClient,
+		Ctx:                 context.Background(),
+	}
+}
+
+func assigned(principal string, role string) securityrolesclient.RoleAssignment {
+	return securityrolesclient.RoleAssignment{
+		Access:   securityrolesclient.RoleAccessAssigned,
+		Identity: securityrolesclient.IdentityRef{ID: testIdentities[principal]},
+		Role:     securityrolesclient.Role{Name: role},
+	}
+}
+
+func newTestResourceData(t *testing.T, authoritative bool, assignments ...map[string]interface{}) *schema.ResourceData {
+	values := []interface{}{}
+	for _, assignment := range assignments {
+		values = append(values, assignment)
+	}
+	return schema.TestResourceDataRaw(t, ResourceSecurityRoleAssignment().Schema, map[string]interface{}{
+		"project_id":    testProjectID,
+		"scope":         securityrolesclient.ScopeServiceEndpoint,
+		"resource_id":   testEndpointID,
+		"authoritative": authoritative,
+		"assignment":    values,
+	})
+}
+
+func flattenedAssignments(d *schema.ResourceData) map[string]string {
+	return expandRoleAssignments(d.Get("assignment").(*schema.Set))
+}
+
+// Roles assigned outside of Terraform are removed in the authoritative mode, inherited roles are kept
+func TestSecurityRoleAssignment_CreateAuthoritative(t *testing.T) {
+	fake := &fakeSecurityRolesClient{assignments: []securityrolesclient.RoleAssignment{
+		assigned("aad.someone", securityrolesclient.RoleAdministrator),
+		{
+			Access:   securityrolesclient.RoleAccessInherited,
+			Identity: securityrolesclient.IdentityRef{ID: testIdentities["vssgp.developers"]},
+			Role:     securityrolesclient.Role{Name: securityrolesclient.RoleReader},
+		},
+	}}
+	d := newTestResourceData(t, true, map[string]interface{}{"principal": "vssgp.deployers", "role_name": securityrolesclient.RoleUser})
+
+	require.NoError(t, resourceSecurityRoleAssignmentCreateOrUpdate(d, newTestClients(t, fake)))
+
+	require.Equal(t, testProjectID+"/"+securityrolesclient.ScopeServiceEndpoint+"/"+testEndpointID, d.Id())
+	require.Equal(t, [][]securityrolesclient.UserRoleAssignment{
+		{{RoleName: securityrolesclient.RoleUser, UserID: testIdentities["vssgp.deployers"]}},
+	}, fake.set)
+	require.Equal(t, [][]string{{testIdentities["aad.someone"]}}, fake.removed)
+	require.Equal(t, map[string]string{"vssgp.deployers": securityrolesclient.RoleUser}, flattenedAssignments(d))
+}
+
+// Roles assigned outside of Terraform are kept, and not read back, in the additive mode
+func TestSecurityRoleAssignment_CreateAdditive(t *testing.T) {
+	fake := &fakeSecurityRolesClient{assignments: []securityrolesclient.RoleAssignment{
+		assigned("aad.someone", securityrolesclient.RoleAdministrator),
+	}}
+	d := newTestResourceData(t, false, map[string]interface{}{"principal": "vssgp.deployers", "role_name": securityrolesclient.RoleCreator})
+
+	require.NoError(t, resourceSecurityRoleAssignmentCreateOrUpdate(d, newTestClients(t, fake)))
+
+	require.Empty(t, fake.removed)
+	require.Len(t, fake.assignments, 2)
+	require.Equal(t, map[string]string{"vssgp.deployers": securityrolesclient.RoleCreator}, flattenedAssignments(d))
+}
+
+// In the authoritative mode, roles assigned outside of Terraform show up as drift
+func TestSecurityRoleAssignment_ReadAuthoritative(t *testing.T) {
+	fake := &fakeSecurityRolesClient{assignments: []securityrolesclient.RoleAssignment{
+		assigned("vssgp.deployers", securityrolesclient.RoleUser),
+		assigned("aad.someone", securityrolesclient.RoleAdministrator),
+		// deleted identities are ignored
+		{Access: securityrolesclient.RoleAccessAssigned, Identity: securityrolesclient.IdentityRef{ID: "00000000-0000-0000-0000-000000000001"}, Role: securityrolesclient.Role{Name: securityrolesclient.RoleReader}},
+	}}
+	d := newTestResourceData(t, true, map[string]interface{}{"principal": "vssgp.deployers", "role_name": securityrolesclient.RoleUser})
+
+	require.NoError(t, resourceSecurityRoleAssignmentRead(d, newTestClients(t, fake)))
+	require.Equal(t, map[string]string{
+		"vssgp.deployers": securityrolesclient.RoleUser,
+		"aad.someone":     securityrolesclient.RoleAdministrator,
+	}, flattenedAssignments(d))
+}
+
+func TestSecurityRoleAssignment_Delete(t *testing.T) {
+	fake := &fakeSecurityRolesClient{assignments: []securityrolesclient.RoleAssignment{
+		assigned("vssgp.deployers", securityrolesclient.RoleUser),
+		assigned("aad.someone", securityrolesclient.RoleAdministrator),
+	}}
+	d := newTestResourceData(t, false, map[string]interface{}{"principal": "vssgp.deployers", "role_name": securityrolesclient.RoleUser})
+	d.SetId(testProjectID + "/" + securityrolesclient.ScopeServiceEndpoint + "/" + testEndpointID)
+
+	require.NoError(t, resourceSecurityRoleAssignmentDelete(d, newTestClients(t, fake)))
+	require.Equal(t, [][]string{{testIdentities["vssgp.deployers"]}}, fake.removed)
+	require.Equal(t, []securityrolesclient.RoleAssignment{assigned("aad.someone", securityrolesclient.RoleAdministrator)}, fake.assignments)
+	require.Empty(t, d.Id())
+}
+
+func TestSecurityRoleAssignment_UnknownPrincipal(t *testing.T) {
+	d := newTestResourceData(t, false, map[string]interface{}{"principal": "vssgp.unknown", "role_name": securityrolesclient.RoleUser})
+
+	err := resourceSecurityRoleAssignmentCreateOrUpdate(d, newTestClients(t, &fakeSecurityRolesClient{}))
+	require.EqualError(t, err, "the principal vssgp.unknown has no identity in Azure DevOps")
+}
+
+func TestSecurityRoleAssignment_DuplicatePrincipal(t *testing.T) {
+	config := map[string]interface{}{
+		"project_id": testProjectID,
+		"scope":      securityrolesclient.ScopeEnvironment,
+		"assignment": []interface{}{
+			map[string]interface{}{"principal": "vssgp.deployers", "role_name": securityrolesclient.RoleUser},
+			map[string]interface{}{"principal": "vssgp.deployers", "role_name": securityrolesclient.RoleAdministrator},
+		},
+	}
+	_, err := ResourceSecurityRoleAssignment().Diff(context.Background(), nil, terraform.NewResourceConfigRaw(config), nil)
+	require.EqualError(t, err, "the principal vssgp.deployers is assigned more than one role, a principal has a single role on a resource")
+}
+
+func Test_apiResourceID(t *testing.T) {
+	require.Equal(t, testProjectID+"_"+testEndpointID, apiResourceID(testProjectID, securityrolesclient.ScopeServiceEndpoint, testEndpointID))
+	require.Equal(t, testProjectID, apiResourceID(testProjectID, securityrolesclient.ScopeEnvironment, ""))
+	require.Equal(t, testProjectID+"$42", apiResourceID(testProjectID, securityrolesclient.ScopeLibrary, "42"))
+	require.Equal(t, testProjectID+"$0", apiResourceID(testProjectID, securityrolesclient.ScopeLibrary, ""))
+}
+
+func TestSecurityRoleAssignment_Import(t *testing.T) {
+	d := schema.TestResourceDataRaw(t, ResourceSecurityRoleAssignment().Schema, map[string]interface{}{})
+	d.SetId(testProjectID + "/" + securityrolesclient.ScopeAgentQueue + "/42")
+
+	got, err := importSecurityRoleAssignment(d, &client.AggregatedClient{Ctx: context.Background()})
+	require.NoError(t, err)
+	require.Len(t, got, 1)
+	require.Equal(t, testProjectID, d.Get("project_id"))
+	require.Equal(t, securityrolesclient.ScopeAgentQueue, d.Get("scope"))
+	require.Equal(t, "42", d.Get("resource_id"))
+	require.Equal(t, true, d.Get("authoritative"))
+
+	for _, id := range []string{testProjectID, "/" + securityrolesclient.ScopeAgentQueue, testProjectID + "/unknown", testProjectID + "/" + securityrolesclient.ScopeAgentQueue + "/"} {
+		d.SetId(id)
+		_, err := importSecurityRoleAssignment(d, &client.AggregatedClient{Ctx: context.Background()})
+		require.Error(t, err, id)
+	}
+}
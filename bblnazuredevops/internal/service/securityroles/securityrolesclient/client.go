@@ -0,0 +1,145 @@
+package securityrolesclient
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/babylonhealth/terraform-provider-bblnazuredevops/bblnazuredevops/internal/client/apiversion"
+)
+
+type SecurityRolesClient interface {
+	GetRoleAssignments(ctx context.Context, scope string, resourceID string) ([]RoleAssignment, error)
+	SetRoleAssignments(ctx context.Context, scope string, resourceID string, assignments []UserRoleAssignment) error
+	RemoveRoleAssignments(ctx context.Context, scope string, resourceID string, identityIDs []string) error
+}
+
+// NewSecurityRoles returns a client of the securityroles API, which assigns roles like User or Administrator on
+// pipeline resources like service endpoints, environments, the library or agent queues
+func NewSecurityRoles(baseUrl string, auth string, timeout *time.Duration, options ...Option) *SecurityRoles {
+	defaultTime := time.Duration(60 * time.Second)
+	if timeout == nil {
+		timeout = &defaultTime
+	}
+
+	client := &http.Client{
+		Timeout: *timeout,
+	}
+
+	s := &SecurityRoles{
+		baseUrl:       baseUrl,
+		client:        client,
+		authorization: auth,
+	}
+	for _, option := range options {
+		option(s)
+	}
+	return s
+}
+
+// Option configures optional behaviour of a SecurityRoles client
+type Option func(*SecurityRoles)
+
+// WithVersionNegotiator makes the client negotiate the api-version of every request with the server
+func WithVersionNegotiator(negotiator *apiversion.Negotiator) Option {
+	return func(s *SecurityRoles) {
+		s.negotiator = negotiator
+	}
+}
+
+// WithTransport sends the requests of the client through transport
+func WithTransport(transport http.RoundTripper) Option {
+	return func(s *SecurityRoles) {
+		s.client.Transport = transport
+	}
+}
+
+func roleAssignmentsURL(scope string, resourceID string) string {
+	return fmt.Sprintf("/_apis/securityroles/scopes/%s/roleassignments/resources/%s", url.PathEscape(scope), url.PathEscape(resourceID))
+}
+
+// GetRoleAssignments returns the roles assigned on the resource, including the ones inherited from its parent
+func (s *SecurityRoles) GetRoleAssignments(ctx context.Context, scope string, resourceID string) ([]RoleAssignment, error) {
+	acceptHeaders := s.negotiator.AcceptHeader(ctx, apiversion.ResourceValues.SecurityRoles)
+	resp, err := s.SendRequest(ctx, "GET", roleAssignmentsURL(scope, resourceID), "", acceptHeaders)
+	if err != nil {
+		return nil, err
+	}
+
+	// arrays are not wrapped, as requested by the Accept header, but servers ignoring it wrap them
+	assignments := []RoleAssignment{}
+	if len(bytes.TrimSpace(resp)) > 0 && bytes.TrimSpace(resp)[0] == '{' {
+		wrapper := struct {
+			Value []RoleAssignment `json:"value"`
+		}{}
+		if err := json.Unmarshal(resp, &wrapper); err != nil {
+			return nil, err
+		}
+		return wrapper.Value, nil
+	}
+	if err := json.Unmarshal(resp, &assignments); err != nil {
+		return nil, err
+	}
+	return assignments, nil
+}
+
+// SetRoleAssignments assigns the roles to the identities. The roles of other identities are kept.
+func (s *SecurityRoles) SetRoleAssignments(ctx context.Context, scope string, resourceID string, assignments []UserRoleAssignment) error {
+	payloadJson, err := json.Marshal(assignments)
+	if err != nil {
+		return err
+	}
+
+	acceptHeaders := s.negotiator.AcceptHeader(ctx, apiversion.ResourceValues.SecurityRoles)
+	_, err = s.SendRequest(ctx, "PUT", roleAssignmentsURL(scope, resourceID), string(payloadJson), acceptHeaders)
+	return err
+}
+
+// RemoveRoleAssignments removes the roles assigned to the identities on the resource
+func (s *SecurityRoles) RemoveRoleAssignments(ctx context.Context, scope string, resourceID string, identityIDs []string) error {
+	payloadJson, err := json.Marshal(identityIDs)
+	if err != nil {
+		return err
+	}
+
+	acceptHeaders := s.negotiator.AcceptHeader(ctx, apiversion.ResourceValues.SecurityRoles)
+	_, err = s.SendRequest(ctx, "PATCH", roleAssignmentsURL(scope, resourceID), string(payloadJson), acceptHeaders)
+	return err
+}
+
+func (s *SecurityRoles) SendRequest(ctx context.Context, httpMethod string, url string, jsonPayload string, acceptHeaders string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, httpMethod, s.baseUrl+url, bytes.NewBufferString(jsonPayload))
+	if err != nil {
+		return []byte{}, err
+	}
+
+	req.Header.Set("Authorization", s.authorization)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", acceptHeaders)
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return []byte{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == 203 {
+		return []byte{}, fmt.Errorf("resp status code from azure 203 - need auth")
+	}
+
+	if resp.StatusCode > 399 {
+		return []byte{}, fmt.Errorf("resp status code from azure 400 or above: %v", resp.StatusCode)
+	}
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return []byte{}, err
+	}
+
+	return body, nil
+}
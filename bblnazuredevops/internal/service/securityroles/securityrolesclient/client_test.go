@@ -0,0 +1,96 @@
+package securityrolesclient
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const testResourceID = "4f7f5d92-0e11-4311-ac85-9972864acbc2_627166ad-752b-47f7-a115-7bdcb385931e"
+
+func TestSecurityRoles_GetRoleAssignments(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, http.MethodGet, r.Method)
+		require.Equal(t, "/_apis/securityroles/scopes/"+ScopeServiceEndpoint+"/roleassignments/resources/"+testResourceID, r.URL.Path)
+		fmt.Fprint(w, `[{"access":1,"identity":{"id":"b555cec9-60cf-4f6e-9626-670f964945c5","displayName":"Deployers"},"role":{"name":"User","scope":"distributedtask.serviceendpointrole"}},`+
+			`{"access":"inherited","identity":{"id":"3e0ea031-f36c-4c43-ab70-34769dc5ba3a"},"role":{"name":"Administrator"}}]`)
+	}))
+	defer ts.Close()
+
+	s := NewSecurityRoles(ts.URL, "Basic token", nil)
+
+	got, err := s.GetRoleAssignments(context.Background(), ScopeServiceEndpoint, testResourceID)
+	require.NoError(t, err)
+	require.Equal(t, []RoleAssignment{
+		{
+			Access:   RoleAccessAssigned,
+			Identity: IdentityRef{ID: "b555cec9-60cf-4f6e-9626-670f964945c5", DisplayName: "Deployers"},
+			Role:     Role{Name: RoleUser, Scope: ScopeServiceEndpoint},
+		},
+		{
+			Access:   RoleAccessInherited,
+			Identity: IdentityRef{ID: "3e0ea031-f36c-4c43-ab70-34769dc5ba3a"},
+			Role:     Role{Name: RoleAdministrator},
+		},
+	}, got)
+}
+
+// Servers ignoring noArrayWrap wrap the assignments
+func TestSecurityRoles_GetRoleAssignments_Wrapped(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"count":1,"value":[{"access":1,"identity":{"id":"b555cec9-60cf-4f6e-9626-670f964945c5"},"role":{"name":"Reader"}}]}`)
+	}))
+	defer ts.Close()
+
+	s := NewSecurityRoles(ts.URL, "Basic token", nil)
+
+	got, err := s.GetRoleAssignments(context.Background(), ScopeAgentQueue, "42")
+	require.NoError(t, err)
+	require.Equal(t, []RoleAssignment{
+		{Access: RoleAccessAssigned, Identity: IdentityRef{ID: "b555cec9-60cf-4f6e-9626-670f964945c5"}, Role: Role{Name: RoleReader}},
+	}, got)
+}
+
+func TestSecurityRoles_SetAndRemoveRoleAssignments(t *testing.T) {
+	var set []UserRoleAssignment
+	var removed []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/_apis/securityroles/scopes/"+ScopeLibrary+"/roleassignments/resources/4f7f5d92-0e11-4311-ac85-9972864acbc2$0", r.URL.Path)
+		switch r.Method {
+		case http.MethodPut:
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&set))
+			fmt.Fprint(w, `[]`)
+		case http.MethodPatch:
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&removed))
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	}))
+	defer ts.Close()
+
+	s := NewSecurityRoles(ts.URL, "Basic token", nil)
+
+	assignments := []UserRoleAssignment{{RoleName: RoleCreator, UserID: "b555cec9-60cf-4f6e-9626-670f964945c5"}}
+	require.NoError(t, s.SetRoleAssignments(context.Background(), ScopeLibrary, "4f7f5d92-0e11-4311-ac85-9972864acbc2$0", assignments))
+	require.Equal(t, assignments, set)
+
+	require.NoError(t, s.RemoveRoleAssignments(context.Background(), ScopeLibrary, "4f7f5d92-0e11-4311-ac85-9972864acbc2$0", []string{"b555cec9-60cf-4f6e-9626-670f964945c5"}))
+	require.Equal(t, []string{"b555cec9-60cf-4f6e-9626-670f964945c5"}, removed)
+}
+
+func TestSecurityRoles_SetRoleAssignments_Error(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer ts.Close()
+
+	s := NewSecurityRoles(ts.URL, "Basic token", nil)
+
+	err := s.SetRoleAssignments(context.Background(), ScopeEnvironment, "42", []UserRoleAssignment{{RoleName: RoleUser, UserID: "b555cec9-60cf-4f6e-9626-670f964945c5"}})
+	require.EqualError(t, err, "resp status code from azure 400 or above: 403")
+}
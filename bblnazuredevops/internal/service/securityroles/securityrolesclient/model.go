@@ -0,0 +1,103 @@
+package securityrolesclient
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/babylonhealth/terraform-provider-bblnazuredevops/bblnazuredevops/internal/client/apiversion"
+)
+
+// Scopes of the security roles of the pipeline resources
+const (
+	ScopeServiceEndpoint = "distributedtask.serviceendpointrole"
+	ScopeEnvironment     = "distributedtask.environmentreferencerole"
+	ScopeLibrary         = "distributedtask.library"
+	ScopeAgentQueue      = "distributedtask.agentqueuerole"
+)
+
+// Scopes returns all supported scopes
+func Scopes() []string {
+	return []string{
+		ScopeServiceEndpoint,
+		ScopeEnvironment,
+		ScopeLibrary,
+		ScopeAgentQueue,
+	}
+}
+
+// Roles which can be assigned in all scopes
+const (
+	RoleReader        = "Reader"
+	RoleUser          = "User"
+	RoleCreator       = "Creator"
+	RoleAdministrator = "Administrator"
+)
+
+// Roles returns all roles
+func Roles() []string {
+	return []string{
+		RoleReader,
+		RoleUser,
+		RoleCreator,
+		RoleAdministrator,
+	}
+}
+
+type SecurityRoles struct {
+	baseUrl       string
+	client        *http.Client
+	authorization string
+	negotiator    *apiversion.Negotiator
+}
+
+// RoleAccess tells whether a role is assigned on the resource itself or inherited from its parent
+type RoleAccess int
+
+const (
+	RoleAccessAssigned  RoleAccess = 1
+	RoleAccessInherited RoleAccess = 2
+)
+
+// UnmarshalJSON accepts the access as a number, as requested by the Accept header, or as its name
+func (a *RoleAccess) UnmarshalJSON(data []byte) error {
+	var name string
+	if err := json.Unmarshal(data, &name); err != nil {
+		return json.Unmarshal(data, (*int)(a))
+	}
+	switch strings.ToLower(name) {
+	case "assigned":
+		*a = RoleAccessAssigned
+	case "inherited":
+		*a = RoleAccessInherited
+	default:
+		return fmt.Errorf("unknown role access %q", name)
+	}
+	return nil
+}
+
+// RoleAssignment is the role of an identity on a resource
+type RoleAssignment struct {
+	Access   RoleAccess  `json:"access"`
+	Identity IdentityRef `json:"identity"`
+	Role     Role        `json:"role"`
+}
+
+type IdentityRef struct {
+	ID          string `json:"id"`
+	DisplayName string `json:"displayName,omitempty"`
+	UniqueName  string `json:"uniqueName,omitempty"`
+}
+
+type Role struct {
+	Name        string `json:"name"`
+	DisplayName string `json:"displayName,omitempty"`
+	Scope       string `json:"scope,omitempty"`
+}
+
+// UserRoleAssignment assigns a role to an identity
+type UserRoleAssignment struct {
+	RoleName string `json:"roleName"`
+	UserID   string `json:"userId"`
+}
@@ -1,16 +1,21 @@
 package serviceendpoint
 
 import (
+	"context"
 	"fmt"
-	"log"
+	"sort"
 	"strings"
 	"time"
 
 	"github.com/babylonhealth/terraform-provider-bblnazuredevops/bblnazuredevops/internal/client"
+	"github.com/babylonhealth/terraform-provider-bblnazuredevops/bblnazuredevops/internal/client/logging"
 	"github.com/babylonhealth/terraform-provider-bblnazuredevops/bblnazuredevops/internal/utils"
 	"github.com/babylonhealth/terraform-provider-bblnazuredevops/bblnazuredevops/internal/utils/converter"
 	"github.com/babylonhealth/terraform-provider-bblnazuredevops/bblnazuredevops/internal/utils/tfhelper"
 	"github.com/google/uuid"
+	"github.com/hashicorp/go-cty/cty"
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/customdiff"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/resource"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
@@ -21,9 +26,24 @@ const errMsgTfConfigRead = "Error reading terraform configuration: %+v"
 const errMsgServiceCreate = "Error looking up service endpoint given ID (%s) and project ID (%s): %v "
 const errMsgServiceDelete = "Error delete service endpoint. ServiceEndpointID: %s, projectID: %s. %v "
 
-type flatFunc func(d *schema.ResourceData, serviceEndpoint *serviceendpoint.ServiceEndpoint, projectID *uuid.UUID)
+// defaultVerifyDataSource is the data source executed by the Verify button of Azure DevOps
+const defaultVerifyDataSource = "TestConnection"
+
+type flatFunc func(d *schema.ResourceData, serviceEndpoint *serviceendpoint.ServiceEndpoint, projectID *uuid.UUID) error
 type expandFunc func(d *schema.ResourceData) (*serviceendpoint.ServiceEndpoint, *uuid.UUID, error)
 
+// inputsFunc returns the authorization scheme, the data and the authorization parameters planned for a service
+// endpoint, to check them against the inputs of its type. ok is false while the scheme is not known.
+type inputsFunc func(d *schema.ResourceDiff) (scheme string, data map[string]string, parameters map[string]string, ok bool)
+
+// vaultRefsFunc returns the references to the Vault secrets holding the values of authorization parameters of a
+// service endpoint, keyed by the name of the parameter, see resolveVaultSecrets
+type vaultRefsFunc func(d *schema.ResourceData) map[string]string
+
+// unknownInputValue stands for the planned values which are not known yet, and for the secrets which are not in
+// the plan, when they are checked against the inputs of the service endpoint type
+const unknownInputValue = "(known after apply)"
+
 type operationState struct {
 	Ready      string
 	Failed     string
@@ -37,12 +57,18 @@ var opState = operationState{
 }
 
 // genBaseServiceEndpointResource creates a Resource with the common parts
-// that all Service Endpoints require.
-func genBaseServiceEndpointResource(f flatFunc, e expandFunc) *schema.Resource {
+// that all Service Endpoints require. Only service endpoints of endpointType can be imported, any service
+// endpoint when it is "". The inputs of the service endpoint are checked against its type at plan time when i is
+// not nil. The authorization parameters returned by v, when it is not nil, are read from Vault on apply.
+func genBaseServiceEndpointResource(endpointType string, f flatFunc, e expandFunc, i inputsFunc, v vaultRefsFunc) *schema.Resource {
+	customizeDiff := []schema.CustomizeDiffFunc{tfhelper.CustomizeDiffProjectID, customizeDiffSharedProjects}
+	if i != nil {
+		customizeDiff = append(customizeDiff, customizeDiffServiceEndpointTypeInputs(endpointType, i))
+	}
 	return &schema.Resource{
-		Create: genServiceEndpointCreateFunc(f, e),
+		Create: genServiceEndpointCreateFunc(f, e, v),
 		Read:   genServiceEndpointReadFunc(f),
-		Update: genServiceEndpointUpdateFunc(f, e),
+		Update: genServiceEndpointUpdateFunc(f, e, v),
 		Delete: genServiceEndpointDeleteFunc(e),
 		Timeouts: &schema.ResourceTimeout{
 			Create: schema.DefaultTimeout(2 * time.Minute),
@@ -50,14 +76,10 @@ func genBaseServiceEndpointResource(f flatFunc, e expandFunc) *schema.Resource {
 			Update: schema.DefaultTimeout(2 * time.Minute),
 			Delete: schema.DefaultTimeout(2 * time.Minute),
 		},
-		Importer: tfhelper.ImportProjectQualifiedResourceUUID(),
+		Importer:      importServiceEndpoint(endpointType),
+		CustomizeDiff: customdiff.All(customizeDiff...),
 		Schema: map[string]*schema.Schema{
-			"project_id": {
-				Type:         schema.TypeString,
-				Required:     true,
-				ForceNew:     true,
-				ValidateFunc: validation.IsUUID,
-			},
+			"project_id": tfhelper.ProjectIDSchema(),
 			"service_endpoint_name": {
 				Type:         schema.TypeString,
 				Required:     true,
@@ -70,6 +92,68 @@ func genBaseServiceEndpointResource(f flatFunc, e expandFunc) *schema.Resource {
 				Default:      "Managed by Terraform",
 				ValidateFunc: validation.StringLenBetween(0, 1024),
 			},
+			// the shared projects are Computed for customizeDiffSharedProjects to plan their IDs
+			"shared_project_ids": {
+				Type:     schema.TypeSet,
+				Optional: true,
+				Computed: true,
+				Elem: &schema.Schema{
+					Type:         schema.TypeString,
+					ValidateFunc: validation.StringIsNotWhiteSpace,
+				},
+				Description: "The names or IDs of the other projects the service endpoint is shared with, the IDs are stored in the state.",
+			},
+			"shared_project_override": {
+				Type:     schema.TypeSet,
+				Optional: true,
+				Computed: true,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"project_id": {
+							Type:         schema.TypeString,
+							Required:     true,
+							ValidateFunc: validation.StringIsNotWhiteSpace,
+							Description:  "The name or ID of a project of shared_project_ids.",
+						},
+						"service_endpoint_name": {
+							Type:         schema.TypeString,
+							Optional:     true,
+							ValidateFunc: validation.StringIsNotWhiteSpace,
+							Description:  "The name of the service endpoint in the project, when it differs from service_endpoint_name.",
+						},
+						"description": {
+							Type:         schema.TypeString,
+							Optional:     true,
+							ValidateFunc: validation.StringLenBetween(0, 1024),
+							Description:  "The description of the service endpoint in the project, when it differs from description.",
+						},
+					},
+				},
+				Description: "The name and description of the service endpoint in some of the projects of shared_project_ids.",
+			},
+			"verify": {
+				Type:     schema.TypeList,
+				Optional: true,
+				MaxItems: 1,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"data_source": {
+							Type:         schema.TypeString,
+							Optional:     true,
+							Default:      defaultVerifyDataSource,
+							ValidateFunc: validation.StringIsNotWhiteSpace,
+							Description:  "The data source of the service endpoint type executed to verify the service endpoint.",
+						},
+						"rollback": {
+							Type:        schema.TypeBool,
+							Optional:    true,
+							Default:     false,
+							Description: "Whether the service endpoint is deleted when it fails the verification after being created.",
+						},
+					},
+				},
+				Description: "Verifies the service endpoint against the service it connects to after it is created or updated, the apply fails when the verification fails.",
+			},
 			"authorization": {
 				Type:         schema.TypeMap,
 				Optional:     true,
@@ -99,20 +183,61 @@ func doBaseExpansion(d *schema.ResourceData) (*serviceendpoint.ServiceEndpoint,
 		Name:        name,
 		Owner:       converter.String("library"),
 		Description: description,
-		ServiceEndpointProjectReferences: &[]serviceendpoint.ServiceEndpointProjectReference{
-			{
-				Name:        name,
-				Description: description,
-				ProjectReference: &serviceendpoint.ProjectReference{
-					Id: &projectID,
-				},
+	}
+	references := []serviceendpoint.ServiceEndpointProjectReference{
+		{
+			Name:        name,
+			Description: description,
+			ProjectReference: &serviceendpoint.ProjectReference{
+				Id: &projectID,
 			},
 		},
 	}
+	references = append(references, expandSharedProjects(d, *name, *description, projectID)...)
+	serviceEndpoint.ServiceEndpointProjectReferences = &references
 
 	return serviceEndpoint, &projectID
 }
 
+// expandSharedProjects returns the references to the projects of shared_project_ids, named and described like the
+// service endpoint unless shared_project_override says otherwise
+func expandSharedProjects(d *schema.ResourceData, name string, description string, projectID uuid.UUID) []serviceendpoint.ServiceEndpointProjectReference {
+	overrides := map[string]map[string]interface{}{}
+	for _, override := range d.Get("shared_project_override").(*schema.Set).List() {
+		overrideMap := override.(map[string]interface{})
+		overrides[strings.ToLower(overrideMap["project_id"].(string))] = overrideMap
+	}
+
+	sharedProjectIDs := tfhelper.ExpandStringSet(d.Get("shared_project_ids").(*schema.Set))
+	sort.Strings(sharedProjectIDs)
+
+	references := []serviceendpoint.ServiceEndpointProjectReference{}
+	for _, sharedProjectID := range sharedProjectIDs {
+		sharedID, err := uuid.Parse(sharedProjectID)
+		if err != nil || sharedID == projectID {
+			continue
+		}
+
+		reference := serviceendpoint.ServiceEndpointProjectReference{
+			Name:        converter.String(name),
+			Description: converter.String(description),
+			ProjectReference: &serviceendpoint.ProjectReference{
+				Id: &sharedID,
+			},
+		}
+		if override, ok := overrides[strings.ToLower(sharedProjectID)]; ok {
+			if overrideName := override["service_endpoint_name"].(string); overrideName != "" {
+				reference.Name = converter.String(overrideName)
+			}
+			if overrideDescription := override["description"].(string); overrideDescription != "" {
+				reference.Description = converter.String(overrideDescription)
+			}
+		}
+		references = append(references, reference)
+	}
+	return references
+}
+
 // doBaseFlattening performs the flattening for the 'base' attributes that are defined in the schema, above
 func doBaseFlattening(d *schema.ResourceData, serviceEndpoint *serviceendpoint.ServiceEndpoint, projectID *uuid.UUID) {
 	d.SetId(serviceEndpoint.Id.String())
@@ -125,6 +250,200 @@ func doBaseFlattening(d *schema.ResourceData, serviceEndpoint *serviceendpoint.S
 			"scheme": *serviceEndpoint.Authorization.Scheme,
 		})
 	}
+
+	if serviceEndpoint.ServiceEndpointProjectReferences != nil {
+		flattenSharedProjects(d, serviceEndpoint, projectID)
+	}
+}
+
+// flattenSharedProjects sets shared_project_ids and shared_project_override from the project references of the
+// service endpoint. An override is kept for the projects where the endpoint is named or described differently,
+// and for the projects which already had one.
+func flattenSharedProjects(d *schema.ResourceData, serviceEndpoint *serviceendpoint.ServiceEndpoint, projectID *uuid.UUID) {
+	overridden := map[string]bool{}
+	for _, override := range d.Get("shared_project_override").(*schema.Set).List() {
+		overridden[strings.ToLower(override.(map[string]interface{})["project_id"].(string))] = true
+	}
+
+	name := converter.ToString(serviceEndpoint.Name, "")
+	description := converter.ToString(serviceEndpoint.Description, "")
+
+	sharedProjectIDs := []interface{}{}
+	overrides := []interface{}{}
+	for _, reference := range *serviceEndpoint.ServiceEndpointProjectReferences {
+		if reference.ProjectReference == nil || reference.ProjectReference.Id == nil || *reference.ProjectReference.Id == *projectID {
+			continue
+		}
+		sharedProjectID := reference.ProjectReference.Id.String()
+		sharedProjectIDs = append(sharedProjectIDs, sharedProjectID)
+
+		override := map[string]interface{}{
+			"project_id":            sharedProjectID,
+			"service_endpoint_name": "",
+			"description":           "",
+		}
+		if referenceName := converter.ToString(reference.Name, name); referenceName != name {
+			override["service_endpoint_name"] = referenceName
+		}
+		if referenceDescription := converter.ToString(reference.Description, description); referenceDescription != description {
+			override["description"] = referenceDescription
+		}
+		if override["service_endpoint_name"] != "" || override["description"] != "" || overridden[strings.ToLower(sharedProjectID)] {
+			overrides = append(overrides, override)
+		}
+	}
+
+	d.Set("shared_project_ids", sharedProjectIDs)
+	d.Set("shared_project_override", overrides)
+}
+
+// customizeDiffSharedProjects plans the IDs of the projects of shared_project_ids and shared_project_override, which
+// accept their names, so that referring to a project by name or by an upper case ID does not cause a diff. It also
+// rejects shared projects which would be reported as drift by flattenSharedProjects.
+func customizeDiffSharedProjects(_ context.Context, d *schema.ResourceDiff, m interface{}) error {
+	if !d.NewValueKnown("shared_project_ids") || !d.NewValueKnown("shared_project_override") {
+		return nil
+	}
+
+	sharedProjectIDs, overrides := configuredSharedProjects(d)
+	sharedProjectIDs, overrides, err := resolveSharedProjects(m, sharedProjectIDs, overrides)
+	if err != nil {
+		return err
+	}
+
+	shared := map[string]bool{}
+	for _, sharedProjectID := range sharedProjectIDs {
+		shared[strings.ToLower(sharedProjectID.(string))] = true
+	}
+
+	if projectID := d.Get("project_id").(string); d.NewValueKnown("project_id") && shared[strings.ToLower(projectID)] {
+		return fmt.Errorf("shared_project_ids must not contain the project of the service endpoint (%s)", projectID)
+	}
+
+	for _, override := range overrides {
+		overrideProjectID := override.(map[string]interface{})["project_id"].(string)
+		if overrideProjectID != "" && !shared[strings.ToLower(overrideProjectID)] {
+			return fmt.Errorf("the project %s of shared_project_override is not in shared_project_ids", overrideProjectID)
+		}
+	}
+
+	if err := d.SetNew("shared_project_ids", sharedProjectIDs); err != nil {
+		return err
+	}
+	return d.SetNew("shared_project_override", overrides)
+}
+
+// configuredSharedProjects returns the shared_project_ids and the shared_project_override of the configuration. They
+// are Computed, so their planned values are the ones of the state when they are not configured.
+func configuredSharedProjects(d *schema.ResourceDiff) ([]interface{}, []interface{}) {
+	sharedProjectIDs := d.Get("shared_project_ids").(*schema.Set).List()
+	overrides := d.Get("shared_project_override").(*schema.Set).List()
+
+	config := d.GetRawConfig()
+	if config.IsNull() || !config.IsKnown() {
+		// without the raw configuration, e.g. in unit tests, the planned values are the configured ones
+		return sharedProjectIDs, overrides
+	}
+	isEmpty := func(value cty.Value) bool {
+		return value.IsNull() || (value.IsKnown() && value.LengthInt() == 0)
+	}
+	if isEmpty(config.GetAttr("shared_project_ids")) {
+		sharedProjectIDs = []interface{}{}
+	}
+	if isEmpty(config.GetAttr("shared_project_override")) {
+		overrides = []interface{}{}
+	}
+	return sharedProjectIDs, overrides
+}
+
+// resolveSharedProjects returns the shared projects with the IDs of the projects named by their name, see
+// tfhelper.GetRealProjectId. IDs are returned in lower case, as returned by Azure DevOps. Names are kept when the
+// provider is not configured, e.g. when validating the configuration.
+func resolveSharedProjects(m interface{}, sharedProjectIDs []interface{}, overrides []interface{}) ([]interface{}, []interface{}, error) {
+	_, ok := m.(*client.AggregatedClient)
+	resolve := func(projectNameOrID string) (string, error) {
+		if projectID, err := uuid.Parse(projectNameOrID); err == nil {
+			return projectID.String(), nil
+		}
+		if !ok {
+			return projectNameOrID, nil
+		}
+		return tfhelper.GetRealProjectId(projectNameOrID, m)
+	}
+
+	resolvedProjectIDs := make([]interface{}, 0, len(sharedProjectIDs))
+	for _, sharedProjectID := range sharedProjectIDs {
+		projectID, err := resolve(sharedProjectID.(string))
+		if err != nil {
+			return nil, nil, err
+		}
+		resolvedProjectIDs = append(resolvedProjectIDs, projectID)
+	}
+
+	resolvedOverrides := make([]interface{}, 0, len(overrides))
+	for _, override := range overrides {
+		resolvedOverride := map[string]interface{}{}
+		for key, value := range override.(map[string]interface{}) {
+			resolvedOverride[key] = value
+		}
+		projectID, err := resolve(resolvedOverride["project_id"].(string))
+		if err != nil {
+			return nil, nil, err
+		}
+		resolvedOverride["project_id"] = projectID
+		resolvedOverrides = append(resolvedOverrides, resolvedOverride)
+	}
+	return resolvedProjectIDs, resolvedOverrides, nil
+}
+
+// resolveSharedProjectsOnApply stores the IDs of the shared projects whose names were not known when planning, see
+// customizeDiffSharedProjects
+func resolveSharedProjectsOnApply(d *schema.ResourceData, m interface{}) error {
+	sharedProjectIDs, overrides, err := resolveSharedProjects(m, d.Get("shared_project_ids").(*schema.Set).List(), d.Get("shared_project_override").(*schema.Set).List())
+	if err != nil {
+		return err
+	}
+	if err := d.Set("shared_project_ids", sharedProjectIDs); err != nil {
+		return err
+	}
+	return d.Set("shared_project_override", overrides)
+}
+
+// customizeDiffServiceEndpointTypeInputs checks the data and the authorization of a service endpoint of endpointType
+// against the inputs of the type, as published by Azure DevOps, see validateServiceEndpointInputs
+func customizeDiffServiceEndpointTypeInputs(endpointType string, i inputsFunc) schema.CustomizeDiffFunc {
+	return func(_ context.Context, d *schema.ResourceDiff, m interface{}) error {
+		clients, ok := m.(*client.AggregatedClient)
+		if !ok {
+			return nil
+		}
+		scheme, data, parameters, ok := i(d)
+		if !ok {
+			return nil
+		}
+		return checkServiceEndpointTypeInputs(clients, endpointType, scheme, data, parameters)
+	}
+}
+
+// plannedInput returns the planned value of an attribute for an input of the service endpoint type,
+// unknownInputValue when it is not known yet
+func plannedInput(d *schema.ResourceDiff, key string) string {
+	if !d.NewValueKnown(key) {
+		return unknownInputValue
+	}
+	return d.Get(key).(string)
+}
+
+// plannedSecretInput returns unknownInputValue when any of the attributes configuring a secret is set, e.g. the
+// secret, the version of its write-only variant or its Vault reference, and "" otherwise. The write-only variant is
+// not in the plan and the Vault reference is only read on apply, so the value of the secret is never checked.
+func plannedSecretInput(d *schema.ResourceDiff, keys ...string) string {
+	for _, key := range keys {
+		if _, ok := d.GetOk(key); ok || !d.NewValueKnown(key) {
+			return unknownInputValue
+		}
+	}
+	return ""
 }
 
 // makeProtectedSchema create protected schema
@@ -152,14 +471,28 @@ func makeUnprotectedSchema(r *schema.Resource, keyName, envVarName, description
 	}
 }
 
-func genServiceEndpointCreateFunc(flatFunc flatFunc, expandFunc expandFunc) func(d *schema.ResourceData, m interface{}) error {
+func genServiceEndpointCreateFunc(flatFunc flatFunc, expandFunc expandFunc, vaultRefsFunc vaultRefsFunc) func(d *schema.ResourceData, m interface{}) error {
 	return func(d *schema.ResourceData, m interface{}) error {
 		clients := m.(*client.AggregatedClient)
+		if _, err := tfhelper.ResolveProjectID(d, m); err != nil {
+			return err
+		}
+		if err := resolveSharedProjectsOnApply(d, m); err != nil {
+			return err
+		}
 		serviceEndpoint, projectID, err := expandFunc(d)
 		if err != nil {
 			return fmt.Errorf(errMsgTfConfigRead, err)
 		}
+		defaultServiceEndpointURL(clients, serviceEndpoint)
+		if vaultRefsFunc != nil {
+			if err := resolveVaultSecrets(clients, serviceEndpoint, vaultRefsFunc(d)); err != nil {
+				return err
+			}
+		}
 
+		// the endpoint is created in its project, then shared with the other projects once it is ready
+		sharedReferences := sharedProjectReferences(serviceEndpoint, projectID)
 		createdServiceEndpoint, err := createServiceEndpoint(clients, serviceEndpoint, projectID)
 		if err != nil {
 			return fmt.Errorf("Error creating service endpoint in Azure DevOps: %+v", err)
@@ -177,16 +510,101 @@ func genServiceEndpointCreateFunc(flatFunc flatFunc, expandFunc expandFunc) func
 
 		if _, err := stateConf.WaitForState(); err != nil {
 			if delErr := deleteServiceEndpoint(clients, projectID, createdServiceEndpoint.Id, d.Timeout(schema.TimeoutDelete)); delErr != nil {
-				log.Printf("[DEBUG] Failed to delete the failed service endpoint: %v ", delErr)
+				ctx := logging.WithSubsystem(clients.Ctx, logging.SubsystemServiceEndpoint)
+				tflog.SubsystemDebug(ctx, logging.SubsystemServiceEndpoint, "Failed to delete the failed service endpoint", map[string]interface{}{"error": delErr.Error()})
 			}
 			return fmt.Errorf(" waiting for service endpoint ready. %v ", err)
 		}
 
+		if err := verifyServiceEndpoint(d, clients, createdServiceEndpoint.Id, projectID); err != nil {
+			if d.Get("verify.0.rollback").(bool) {
+				if delErr := deleteServiceEndpoint(clients, projectID, createdServiceEndpoint.Id, d.Timeout(schema.TimeoutDelete)); delErr != nil {
+					return fmt.Errorf("%v. Deleting the service endpoint failed too: %v", err, delErr)
+				}
+				return err
+			}
+			// the endpoint is tainted, so that it is replaced by the next apply
+			d.SetId(createdServiceEndpoint.Id.String())
+			return err
+		}
+
 		d.SetId(createdServiceEndpoint.Id.String())
+		if err := shareServiceEndpoint(clients, createdServiceEndpoint.Id, sharedReferences); err != nil {
+			return err
+		}
 		return genServiceEndpointReadFunc(flatFunc)(d, m)
 	}
 }
 
+// importServiceEndpoint imports a service endpoint from an ID like <project name or ID>/<endpoint name or ID>.
+// The import fails when the service endpoint is not of endpointType, as it can't be managed by the resource. Service
+// endpoints of any type are imported when endpointType is "".
+func importServiceEndpoint(endpointType string) *schema.ResourceImporter {
+	return &schema.ResourceImporter{
+		State: func(d *schema.ResourceData, m interface{}) ([]*schema.ResourceData, error) {
+			clients := m.(*client.AggregatedClient)
+
+			projectNameOrID, serviceEndpointNameOrID, err := tfhelper.ParseImportedName(d.Id())
+			if err != nil {
+				return nil, fmt.Errorf("error parsing the resource ID from the Terraform resource data: %v", err)
+			}
+			projectID, err := tfhelper.GetRealProjectId(projectNameOrID, m)
+			if err != nil {
+				return nil, err
+			}
+
+			serviceEndpoint, err := lookupServiceEndpoint(clients, projectID, serviceEndpointNameOrID)
+			if err != nil {
+				return nil, err
+			}
+			if actualType := converter.ToString(serviceEndpoint.Type, ""); endpointType != "" && !strings.EqualFold(actualType, endpointType) {
+				return nil, fmt.Errorf("the service endpoint %s is of type %s, this resource only manages service endpoints of type %s", serviceEndpointNameOrID, actualType, endpointType)
+			}
+
+			d.Set("project_id", projectID)
+			d.SetId(serviceEndpoint.Id.String())
+			return []*schema.ResourceData{d}, nil
+		},
+	}
+}
+
+// lookupServiceEndpoint returns the service endpoint of the project with the given ID or name
+func lookupServiceEndpoint(clients *client.AggregatedClient, projectID string, serviceEndpointNameOrID string) (*serviceendpoint.ServiceEndpoint, error) {
+	if serviceEndpointID, err := uuid.Parse(serviceEndpointNameOrID); err == nil {
+		serviceEndpoint, err := clients.ServiceEndpointClient.GetServiceEndpointDetails(
+			clients.Ctx,
+			serviceendpoint.GetServiceEndpointDetailsArgs{
+				EndpointId: &serviceEndpointID,
+				Project:    converter.String(projectID),
+			},
+		)
+		if err != nil && !utils.ResponseWasNotFound(err) {
+			return nil, fmt.Errorf("Error looking up service endpoint given ID (%v) and project ID (%v): %v", serviceEndpointID, projectID, err)
+		}
+		if err != nil || serviceEndpoint == nil || serviceEndpoint.Id == nil {
+			return nil, fmt.Errorf("No service endpoint with ID %s found in project %s", serviceEndpointID, projectID)
+		}
+		return serviceEndpoint, nil
+	}
+
+	serviceEndpoints, err := clients.ServiceEndpointClient.GetServiceEndpointsByNames(
+		clients.Ctx,
+		serviceendpoint.GetServiceEndpointsByNamesArgs{
+			Project:       converter.String(projectID),
+			EndpointNames: &[]string{serviceEndpointNameOrID},
+		})
+	if err != nil {
+		return nil, fmt.Errorf("Error looking up service endpoint %s in project %s: %+v", serviceEndpointNameOrID, projectID, err)
+	}
+	if serviceEndpoints == nil || len(*serviceEndpoints) == 0 {
+		return nil, fmt.Errorf("No service endpoint named %s found in project %s", serviceEndpointNameOrID, projectID)
+	}
+	if len(*serviceEndpoints) > 1 {
+		return nil, fmt.Errorf("Found %d service endpoints named %s in project %s, import it by its ID", len(*serviceEndpoints), serviceEndpointNameOrID, projectID)
+	}
+	return &(*serviceEndpoints)[0], nil
+}
+
 func genServiceEndpointReadFunc(flatFunc flatFunc) func(d *schema.ResourceData, m interface{}) error {
 	return func(d *schema.ResourceData, m interface{}) error {
 		clients := m.(*client.AggregatedClient)
@@ -221,27 +639,64 @@ func genServiceEndpointReadFunc(flatFunc flatFunc) func(d *schema.ResourceData,
 		if serviceEndpoint.Id == nil {
 			// e.g. service endpoint has been deleted separately without TF
 			d.SetId("")
-		} else {
-			flatFunc(d, serviceEndpoint, &projectID)
+		} else if err := flatFunc(d, serviceEndpoint, &projectID); err != nil {
+			return fmt.Errorf("Error flattening service endpoint %s: %+v", serviceEndpointID, err)
 		}
 		return nil
 	}
 }
 
-func genServiceEndpointUpdateFunc(flatFunc flatFunc, expandFunc expandFunc) schema.UpdateFunc {
+func genServiceEndpointUpdateFunc(flatFunc flatFunc, expandFunc expandFunc, vaultRefsFunc vaultRefsFunc) schema.UpdateFunc {
 	return func(d *schema.ResourceData, m interface{}) error {
 		clients := m.(*client.AggregatedClient)
+		if err := resolveSharedProjectsOnApply(d, m); err != nil {
+			return err
+		}
 		serviceEndpoint, projectID, err := expandFunc(d)
 		if err != nil {
 			return fmt.Errorf(errMsgTfConfigRead, err)
 		}
+		defaultServiceEndpointURL(clients, serviceEndpoint)
+		if vaultRefsFunc != nil {
+			if err := resolveVaultSecrets(clients, serviceEndpoint, vaultRefsFunc(d)); err != nil {
+				return err
+			}
+		}
+
+		// projects added to shared_project_ids are shared before the update, which sets the name and description
+		// of the endpoint in every project, and removed ones are unshared after it
+		oldShared, newShared := d.GetChange("shared_project_ids")
+		addedProjectIDs := newShared.(*schema.Set).Difference(oldShared.(*schema.Set))
+		removedProjectIDs := tfhelper.ExpandStringSet(oldShared.(*schema.Set).Difference(newShared.(*schema.Set)))
+
+		addedReferences := []serviceendpoint.ServiceEndpointProjectReference{}
+		for _, reference := range sharedProjectReferences(serviceEndpoint, projectID) {
+			if addedProjectIDs.Contains(reference.ProjectReference.Id.String()) {
+				addedReferences = append(addedReferences, reference)
+			}
+		}
+		if err := shareServiceEndpoint(clients, serviceEndpoint.Id, addedReferences); err != nil {
+			return err
+		}
 
 		updatedServiceEndpoint, err := updateServiceEndpoint(clients, serviceEndpoint, projectID)
 		if err != nil {
 			return fmt.Errorf("Error updating service endpoint in Azure DevOps: %+v", err)
 		}
 
-		flatFunc(d, updatedServiceEndpoint, projectID)
+		if err := unshareServiceEndpoint(clients, serviceEndpoint.Id, removedProjectIDs); err != nil {
+			return err
+		}
+
+		if err := verifyServiceEndpoint(d, clients, serviceEndpoint.Id, projectID); err != nil {
+			// keep the previous state, so that the update is applied again by the next apply
+			d.Partial(true)
+			return err
+		}
+
+		if err := flatFunc(d, updatedServiceEndpoint, projectID); err != nil {
+			return fmt.Errorf("Error flattening service endpoint %s: %+v", serviceEndpoint.Id, err)
+		}
 		return genServiceEndpointReadFunc(flatFunc)(d, m)
 	}
 }
@@ -254,10 +709,149 @@ func genServiceEndpointDeleteFunc(expandFunc expandFunc) schema.DeleteFunc {
 			return fmt.Errorf(errMsgTfConfigRead, err)
 		}
 
+		sharedProjectIDs := []string{}
+		for _, reference := range sharedProjectReferences(serviceEndpoint, projectID) {
+			sharedProjectIDs = append(sharedProjectIDs, reference.ProjectReference.Id.String())
+		}
+		if err := unshareServiceEndpoint(clients, serviceEndpoint.Id, sharedProjectIDs); err != nil {
+			return err
+		}
+
 		return deleteServiceEndpoint(clients, projectID, serviceEndpoint.Id, d.Timeout(schema.TimeoutDelete))
 	}
 }
 
+// sharedProjectReferences returns the references of the endpoint to the projects it is shared with
+func sharedProjectReferences(endpoint *serviceendpoint.ServiceEndpoint, projectID *uuid.UUID) []serviceendpoint.ServiceEndpointProjectReference {
+	references := []serviceendpoint.ServiceEndpointProjectReference{}
+	if endpoint.ServiceEndpointProjectReferences == nil {
+		return references
+	}
+	for _, reference := range *endpoint.ServiceEndpointProjectReferences {
+		if reference.ProjectReference != nil && reference.ProjectReference.Id != nil && *reference.ProjectReference.Id != *projectID {
+			references = append(references, reference)
+		}
+	}
+	return references
+}
+
+// Make the Azure DevOps API call to share the endpoint with other projects
+func shareServiceEndpoint(clients *client.AggregatedClient, serviceEndpointID *uuid.UUID, references []serviceendpoint.ServiceEndpointProjectReference) error {
+	if len(references) == 0 {
+		return nil
+	}
+	if err := clients.ServiceEndpointClient.ShareServiceEndpoint(
+		clients.Ctx,
+		serviceendpoint.ShareServiceEndpointArgs{
+			EndpointId:                serviceEndpointID,
+			EndpointProjectReferences: &references,
+		}); err != nil {
+		return fmt.Errorf("Error sharing service endpoint %s: %+v", serviceEndpointID, err)
+	}
+	return nil
+}
+
+// Make the Azure DevOps API call to stop sharing the endpoint with other projects, deleting it from these projects only
+func unshareServiceEndpoint(clients *client.AggregatedClient, serviceEndpointID *uuid.UUID, projectIDs []string) error {
+	if len(projectIDs) == 0 {
+		return nil
+	}
+	if err := clients.ServiceEndpointClient.DeleteServiceEndpoint(
+		clients.Ctx,
+		serviceendpoint.DeleteServiceEndpointArgs{
+			EndpointId: serviceEndpointID,
+			ProjectIds: &projectIDs,
+		}); err != nil {
+		return fmt.Errorf("Error unsharing service endpoint %s from projects %s: %+v", serviceEndpointID, strings.Join(projectIDs, ", "), err)
+	}
+	return nil
+}
+
+// verifyServiceEndpoint executes the data source of the verify block against the service endpoint, as the Verify
+// button of Azure DevOps does. Endpoints without a verify block are not verified.
+func verifyServiceEndpoint(d *schema.ResourceData, clients *client.AggregatedClient, serviceEndpointID *uuid.UUID, projectID *uuid.UUID) error {
+	if len(d.Get("verify").([]interface{})) == 0 {
+		return nil
+	}
+	dataSource := d.Get("verify.0.data_source").(string)
+
+	result, err := clients.ServiceEndpointClient.ExecuteServiceEndpointRequest(
+		clients.Ctx,
+		serviceendpoint.ExecuteServiceEndpointRequestArgs{
+			Project:    converter.String(projectID.String()),
+			EndpointId: converter.String(serviceEndpointID.String()),
+			ServiceEndpointRequest: &serviceendpoint.ServiceEndpointRequest{
+				DataSourceDetails: &serviceendpoint.DataSourceDetails{
+					DataSourceName: converter.String(dataSource),
+					Parameters:     &map[string]string{},
+				},
+				ResultTransformationDetails: &serviceendpoint.ResultTransformationDetails{},
+			},
+		})
+	if err != nil {
+		return fmt.Errorf("Error verifying service endpoint %s with %s: %+v", serviceEndpointID, dataSource, err)
+	}
+	if result == nil {
+		return fmt.Errorf("Error verifying service endpoint %s with %s: no result was returned", serviceEndpointID, dataSource)
+	}
+
+	statusCode := converter.ToString(result.StatusCode, "")
+	if !strings.EqualFold(statusCode, "ok") {
+		return fmt.Errorf("Service endpoint %s failed the verification with %s (%s): %s", serviceEndpointID, dataSource, statusCode, converter.ToString(result.ErrorMessage, "no error message"))
+	}
+	return nil
+}
+
+// defaultServiceEndpointURL points the service endpoints of the types without a URL of their own, e.g. the incoming
+// webhooks, to the organization
+func defaultServiceEndpointURL(clients *client.AggregatedClient, serviceEndpoint *serviceendpoint.ServiceEndpoint) {
+	if serviceEndpoint.Url == nil {
+		serviceEndpoint.Url = converter.String(clients.OrganizationURL)
+	}
+}
+
+// vaultRefsOf returns the vaultRefsFunc of the authorization parameters whose value is set by a secret attribute,
+// see tfhelper.GenerateVaultRefSchema. attributes maps the name of each parameter to the key of its secret, e.g.
+// password to secret_id when secret_id_vault_ref sets the parameter password.
+func vaultRefsOf(attributes map[string]string) vaultRefsFunc {
+	return func(d *schema.ResourceData) map[string]string {
+		refs := map[string]string{}
+		for name, secretKey := range attributes {
+			if ref := tfhelper.GetVaultRef(d, secretKey); ref != "" {
+				refs[name] = ref
+			}
+		}
+		return refs
+	}
+}
+
+// resolveVaultSecrets sets the authorization parameters whose value is read from Vault, refs maps the name of each
+// parameter to its Vault reference. The values are only sent to Azure DevOps, they are never stored in the state.
+func resolveVaultSecrets(clients *client.AggregatedClient, serviceEndpoint *serviceendpoint.ServiceEndpoint, refs map[string]string) error {
+	if len(refs) == 0 {
+		return nil
+	}
+	if clients.VaultClient == nil {
+		return fmt.Errorf("the authorization parameters of the service endpoint are read from Vault, but the provider has no vault block")
+	}
+	if serviceEndpoint.Authorization == nil {
+		serviceEndpoint.Authorization = &serviceendpoint.EndpointAuthorization{}
+	}
+	if serviceEndpoint.Authorization.Parameters == nil {
+		serviceEndpoint.Authorization.Parameters = &map[string]string{}
+	}
+
+	parameters := *serviceEndpoint.Authorization.Parameters
+	for name, ref := range refs {
+		value, err := clients.VaultClient.ReadSecret(clients.Ctx, ref)
+		if err != nil {
+			return fmt.Errorf("Error reading the value of the authorization parameter %s from Vault: %+v", name, err)
+		}
+		parameters[name] = value
+	}
+	return nil
+}
+
 // Make the Azure DevOps API call to create the endpoint
 func createServiceEndpoint(clients *client.AggregatedClient, endpoint *serviceendpoint.ServiceEndpoint, projectID *uuid.UUID) (*serviceendpoint.ServiceEndpoint, error) {
 	if strings.EqualFold(*endpoint.Type, "github") && strings.EqualFold(*endpoint.Authorization.Scheme, "InstallationToken") {
@@ -0,0 +1,456 @@
+package serviceendpoint
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/babylonhealth/terraform-provider-bblnazuredevops/azdosdkmocks"
+	"github.com/babylonhealth/terraform-provider-bblnazuredevops/bblnazuredevops/internal/client"
+	"github.com/babylonhealth/terraform-provider-bblnazuredevops/bblnazuredevops/internal/client/vault"
+	"github.com/babylonhealth/terraform-provider-bblnazuredevops/bblnazuredevops/internal/utils/cache"
+	"github.com/babylonhealth/terraform-provider-bblnazuredevops/bblnazuredevops/internal/utils/converter"
+	"github.com/golang/mock/gomock"
+	"github.com/google/uuid"
+	"github.com/hashicorp/go-cty/cty"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
+	"github.com/microsoft/azure-devops-go-api/azuredevops/v6/core"
+	"github.com/microsoft/azure-devops-go-api/azuredevops/v6/serviceendpoint"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	testProjectID        = uuid.MustParse("11111111-1111-1111-1111-111111111111")
+	testSharedProjectID  = uuid.MustParse("22222222-2222-2222-2222-222222222222")
+	testSharedProjectID2 = uuid.MustParse("33333333-3333-3333-3333-333333333333")
+)
+
+func Test_expandSharedProjects(t *testing.T) {
+	d := schema.TestResourceDataRaw(t, ResourceServiceEndpointGenericWebhook().Schema, map[string]interface{}{
+		"project_id":            testProjectID.String(),
+		"service_endpoint_name": "endpoint",
+		"description":           "Managed by Terraform",
+		"shared_project_ids":    []interface{}{testSharedProjectID2.String(), testSharedProjectID.String()},
+		"shared_project_override": []interface{}{
+			map[string]interface{}{
+				"project_id":            testSharedProjectID2.String(),
+				"service_endpoint_name": "endpoint-2",
+			},
+		},
+	})
+
+	endpoint, projectID := doBaseExpansion(d)
+	require.Equal(t, testProjectID, *projectID)
+	require.Equal(t, []serviceendpoint.ServiceEndpointProjectReference{
+		{
+			Name:             converter.String("endpoint"),
+			Description:      converter.String("Managed by Terraform"),
+			ProjectReference: &serviceendpoint.ProjectReference{Id: &testProjectID},
+		},
+		{
+			Name:             converter.String("endpoint"),
+			Description:      converter.String("Managed by Terraform"),
+			ProjectReference: &serviceendpoint.ProjectReference{Id: &testSharedProjectID},
+		},
+		{
+			Name:             converter.String("endpoint-2"),
+			Description:      converter.String("Managed by Terraform"),
+			ProjectReference: &serviceendpoint.ProjectReference{Id: &testSharedProjectID2},
+		},
+	}, *endpoint.ServiceEndpointProjectReferences)
+}
+
+// Projects the endpoint was shared with outside of Terraform are reported as drift
+func Test_flattenSharedProjects(t *testing.T) {
+	d := schema.TestResourceDataRaw(t, ResourceServiceEndpointGenericWebhook().Schema, map[string]interface{}{})
+
+	endpointID := uuid.New()
+	doBaseFlattening(d, &serviceendpoint.ServiceEndpoint{
+		Id:          &endpointID,
+		Name:        converter.String("endpoint"),
+		Description: converter.String("Managed by Terraform"),
+		ServiceEndpointProjectReferences: &[]serviceendpoint.ServiceEndpointProjectReference{
+			{
+				Name:             converter.String("endpoint"),
+				Description:      converter.String("Managed by Terraform"),
+				ProjectReference: &serviceendpoint.ProjectReference{Id: &testProjectID},
+			},
+			{
+				Name:             converter.String("endpoint"),
+				Description:      converter.String("Managed by Terraform"),
+				ProjectReference: &serviceendpoint.ProjectReference{Id: &testSharedProjectID},
+			},
+			{
+				Name:             converter.String("endpoint"),
+				Description:      converter.String("Shared with the platform team"),
+				ProjectReference: &serviceendpoint.ProjectReference{Id: &testSharedProjectID2},
+			},
+		},
+	}, &testProjectID)
+
+	require.ElementsMatch(t, []interface{}{testSharedProjectID.String(), testSharedProjectID2.String()}, d.Get("shared_project_ids").(*schema.Set).List())
+	require.Equal(t, []interface{}{
+		map[string]interface{}{
+			"project_id":            testSharedProjectID2.String(),
+			"service_endpoint_name": "",
+			"description":           "Shared with the platform team",
+		},
+	}, d.Get("shared_project_override").(*schema.Set).List())
+}
+
+// The shared projects are planned by ID, whether they are configured by name or by an upper case ID
+func Test_customizeDiffSharedProjects(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	coreClient := azdosdkmocks.NewMockCoreClient(ctrl)
+	clients := &client.AggregatedClient{CoreClient: coreClient, Cache: cache.New(time.Minute), Ctx: context.Background()}
+	coreClient.EXPECT().GetProject(clients.Ctx, core.GetProjectArgs{
+		ProjectId:           converter.String("shared"),
+		IncludeCapabilities: converter.Bool(true),
+		IncludeHistory:      converter.Bool(false),
+	}).Return(&core.TeamProject{Id: &testSharedProjectID}, nil).Times(1)
+
+	config := map[string]interface{}{
+		"project_id":            testProjectID.String(),
+		"service_endpoint_name": "webhook",
+		"webhook_name":          "releases",
+		"http_header":           "X-Hub-Signature",
+		"secret":                "hmac-secret",
+		"shared_project_ids":    []interface{}{"shared", strings.ToUpper(testSharedProjectID2.String())},
+		"shared_project_override": []interface{}{
+			map[string]interface{}{"project_id": "shared", "service_endpoint_name": "webhook-shared"},
+		},
+	}
+	diff, err := ResourceServiceEndpointIncomingWebhook().Diff(context.Background(), nil, terraform.NewResourceConfigRaw(config), clients)
+	require.NoError(t, err)
+
+	sharedProjectIDs := []string{}
+	overrideProjectIDs := []string{}
+	for key, attribute := range diff.Attributes {
+		switch {
+		case strings.HasPrefix(key, "shared_project_ids.") && key != "shared_project_ids.#":
+			sharedProjectIDs = append(sharedProjectIDs, attribute.New)
+		case strings.HasPrefix(key, "shared_project_override.") && strings.HasSuffix(key, ".project_id"):
+			overrideProjectIDs = append(overrideProjectIDs, attribute.New)
+		}
+	}
+	require.ElementsMatch(t, []string{testSharedProjectID.String(), testSharedProjectID2.String()}, sharedProjectIDs)
+	require.Equal(t, []string{testSharedProjectID.String()}, overrideProjectIDs)
+
+	config["shared_project_ids"] = []interface{}{strings.ToUpper(testProjectID.String())}
+	config["shared_project_override"] = []interface{}{}
+	_, err = ResourceServiceEndpointIncomingWebhook().Diff(context.Background(), nil, terraform.NewResourceConfigRaw(config), clients)
+	require.EqualError(t, err, "shared_project_ids must not contain the project of the service endpoint ("+testProjectID.String()+")")
+}
+
+// The shared projects of the state are unshared when they are removed from the configuration, although they are
+// Computed
+func Test_customizeDiffSharedProjects_Unset(t *testing.T) {
+	r := ResourceServiceEndpointIncomingWebhook()
+	config := map[string]interface{}{
+		"project_id":            testProjectID.String(),
+		"service_endpoint_name": "webhook",
+		"webhook_name":          "releases",
+		"http_header":           "X-Hub-Signature",
+		"secret":                "hmac-secret",
+	}
+	rawConfig, err := r.CoreConfigSchema().CoerceValue(cty.ObjectVal(map[string]cty.Value{
+		"project_id":            cty.StringVal(testProjectID.String()),
+		"service_endpoint_name": cty.StringVal("webhook"),
+		"webhook_name":          cty.StringVal("releases"),
+		"http_header":           cty.StringVal("X-Hub-Signature"),
+		"secret":                cty.StringVal("hmac-secret"),
+	}))
+	require.NoError(t, err)
+
+	state := &terraform.InstanceState{
+		ID: "1ceae7ff-565c-4cdf-9214-6e2246cba764",
+		Attributes: map[string]string{
+			"project_id":                                      testProjectID.String(),
+			"service_endpoint_name":                           "webhook",
+			"description":                                     "Managed by Terraform",
+			"webhook_name":                                    "releases",
+			"http_header":                                     "X-Hub-Signature",
+			"shared_project_ids.#":                            "1",
+			"shared_project_ids.0":                            testSharedProjectID.String(),
+			"shared_project_override.#":                       "1",
+			"shared_project_override.0.project_id":            testSharedProjectID.String(),
+			"shared_project_override.0.description":           "",
+			"shared_project_override.0.service_endpoint_name": "webhook-shared",
+		},
+		RawConfig: rawConfig,
+	}
+	diff, err := r.Diff(context.Background(), state, terraform.NewResourceConfigRaw(config), nil)
+	require.NoError(t, err)
+	require.NotNil(t, diff)
+	require.Equal(t, "0", diff.Attributes["shared_project_ids.#"].New)
+	require.Equal(t, "0", diff.Attributes["shared_project_override.#"].New)
+}
+
+func Test_shareServiceEndpoint_SkipsEmptyReferences(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	serviceEndpointClient := azdosdkmocks.NewMockServiceendpointClient(ctrl)
+	clients := &client.AggregatedClient{ServiceEndpointClient: serviceEndpointClient, Ctx: context.Background()}
+
+	endpointID := uuid.New()
+	require.NoError(t, shareServiceEndpoint(clients, &endpointID, nil))
+	require.NoError(t, unshareServiceEndpoint(clients, &endpointID, nil))
+}
+
+func Test_unshareServiceEndpoint_DeletesFromSharedProjectsOnly(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	serviceEndpointClient := azdosdkmocks.NewMockServiceendpointClient(ctrl)
+	clients := &client.AggregatedClient{ServiceEndpointClient: serviceEndpointClient, Ctx: context.Background()}
+
+	endpointID := uuid.New()
+	projectIDs := []string{testSharedProjectID.String()}
+	serviceEndpointClient.EXPECT().
+		DeleteServiceEndpoint(clients.Ctx, serviceendpoint.DeleteServiceEndpointArgs{
+			EndpointId: &endpointID,
+			ProjectIds: &projectIDs,
+		}).
+		Return(nil).
+		Times(1)
+
+	require.NoError(t, unshareServiceEndpoint(clients, &endpointID, projectIDs))
+}
+
+func Test_verifyServiceEndpoint(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	serviceEndpointClient := azdosdkmocks.NewMockServiceendpointClient(ctrl)
+	clients := &client.AggregatedClient{ServiceEndpointClient: serviceEndpointClient, Ctx: context.Background()}
+
+	endpointID := uuid.New()
+	expectedArgs := serviceendpoint.ExecuteServiceEndpointRequestArgs{
+		Project:    converter.String(testProjectID.String()),
+		EndpointId: converter.String(endpointID.String()),
+		ServiceEndpointRequest: &serviceendpoint.ServiceEndpointRequest{
+			DataSourceDetails: &serviceendpoint.DataSourceDetails{
+				DataSourceName: converter.String("TestConnection"),
+				Parameters:     &map[string]string{},
+			},
+			ResultTransformationDetails: &serviceendpoint.ResultTransformationDetails{},
+		},
+	}
+
+	// endpoints without a verify block are not verified
+	d := schema.TestResourceDataRaw(t, ResourceServiceEndpointGenericWebhook().Schema, map[string]interface{}{})
+	require.NoError(t, verifyServiceEndpoint(d, clients, &endpointID, &testProjectID))
+
+	d = schema.TestResourceDataRaw(t, ResourceServiceEndpointGenericWebhook().Schema, map[string]interface{}{
+		"verify": []interface{}{map[string]interface{}{}},
+	})
+
+	serviceEndpointClient.EXPECT().
+		ExecuteServiceEndpointRequest(clients.Ctx, expectedArgs).
+		Return(&serviceendpoint.ServiceEndpointRequestResult{StatusCode: converter.String("ok")}, nil).
+		Times(1)
+	require.NoError(t, verifyServiceEndpoint(d, clients, &endpointID, &testProjectID))
+
+	serviceEndpointClient.EXPECT().
+		ExecuteServiceEndpointRequest(clients.Ctx, expectedArgs).
+		Return(&serviceendpoint.ServiceEndpointRequestResult{
+			StatusCode:   converter.String("unauthorized"),
+			ErrorMessage: converter.String("The security token included in the request is invalid."),
+		}, nil).
+		Times(1)
+	require.EqualError(t, verifyServiceEndpoint(d, clients, &endpointID, &testProjectID),
+		"Service endpoint "+endpointID.String()+" failed the verification with TestConnection (unauthorized): The security token included in the request is invalid.")
+
+	serviceEndpointClient.EXPECT().
+		ExecuteServiceEndpointRequest(clients.Ctx, expectedArgs).
+		Return(nil, nil).
+		Times(1)
+	require.EqualError(t, verifyServiceEndpoint(d, clients, &endpointID, &testProjectID),
+		"Error verifying service endpoint "+endpointID.String()+" with TestConnection: no result was returned")
+}
+
+func Test_importServiceEndpoint(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	serviceEndpointClient := azdosdkmocks.NewMockServiceendpointClient(ctrl)
+	clients := &client.AggregatedClient{ServiceEndpointClient: serviceEndpointClient, Ctx: context.Background()}
+	importer := ResourceServiceEndpointBabylonVault().Importer
+
+	serviceEndpointClient.EXPECT().
+		GetServiceEndpointsByNames(clients.Ctx, serviceendpoint.GetServiceEndpointsByNamesArgs{
+			Project:       converter.String(testProjectID.String()),
+			EndpointNames: &[]string{"vault"},
+		}).
+		Return(&[]serviceendpoint.ServiceEndpoint{newTestVaultEndpoint(testVaultEndpointID, "vault")}, nil).
+		Times(1)
+
+	d := schema.TestResourceDataRaw(t, ResourceServiceEndpointBabylonVault().Schema, map[string]interface{}{})
+	d.SetId(testProjectID.String() + "/vault")
+	imported, err := importer.State(d, clients)
+	require.NoError(t, err)
+	require.Len(t, imported, 1)
+	require.Equal(t, testVaultEndpointID.String(), imported[0].Id())
+	require.Equal(t, testProjectID.String(), imported[0].Get("project_id"))
+
+	serviceEndpointClient.EXPECT().
+		GetServiceEndpointDetails(clients.Ctx, serviceendpoint.GetServiceEndpointDetailsArgs{
+			EndpointId: &testVaultEndpointID,
+			Project:    converter.String(testProjectID.String()),
+		}).
+		Return(&serviceendpoint.ServiceEndpoint{}, nil).
+		Times(1)
+
+	d.SetId(testProjectID.String() + "/" + testVaultEndpointID.String())
+	_, err = importer.State(d, clients)
+	require.EqualError(t, err, "No service endpoint with ID "+testVaultEndpointID.String()+" found in project "+testProjectID.String())
+}
+
+// Service endpoints of another type can't be managed by the resource, they must not be imported
+func Test_importServiceEndpoint_RejectsOtherTypes(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	serviceEndpointClient := azdosdkmocks.NewMockServiceendpointClient(ctrl)
+	clients := &client.AggregatedClient{ServiceEndpointClient: serviceEndpointClient, Ctx: context.Background()}
+
+	githubEndpoint := newTestVaultEndpoint(testVaultEndpointID, "github")
+	githubEndpoint.Type = converter.String("github")
+	serviceEndpointClient.EXPECT().
+		GetServiceEndpointDetails(clients.Ctx, serviceendpoint.GetServiceEndpointDetailsArgs{
+			EndpointId: &testVaultEndpointID,
+			Project:    converter.String(testProjectID.String()),
+		}).
+		Return(&githubEndpoint, nil).
+		Times(1)
+
+	d := schema.TestResourceDataRaw(t, ResourceServiceEndpointBabylonVault().Schema, map[string]interface{}{})
+	d.SetId(testProjectID.String() + "/" + testVaultEndpointID.String())
+	_, err := ResourceServiceEndpointBabylonVault().Importer.State(d, clients)
+	require.EqualError(t, err, "the service endpoint "+testVaultEndpointID.String()+" is of type github, this resource only manages service endpoints of type "+BABYLON_VAULT_SERVICE_CONNECTION_TYPE)
+}
+
+func Test_resolveVaultSecrets(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/secret/data/azdo" || r.Header.Get("X-Vault-Token") != "root" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		fmt.Fprint(w, `{"data":{"data":{"secret":"vault-secret"},"metadata":{"version":1}}}`)
+	}))
+	defer server.Close()
+
+	vaultClient, err := vault.New(vault.Config{Address: server.URL, Token: "root"}, server.Client())
+	require.NoError(t, err)
+	clients := &client.AggregatedClient{VaultClient: vaultClient, Ctx: context.Background()}
+
+	tests := []struct {
+		name     string
+		resource *schema.Resource
+		expand   expandFunc
+		refs     vaultRefsFunc
+		config   map[string]interface{}
+		expected map[string]string
+	}{
+		{
+			name:     "babylon aws iam password",
+			resource: ResourceServiceEndpointBabylonAwsIam(),
+			expand:   expandServiceEndpointBabylonAwsIam,
+			refs:     babylonAwsIamVaultRefs,
+			config: map[string]interface{}{
+				"username":           "user",
+				"global_role_arn":    "roleArn",
+				"password_vault_ref": "secret/data/azdo#secret",
+			},
+			expected: map[string]string{
+				"username":             "user",
+				"password":             "vault-secret",
+				"globalRoleArn":        "roleArn",
+				"globalStsSessionName": BABYLON_AWS_IAM_DEFAULT_SESSION_NAME,
+			},
+		},
+		{
+			name:     "babylon vault secret_id",
+			resource: ResourceServiceEndpointBabylonVault(),
+			expand:   expandServiceEndpointBabylonVault,
+			refs:     babylonVaultVaultRefs,
+			config: map[string]interface{}{
+				"url":                 "https://vault.example.com",
+				"auth_method":         BABYLON_VAULT_AUTH_METHOD_APPROLE,
+				"role_id":             "role",
+				"secret_id_vault_ref": "secret/data/azdo#secret",
+			},
+			expected: map[string]string{"username": "role", "password": "vault-secret"},
+		},
+		{
+			name:     "generic webhook token",
+			resource: ResourceServiceEndpointGenericWebhook(),
+			expand:   expandServiceEndpointGenericWebhook,
+			refs:     genericWebhookVaultRefs,
+			config: map[string]interface{}{
+				"url":   "https://example.com",
+				"token": []interface{}{map[string]interface{}{"value_vault_ref": "secret/data/azdo#secret"}},
+			},
+			expected: map[string]string{"apitoken": "vault-secret"},
+		},
+		{
+			name:     "generic webhook api key",
+			resource: ResourceServiceEndpointGenericWebhook(),
+			expand:   expandServiceEndpointGenericWebhook,
+			refs:     genericWebhookVaultRefs,
+			config: map[string]interface{}{
+				"url":     "https://example.com",
+				"api_key": []interface{}{map[string]interface{}{"header": "X-Api-Key", "value_vault_ref": "secret/data/azdo#secret"}},
+			},
+			expected: map[string]string{"headerName": "X-Api-Key", "apiKey": "vault-secret"},
+		},
+		{
+			name:     "incoming webhook secret",
+			resource: ResourceServiceEndpointIncomingWebhook(),
+			expand:   expandServiceEndpointIncomingWebhook,
+			refs:     incomingWebhookVaultRefs,
+			config: map[string]interface{}{
+				"webhook_name":     "hook",
+				"http_header":      "X-Hub-Signature",
+				"secret_vault_ref": "secret/data/azdo#secret",
+			},
+			expected: map[string]string{"webhookName": "hook", "headerName": "X-Hub-Signature", "secret": "vault-secret"},
+		},
+		{
+			name:     "custom secret parameters",
+			resource: ResourceServiceEndpointCustom(),
+			expand:   expandServiceEndpointCustom,
+			refs:     customVaultRefs,
+			config: map[string]interface{}{
+				"type":                     "in-house",
+				"url":                      "https://example.com",
+				"authorization_parameters": map[string]interface{}{"username": "user"},
+				"secret_authorization_parameters_vault_ref": map[string]interface{}{"password": "secret/data/azdo#secret"},
+			},
+			expected: map[string]string{"username": "user", "password": "vault-secret"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.config["project_id"] = testProjectID.String()
+			tt.config["service_endpoint_name"] = "endpoint"
+			d := schema.TestResourceDataRaw(t, tt.resource.Schema, tt.config)
+
+			serviceEndpoint, _, err := tt.expand(d)
+			require.NoError(t, err)
+			require.NoError(t, resolveVaultSecrets(clients, serviceEndpoint, tt.refs(d)))
+			require.Equal(t, tt.expected, *serviceEndpoint.Authorization.Parameters)
+
+			err = resolveVaultSecrets(&client.AggregatedClient{Ctx: context.Background()}, serviceEndpoint, tt.refs(d))
+			require.EqualError(t, err, "the authorization parameters of the service endpoint are read from Vault, but the provider has no vault block")
+		})
+	}
+}
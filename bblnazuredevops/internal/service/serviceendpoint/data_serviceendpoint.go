@@ -0,0 +1,177 @@
+package serviceendpoint
+
+import (
+	"fmt"
+
+	"github.com/babylonhealth/terraform-provider-bblnazuredevops/bblnazuredevops/internal/client"
+	"github.com/babylonhealth/terraform-provider-bblnazuredevops/bblnazuredevops/internal/utils/converter"
+	"github.com/babylonhealth/terraform-provider-bblnazuredevops/bblnazuredevops/internal/utils/tfhelper"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+	"github.com/microsoft/azure-devops-go-api/azuredevops/v6/serviceendpoint"
+)
+
+// DataServiceEndpoint schema and implementation for the lookup of a service endpoint by name. The secrets of
+// the service endpoint are never read.
+func DataServiceEndpoint() *schema.Resource {
+	s := dataServiceEndpointAttributes()
+	delete(s, "id")
+	s["project_id"] = tfhelper.DataSourceProjectIDSchema()
+	s["service_endpoint_name"] = &schema.Schema{
+		Type:         schema.TypeString,
+		Required:     true,
+		ValidateFunc: validation.StringIsNotWhiteSpace,
+		Description:  "The name of the service endpoint.",
+	}
+	s["type"] = &schema.Schema{
+		Type:         schema.TypeString,
+		Optional:     true,
+		Computed:     true,
+		ValidateFunc: validation.StringIsNotWhiteSpace,
+		Description:  fmt.Sprintf("The type of the service endpoint, e.g. %s or %s.", BABYLON_VAULT_SERVICE_CONNECTION_TYPE, BABYLON_AWS_IAM_SERVICE_CONNECTION_TYPE),
+	}
+	s["owner"] = &schema.Schema{
+		Type:         schema.TypeString,
+		Optional:     true,
+		Computed:     true,
+		ValidateFunc: validation.StringIsNotWhiteSpace,
+		Description:  "The owner of the service endpoint, e.g. library or agentcloud.",
+	}
+
+	return &schema.Resource{
+		Read:   dataServiceEndpointRead,
+		Schema: s,
+	}
+}
+
+func dataServiceEndpointRead(d *schema.ResourceData, m interface{}) error {
+	clients := m.(*client.AggregatedClient)
+
+	projectID, err := tfhelper.ResolveProjectID(d, m)
+	if err != nil {
+		return err
+	}
+	name := d.Get("service_endpoint_name").(string)
+
+	serviceEndpoints, err := clients.ServiceEndpointClient.GetServiceEndpointsByNames(
+		clients.Ctx,
+		serviceendpoint.GetServiceEndpointsByNamesArgs{
+			Project:       converter.String(projectID),
+			EndpointNames: &[]string{name},
+			Type:          optionalString(d, "type"),
+			Owner:         optionalString(d, "owner"),
+		})
+	if err != nil {
+		return fmt.Errorf("Error looking up service endpoint %s in project %s: %+v", name, projectID, err)
+	}
+	if serviceEndpoints == nil || len(*serviceEndpoints) == 0 {
+		return fmt.Errorf("No service endpoint named %s found in project %s", name, projectID)
+	}
+	if len(*serviceEndpoints) > 1 {
+		return fmt.Errorf("Found %d service endpoints named %s in project %s, set type or owner to tell them apart", len(*serviceEndpoints), name, projectID)
+	}
+
+	serviceEndpoint := (*serviceEndpoints)[0]
+	d.SetId(serviceEndpoint.Id.String())
+	for key, value := range flattenServiceEndpointDetails(&serviceEndpoint) {
+		if key != "id" {
+			d.Set(key, value)
+		}
+	}
+	return nil
+}
+
+// dataServiceEndpointAttributes returns the computed attributes describing a service endpoint in the data sources
+func dataServiceEndpointAttributes() map[string]*schema.Schema {
+	return map[string]*schema.Schema{
+		"id": {
+			Type:        schema.TypeString,
+			Computed:    true,
+			Description: "The ID of the service endpoint.",
+		},
+		"service_endpoint_name": {
+			Type:        schema.TypeString,
+			Computed:    true,
+			Description: "The name of the service endpoint.",
+		},
+		"type": {
+			Type:        schema.TypeString,
+			Computed:    true,
+			Description: "The type of the service endpoint.",
+		},
+		"owner": {
+			Type:        schema.TypeString,
+			Computed:    true,
+			Description: "The owner of the service endpoint.",
+		},
+		"url": {
+			Type:        schema.TypeString,
+			Computed:    true,
+			Description: "The URL of the service the service endpoint connects to.",
+		},
+		"description": {
+			Type:        schema.TypeString,
+			Computed:    true,
+			Description: "The description of the service endpoint.",
+		},
+		"data": {
+			Type:     schema.TypeMap,
+			Computed: true,
+			Elem: &schema.Schema{
+				Type: schema.TypeString,
+			},
+			Description: "The data of the service endpoint. The parameters of its authorization, which hold its secrets, are not read.",
+		},
+		"authorization_scheme": {
+			Type:        schema.TypeString,
+			Computed:    true,
+			Description: "The scheme of the authorization of the service endpoint.",
+		},
+		"is_ready": {
+			Type:        schema.TypeBool,
+			Computed:    true,
+			Description: "Whether the service endpoint is ready to be used.",
+		},
+		"is_shared": {
+			Type:        schema.TypeBool,
+			Computed:    true,
+			Description: "Whether the service endpoint is shared with other projects.",
+		},
+	}
+}
+
+// flattenServiceEndpointDetails returns the attributes of dataServiceEndpointAttributes. The parameters of the
+// authorization are left out, as they hold the secrets of the service endpoint.
+func flattenServiceEndpointDetails(serviceEndpoint *serviceendpoint.ServiceEndpoint) map[string]interface{} {
+	details := map[string]interface{}{
+		"id":                    serviceEndpoint.Id.String(),
+		"service_endpoint_name": converter.ToString(serviceEndpoint.Name, ""),
+		"type":                  converter.ToString(serviceEndpoint.Type, ""),
+		"owner":                 converter.ToString(serviceEndpoint.Owner, ""),
+		"url":                   converter.ToString(serviceEndpoint.Url, ""),
+		"description":           converter.ToString(serviceEndpoint.Description, ""),
+		"data":                  map[string]interface{}{},
+		"authorization_scheme":  "",
+		"is_ready":              converter.ToBool(serviceEndpoint.IsReady, false),
+		"is_shared":             converter.ToBool(serviceEndpoint.IsShared, false),
+	}
+	if serviceEndpoint.Data != nil {
+		data := map[string]interface{}{}
+		for key, value := range *serviceEndpoint.Data {
+			data[key] = value
+		}
+		details["data"] = data
+	}
+	if serviceEndpoint.Authorization != nil {
+		details["authorization_scheme"] = converter.ToString(serviceEndpoint.Authorization.Scheme, "")
+	}
+	return details
+}
+
+// optionalString returns the value of the attribute, nil when it is not set
+func optionalString(d *schema.ResourceData, key string) *string {
+	if value, ok := d.GetOk(key); ok {
+		return converter.String(value.(string))
+	}
+	return nil
+}
@@ -0,0 +1,145 @@
+package serviceendpoint
+
+import (
+	"context"
+	"testing"
+
+	"github.com/babylonhealth/terraform-provider-bblnazuredevops/azdosdkmocks"
+	"github.com/babylonhealth/terraform-provider-bblnazuredevops/bblnazuredevops/internal/client"
+	"github.com/babylonhealth/terraform-provider-bblnazuredevops/bblnazuredevops/internal/utils/converter"
+	"github.com/golang/mock/gomock"
+	"github.com/google/uuid"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/microsoft/azure-devops-go-api/azuredevops/v6/serviceendpoint"
+	"github.com/stretchr/testify/require"
+)
+
+var testVaultEndpointID = uuid.MustParse("627166ad-752b-47f7-a115-7bdcb385931e")
+
+func newTestVaultEndpoint(id uuid.UUID, name string) serviceendpoint.ServiceEndpoint {
+	return serviceendpoint.ServiceEndpoint{
+		Id:          &id,
+		Name:        converter.String(name),
+		Type:        converter.String(BABYLON_VAULT_SERVICE_CONNECTION_TYPE),
+		Owner:       converter.String("library"),
+		Url:         converter.String("https://vault.example.com"),
+		Description: converter.String("Managed by Terraform"),
+		Data:        &map[string]string{"namespace": "team"},
+		Authorization: &serviceendpoint.EndpointAuthorization{
+			Scheme:     converter.String("UsernamePassword"),
+			Parameters: &map[string]string{"password": "secret"},
+		},
+		IsReady:  converter.Bool(true),
+		IsShared: converter.Bool(false),
+	}
+}
+
+func TestDataServiceEndpoint_Read(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	serviceEndpointClient := azdosdkmocks.NewMockServiceendpointClient(ctrl)
+	clients := &client.AggregatedClient{ServiceEndpointClient: serviceEndpointClient, Ctx: context.Background()}
+
+	serviceEndpointClient.EXPECT().
+		GetServiceEndpointsByNames(clients.Ctx, serviceendpoint.GetServiceEndpointsByNamesArgs{
+			Project:       converter.String(testProjectID.String()),
+			EndpointNames: &[]string{"vault"},
+			Type:          converter.String(BABYLON_VAULT_SERVICE_CONNECTION_TYPE),
+		}).
+		Return(&[]serviceendpoint.ServiceEndpoint{newTestVaultEndpoint(testVaultEndpointID, "vault")}, nil).
+		Times(1)
+
+	d := schema.TestResourceDataRaw(t, DataServiceEndpoint().Schema, map[string]interface{}{
+		"project_id":            testProjectID.String(),
+		"service_endpoint_name": "vault",
+		"type":                  BABYLON_VAULT_SERVICE_CONNECTION_TYPE,
+	})
+	require.NoError(t, dataServiceEndpointRead(d, clients))
+
+	require.Equal(t, testVaultEndpointID.String(), d.Id())
+	require.Equal(t, "library", d.Get("owner"))
+	require.Equal(t, "https://vault.example.com", d.Get("url"))
+	require.Equal(t, map[string]interface{}{"namespace": "team"}, d.Get("data"))
+	require.Equal(t, "UsernamePassword", d.Get("authorization_scheme"))
+	require.True(t, d.Get("is_ready").(bool))
+	for _, value := range d.State().Attributes {
+		require.NotEqual(t, "secret", value, "the secrets of the service endpoint must not be stored")
+	}
+}
+
+func TestDataServiceEndpoint_Read_NotFound(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	serviceEndpointClient := azdosdkmocks.NewMockServiceendpointClient(ctrl)
+	clients := &client.AggregatedClient{ServiceEndpointClient: serviceEndpointClient, Ctx: context.Background()}
+
+	serviceEndpointClient.EXPECT().
+		GetServiceEndpointsByNames(clients.Ctx, gomock.Any()).
+		Return(&[]serviceendpoint.ServiceEndpoint{}, nil).
+		Times(1)
+
+	d := schema.TestResourceDataRaw(t, DataServiceEndpoint().Schema, map[string]interface{}{
+		"project_id":            testProjectID.String(),
+		"service_endpoint_name": "vault",
+	})
+	require.EqualError(t, dataServiceEndpointRead(d, clients), "No service endpoint named vault found in project "+testProjectID.String())
+}
+
+func TestDataServiceEndpoints_Read(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	serviceEndpointClient := azdosdkmocks.NewMockServiceendpointClient(ctrl)
+	clients := &client.AggregatedClient{ServiceEndpointClient: serviceEndpointClient, Ctx: context.Background()}
+
+	otherEndpointID := uuid.New()
+	serviceEndpointClient.EXPECT().
+		GetServiceEndpoints(clients.Ctx, serviceendpoint.GetServiceEndpointsArgs{
+			Project: converter.String(testProjectID.String()),
+			Type:    converter.String(BABYLON_VAULT_SERVICE_CONNECTION_TYPE),
+		}).
+		Return(&[]serviceendpoint.ServiceEndpoint{
+			newTestVaultEndpoint(otherEndpointID, "vault-prod"),
+			newTestVaultEndpoint(testVaultEndpointID, "vault-dev"),
+		}, nil).
+		Times(1)
+
+	d := schema.TestResourceDataRaw(t, DataServiceEndpoints().Schema, map[string]interface{}{
+		"project_id": testProjectID.String(),
+		"type":       BABYLON_VAULT_SERVICE_CONNECTION_TYPE,
+	})
+	require.NoError(t, dataServiceEndpointsRead(d, clients))
+
+	serviceEndpoints := d.Get("service_endpoints").([]interface{})
+	require.Len(t, serviceEndpoints, 2)
+	require.Equal(t, testVaultEndpointID.String(), serviceEndpoints[0].(map[string]interface{})["id"])
+	require.Equal(t, "vault-dev", serviceEndpoints[0].(map[string]interface{})["service_endpoint_name"])
+	require.Equal(t, otherEndpointID.String(), serviceEndpoints[1].(map[string]interface{})["id"])
+}
+
+func TestDataServiceEndpoints_Read_ByNames(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	serviceEndpointClient := azdosdkmocks.NewMockServiceendpointClient(ctrl)
+	clients := &client.AggregatedClient{ServiceEndpointClient: serviceEndpointClient, Ctx: context.Background()}
+
+	serviceEndpointClient.EXPECT().
+		GetServiceEndpointsByNames(clients.Ctx, serviceendpoint.GetServiceEndpointsByNamesArgs{
+			Project:       converter.String(testProjectID.String()),
+			EndpointNames: &[]string{"vault-dev", "vault-prod"},
+			Owner:         converter.String("library"),
+		}).
+		Return(&[]serviceendpoint.ServiceEndpoint{newTestVaultEndpoint(testVaultEndpointID, "vault-dev")}, nil).
+		Times(1)
+
+	d := schema.TestResourceDataRaw(t, DataServiceEndpoints().Schema, map[string]interface{}{
+		"project_id":             testProjectID.String(),
+		"service_endpoint_names": []interface{}{"vault-prod", "vault-dev"},
+		"owner":                  "library",
+	})
+	require.NoError(t, dataServiceEndpointsRead(d, clients))
+	require.Len(t, d.Get("service_endpoints").([]interface{}), 1)
+}
@@ -0,0 +1,306 @@
+package serviceendpoint
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/babylonhealth/terraform-provider-bblnazuredevops/bblnazuredevops/internal/client"
+	"github.com/babylonhealth/terraform-provider-bblnazuredevops/bblnazuredevops/internal/utils/cache"
+	"github.com/babylonhealth/terraform-provider-bblnazuredevops/bblnazuredevops/internal/utils/converter"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+	"github.com/microsoft/azure-devops-go-api/azuredevops/v6/forminput"
+	"github.com/microsoft/azure-devops-go-api/azuredevops/v6/serviceendpoint"
+)
+
+// DataServiceEndpointTypes schema and implementation for the lookup of the service endpoint types of the
+// organization, including the types contributed by extensions, with their authorization schemes and inputs
+func DataServiceEndpointTypes() *schema.Resource {
+	return &schema.Resource{
+		Read: dataServiceEndpointTypesRead,
+		Schema: map[string]*schema.Schema{
+			"name": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				ValidateFunc: validation.StringIsNotWhiteSpace,
+				Description:  "Only returns the service endpoint type with this name. All types are returned when it is not set.",
+			},
+			"types": {
+				Type:     schema.TypeList,
+				Computed: true,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"name": {
+							Type:        schema.TypeString,
+							Computed:    true,
+							Description: "The name of the service endpoint type, the type of its service endpoints.",
+						},
+						"display_name": {
+							Type:        schema.TypeString,
+							Computed:    true,
+							Description: "The display name of the service endpoint type.",
+						},
+						"description": {
+							Type:        schema.TypeString,
+							Computed:    true,
+							Description: "The description of the service endpoint type.",
+						},
+						"inputs": {
+							Type:     schema.TypeList,
+							Computed: true,
+							Elem: &schema.Resource{
+								Schema: dataServiceEndpointInputAttributes(),
+							},
+							Description: "The inputs of the service endpoint type, set in the data of its service endpoints.",
+						},
+						"authentication_schemes": {
+							Type:     schema.TypeList,
+							Computed: true,
+							Elem: &schema.Resource{
+								Schema: map[string]*schema.Schema{
+									"scheme": {
+										Type:        schema.TypeString,
+										Computed:    true,
+										Description: "The authorization scheme.",
+									},
+									"display_name": {
+										Type:        schema.TypeString,
+										Computed:    true,
+										Description: "The display name of the authorization scheme.",
+									},
+									"inputs": {
+										Type:     schema.TypeList,
+										Computed: true,
+										Elem: &schema.Resource{
+											Schema: dataServiceEndpointInputAttributes(),
+										},
+										Description: "The inputs of the authorization scheme, set in the authorization parameters of the service endpoints.",
+									},
+								},
+							},
+							Description: "The authorization schemes supported by the service endpoint type.",
+						},
+					},
+				},
+				Description: "The service endpoint types, sorted by name.",
+			},
+		},
+	}
+}
+
+func dataServiceEndpointTypesRead(d *schema.ResourceData, m interface{}) error {
+	clients := m.(*client.AggregatedClient)
+
+	name := optionalString(d, "name")
+	endpointTypes, err := clients.ServiceEndpointClient.GetServiceEndpointTypes(clients.Ctx, serviceendpoint.GetServiceEndpointTypesArgs{
+		Type: name,
+	})
+	if err != nil {
+		return fmt.Errorf("Error looking up service endpoint types: %+v", err)
+	}
+
+	results := []interface{}{}
+	if endpointTypes != nil {
+		sort.SliceStable(*endpointTypes, func(i, j int) bool {
+			return converter.ToString((*endpointTypes)[i].Name, "") < converter.ToString((*endpointTypes)[j].Name, "")
+		})
+		for i := range *endpointTypes {
+			results = append(results, flattenServiceEndpointType(&(*endpointTypes)[i]))
+		}
+	}
+
+	d.SetId("serviceendpointtypes/" + converter.ToString(name, ""))
+	return d.Set("types", results)
+}
+
+// dataServiceEndpointInputAttributes returns the computed attributes describing an input of a service endpoint type
+func dataServiceEndpointInputAttributes() map[string]*schema.Schema {
+	return map[string]*schema.Schema{
+		"id": {
+			Type:        schema.TypeString,
+			Computed:    true,
+			Description: "The ID of the input, its key in the data or the authorization parameters.",
+		},
+		"name": {
+			Type:        schema.TypeString,
+			Computed:    true,
+			Description: "The display name of the input.",
+		},
+		"description": {
+			Type:        schema.TypeString,
+			Computed:    true,
+			Description: "The description of the input.",
+		},
+		"type": {
+			Type:        schema.TypeString,
+			Computed:    true,
+			Description: "The type of the value of the input.",
+		},
+		"is_required": {
+			Type:        schema.TypeBool,
+			Computed:    true,
+			Description: "Whether the input is required.",
+		},
+		"is_confidential": {
+			Type:        schema.TypeBool,
+			Computed:    true,
+			Description: "Whether the value of the input is confidential.",
+		},
+		"default_value": {
+			Type:        schema.TypeString,
+			Computed:    true,
+			Description: "The default value of the input.",
+		},
+	}
+}
+
+func flattenServiceEndpointType(endpointType *serviceendpoint.ServiceEndpointType) map[string]interface{} {
+	schemes := []interface{}{}
+	if endpointType.AuthenticationSchemes != nil {
+		for _, scheme := range *endpointType.AuthenticationSchemes {
+			schemes = append(schemes, map[string]interface{}{
+				"scheme":       converter.ToString(scheme.Scheme, ""),
+				"display_name": converter.ToString(scheme.DisplayName, ""),
+				"inputs":       flattenServiceEndpointInputs(scheme.InputDescriptors),
+			})
+		}
+	}
+
+	return map[string]interface{}{
+		"name":                   converter.ToString(endpointType.Name, ""),
+		"display_name":           converter.ToString(endpointType.DisplayName, ""),
+		"description":            converter.ToString(endpointType.Description, ""),
+		"inputs":                 flattenServiceEndpointInputs(endpointType.InputDescriptors),
+		"authentication_schemes": schemes,
+	}
+}
+
+func flattenServiceEndpointInputs(inputDescriptors *[]forminput.InputDescriptor) []interface{} {
+	inputs := []interface{}{}
+	if inputDescriptors == nil {
+		return inputs
+	}
+	for _, input := range *inputDescriptors {
+		defaultValue := ""
+		if input.Values != nil {
+			defaultValue = converter.ToString(input.Values.DefaultValue, "")
+		}
+		inputs = append(inputs, map[string]interface{}{
+			"id":              converter.ToString(input.Id, ""),
+			"name":            converter.ToString(input.Name, ""),
+			"description":     converter.ToString(input.Description, ""),
+			"type":            converter.ToString(input.Type, ""),
+			"is_required":     isRequiredInput(&input),
+			"is_confidential": converter.ToBool(input.IsConfidential, false),
+			"default_value":   defaultValue,
+		})
+	}
+	return inputs
+}
+
+// isRequiredInput returns whether the value of the input must be set
+func isRequiredInput(input *forminput.InputDescriptor) bool {
+	return input.Validation != nil && converter.ToBool(input.Validation.IsRequired, false)
+}
+
+// getServiceEndpointType returns the service endpoint type with the given name, nil when Azure DevOps does not know
+// it. The types are cached for all resources of the provider, they only change when extensions are installed.
+func getServiceEndpointType(clients *client.AggregatedClient, name string) (*serviceendpoint.ServiceEndpointType, error) {
+	endpointType, err := clients.Cache.GetOrLoad(clients.Ctx, cache.Key("serviceendpointtypes", strings.ToLower(name)), func() (interface{}, error) {
+		endpointTypes, err := clients.ServiceEndpointClient.GetServiceEndpointTypes(clients.Ctx, serviceendpoint.GetServiceEndpointTypesArgs{
+			Type: converter.String(name),
+		})
+		if err != nil {
+			return nil, err
+		}
+		if endpointTypes != nil {
+			for i := range *endpointTypes {
+				if strings.EqualFold(converter.ToString((*endpointTypes)[i].Name, ""), name) {
+					return &(*endpointTypes)[i], nil
+				}
+			}
+		}
+		return (*serviceendpoint.ServiceEndpointType)(nil), nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Error looking up service endpoint type %s: %+v", name, err)
+	}
+	return endpointType.(*serviceendpoint.ServiceEndpointType), nil
+}
+
+// checkServiceEndpointTypeInputs checks the data and the authorization parameters of a service endpoint against the
+// inputs of the service endpoint type with the given name, which Azure DevOps must know
+func checkServiceEndpointTypeInputs(clients *client.AggregatedClient, name string, scheme string, data map[string]string, parameters map[string]string) error {
+	endpointType, err := getServiceEndpointType(clients, name)
+	if err != nil {
+		return err
+	}
+	if endpointType == nil {
+		return fmt.Errorf("the service endpoint type %s is not known by Azure DevOps, is the extension contributing it installed?", name)
+	}
+	return validateServiceEndpointInputs(endpointType, scheme, data, parameters)
+}
+
+// validateServiceEndpointInputs checks the data and the authorization parameters of a service endpoint against the
+// inputs of its type. Unknown data keys and missing required inputs are rejected, as Azure DevOps only rejects
+// them with an opaque error.
+func validateServiceEndpointInputs(endpointType *serviceendpoint.ServiceEndpointType, scheme string, data map[string]string, parameters map[string]string) error {
+	name := converter.ToString(endpointType.Name, "")
+
+	inputs := map[string]*forminput.InputDescriptor{}
+	inputIDs := []string{}
+	if endpointType.InputDescriptors != nil {
+		for i, input := range *endpointType.InputDescriptors {
+			inputs[converter.ToString(input.Id, "")] = &(*endpointType.InputDescriptors)[i]
+			inputIDs = append(inputIDs, converter.ToString(input.Id, ""))
+		}
+	}
+	sort.Strings(inputIDs)
+
+	keys := []string{}
+	for key := range data {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if _, ok := inputs[key]; !ok {
+			return fmt.Errorf("the data key %s is not an input of the service endpoint type %s, expected one of [%s]", key, name, strings.Join(inputIDs, ", "))
+		}
+	}
+	if input := missingRequiredInput(endpointType.InputDescriptors, data); input != "" {
+		return fmt.Errorf("the input %s, required by the service endpoint type %s, is missing from the data", input, name)
+	}
+
+	if endpointType.AuthenticationSchemes == nil || len(*endpointType.AuthenticationSchemes) == 0 {
+		return nil
+	}
+	schemes := []string{}
+	for _, authenticationScheme := range *endpointType.AuthenticationSchemes {
+		if strings.EqualFold(converter.ToString(authenticationScheme.Scheme, ""), scheme) {
+			if input := missingRequiredInput(authenticationScheme.InputDescriptors, parameters); input != "" {
+				return fmt.Errorf("the input %s, required by the %s authorization scheme, is missing from the authorization parameters", input, scheme)
+			}
+			return nil
+		}
+		schemes = append(schemes, converter.ToString(authenticationScheme.Scheme, ""))
+	}
+	return fmt.Errorf("the authorization scheme %s is not supported by the service endpoint type %s, expected one of [%s]", scheme, name, strings.Join(schemes, ", "))
+}
+
+// missingRequiredInput returns the ID of the first required input without a value or a default value, "" when
+// all required inputs have a value
+func missingRequiredInput(inputDescriptors *[]forminput.InputDescriptor, values map[string]string) string {
+	if inputDescriptors == nil {
+		return ""
+	}
+	for _, input := range *inputDescriptors {
+		if !isRequiredInput(&input) || (input.Values != nil && converter.ToString(input.Values.DefaultValue, "") != "") {
+			continue
+		}
+		if values[converter.ToString(input.Id, "")] == "" {
+			return converter.ToString(input.Id, "")
+		}
+	}
+	return ""
+}
@@ -0,0 +1,189 @@
+package serviceendpoint
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/babylonhealth/terraform-provider-bblnazuredevops/azdosdkmocks"
+	"github.com/babylonhealth/terraform-provider-bblnazuredevops/bblnazuredevops/internal/client"
+	"github.com/babylonhealth/terraform-provider-bblnazuredevops/bblnazuredevops/internal/utils/cache"
+	"github.com/babylonhealth/terraform-provider-bblnazuredevops/bblnazuredevops/internal/utils/converter"
+	"github.com/golang/mock/gomock"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
+	"github.com/microsoft/azure-devops-go-api/azuredevops/v6/forminput"
+	"github.com/microsoft/azure-devops-go-api/azuredevops/v6/serviceendpoint"
+	"github.com/stretchr/testify/require"
+)
+
+const testArtifactsEndpointType = "babylon-service-endpoint-artifacts"
+
+func newTestInput(id string, isRequired bool) forminput.InputDescriptor {
+	return forminput.InputDescriptor{
+		Id:             converter.String(id),
+		Name:           converter.String(id),
+		Type:           converter.String("string"),
+		IsConfidential: converter.Bool(id == "password"),
+		Validation:     &forminput.InputValidation{IsRequired: converter.Bool(isRequired)},
+	}
+}
+
+func newTestEndpointType() serviceendpoint.ServiceEndpointType {
+	return serviceendpoint.ServiceEndpointType{
+		Name:             converter.String(testArtifactsEndpointType),
+		DisplayName:      converter.String("Artifacts"),
+		InputDescriptors: &[]forminput.InputDescriptor{newTestInput("repository", true), newTestInput("path", false)},
+		AuthenticationSchemes: &[]serviceendpoint.ServiceEndpointAuthenticationScheme{
+			{
+				Scheme:           converter.String("UsernamePassword"),
+				DisplayName:      converter.String("Username and password"),
+				InputDescriptors: &[]forminput.InputDescriptor{newTestInput("username", true), newTestInput("password", true)},
+			},
+			{
+				Scheme: converter.String("None"),
+			},
+		},
+	}
+}
+
+// newTestEndpointTypeClients returns a client whose Azure DevOps knows the service endpoint type, which is looked up
+// once
+func newTestEndpointTypeClients(ctrl *gomock.Controller, endpointType serviceendpoint.ServiceEndpointType) *client.AggregatedClient {
+	serviceEndpointClient := azdosdkmocks.NewMockServiceendpointClient(ctrl)
+	clients := &client.AggregatedClient{ServiceEndpointClient: serviceEndpointClient, Cache: cache.New(time.Minute), Ctx: context.Background()}
+	serviceEndpointClient.EXPECT().
+		GetServiceEndpointTypes(clients.Ctx, serviceendpoint.GetServiceEndpointTypesArgs{Type: endpointType.Name}).
+		Return(&[]serviceendpoint.ServiceEndpointType{endpointType}, nil).
+		Times(1)
+	return clients
+}
+
+func TestDataServiceEndpointTypes_Read(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	serviceEndpointClient := azdosdkmocks.NewMockServiceendpointClient(ctrl)
+	clients := &client.AggregatedClient{ServiceEndpointClient: serviceEndpointClient, Ctx: context.Background()}
+
+	serviceEndpointClient.EXPECT().
+		GetServiceEndpointTypes(clients.Ctx, serviceendpoint.GetServiceEndpointTypesArgs{}).
+		Return(&[]serviceendpoint.ServiceEndpointType{
+			newTestEndpointType(),
+			{Name: converter.String("aws")},
+		}, nil).
+		Times(1)
+
+	d := schema.TestResourceDataRaw(t, DataServiceEndpointTypes().Schema, map[string]interface{}{})
+	require.NoError(t, dataServiceEndpointTypesRead(d, clients))
+
+	types := d.Get("types").([]interface{})
+	require.Len(t, types, 2)
+	require.Equal(t, "aws", types[0].(map[string]interface{})["name"])
+
+	artifacts := types[1].(map[string]interface{})
+	require.Equal(t, testArtifactsEndpointType, artifacts["name"])
+	require.Equal(t, "Artifacts", artifacts["display_name"])
+	require.Len(t, artifacts["inputs"], 2)
+	require.Equal(t, true, artifacts["inputs"].([]interface{})[0].(map[string]interface{})["is_required"])
+
+	schemes := artifacts["authentication_schemes"].([]interface{})
+	require.Len(t, schemes, 2)
+	password := schemes[0].(map[string]interface{})["inputs"].([]interface{})[1].(map[string]interface{})
+	require.Equal(t, "password", password["id"])
+	require.Equal(t, true, password["is_confidential"])
+}
+
+func Test_validateServiceEndpointInputs(t *testing.T) {
+	endpointType := newTestEndpointType()
+	tests := []struct {
+		name       string
+		scheme     string
+		data       map[string]string
+		parameters map[string]string
+		wantErr    string
+	}{
+		{
+			name:       "valid",
+			scheme:     "UsernamePassword",
+			data:       map[string]string{"repository": "releases"},
+			parameters: map[string]string{"username": "deployer", "password": "secret"},
+		},
+		{
+			name:   "valid without authorization",
+			scheme: "none",
+			data:   map[string]string{"repository": "releases", "path": "/"},
+		},
+		{
+			name:    "unknown data key",
+			scheme:  "None",
+			data:    map[string]string{"repository": "releases", "repo": "releases"},
+			wantErr: "the data key repo is not an input of the service endpoint type " + testArtifactsEndpointType + ", expected one of [path, repository]",
+		},
+		{
+			name:    "missing data input",
+			scheme:  "None",
+			data:    map[string]string{"path": "/"},
+			wantErr: "the input repository, required by the service endpoint type " + testArtifactsEndpointType + ", is missing from the data",
+		},
+		{
+			name:       "missing authorization input",
+			scheme:     "UsernamePassword",
+			data:       map[string]string{"repository": "releases"},
+			parameters: map[string]string{"username": "deployer"},
+			wantErr:    "the input password, required by the UsernamePassword authorization scheme, is missing from the authorization parameters",
+		},
+		{
+			name:    "unsupported scheme",
+			scheme:  "Token",
+			data:    map[string]string{"repository": "releases"},
+			wantErr: "the authorization scheme Token is not supported by the service endpoint type " + testArtifactsEndpointType + ", expected one of [UsernamePassword, None]",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateServiceEndpointInputs(&endpointType, tt.scheme, tt.data, tt.parameters)
+			if tt.wantErr == "" {
+				require.NoError(t, err)
+			} else {
+				require.EqualError(t, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// The types are looked up once, unknown types are rejected at plan time
+func Test_customizeDiffCustomServiceEndpointType(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	serviceEndpointClient := azdosdkmocks.NewMockServiceendpointClient(ctrl)
+	clients := &client.AggregatedClient{ServiceEndpointClient: serviceEndpointClient, Cache: cache.New(time.Minute), Ctx: context.Background()}
+
+	serviceEndpointClient.EXPECT().
+		GetServiceEndpointTypes(clients.Ctx, serviceendpoint.GetServiceEndpointTypesArgs{Type: converter.String(testArtifactsEndpointType)}).
+		Return(&[]serviceendpoint.ServiceEndpointType{newTestEndpointType()}, nil).
+		Times(1)
+	serviceEndpointClient.EXPECT().
+		GetServiceEndpointTypes(clients.Ctx, serviceendpoint.GetServiceEndpointTypesArgs{Type: converter.String("unknown")}).
+		Return(&[]serviceendpoint.ServiceEndpointType{}, nil).
+		Times(1)
+
+	config := map[string]interface{}{
+		"project_id":            testProjectID.String(),
+		"service_endpoint_name": "artifacts",
+		"type":                  testArtifactsEndpointType,
+		"url":                   "https://artifacts.babylonhealth.com",
+		"data":                  map[string]interface{}{"repository": "releases"},
+	}
+	_, err := ResourceServiceEndpointCustom().Diff(context.Background(), nil, terraform.NewResourceConfigRaw(config), clients)
+	require.NoError(t, err)
+
+	config["data"] = map[string]interface{}{}
+	_, err = ResourceServiceEndpointCustom().Diff(context.Background(), nil, terraform.NewResourceConfigRaw(config), clients)
+	require.EqualError(t, err, "the input repository, required by the service endpoint type "+testArtifactsEndpointType+", is missing from the data")
+
+	config["type"] = "unknown"
+	_, err = ResourceServiceEndpointCustom().Diff(context.Background(), nil, terraform.NewResourceConfigRaw(config), clients)
+	require.EqualError(t, err, "the service endpoint type unknown is not known by Azure DevOps, is the extension contributing it installed?")
+}
@@ -0,0 +1,103 @@
+package serviceendpoint
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/babylonhealth/terraform-provider-bblnazuredevops/bblnazuredevops/internal/client"
+	"github.com/babylonhealth/terraform-provider-bblnazuredevops/bblnazuredevops/internal/utils/converter"
+	"github.com/babylonhealth/terraform-provider-bblnazuredevops/bblnazuredevops/internal/utils/tfhelper"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+	"github.com/microsoft/azure-devops-go-api/azuredevops/v6/serviceendpoint"
+)
+
+// DataServiceEndpoints schema and implementation for the lookup of the service endpoints of a project, by name,
+// type and owner. The secrets of the service endpoints are never read.
+func DataServiceEndpoints() *schema.Resource {
+	return &schema.Resource{
+		Read: dataServiceEndpointsRead,
+		Schema: map[string]*schema.Schema{
+			"project_id": tfhelper.DataSourceProjectIDSchema(),
+			"service_endpoint_names": {
+				Type:     schema.TypeSet,
+				Optional: true,
+				Elem: &schema.Schema{
+					Type:         schema.TypeString,
+					ValidateFunc: validation.StringIsNotWhiteSpace,
+				},
+				Description: "The names of the service endpoints. All service endpoints of the project are returned when it is not set.",
+			},
+			"type": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				ValidateFunc: validation.StringIsNotWhiteSpace,
+				Description:  fmt.Sprintf("Only returns the service endpoints of this type, e.g. %s or %s.", BABYLON_VAULT_SERVICE_CONNECTION_TYPE, BABYLON_AWS_IAM_SERVICE_CONNECTION_TYPE),
+			},
+			"owner": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				ValidateFunc: validation.StringIsNotWhiteSpace,
+				Description:  "Only returns the service endpoints of this owner, e.g. library or agentcloud.",
+			},
+			"service_endpoints": {
+				Type:     schema.TypeList,
+				Computed: true,
+				Elem: &schema.Resource{
+					Schema: dataServiceEndpointAttributes(),
+				},
+				Description: "The service endpoints, sorted by name.",
+			},
+		},
+	}
+}
+
+func dataServiceEndpointsRead(d *schema.ResourceData, m interface{}) error {
+	clients := m.(*client.AggregatedClient)
+
+	projectID, err := tfhelper.ResolveProjectID(d, m)
+	if err != nil {
+		return err
+	}
+	names := tfhelper.ExpandStringSet(d.Get("service_endpoint_names").(*schema.Set))
+	sort.Strings(names)
+	endpointType := optionalString(d, "type")
+	owner := optionalString(d, "owner")
+
+	var serviceEndpoints *[]serviceendpoint.ServiceEndpoint
+	if len(names) > 0 {
+		serviceEndpoints, err = clients.ServiceEndpointClient.GetServiceEndpointsByNames(
+			clients.Ctx,
+			serviceendpoint.GetServiceEndpointsByNamesArgs{
+				Project:       converter.String(projectID),
+				EndpointNames: &names,
+				Type:          endpointType,
+				Owner:         owner,
+			})
+	} else {
+		serviceEndpoints, err = clients.ServiceEndpointClient.GetServiceEndpoints(
+			clients.Ctx,
+			serviceendpoint.GetServiceEndpointsArgs{
+				Project: converter.String(projectID),
+				Type:    endpointType,
+				Owner:   owner,
+			})
+	}
+	if err != nil {
+		return fmt.Errorf("Error looking up service endpoints in project %s: %+v", projectID, err)
+	}
+
+	results := []interface{}{}
+	if serviceEndpoints != nil {
+		sort.SliceStable(*serviceEndpoints, func(i, j int) bool {
+			return converter.ToString((*serviceEndpoints)[i].Name, "") < converter.ToString((*serviceEndpoints)[j].Name, "")
+		})
+		for i := range *serviceEndpoints {
+			results = append(results, flattenServiceEndpointDetails(&(*serviceEndpoints)[i]))
+		}
+	}
+
+	d.SetId(fmt.Sprintf("%s/%s/%s/%s", projectID, converter.ToString(endpointType, ""), converter.ToString(owner, ""), strings.Join(names, ",")))
+	return d.Set("service_endpoints", results)
+}
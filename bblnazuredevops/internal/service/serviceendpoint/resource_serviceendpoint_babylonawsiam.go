@@ -1,34 +1,66 @@
 package serviceendpoint
 
 import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
 	"github.com/babylonhealth/terraform-provider-bblnazuredevops/bblnazuredevops/internal/utils/converter"
 	"github.com/babylonhealth/terraform-provider-bblnazuredevops/bblnazuredevops/internal/utils/tfhelper"
+	"github.com/babylonhealth/terraform-provider-bblnazuredevops/bblnazuredevops/internal/utils/validate"
 	"github.com/google/uuid"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/customdiff"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/structure"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
 	"github.com/microsoft/azure-devops-go-api/azuredevops/v6/serviceendpoint"
 )
 
 const BABYLON_AWS_IAM_SERVICE_CONNECTION_TYPE string = "babylon-service-endpoint-aws-iam"
 const BABYLON_AWS_IAM_DEFAULT_SESSION_NAME string = "azure-pipelines-task"
+const BABYLON_AWS_IAM_DEFAULT_AUDIENCE string = "sts.amazonaws.com"
+
+var awsRegionRegexp = regexp.MustCompile(`^[a-z]{2}(-[a-z]+)+-\d$`)
+
+// babylonAwsIamRegionalRole is a regional_role, the roles are stored as JSON in the regionalRoles data of the
+// service endpoint
+type babylonAwsIamRegionalRole struct {
+	Region          string `json:"region"`
+	RoleArn         string `json:"roleArn"`
+	ExternalID      string `json:"externalId,omitempty"`
+	SessionDuration int    `json:"sessionDuration"`
+}
+
+// babylonAwsIamVaultRefs maps the authorization parameters read from Vault to the secret setting them
+var babylonAwsIamVaultRefs = vaultRefsOf(map[string]string{"password": "password"})
 
 func ResourceServiceEndpointBabylonAwsIam() *schema.Resource {
-	r := genBaseServiceEndpointResource(flattenServiceEndpointBabylonAwsIam, expandServiceEndpointBabylonAwsIam)
+	r := genBaseServiceEndpointResource(BABYLON_AWS_IAM_SERVICE_CONNECTION_TYPE, flattenServiceEndpointBabylonAwsIam, expandServiceEndpointBabylonAwsIam, babylonAwsIamInputs, babylonAwsIamVaultRefs)
 	r.Schema["username"] = &schema.Schema{
-		Type:        schema.TypeString,
-		Required:    true,
-		Description: "AWS Access Key ID of the IAM user",
+		Type:         schema.TypeString,
+		Optional:     true,
+		Description:  "AWS Access Key ID of the IAM user",
+		ExactlyOneOf: []string{"username", "web_identity_federation"},
 	}
 	r.Schema["password"] = &schema.Schema{
 		Type:             schema.TypeString,
-		Required:         true,
+		Optional:         true,
 		Description:      "AWS Secret Access Key of the IAM user",
 		Sensitive:        true,
 		DiffSuppressFunc: tfhelper.DiffFuncSuppressSecretChanged,
+		ExactlyOneOf:     []string{"password", "password_wo", "password_vault_ref", "web_identity_federation"},
+	}
+	for key, value := range tfhelper.GenerateWriteOnlySecretSchema("password") {
+		r.Schema[key] = value
 	}
 	r.Schema["global_role_arn"] = &schema.Schema{
-		Type:        schema.TypeString,
-		Required:    true,
-		Description: "The Amazon Resource Name (ARN) of the role to assume",
+		Type:         schema.TypeString,
+		Required:     true,
+		ValidateFunc: validate.AwsIamRoleArn,
+		Description:  "The Amazon Resource Name (ARN) of the role to assume",
 	}
 	r.Schema["global_sts_session_name"] = &schema.Schema{
 		Type:        schema.TypeString,
@@ -36,36 +68,241 @@ func ResourceServiceEndpointBabylonAwsIam() *schema.Resource {
 		Description: "Session name to be used when assuming the role. The session name should match the one specified in the trust policies of the regional IAM roles.",
 		Default:     BABYLON_AWS_IAM_DEFAULT_SESSION_NAME,
 	}
+	r.Schema["web_identity_federation"] = &schema.Schema{
+		Type:     schema.TypeList,
+		Optional: true,
+		MaxItems: 1,
+		Elem: &schema.Resource{
+			Schema: map[string]*schema.Schema{
+				"audience": {
+					Type:         schema.TypeString,
+					Optional:     true,
+					Default:      BABYLON_AWS_IAM_DEFAULT_AUDIENCE,
+					ValidateFunc: validation.StringIsNotWhiteSpace,
+					Description:  "The audience of the OIDC token of the pipeline, as configured in the IAM OIDC identity provider",
+				},
+				"session_duration": {
+					Type:         schema.TypeInt,
+					Optional:     true,
+					Default:      3600,
+					ValidateFunc: validation.IntBetween(900, 43200),
+					Description:  "The duration, in seconds, of the session of the role to assume",
+				},
+			},
+		},
+		Description: "Assume global_role_arn with the OIDC token of the pipeline instead of the access key of an IAM user",
+	}
+	r.Schema["regional_role"] = &schema.Schema{
+		Type:     schema.TypeList,
+		Optional: true,
+		Elem: &schema.Resource{
+			Schema: map[string]*schema.Schema{
+				"region": {
+					Type:         schema.TypeString,
+					Required:     true,
+					ValidateFunc: validation.StringMatch(awsRegionRegexp, "expected an AWS region like eu-west-2"),
+					Description:  "The AWS region the role is assumed for",
+				},
+				"role_arn": {
+					Type:         schema.TypeString,
+					Required:     true,
+					ValidateFunc: validate.AwsIamRoleArn,
+					Description:  "The Amazon Resource Name (ARN) of the role to assume in the region, with the session of global_role_arn",
+				},
+				"external_id": {
+					Type:         schema.TypeString,
+					Optional:     true,
+					ValidateFunc: validation.StringLenBetween(2, 1224),
+					Description:  "The external ID required by the trust policy of the role",
+				},
+				"session_duration": {
+					Type:         schema.TypeInt,
+					Optional:     true,
+					Default:      3600,
+					ValidateFunc: validation.IntBetween(900, 43200),
+					Description:  "The duration, in seconds, of the session of the role",
+				},
+			},
+		},
+		Description: "The roles to assume in each region, their trust policies must allow global_sts_session_name",
+	}
+	r.Schema["session_policy"] = &schema.Schema{
+		Type:             schema.TypeString,
+		Optional:         true,
+		ValidateFunc:     validation.StringIsJSON,
+		DiffSuppressFunc: structure.SuppressJsonDiff,
+		Description:      "An inline IAM policy, as JSON, further restricting the permissions of the sessions of the roles",
+	}
+	vaultRefKey, vaultRefSchema := tfhelper.GenerateVaultRefSchema("password")
+	r.Schema[vaultRefKey] = vaultRefSchema
 	secretHashKey, secretHashSchema := tfhelper.GenerateSecreteMemoSchema("password")
 	r.Schema[secretHashKey] = secretHashSchema
+
+	r.SchemaVersion = 1
+	r.StateUpgraders = []schema.StateUpgrader{tfhelper.SecretMemoStateUpgrader(r, "password")}
+	r.CustomizeDiff = customdiff.All(r.CustomizeDiff, customizeDiffBabylonAwsIamRegionalRoles)
 	return r
 }
 
+// customizeDiffBabylonAwsIamRegionalRoles checks that each region has at most one regional_role
+func customizeDiffBabylonAwsIamRegionalRoles(_ context.Context, d *schema.ResourceDiff, _ interface{}) error {
+	regions := map[string]bool{}
+	for i := range d.Get("regional_role").([]interface{}) {
+		key := fmt.Sprintf("regional_role.%d.region", i)
+		if !d.NewValueKnown(key) {
+			continue
+		}
+		region := d.Get(key).(string)
+		if regions[region] {
+			return fmt.Errorf("the region %s has more than one regional_role", region)
+		}
+		regions[region] = true
+	}
+	return nil
+}
+
+// babylonAwsIamInputs returns the authorization scheme, the data and the authorization parameters planned for the
+// service endpoint, as expandServiceEndpointBabylonAwsIam sends them
+func babylonAwsIamInputs(d *schema.ResourceDiff) (string, map[string]string, map[string]string, bool) {
+	if !d.NewValueKnown("web_identity_federation") {
+		return "", nil, nil, false
+	}
+
+	scheme := "UsernamePassword"
+	parameters := map[string]string{
+		"globalRoleArn":        plannedInput(d, "global_role_arn"),
+		"globalStsSessionName": plannedInput(d, "global_sts_session_name"),
+	}
+	if len(d.Get("web_identity_federation").([]interface{})) > 0 {
+		scheme = "WorkloadIdentityFederation"
+		parameters["audience"] = plannedInput(d, "web_identity_federation.0.audience")
+		parameters["sessionDuration"] = strconv.Itoa(d.Get("web_identity_federation.0.session_duration").(int))
+	} else {
+		parameters["username"] = plannedInput(d, "username")
+		parameters["password"] = plannedSecretInput(d, "password", "password_version", "password_vault_ref")
+	}
+
+	data := map[string]string{}
+	if !d.NewValueKnown("regional_role") || len(d.Get("regional_role").([]interface{})) > 0 {
+		data["regionalRoles"] = unknownInputValue
+	}
+	if sessionPolicy := plannedInput(d, "session_policy"); sessionPolicy != "" {
+		data["sessionPolicy"] = sessionPolicy
+	}
+	return scheme, data, parameters, true
+}
+
 // Convert internal Terraform data structure to an AzDO data structure
 func expandServiceEndpointBabylonAwsIam(d *schema.ResourceData) (*serviceendpoint.ServiceEndpoint, *uuid.UUID, error) {
 	serviceEndpoint, projectID := doBaseExpansion(d)
-	serviceEndpoint.Authorization = &serviceendpoint.EndpointAuthorization{
-		Parameters: &map[string]string{
-			"username":             d.Get("username").(string),
-			"password":             d.Get("password").(string),
-			"globalRoleArn":        d.Get("global_role_arn").(string),
-			"globalStsSessionName": d.Get("global_sts_session_name").(string),
-		},
-		Scheme: converter.String("UsernamePassword"),
+	if len(d.Get("web_identity_federation").([]interface{})) > 0 {
+		serviceEndpoint.Authorization = &serviceendpoint.EndpointAuthorization{
+			Parameters: &map[string]string{
+				"globalRoleArn":        d.Get("global_role_arn").(string),
+				"globalStsSessionName": d.Get("global_sts_session_name").(string),
+				"audience":             d.Get("web_identity_federation.0.audience").(string),
+				"sessionDuration":      strconv.Itoa(d.Get("web_identity_federation.0.session_duration").(int)),
+			},
+			Scheme: converter.String("WorkloadIdentityFederation"),
+		}
+	} else {
+		serviceEndpoint.Authorization = &serviceendpoint.EndpointAuthorization{
+			Parameters: &map[string]string{
+				"username":             d.Get("username").(string),
+				"password":             tfhelper.GetSecret(d, "password"),
+				"globalRoleArn":        d.Get("global_role_arn").(string),
+				"globalStsSessionName": d.Get("global_sts_session_name").(string),
+			},
+			Scheme: converter.String("UsernamePassword"),
+		}
+	}
+	data, err := expandBabylonAwsIamData(d)
+	if err != nil {
+		return nil, nil, err
 	}
-	serviceEndpoint.Data = &map[string]string{}
+	serviceEndpoint.Data = data
 	serviceEndpoint.Type = converter.String(BABYLON_AWS_IAM_SERVICE_CONNECTION_TYPE)
 	serviceEndpoint.Url = converter.String("https://aws.amazon.com/")
 	return serviceEndpoint, projectID, nil
 }
 
 // Convert AzDO data structure to internal Terraform data structure
-func flattenServiceEndpointBabylonAwsIam(d *schema.ResourceData, serviceEndpoint *serviceendpoint.ServiceEndpoint, projectID *uuid.UUID) {
+func flattenServiceEndpointBabylonAwsIam(d *schema.ResourceData, serviceEndpoint *serviceendpoint.ServiceEndpoint, projectID *uuid.UUID) error {
 	doBaseFlattening(d, serviceEndpoint, projectID)
 	tfhelper.HelpFlattenSecret(d, "password")
 
-	d.Set("username", (*serviceEndpoint.Authorization.Parameters)["username"])
-	d.Set("password", (*serviceEndpoint.Authorization.Parameters)["password"])
-	d.Set("global_role_arn", (*serviceEndpoint.Authorization.Parameters)["globalRoleArn"])
-	d.Set("global_sts_session_name", (*serviceEndpoint.Authorization.Parameters)["globalStsSessionName"])
+	parameters := *serviceEndpoint.Authorization.Parameters
+	if strings.EqualFold(converter.ToString(serviceEndpoint.Authorization.Scheme, ""), "WorkloadIdentityFederation") {
+		sessionDuration, _ := strconv.Atoi(parameters["sessionDuration"])
+		d.Set("web_identity_federation", []interface{}{
+			map[string]interface{}{
+				"audience":         parameters["audience"],
+				"session_duration": sessionDuration,
+			},
+		})
+		d.Set("username", "")
+	} else {
+		d.Set("web_identity_federation", []interface{}{})
+		d.Set("username", parameters["username"])
+		if !tfhelper.IsSecretKeptOutOfState(d, "password") {
+			d.Set("password", parameters["password"])
+		}
+	}
+	d.Set("global_role_arn", parameters["globalRoleArn"])
+	d.Set("global_sts_session_name", parameters["globalStsSessionName"])
+	return flattenBabylonAwsIamData(d, serviceEndpoint.Data)
+}
+
+// expandBabylonAwsIamData returns the data of the service endpoint holding the regional roles and the session policy
+func expandBabylonAwsIamData(d *schema.ResourceData) (*map[string]string, error) {
+	data := map[string]string{}
+
+	regionalRoles := []babylonAwsIamRegionalRole{}
+	for _, raw := range d.Get("regional_role").([]interface{}) {
+		regionalRole := raw.(map[string]interface{})
+		regionalRoles = append(regionalRoles, babylonAwsIamRegionalRole{
+			Region:          regionalRole["region"].(string),
+			RoleArn:         regionalRole["role_arn"].(string),
+			ExternalID:      regionalRole["external_id"].(string),
+			SessionDuration: regionalRole["session_duration"].(int),
+		})
+	}
+	if len(regionalRoles) > 0 {
+		regionalRolesJson, err := json.Marshal(regionalRoles)
+		if err != nil {
+			return nil, fmt.Errorf("Error encoding regional_role: %+v", err)
+		}
+		data["regionalRoles"] = string(regionalRolesJson)
+	}
+
+	if sessionPolicy := d.Get("session_policy").(string); sessionPolicy != "" {
+		data["sessionPolicy"] = sessionPolicy
+	}
+	return &data, nil
+}
+
+// flattenBabylonAwsIamData sets regional_role and session_policy from the data of the service endpoint
+func flattenBabylonAwsIamData(d *schema.ResourceData, data *map[string]string) error {
+	if data == nil {
+		data = &map[string]string{}
+	}
+
+	regionalRoles := []interface{}{}
+	if regionalRolesJson := (*data)["regionalRoles"]; regionalRolesJson != "" {
+		var decoded []babylonAwsIamRegionalRole
+		if err := json.Unmarshal([]byte(regionalRolesJson), &decoded); err != nil {
+			return fmt.Errorf("Error decoding the regional roles of the service endpoint: %+v", err)
+		}
+		for _, regionalRole := range decoded {
+			regionalRoles = append(regionalRoles, map[string]interface{}{
+				"region":           regionalRole.Region,
+				"role_arn":         regionalRole.RoleArn,
+				"external_id":      regionalRole.ExternalID,
+				"session_duration": regionalRole.SessionDuration,
+			})
+		}
+	}
+	d.Set("regional_role", regionalRoles)
+	d.Set("session_policy", (*data)["sessionPolicy"])
+	return nil
 }
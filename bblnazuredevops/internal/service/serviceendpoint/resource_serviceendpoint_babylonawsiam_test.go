@@ -1,17 +1,24 @@
 package serviceendpoint
 
 import (
+	"context"
 	"fmt"
-	"github.com/google/uuid"
 	"testing"
 
 	"github.com/babylonhealth/terraform-provider-bblnazuredevops/bblnazuredevops/internal/utils/converter"
 	"github.com/babylonhealth/terraform-provider-bblnazuredevops/bblnazuredevops/internal/utils/tfhelper"
+	"github.com/babylonhealth/terraform-provider-bblnazuredevops/bblnazuredevops/internal/utils/validate"
 	"github.com/go-test/deep"
+	"github.com/golang/mock/gomock"
+	"github.com/google/uuid"
 	"github.com/hashicorp/go-multierror"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/structure"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
+	"github.com/microsoft/azure-devops-go-api/azuredevops/v6/forminput"
 	"github.com/microsoft/azure-devops-go-api/azuredevops/v6/serviceendpoint"
+	"github.com/stretchr/testify/require"
 )
 
 func TestResourceServiceEndpointBabylonAwsIam(t *testing.T) {
@@ -23,21 +30,24 @@ func TestResourceServiceEndpointBabylonAwsIam(t *testing.T) {
 			name: "test",
 			expectedSchema: map[string]*schema.Schema{
 				"username": {
-					Type:        schema.TypeString,
-					Required:    true,
-					Description: "AWS Access Key ID of the IAM user",
+					Type:         schema.TypeString,
+					Optional:     true,
+					Description:  "AWS Access Key ID of the IAM user",
+					ExactlyOneOf: []string{"username", "web_identity_federation"},
 				},
 				"password": {
 					Type:             schema.TypeString,
-					Required:         true,
+					Optional:         true,
 					Description:      "AWS Secret Access Key of the IAM user",
 					Sensitive:        true,
 					DiffSuppressFunc: tfhelper.DiffFuncSuppressSecretChanged,
+					ExactlyOneOf:     []string{"password", "password_wo", "password_vault_ref", "web_identity_federation"},
 				},
 				"global_role_arn": {
-					Type:        schema.TypeString,
-					Required:    true,
-					Description: "The Amazon Resource Name (ARN) of the role to assume",
+					Type:         schema.TypeString,
+					Required:     true,
+					ValidateFunc: validate.AwsIamRoleArn,
+					Description:  "The Amazon Resource Name (ARN) of the role to assume",
 				},
 				"global_sts_session_name": {
 					Type:        schema.TypeString,
@@ -45,11 +55,78 @@ func TestResourceServiceEndpointBabylonAwsIam(t *testing.T) {
 					Default:     "azure-pipelines-task",
 					Description: "Session name to be used when assuming the role. The session name should match the one specified in the trust policies of the regional IAM roles.",
 				},
+				"web_identity_federation": {
+					Type:     schema.TypeList,
+					Optional: true,
+					MaxItems: 1,
+					Elem: &schema.Resource{
+						Schema: map[string]*schema.Schema{
+							"audience": {
+								Type:         schema.TypeString,
+								Optional:     true,
+								Default:      "sts.amazonaws.com",
+								ValidateFunc: validation.StringIsNotWhiteSpace,
+								Description:  "The audience of the OIDC token of the pipeline, as configured in the IAM OIDC identity provider",
+							},
+							"session_duration": {
+								Type:         schema.TypeInt,
+								Optional:     true,
+								Default:      3600,
+								ValidateFunc: validation.IntBetween(900, 43200),
+								Description:  "The duration, in seconds, of the session of the role to assume",
+							},
+						},
+					},
+					Description: "Assume global_role_arn with the OIDC token of the pipeline instead of the access key of an IAM user",
+				},
+				"regional_role": {
+					Type:     schema.TypeList,
+					Optional: true,
+					Elem: &schema.Resource{
+						Schema: map[string]*schema.Schema{
+							"region": {
+								Type:         schema.TypeString,
+								Required:     true,
+								ValidateFunc: validation.StringIsNotWhiteSpace,
+								Description:  "The AWS region the role is assumed for",
+							},
+							"role_arn": {
+								Type:         schema.TypeString,
+								Required:     true,
+								ValidateFunc: validate.AwsIamRoleArn,
+								Description:  "The Amazon Resource Name (ARN) of the role to assume in the region, with the session of global_role_arn",
+							},
+							"external_id": {
+								Type:         schema.TypeString,
+								Optional:     true,
+								ValidateFunc: validation.StringLenBetween(2, 1224),
+								Description:  "The external ID required by the trust policy of the role",
+							},
+							"session_duration": {
+								Type:         schema.TypeInt,
+								Optional:     true,
+								Default:      3600,
+								ValidateFunc: validation.IntBetween(900, 43200),
+								Description:  "The duration, in seconds, of the session of the role",
+							},
+						},
+					},
+					Description: "The roles to assume in each region, their trust policies must allow global_sts_session_name",
+				},
+				"session_policy": {
+					Type:             schema.TypeString,
+					Optional:         true,
+					ValidateFunc:     validation.StringIsJSON,
+					DiffSuppressFunc: structure.SuppressJsonDiff,
+					Description:      "An inline IAM policy, as JSON, further restricting the permissions of the sessions of the roles",
+				},
 				"project_id": {
 					Type:         schema.TypeString,
-					Required:     true,
+					Optional:     true,
+					Computed:     true,
 					ForceNew:     true,
 					ValidateFunc: validation.StringIsNotWhiteSpace,
+					Description:  "The name or ID of the project. Defaults to the default_project of the provider.",
 				},
 				"service_endpoint_name": {
 					Type:         schema.TypeString,
@@ -63,6 +140,67 @@ func TestResourceServiceEndpointBabylonAwsIam(t *testing.T) {
 					Default:      "Managed by Terraform",
 					ValidateFunc: validation.StringIsNotWhiteSpace,
 				},
+				"shared_project_ids": {
+					Type:     schema.TypeSet,
+					Optional: true,
+					Computed: true,
+					Elem: &schema.Schema{
+						Type:         schema.TypeString,
+						ValidateFunc: validation.StringIsNotWhiteSpace,
+					},
+					Description: "The names or IDs of the other projects the service endpoint is shared with, the IDs are stored in the state.",
+				},
+				"shared_project_override": {
+					Type:     schema.TypeSet,
+					Optional: true,
+					Computed: true,
+					Elem: &schema.Resource{
+						Schema: map[string]*schema.Schema{
+							"project_id": {
+								Type:         schema.TypeString,
+								Required:     true,
+								ValidateFunc: validation.StringIsNotWhiteSpace,
+								Description:  "The name or ID of a project of shared_project_ids.",
+							},
+							"service_endpoint_name": {
+								Type:         schema.TypeString,
+								Optional:     true,
+								ValidateFunc: validation.StringIsNotWhiteSpace,
+								Description:  "The name of the service endpoint in the project, when it differs from service_endpoint_name.",
+							},
+							"description": {
+								Type:         schema.TypeString,
+								Optional:     true,
+								ValidateFunc: validation.StringLenBetween(0, 1024),
+								Description:  "The description of the service endpoint in the project, when it differs from description.",
+							},
+						},
+					},
+					Description: "The name and description of the service endpoint in some of the projects of shared_project_ids.",
+				},
+				"verify": {
+					Type:     schema.TypeList,
+					Optional: true,
+					MaxItems: 1,
+					Elem: &schema.Resource{
+						Schema: map[string]*schema.Schema{
+							"data_source": {
+								Type:         schema.TypeString,
+								Optional:     true,
+								Default:      "TestConnection",
+								ValidateFunc: validation.StringIsNotWhiteSpace,
+								Description:  "The data source of the service endpoint type executed to verify the service endpoint.",
+							},
+							"rollback": {
+								Type:        schema.TypeBool,
+								Optional:    true,
+								Default:     false,
+								Description: "Whether the service endpoint is deleted when it fails the verification after being created.",
+							},
+						},
+					},
+					Description: "Verifies the service endpoint against the service it connects to after it is created or updated, the apply fails when the verification fails.",
+				},
 				"authorization": {
 					Type:         schema.TypeMap,
 					Optional:     true,
@@ -72,11 +210,32 @@ func TestResourceServiceEndpointBabylonAwsIam(t *testing.T) {
 						Type: schema.TypeString,
 					},
 				},
+				"password_wo": {
+					Type:          schema.TypeString,
+					Optional:      true,
+					WriteOnly:     true,
+					Sensitive:     true,
+					ConflictsWith: []string{"password"},
+					RequiredWith:  []string{"password_version"},
+					Description:   "The write-only variant of the attribute 'password', it is never stored in the state",
+				},
+				"password_version": {
+					Type:         schema.TypeInt,
+					Optional:     true,
+					RequiredWith: []string{"password_wo"},
+					Description:  "The version of the attribute 'password_wo', change it to update the secret",
+				},
+				"password_vault_ref": {
+					Type:          schema.TypeString,
+					Optional:      true,
+					ConflictsWith: []string{"password", "password_wo"},
+					Description:   "The Vault secret holding the value of the attribute 'password', as <path>#<key>. It is read from the vault of the provider when the resource is applied",
+				},
 				"password_hash": {
 					Type:        schema.TypeString,
 					Computed:    true,
 					Default:     nil,
-					Description: fmt.Sprintf("A bcrypted hash of the attribute '%s'", "password"),
+					Description: fmt.Sprintf("A memo of the attribute '%s', computed by the secret_memo of the provider", "password"),
 					Sensitive:   true,
 				},
 			},
@@ -225,16 +384,21 @@ func Test_flattenServiceEndpointBabylonAwsIam(t *testing.T) {
 				projectID: converter.UUID("3c49c3b6-a06d-424d-a6b6-0cd375ee9261"),
 			},
 			expected: map[string]string{
-				"id":                      "1ceae7ff-565c-4cdf-9214-6e2246cba764",
-				"authorization.%":         "1",
-				"authorization.scheme":    "UsernamePassword",
-				"description":             "",
-				"password":                "password1",
-				"global_role_arn":         "roleArn1",
-				"global_sts_session_name": "sessionName1",
-				"project_id":              "3c49c3b6-a06d-424d-a6b6-0cd375ee9261",
-				"service_endpoint_name":   "",
-				"username":                "user1",
+				"id":                        "1ceae7ff-565c-4cdf-9214-6e2246cba764",
+				"authorization.%":           "1",
+				"authorization.scheme":      "UsernamePassword",
+				"shared_project_ids.#":      "0",
+				"shared_project_override.#": "0",
+				"description":               "",
+				"password":                  "password1",
+				"global_role_arn":           "roleArn1",
+				"global_sts_session_name":   "sessionName1",
+				"project_id":                "3c49c3b6-a06d-424d-a6b6-0cd375ee9261",
+				"service_endpoint_name":     "",
+				"username":                  "user1",
+				"web_identity_federation.#": "0",
+				"regional_role.#":           "0",
+				"session_policy":            "",
 			},
 		},
 	}
@@ -244,7 +408,7 @@ func Test_flattenServiceEndpointBabylonAwsIam(t *testing.T) {
 
 			resourceData := schema.TestResourceDataRaw(t, r.Schema, nil)
 
-			flattenServiceEndpointBabylonAwsIam(resourceData, tt.args.serviceEndpoint, tt.args.projectID)
+			require.NoError(t, flattenServiceEndpointBabylonAwsIam(resourceData, tt.args.serviceEndpoint, tt.args.projectID))
 			state := resourceData.State()
 
 			if diff := deep.Equal(tt.expected, state.Attributes); len(diff) > 0 {
@@ -253,3 +417,290 @@ func Test_flattenServiceEndpointBabylonAwsIam(t *testing.T) {
 		})
 	}
 }
+
+func Test_flattenServiceEndpointBabylonAwsIam_WriteOnlyPassword(t *testing.T) {
+	r := ResourceServiceEndpointBabylonAwsIam()
+	resourceData := schema.TestResourceDataRaw(t, r.Schema, map[string]interface{}{
+		"password_version": 1,
+	})
+
+	require.NoError(t, flattenServiceEndpointBabylonAwsIam(resourceData, &serviceendpoint.ServiceEndpoint{
+		Id:  converter.UUID("1ceae7ff-565c-4cdf-9214-6e2246cba764"),
+		Url: converter.String("https://aws.amazon.com/"),
+		Authorization: &serviceendpoint.EndpointAuthorization{
+			Parameters: &map[string]string{
+				"username":             "user1",
+				"password":             "password1",
+				"globalRoleArn":        "roleArn1",
+				"globalStsSessionName": "sessionName1",
+			},
+			Scheme: converter.String("UsernamePassword"),
+		},
+	}, converter.UUID("3c49c3b6-a06d-424d-a6b6-0cd375ee9261")))
+	state := resourceData.State()
+
+	expected := map[string]string{
+		"id":                        "1ceae7ff-565c-4cdf-9214-6e2246cba764",
+		"authorization.%":           "1",
+		"authorization.scheme":      "UsernamePassword",
+		"shared_project_ids.#":      "0",
+		"shared_project_override.#": "0",
+		"description":               "",
+		"password":                  "",
+		"password_hash":             "",
+		"password_version":          "1",
+		"global_role_arn":           "roleArn1",
+		"global_sts_session_name":   "sessionName1",
+		"project_id":                "3c49c3b6-a06d-424d-a6b6-0cd375ee9261",
+		"service_endpoint_name":     "",
+		"username":                  "user1",
+		"web_identity_federation.#": "0",
+		"regional_role.#":           "0",
+		"session_policy":            "",
+	}
+	if diff := deep.Equal(expected, state.Attributes); len(diff) > 0 {
+		t.Errorf("mismatch:\n%s", diff)
+	}
+}
+
+func Test_expandServiceEndpointBabylonAwsIam_WebIdentityFederation(t *testing.T) {
+	r := ResourceServiceEndpointBabylonAwsIam()
+	resourceData := schema.TestResourceDataRaw(t, r.Schema, map[string]interface{}{
+		"project_id":      "3c49c3b6-a06d-424d-a6b6-0cd375ee9261",
+		"global_role_arn": "arn:aws:iam::123456789012:role/deployer",
+		"web_identity_federation": []interface{}{
+			map[string]interface{}{"session_duration": 900},
+		},
+	})
+
+	serviceEndpoint, _, err := expandServiceEndpointBabylonAwsIam(resourceData)
+	require.NoError(t, err)
+	require.Equal(t, &serviceendpoint.EndpointAuthorization{
+		Parameters: &map[string]string{
+			"globalRoleArn":        "arn:aws:iam::123456789012:role/deployer",
+			"globalStsSessionName": BABYLON_AWS_IAM_DEFAULT_SESSION_NAME,
+			"audience":             BABYLON_AWS_IAM_DEFAULT_AUDIENCE,
+			"sessionDuration":      "900",
+		},
+		Scheme: converter.String("WorkloadIdentityFederation"),
+	}, serviceEndpoint.Authorization)
+
+	resourceData = schema.TestResourceDataRaw(t, r.Schema, nil)
+	serviceEndpoint.Id = converter.UUID("1ceae7ff-565c-4cdf-9214-6e2246cba764")
+	require.NoError(t, flattenServiceEndpointBabylonAwsIam(resourceData, serviceEndpoint, converter.UUID("3c49c3b6-a06d-424d-a6b6-0cd375ee9261")))
+	require.Equal(t, []interface{}{
+		map[string]interface{}{"audience": BABYLON_AWS_IAM_DEFAULT_AUDIENCE, "session_duration": 900},
+	}, resourceData.Get("web_identity_federation"))
+	require.Equal(t, "", resourceData.Get("username"))
+	require.Equal(t, "arn:aws:iam::123456789012:role/deployer", resourceData.Get("global_role_arn"))
+}
+
+// The access key of an IAM user and the web identity federation are exclusive
+func TestResourceServiceEndpointBabylonAwsIam_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  map[string]interface{}
+		wantErr bool
+	}{
+		{
+			name:   "access key",
+			config: map[string]interface{}{"username": "user", "password": "password"},
+		},
+		{
+			name:   "web identity federation",
+			config: map[string]interface{}{"web_identity_federation": []interface{}{map[string]interface{}{}}},
+		},
+		{
+			name:    "both",
+			config:  map[string]interface{}{"username": "user", "password": "password", "web_identity_federation": []interface{}{map[string]interface{}{}}},
+			wantErr: true,
+		},
+		{
+			name:    "none",
+			config:  map[string]interface{}{},
+			wantErr: true,
+		},
+		{
+			name: "invalid regional role",
+			config: map[string]interface{}{"username": "user", "password": "password", "regional_role": []interface{}{
+				map[string]interface{}{"region": "eu-west-2", "role_arn": "arn:aws:iam::123456789012:user/deployer"},
+			}},
+			wantErr: true,
+		},
+		{
+			name:    "invalid session policy",
+			config:  map[string]interface{}{"username": "user", "password": "password", "session_policy": "{"},
+			wantErr: true,
+		},
+		{
+			name:    "invalid role ARN",
+			config:  map[string]interface{}{"username": "user", "password": "password", "global_role_arn": "deployer"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.config["service_endpoint_name"] = "aws"
+			if _, ok := tt.config["global_role_arn"]; !ok {
+				tt.config["global_role_arn"] = "arn:aws:iam::123456789012:role/deployer"
+			}
+
+			diags := ResourceServiceEndpointBabylonAwsIam().Validate(terraform.NewResourceConfigRaw(tt.config))
+			require.Equal(t, tt.wantErr, diags.HasError(), "unexpected diagnostics: %v", diags)
+		})
+	}
+}
+
+func Test_expandServiceEndpointBabylonAwsIam_RegionalRoles(t *testing.T) {
+	r := ResourceServiceEndpointBabylonAwsIam()
+	resourceData := schema.TestResourceDataRaw(t, r.Schema, map[string]interface{}{
+		"project_id":      "3c49c3b6-a06d-424d-a6b6-0cd375ee9261",
+		"username":        "user",
+		"password":        "password",
+		"global_role_arn": "arn:aws:iam::123456789012:role/deployer",
+		"regional_role": []interface{}{
+			map[string]interface{}{
+				"region":   "eu-west-2",
+				"role_arn": "arn:aws:iam::123456789012:role/deployer-eu-west-2",
+			},
+			map[string]interface{}{
+				"region":           "us-east-1",
+				"role_arn":         "arn:aws:iam::210987654321:role/deployer-us-east-1",
+				"external_id":      "babylon",
+				"session_duration": 900,
+			},
+		},
+		"session_policy": `{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Action":"s3:*","Resource":"*"}]}`,
+	})
+
+	serviceEndpoint, _, err := expandServiceEndpointBabylonAwsIam(resourceData)
+	require.NoError(t, err)
+	require.Equal(t, &map[string]string{
+		"regionalRoles": `[{"region":"eu-west-2","roleArn":"arn:aws:iam::123456789012:role/deployer-eu-west-2","sessionDuration":3600},` +
+			`{"region":"us-east-1","roleArn":"arn:aws:iam::210987654321:role/deployer-us-east-1","externalId":"babylon","sessionDuration":900}]`,
+		"sessionPolicy": `{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Action":"s3:*","Resource":"*"}]}`,
+	}, serviceEndpoint.Data)
+
+	flattened := schema.TestResourceDataRaw(t, r.Schema, nil)
+	serviceEndpoint.Id = converter.UUID("1ceae7ff-565c-4cdf-9214-6e2246cba764")
+	require.NoError(t, flattenServiceEndpointBabylonAwsIam(flattened, serviceEndpoint, converter.UUID("3c49c3b6-a06d-424d-a6b6-0cd375ee9261")))
+	require.Equal(t, resourceData.Get("regional_role"), flattened.Get("regional_role"))
+	require.Equal(t, resourceData.Get("session_policy"), flattened.Get("session_policy"))
+}
+
+// Regional roles which cannot be decoded fail the read instead of being read back empty
+func Test_flattenServiceEndpointBabylonAwsIam_InvalidRegionalRoles(t *testing.T) {
+	resourceData := schema.TestResourceDataRaw(t, ResourceServiceEndpointBabylonAwsIam().Schema, nil)
+
+	err := flattenServiceEndpointBabylonAwsIam(resourceData, &serviceendpoint.ServiceEndpoint{
+		Id:  converter.UUID("1ceae7ff-565c-4cdf-9214-6e2246cba764"),
+		Url: converter.String("https://aws.amazon.com/"),
+		Authorization: &serviceendpoint.EndpointAuthorization{
+			Parameters: &map[string]string{"username": "user1", "globalRoleArn": "roleArn1"},
+			Scheme:     converter.String("UsernamePassword"),
+		},
+		Data: &map[string]string{"regionalRoles": `{"region":"eu-west-2"}`},
+	}, converter.UUID("3c49c3b6-a06d-424d-a6b6-0cd375ee9261"))
+	require.ErrorContains(t, err, "Error decoding the regional roles of the service endpoint")
+}
+
+func Test_customizeDiffBabylonAwsIamRegionalRoles(t *testing.T) {
+	tests := []struct {
+		name    string
+		roles   []interface{}
+		wantErr string
+	}{
+		{
+			name: "one role per region",
+			roles: []interface{}{
+				map[string]interface{}{"region": "eu-west-2", "role_arn": "arn:aws:iam::123456789012:role/deployer"},
+				map[string]interface{}{"region": "us-east-1", "role_arn": "arn:aws:iam::123456789012:role/deployer"},
+			},
+		},
+		{
+			name: "duplicate region",
+			roles: []interface{}{
+				map[string]interface{}{"region": "eu-west-2", "role_arn": "arn:aws:iam::123456789012:role/deployer"},
+				map[string]interface{}{"region": "us-east-1", "role_arn": "arn:aws:iam::123456789012:role/deployer"},
+				map[string]interface{}{"region": "eu-west-2", "role_arn": "arn:aws:iam::210987654321:role/deployer"},
+			},
+			wantErr: "the region eu-west-2 has more than one regional_role",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := map[string]interface{}{
+				"project_id":            "3c49c3b6-a06d-424d-a6b6-0cd375ee9261",
+				"service_endpoint_name": "aws",
+				"username":              "user",
+				"password":              "password",
+				"global_role_arn":       "arn:aws:iam::123456789012:role/deployer",
+				"regional_role":         tt.roles,
+			}
+			_, err := ResourceServiceEndpointBabylonAwsIam().Diff(context.Background(), nil, terraform.NewResourceConfigRaw(config), nil)
+			if tt.wantErr == "" {
+				require.NoError(t, err)
+			} else {
+				require.EqualError(t, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// The data and the authorization of the service endpoint are checked against the inputs of its type
+func Test_customizeDiffBabylonAwsIamTypeInputs(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	clients := newTestEndpointTypeClients(ctrl, serviceendpoint.ServiceEndpointType{
+		Name:             converter.String(BABYLON_AWS_IAM_SERVICE_CONNECTION_TYPE),
+		InputDescriptors: &[]forminput.InputDescriptor{newTestInput("regionalRoles", false)},
+		AuthenticationSchemes: &[]serviceendpoint.ServiceEndpointAuthenticationScheme{
+			{
+				Scheme: converter.String("UsernamePassword"),
+				InputDescriptors: &[]forminput.InputDescriptor{
+					newTestInput("username", true),
+					newTestInput("password", true),
+					newTestInput("globalRoleArn", true),
+				},
+			},
+		},
+	})
+
+	tests := []struct {
+		name    string
+		config  map[string]interface{}
+		wantErr string
+	}{
+		{
+			name: "access key from Vault",
+			config: map[string]interface{}{"username": "user", "password_vault_ref": "aws/deployer#secret_key", "regional_role": []interface{}{
+				map[string]interface{}{"region": "eu-west-2", "role_arn": "arn:aws:iam::123456789012:role/deployer"},
+			}},
+		},
+		{
+			name:    "input the type does not have",
+			config:  map[string]interface{}{"username": "user", "password": "password", "session_policy": `{"Version": "2012-10-17"}`},
+			wantErr: "the data key sessionPolicy is not an input of the service endpoint type babylon-service-endpoint-aws-iam, expected one of [regionalRoles]",
+		},
+		{
+			name:    "scheme the type does not support",
+			config:  map[string]interface{}{"web_identity_federation": []interface{}{map[string]interface{}{}}},
+			wantErr: "the authorization scheme WorkloadIdentityFederation is not supported by the service endpoint type babylon-service-endpoint-aws-iam, expected one of [UsernamePassword]",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.config["project_id"] = "3c49c3b6-a06d-424d-a6b6-0cd375ee9261"
+			tt.config["service_endpoint_name"] = "aws"
+			tt.config["global_role_arn"] = "arn:aws:iam::123456789012:role/deployer"
+
+			_, err := ResourceServiceEndpointBabylonAwsIam().Diff(context.Background(), nil, terraform.NewResourceConfigRaw(tt.config), clients)
+			if tt.wantErr == "" {
+				require.NoError(t, err)
+			} else {
+				require.EqualError(t, err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -1,12 +1,15 @@
 package serviceendpoint
 
 import (
+	"context"
 	"fmt"
 	"github.com/google/uuid"
+	"regexp"
 	"strings"
 
 	"github.com/babylonhealth/terraform-provider-bblnazuredevops/bblnazuredevops/internal/utils/converter"
 	"github.com/babylonhealth/terraform-provider-bblnazuredevops/bblnazuredevops/internal/utils/tfhelper"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/customdiff"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
 	"github.com/microsoft/azure-devops-go-api/azuredevops/v6/serviceendpoint"
@@ -14,8 +17,28 @@ import (
 
 const BABYLON_VAULT_SERVICE_CONNECTION_TYPE string = "babylon-service-endpoint-vault"
 
+// The auth methods the pipelines log in to Vault with
+const (
+	BABYLON_VAULT_AUTH_METHOD_JWT        string = "jwt"
+	BABYLON_VAULT_AUTH_METHOD_APPROLE    string = "approle"
+	BABYLON_VAULT_AUTH_METHOD_KUBERNETES string = "kubernetes"
+)
+
+var vaultMountPathRegexp = regexp.MustCompile(`^[^/]+(/[^/]+)*$`)
+
+// babylonVaultDataAttributes maps the optional data of the service endpoint to their attribute
+var babylonVaultDataAttributes = map[string]string{
+	"vaultRole":     "vault_role",
+	"mountPath":     "mount_path",
+	"namespace":     "namespace",
+	"caCertificate": "ca_certificate",
+}
+
+// babylonVaultVaultRefs maps the authorization parameters read from Vault to the secret setting them
+var babylonVaultVaultRefs = vaultRefsOf(map[string]string{"password": "secret_id"})
+
 func ResourceServiceEndpointBabylonVault() *schema.Resource {
-	r := genBaseServiceEndpointResource(flattenServiceEndpointBabylonVault, expandServiceEndpointBabylonVault)
+	r := genBaseServiceEndpointResource(BABYLON_VAULT_SERVICE_CONNECTION_TYPE, flattenServiceEndpointBabylonVault, expandServiceEndpointBabylonVault, babylonVaultInputs, babylonVaultVaultRefs)
 	r.Schema["url"] = &schema.Schema{
 		Type:     schema.TypeString,
 		Required: true,
@@ -34,32 +57,185 @@ func ResourceServiceEndpointBabylonVault() *schema.Resource {
 		Description: "Url for the Vault Server",
 	}
 
+	r.Schema["auth_method"] = &schema.Schema{
+		Type:     schema.TypeString,
+		Optional: true,
+		Default:  BABYLON_VAULT_AUTH_METHOD_JWT,
+		ValidateFunc: validation.StringInSlice([]string{
+			BABYLON_VAULT_AUTH_METHOD_JWT,
+			BABYLON_VAULT_AUTH_METHOD_APPROLE,
+			BABYLON_VAULT_AUTH_METHOD_KUBERNETES,
+		}, false),
+		Description: "The auth method to log in to Vault with: jwt (the OIDC token of the pipeline), approle or kubernetes",
+	}
+	r.Schema["mount_path"] = &schema.Schema{
+		Type:         schema.TypeString,
+		Optional:     true,
+		ValidateFunc: validation.StringMatch(vaultMountPathRegexp, "expected a path without leading or trailing slashes"),
+		Description:  "The path the auth method is mounted at, defaults to the name of the auth method",
+	}
 	r.Schema["vault_role"] = &schema.Schema{
-		Type:        schema.TypeString,
-		Required:    true,
-		Description: "Vault role to log in as",
+		Type:         schema.TypeString,
+		Optional:     true,
+		ValidateFunc: validation.StringIsNotWhiteSpace,
+		Description:  "Vault role to log in as, required by the jwt and kubernetes auth methods",
+	}
+	r.Schema["role_id"] = &schema.Schema{
+		Type:         schema.TypeString,
+		Optional:     true,
+		ValidateFunc: validation.StringIsNotWhiteSpace,
+		Description:  "The role ID to log in with, required by the approle auth method",
 	}
+	r.Schema["secret_id"] = &schema.Schema{
+		Type:             schema.TypeString,
+		Optional:         true,
+		Description:      "The secret ID to log in with, required by the approle auth method",
+		Sensitive:        true,
+		DiffSuppressFunc: tfhelper.DiffFuncSuppressSecretChanged,
+	}
+	for key, value := range tfhelper.GenerateWriteOnlySecretSchema("secret_id") {
+		r.Schema[key] = value
+	}
+	vaultRefKey, vaultRefSchema := tfhelper.GenerateVaultRefSchema("secret_id")
+	r.Schema[vaultRefKey] = vaultRefSchema
+	secretHashKey, secretHashSchema := tfhelper.GenerateSecreteMemoSchema("secret_id")
+	r.Schema[secretHashKey] = secretHashSchema
+	r.Schema["namespace"] = &schema.Schema{
+		Type:         schema.TypeString,
+		Optional:     true,
+		ValidateFunc: validation.StringIsNotWhiteSpace,
+		Description:  "The Vault Enterprise namespace to log in to",
+	}
+	r.Schema["ca_certificate"] = &schema.Schema{
+		Type:         schema.TypeString,
+		Optional:     true,
+		ValidateFunc: validation.StringIsNotWhiteSpace,
+		Description:  "The PEM encoded CA certificate to verify the TLS certificate of the Vault Server with",
+	}
+
+	r.CustomizeDiff = customdiff.All(r.CustomizeDiff, customizeDiffBabylonVaultAuthMethod)
 	return r
 }
 
+// customizeDiffBabylonVaultAuthMethod checks that the attributes required by the auth method are set, and only them
+func customizeDiffBabylonVaultAuthMethod(_ context.Context, d *schema.ResourceDiff, _ interface{}) error {
+	if !d.NewValueKnown("auth_method") {
+		return nil
+	}
+	authMethod := d.Get("auth_method").(string)
+
+	isSet := func(key string) bool {
+		return !d.NewValueKnown(key) || d.Get(key).(string) != ""
+	}
+	hasSecretID := isSet("secret_id") || d.Get("secret_id_version").(int) != 0 || isSet("secret_id_vault_ref")
+
+	if authMethod == BABYLON_VAULT_AUTH_METHOD_APPROLE {
+		if !isSet("role_id") {
+			return fmt.Errorf("role_id is required by the %s auth method", authMethod)
+		}
+		if !hasSecretID {
+			return fmt.Errorf("one of secret_id, secret_id_wo or secret_id_vault_ref is required by the %s auth method", authMethod)
+		}
+		if isSet("vault_role") {
+			return fmt.Errorf("vault_role is not used by the %s auth method, set role_id instead", authMethod)
+		}
+		return nil
+	}
+
+	if !isSet("vault_role") {
+		return fmt.Errorf("vault_role is required by the %s auth method", authMethod)
+	}
+	if isSet("role_id") || hasSecretID {
+		return fmt.Errorf("role_id and secret_id are only used by the %s auth method", BABYLON_VAULT_AUTH_METHOD_APPROLE)
+	}
+	return nil
+}
+
+// babylonVaultInputs returns the authorization scheme, the data and the authorization parameters planned for the
+// service endpoint, as expandServiceEndpointBabylonVault sends them
+func babylonVaultInputs(d *schema.ResourceDiff) (string, map[string]string, map[string]string, bool) {
+	if !d.NewValueKnown("auth_method") {
+		return "", nil, nil, false
+	}
+	authMethod := d.Get("auth_method").(string)
+
+	scheme := "None"
+	parameters := map[string]string{}
+	if authMethod == BABYLON_VAULT_AUTH_METHOD_APPROLE {
+		scheme = "UsernamePassword"
+		parameters["username"] = plannedInput(d, "role_id")
+		parameters["password"] = plannedSecretInput(d, "secret_id", "secret_id_version", "secret_id_vault_ref")
+	}
+
+	data := map[string]string{"authMethod": authMethod}
+	for key, attribute := range babylonVaultDataAttributes {
+		if value := plannedInput(d, attribute); value != "" {
+			data[key] = value
+		}
+	}
+	return scheme, data, parameters, true
+}
+
 // Convert internal Terraform data structure to an AzDO data structure
 func expandServiceEndpointBabylonVault(d *schema.ResourceData) (*serviceendpoint.ServiceEndpoint, *uuid.UUID, error) {
 	serviceEndpoint, projectID := doBaseExpansion(d)
-	serviceEndpoint.Authorization = &serviceendpoint.EndpointAuthorization{
-		Parameters: &map[string]string{},
-		Scheme:     converter.String("None"),
+	authMethod := d.Get("auth_method").(string)
+	if authMethod == BABYLON_VAULT_AUTH_METHOD_APPROLE {
+		serviceEndpoint.Authorization = &serviceendpoint.EndpointAuthorization{
+			Parameters: &map[string]string{
+				"username": d.Get("role_id").(string),
+				"password": tfhelper.GetSecret(d, "secret_id"),
+			},
+			Scheme: converter.String("UsernamePassword"),
+		}
+	} else {
+		serviceEndpoint.Authorization = &serviceendpoint.EndpointAuthorization{
+			Parameters: &map[string]string{},
+			Scheme:     converter.String("None"),
+		}
 	}
-	serviceEndpoint.Data = &map[string]string{"vaultRole": d.Get("vault_role").(string)}
+
+	data := map[string]string{"authMethod": authMethod}
+	for key, attribute := range babylonVaultDataAttributes {
+		if value := d.Get(attribute).(string); value != "" {
+			data[key] = value
+		}
+	}
+	serviceEndpoint.Data = &data
 	serviceEndpoint.Type = converter.String(BABYLON_VAULT_SERVICE_CONNECTION_TYPE)
 	serviceEndpoint.Url = converter.String(d.Get("url").(string))
 	return serviceEndpoint, projectID, nil
 }
 
 // Convert AzDO data structure to internal Terraform data structure
-func flattenServiceEndpointBabylonVault(d *schema.ResourceData, serviceEndpoint *serviceendpoint.ServiceEndpoint, projectID *uuid.UUID) {
+func flattenServiceEndpointBabylonVault(d *schema.ResourceData, serviceEndpoint *serviceendpoint.ServiceEndpoint, projectID *uuid.UUID) error {
 	doBaseFlattening(d, serviceEndpoint, projectID)
-	tfhelper.HelpFlattenSecret(d, "password")
+	tfhelper.HelpFlattenSecret(d, "secret_id")
+
+	data := map[string]string{}
+	if serviceEndpoint.Data != nil {
+		data = *serviceEndpoint.Data
+	}
+	// service endpoints created before the auth method could be chosen log in with the OIDC token of the pipeline
+	authMethod := data["authMethod"]
+	if authMethod == "" {
+		authMethod = BABYLON_VAULT_AUTH_METHOD_JWT
+	}
 
 	d.Set("url", *serviceEndpoint.Url)
-	d.Set("vault_role", (*serviceEndpoint.Data)["vaultRole"])
+	d.Set("auth_method", authMethod)
+	d.Set("vault_role", data["vaultRole"])
+	d.Set("mount_path", data["mountPath"])
+	d.Set("namespace", data["namespace"])
+	d.Set("ca_certificate", data["caCertificate"])
+
+	if authMethod == BABYLON_VAULT_AUTH_METHOD_APPROLE && serviceEndpoint.Authorization != nil && serviceEndpoint.Authorization.Parameters != nil {
+		d.Set("role_id", (*serviceEndpoint.Authorization.Parameters)["username"])
+		if !tfhelper.IsSecretKeptOutOfState(d, "secret_id") {
+			d.Set("secret_id", (*serviceEndpoint.Authorization.Parameters)["password"])
+		}
+	} else {
+		d.Set("role_id", "")
+	}
+	return nil
 }
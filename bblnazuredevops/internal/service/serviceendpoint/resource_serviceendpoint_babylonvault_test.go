@@ -1,15 +1,21 @@
 package serviceendpoint
 
 import (
+	"context"
 	"github.com/google/uuid"
 	"testing"
 
 	"github.com/babylonhealth/terraform-provider-bblnazuredevops/bblnazuredevops/internal/utils/converter"
+	"github.com/babylonhealth/terraform-provider-bblnazuredevops/bblnazuredevops/internal/utils/tfhelper"
 	"github.com/go-test/deep"
+	"github.com/golang/mock/gomock"
 	"github.com/hashicorp/go-multierror"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
+	"github.com/microsoft/azure-devops-go-api/azuredevops/v6/forminput"
 	"github.com/microsoft/azure-devops-go-api/azuredevops/v6/serviceendpoint"
+	"github.com/stretchr/testify/require"
 )
 
 func TestResourceServiceEndpointBabylonVault(t *testing.T) {
@@ -25,16 +31,84 @@ func TestResourceServiceEndpointBabylonVault(t *testing.T) {
 					Required:    true,
 					Description: "Url for the Vault Server",
 				},
+				"auth_method": {
+					Type:         schema.TypeString,
+					Optional:     true,
+					Default:      "jwt",
+					ValidateFunc: validation.StringInSlice([]string{"jwt", "approle", "kubernetes"}, false),
+					Description:  "The auth method to log in to Vault with: jwt (the OIDC token of the pipeline), approle or kubernetes",
+				},
+				"mount_path": {
+					Type:         schema.TypeString,
+					Optional:     true,
+					ValidateFunc: validation.StringIsNotWhiteSpace,
+					Description:  "The path the auth method is mounted at, defaults to the name of the auth method",
+				},
 				"vault_role": {
+					Type:         schema.TypeString,
+					Optional:     true,
+					ValidateFunc: validation.StringIsNotWhiteSpace,
+					Description:  "Vault role to log in as, required by the jwt and kubernetes auth methods",
+				},
+				"role_id": {
+					Type:         schema.TypeString,
+					Optional:     true,
+					ValidateFunc: validation.StringIsNotWhiteSpace,
+					Description:  "The role ID to log in with, required by the approle auth method",
+				},
+				"secret_id": {
+					Type:             schema.TypeString,
+					Optional:         true,
+					Description:      "The secret ID to log in with, required by the approle auth method",
+					Sensitive:        true,
+					DiffSuppressFunc: tfhelper.DiffFuncSuppressSecretChanged,
+				},
+				"secret_id_wo": {
+					Type:          schema.TypeString,
+					Optional:      true,
+					WriteOnly:     true,
+					Sensitive:     true,
+					ConflictsWith: []string{"secret_id"},
+					RequiredWith:  []string{"secret_id_version"},
+					Description:   "The write-only variant of the attribute 'secret_id', it is never stored in the state",
+				},
+				"secret_id_version": {
+					Type:         schema.TypeInt,
+					Optional:     true,
+					RequiredWith: []string{"secret_id_wo"},
+					Description:  "The version of the attribute 'secret_id_wo', change it to update the secret",
+				},
+				"secret_id_vault_ref": {
+					Type:          schema.TypeString,
+					Optional:      true,
+					ConflictsWith: []string{"secret_id", "secret_id_wo"},
+					Description:   "The Vault secret holding the value of the attribute 'secret_id', as <path>#<key>. It is read from the vault of the provider when the resource is applied",
+				},
+				"secret_id_hash": {
 					Type:        schema.TypeString,
-					Required:    true,
-					Description: "Vault role to log in as",
+					Computed:    true,
+					Sensitive:   true,
+					Description: "A memo of the attribute 'secret_id', computed by the secret_memo of the provider",
+				},
+				"namespace": {
+					Type:         schema.TypeString,
+					Optional:     true,
+					ValidateFunc: validation.StringIsNotWhiteSpace,
+					Description:  "The Vault Enterprise namespace to log in to",
+				},
+				"ca_certificate": {
+					Type:         schema.TypeString,
+					Optional:     true,
+					ValidateFunc: validation.StringIsNotWhiteSpace,
+					Description:  "The PEM encoded CA certificate to verify the TLS certificate of the Vault Server with",
 				},
 				"project_id": {
 					Type:         schema.TypeString,
-					Required:     true,
+					Optional:     true,
+					Computed:     true,
 					ForceNew:     true,
 					ValidateFunc: validation.StringIsNotWhiteSpace,
+					Description:  "The name or ID of the project. Defaults to the default_project of the provider.",
 				},
 				"service_endpoint_name": {
 					Type:         schema.TypeString,
@@ -48,6 +122,67 @@ func TestResourceServiceEndpointBabylonVault(t *testing.T) {
 					Default:      "Managed by Terraform",
 					ValidateFunc: validation.StringIsNotWhiteSpace,
 				},
+				"shared_project_ids": {
+					Type:     schema.TypeSet,
+					Optional: true,
+					Computed: true,
+					Elem: &schema.Schema{
+						Type:         schema.TypeString,
+						ValidateFunc: validation.StringIsNotWhiteSpace,
+					},
+					Description: "The names or IDs of the other projects the service endpoint is shared with, the IDs are stored in the state.",
+				},
+				"shared_project_override": {
+					Type:     schema.TypeSet,
+					Optional: true,
+					Computed: true,
+					Elem: &schema.Resource{
+						Schema: map[string]*schema.Schema{
+							"project_id": {
+								Type:         schema.TypeString,
+								Required:     true,
+								ValidateFunc: validation.StringIsNotWhiteSpace,
+								Description:  "The name or ID of a project of shared_project_ids.",
+							},
+							"service_endpoint_name": {
+								Type:         schema.TypeString,
+								Optional:     true,
+								ValidateFunc: validation.StringIsNotWhiteSpace,
+								Description:  "The name of the service endpoint in the project, when it differs from service_endpoint_name.",
+							},
+							"description": {
+								Type:         schema.TypeString,
+								Optional:     true,
+								ValidateFunc: validation.StringLenBetween(0, 1024),
+								Description:  "The description of the service endpoint in the project, when it differs from description.",
+							},
+						},
+					},
+					Description: "The name and description of the service endpoint in some of the projects of shared_project_ids.",
+				},
+				"verify": {
+					Type:     schema.TypeList,
+					Optional: true,
+					MaxItems: 1,
+					Elem: &schema.Resource{
+						Schema: map[string]*schema.Schema{
+							"data_source": {
+								Type:         schema.TypeString,
+								Optional:     true,
+								Default:      "TestConnection",
+								ValidateFunc: validation.StringIsNotWhiteSpace,
+								Description:  "The data source of the service endpoint type executed to verify the service endpoint.",
+							},
+							"rollback": {
+								Type:        schema.TypeBool,
+								Optional:    true,
+								Default:     false,
+								Description: "Whether the service endpoint is deleted when it fails the verification after being created.",
+							},
+						},
+					},
+					Description: "Verifies the service endpoint against the service it connects to after it is created or updated, the apply fails when the verification fails.",
+				},
 				"authorization": {
 					Type:         schema.TypeMap,
 					Optional:     true,
@@ -99,7 +234,8 @@ func Test_expandServiceEndpointBabylonVault(t *testing.T) {
 					Scheme:     converter.String("None"),
 				},
 				Data: &map[string]string{
-					"vaultRole": "devtest",
+					"authMethod": "jwt",
+					"vaultRole":  "devtest",
 				},
 				Description: converter.String("Managed by Terraform"),
 				Owner:       converter.String("library"),
@@ -188,14 +324,21 @@ func Test_flattenServiceEndpointBabylonVault(t *testing.T) {
 				projectID: converter.UUID("3c49c3b6-a06d-424d-a6b6-0cd375ee9261"),
 			},
 			expected: map[string]string{
-				"id":                    "1ceae7ff-565c-4cdf-9214-6e2246cba764",
-				"authorization.%":       "1",
-				"authorization.scheme":  "None",
-				"description":           "",
-				"url":                   "https://vault.babylonhealth.com",
-				"vault_role":            "devtest",
-				"project_id":            "3c49c3b6-a06d-424d-a6b6-0cd375ee9261",
-				"service_endpoint_name": "",
+				"id":                        "1ceae7ff-565c-4cdf-9214-6e2246cba764",
+				"authorization.%":           "1",
+				"authorization.scheme":      "None",
+				"shared_project_ids.#":      "0",
+				"shared_project_override.#": "0",
+				"description":               "",
+				"url":                       "https://vault.babylonhealth.com",
+				"auth_method":               "jwt",
+				"vault_role":                "devtest",
+				"mount_path":                "",
+				"role_id":                   "",
+				"namespace":                 "",
+				"ca_certificate":            "",
+				"project_id":                "3c49c3b6-a06d-424d-a6b6-0cd375ee9261",
+				"service_endpoint_name":     "",
 			},
 		},
 	}
@@ -205,7 +348,7 @@ func Test_flattenServiceEndpointBabylonVault(t *testing.T) {
 
 			resourceData := schema.TestResourceDataRaw(t, r.Schema, nil)
 
-			flattenServiceEndpointBabylonVault(resourceData, tt.args.serviceEndpoint, tt.args.projectID)
+			require.NoError(t, flattenServiceEndpointBabylonVault(resourceData, tt.args.serviceEndpoint, tt.args.projectID))
 			state := resourceData.State()
 
 			if diff := deep.Equal(tt.expected, state.Attributes); len(diff) > 0 {
@@ -214,3 +357,147 @@ func Test_flattenServiceEndpointBabylonVault(t *testing.T) {
 		})
 	}
 }
+
+func Test_expandServiceEndpointBabylonVault_AppRole(t *testing.T) {
+	resourceData := schema.TestResourceDataRaw(t, ResourceServiceEndpointBabylonVault().Schema, map[string]interface{}{
+		"project_id":     "3c49c3b6-a06d-424d-a6b6-0cd375ee9261",
+		"url":            "https://vault.babylonhealth.com",
+		"auth_method":    "approle",
+		"mount_path":     "business-unit/approle",
+		"role_id":        "0b8d3a5e-role",
+		"secret_id":      "0b8d3a5e-secret",
+		"namespace":      "admin/platform",
+		"ca_certificate": "-----BEGIN CERTIFICATE-----",
+	})
+
+	got, _, err := expandServiceEndpointBabylonVault(resourceData)
+	require.NoError(t, err)
+	require.Equal(t, &serviceendpoint.EndpointAuthorization{
+		Parameters: &map[string]string{
+			"username": "0b8d3a5e-role",
+			"password": "0b8d3a5e-secret",
+		},
+		Scheme: converter.String("UsernamePassword"),
+	}, got.Authorization)
+	require.Equal(t, &map[string]string{
+		"authMethod":    "approle",
+		"mountPath":     "business-unit/approle",
+		"namespace":     "admin/platform",
+		"caCertificate": "-----BEGIN CERTIFICATE-----",
+	}, got.Data)
+
+	// the settings are read back from the service endpoint, the secret ID is not returned by Azure DevOps
+	resourceData = schema.TestResourceDataRaw(t, ResourceServiceEndpointBabylonVault().Schema, nil)
+	got.Id = converter.UUID("1ceae7ff-565c-4cdf-9214-6e2246cba764")
+	delete(*got.Authorization.Parameters, "password")
+	require.NoError(t, flattenServiceEndpointBabylonVault(resourceData, got, converter.UUID("3c49c3b6-a06d-424d-a6b6-0cd375ee9261")))
+	require.Equal(t, "approle", resourceData.Get("auth_method"))
+	require.Equal(t, "business-unit/approle", resourceData.Get("mount_path"))
+	require.Equal(t, "0b8d3a5e-role", resourceData.Get("role_id"))
+	require.Equal(t, "admin/platform", resourceData.Get("namespace"))
+	require.Equal(t, "-----BEGIN CERTIFICATE-----", resourceData.Get("ca_certificate"))
+	require.Equal(t, "", resourceData.Get("vault_role"))
+}
+
+func Test_customizeDiffBabylonVaultAuthMethod(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  map[string]interface{}
+		wantErr string
+	}{
+		{
+			name:   "jwt",
+			config: map[string]interface{}{"vault_role": "devtest"},
+		},
+		{
+			name:    "jwt without a role",
+			config:  map[string]interface{}{},
+			wantErr: "vault_role is required by the jwt auth method",
+		},
+		{
+			name:    "kubernetes with a secret ID",
+			config:  map[string]interface{}{"auth_method": "kubernetes", "vault_role": "devtest", "secret_id": "secret"},
+			wantErr: "role_id and secret_id are only used by the approle auth method",
+		},
+		{
+			name:   "approle",
+			config: map[string]interface{}{"auth_method": "approle", "role_id": "role", "secret_id_wo": "secret", "secret_id_version": 1},
+		},
+		{
+			name:    "approle without a secret ID",
+			config:  map[string]interface{}{"auth_method": "approle", "role_id": "role"},
+			wantErr: "one of secret_id, secret_id_wo or secret_id_vault_ref is required by the approle auth method",
+		},
+		{
+			name:    "approle with a vault role",
+			config:  map[string]interface{}{"auth_method": "approle", "role_id": "role", "secret_id": "secret", "vault_role": "devtest"},
+			wantErr: "vault_role is not used by the approle auth method, set role_id instead",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.config["project_id"] = "3c49c3b6-a06d-424d-a6b6-0cd375ee9261"
+			tt.config["service_endpoint_name"] = "vault"
+			tt.config["url"] = "https://vault.babylonhealth.com"
+
+			_, err := ResourceServiceEndpointBabylonVault().Diff(context.Background(), nil, terraform.NewResourceConfigRaw(tt.config), nil)
+			if tt.wantErr == "" {
+				require.NoError(t, err)
+			} else {
+				require.EqualError(t, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// The data and the authorization of the service endpoint are checked against the inputs of its type
+func Test_customizeDiffBabylonVaultTypeInputs(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	clients := newTestEndpointTypeClients(ctrl, serviceendpoint.ServiceEndpointType{
+		Name:             converter.String(BABYLON_VAULT_SERVICE_CONNECTION_TYPE),
+		InputDescriptors: &[]forminput.InputDescriptor{newTestInput("authMethod", true), newTestInput("vaultRole", false), newTestInput("mountPath", false)},
+		AuthenticationSchemes: &[]serviceendpoint.ServiceEndpointAuthenticationScheme{
+			{Scheme: converter.String("None")},
+			{
+				Scheme:           converter.String("UsernamePassword"),
+				InputDescriptors: &[]forminput.InputDescriptor{newTestInput("username", true), newTestInput("password", true)},
+			},
+		},
+	})
+
+	tests := []struct {
+		name    string
+		config  map[string]interface{}
+		wantErr string
+	}{
+		{
+			name:   "jwt",
+			config: map[string]interface{}{"vault_role": "devtest", "mount_path": "jwt/azure"},
+		},
+		{
+			name:   "approle with a write-only secret ID",
+			config: map[string]interface{}{"auth_method": "approle", "role_id": "role", "secret_id_wo": "secret", "secret_id_version": 1},
+		},
+		{
+			name:    "input the type does not have",
+			config:  map[string]interface{}{"vault_role": "devtest", "namespace": "admin/platform"},
+			wantErr: "the data key namespace is not an input of the service endpoint type babylon-service-endpoint-vault, expected one of [authMethod, mountPath, vaultRole]",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.config["project_id"] = "3c49c3b6-a06d-424d-a6b6-0cd375ee9261"
+			tt.config["service_endpoint_name"] = "vault"
+			tt.config["url"] = "https://vault.babylonhealth.com"
+
+			_, err := ResourceServiceEndpointBabylonVault().Diff(context.Background(), nil, terraform.NewResourceConfigRaw(tt.config), clients)
+			if tt.wantErr == "" {
+				require.NoError(t, err)
+			} else {
+				require.EqualError(t, err, tt.wantErr)
+			}
+		})
+	}
+}
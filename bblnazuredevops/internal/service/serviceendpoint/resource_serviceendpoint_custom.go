@@ -0,0 +1,203 @@
+package serviceendpoint
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/babylonhealth/terraform-provider-bblnazuredevops/bblnazuredevops/internal/client"
+	"github.com/babylonhealth/terraform-provider-bblnazuredevops/bblnazuredevops/internal/utils/converter"
+	"github.com/babylonhealth/terraform-provider-bblnazuredevops/bblnazuredevops/internal/utils/tfhelper"
+	"github.com/google/uuid"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/customdiff"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+	"github.com/microsoft/azure-devops-go-api/azuredevops/v6/serviceendpoint"
+)
+
+// ResourceServiceEndpointCustom schema and implementation for the service endpoints of any type, e.g. the types
+// contributed by in-house extensions, described by their raw data and authorization
+func ResourceServiceEndpointCustom() *schema.Resource {
+	r := genBaseServiceEndpointResource("", flattenServiceEndpointCustom, expandServiceEndpointCustom, nil, customVaultRefs)
+	r.Schema["type"] = &schema.Schema{
+		Type:         schema.TypeString,
+		Required:     true,
+		ForceNew:     true,
+		ValidateFunc: validation.StringIsNotWhiteSpace,
+		Description:  "The type of the service endpoint, as contributed by its extension",
+	}
+	r.Schema["url"] = &schema.Schema{
+		Type:         schema.TypeString,
+		Required:     true,
+		ValidateFunc: validation.IsURLWithHTTPorHTTPS,
+		Description:  "The URL of the service the service endpoint connects to",
+	}
+	r.Schema["authorization_scheme"] = &schema.Schema{
+		Type:         schema.TypeString,
+		Optional:     true,
+		Default:      "None",
+		ValidateFunc: validation.StringIsNotWhiteSpace,
+		Description:  "The authorization scheme of the service endpoint, one of the schemes of its type",
+	}
+	r.Schema["data"] = &schema.Schema{
+		Type:     schema.TypeMap,
+		Optional: true,
+		Elem: &schema.Schema{
+			Type: schema.TypeString,
+		},
+		Description: "The data of the service endpoint, as defined by its type",
+	}
+	r.Schema["authorization_parameters"] = &schema.Schema{
+		Type:     schema.TypeMap,
+		Optional: true,
+		Elem: &schema.Schema{
+			Type: schema.TypeString,
+		},
+		Description: "The parameters of the authorization which are not confidential",
+	}
+	r.Schema["secret_authorization_parameters"] = &schema.Schema{
+		Type:      schema.TypeMap,
+		Optional:  true,
+		Sensitive: true,
+		Elem: &schema.Schema{
+			Type: schema.TypeString,
+		},
+		DiffSuppressFunc: tfhelper.DiffFuncSuppressSecretMapChanged,
+		Description:      "The confidential parameters of the authorization, they are not returned by Azure DevOps",
+	}
+	secretHashKey, secretHashSchema := tfhelper.GenerateSecretMapMemoSchema("secret_authorization_parameters")
+	r.Schema[secretHashKey] = secretHashSchema
+	vaultRefKey, vaultRefSchema := tfhelper.GenerateVaultRefMapSchema("secret_authorization_parameters")
+	r.Schema[vaultRefKey] = vaultRefSchema
+
+	r.CustomizeDiff = customdiff.All(r.CustomizeDiff, customizeDiffCustomAuthorizationParameters, customizeDiffCustomServiceEndpointType)
+	return r
+}
+
+// customAuthorizationParameterKeys are the attributes setting the authorization parameters of the service endpoint
+var customAuthorizationParameterKeys = []string{"authorization_parameters", "secret_authorization_parameters", "secret_authorization_parameters_vault_ref"}
+
+// customizeDiffCustomAuthorizationParameters rejects the parameters set by more than one of
+// customAuthorizationParameterKeys, e.g. as both confidential and not confidential
+func customizeDiffCustomAuthorizationParameters(_ context.Context, d *schema.ResourceDiff, _ interface{}) error {
+	for _, key := range customAuthorizationParameterKeys {
+		if !d.NewValueKnown(key) {
+			return nil
+		}
+	}
+
+	keyOf := map[string]string{}
+	for _, key := range customAuthorizationParameterKeys {
+		for name := range d.Get(key).(map[string]interface{}) {
+			if other, ok := keyOf[name]; ok {
+				return fmt.Errorf("the authorization parameter %s is set in both %s and %s", name, other, key)
+			}
+			keyOf[name] = key
+		}
+	}
+	return nil
+}
+
+// customizeDiffCustomServiceEndpointType checks the data and the authorization of the service endpoint against the
+// inputs of its type, as published by Azure DevOps, see validateServiceEndpointInputs
+func customizeDiffCustomServiceEndpointType(_ context.Context, d *schema.ResourceDiff, m interface{}) error {
+	clients, ok := m.(*client.AggregatedClient)
+	if !ok {
+		return nil
+	}
+	for _, key := range append([]string{"type", "authorization_scheme", "data"}, customAuthorizationParameterKeys...) {
+		if !d.NewValueKnown(key) {
+			return nil
+		}
+	}
+
+	parameters := map[string]string{}
+	for _, key := range []string{"authorization_parameters", "secret_authorization_parameters"} {
+		for name, value := range d.Get(key).(map[string]interface{}) {
+			parameters[name] = value.(string)
+		}
+	}
+	// the values read from Vault are only known on apply
+	for name := range d.Get("secret_authorization_parameters_vault_ref").(map[string]interface{}) {
+		parameters[name] = unknownInputValue
+	}
+	data := map[string]string{}
+	for key, value := range d.Get("data").(map[string]interface{}) {
+		data[key] = value.(string)
+	}
+	return checkServiceEndpointTypeInputs(clients, d.Get("type").(string), d.Get("authorization_scheme").(string), data, parameters)
+}
+
+// customVaultRefs returns the references to the Vault secrets holding the values of confidential parameters of the
+// authorization, see resolveVaultSecrets
+func customVaultRefs(d *schema.ResourceData) map[string]string {
+	return tfhelper.GetVaultRefMap(d, "secret_authorization_parameters")
+}
+
+// Convert internal Terraform data structure to an AzDO data structure
+func expandServiceEndpointCustom(d *schema.ResourceData) (*serviceendpoint.ServiceEndpoint, *uuid.UUID, error) {
+	serviceEndpoint, projectID := doBaseExpansion(d)
+
+	parameters := map[string]string{}
+	for name, value := range d.Get("authorization_parameters").(map[string]interface{}) {
+		parameters[name] = value.(string)
+	}
+	for name, value := range d.Get("secret_authorization_parameters").(map[string]interface{}) {
+		parameters[name] = value.(string)
+	}
+	serviceEndpoint.Authorization = &serviceendpoint.EndpointAuthorization{
+		Parameters: &parameters,
+		Scheme:     converter.String(d.Get("authorization_scheme").(string)),
+	}
+
+	data := map[string]string{}
+	for key, value := range d.Get("data").(map[string]interface{}) {
+		data[key] = value.(string)
+	}
+	serviceEndpoint.Data = &data
+	serviceEndpoint.Type = converter.String(d.Get("type").(string))
+	serviceEndpoint.Url = converter.String(d.Get("url").(string))
+	return serviceEndpoint, projectID, nil
+}
+
+// Convert AzDO data structure to internal Terraform data structure
+func flattenServiceEndpointCustom(d *schema.ResourceData, serviceEndpoint *serviceendpoint.ServiceEndpoint, projectID *uuid.UUID) error {
+	doBaseFlattening(d, serviceEndpoint, projectID)
+	tfhelper.HelpFlattenSecretMap(d, "secret_authorization_parameters")
+
+	d.Set("type", converter.ToString(serviceEndpoint.Type, ""))
+	d.Set("url", converter.ToString(serviceEndpoint.Url, ""))
+
+	data := map[string]interface{}{}
+	if serviceEndpoint.Data != nil {
+		for key, value := range *serviceEndpoint.Data {
+			data[key] = value
+		}
+	}
+	d.Set("data", data)
+
+	parameters := map[string]string{}
+	if serviceEndpoint.Authorization != nil {
+		d.Set("authorization_scheme", converter.ToString(serviceEndpoint.Authorization.Scheme, ""))
+		if serviceEndpoint.Authorization.Parameters != nil {
+			parameters = *serviceEndpoint.Authorization.Parameters
+		}
+	}
+
+	// the confidential parameters are the ones of the configuration, Azure DevOps does not return their values
+	secretParameters := map[string]interface{}{}
+	for name := range d.Get("secret_authorization_parameters").(map[string]interface{}) {
+		secretParameters[name] = parameters[name]
+	}
+	vaultRefs := customVaultRefs(d)
+	authorizationParameters := map[string]interface{}{}
+	for name, value := range parameters {
+		_, isSecret := secretParameters[name]
+		_, isVaultSecret := vaultRefs[name]
+		if !isSecret && !isVaultSecret {
+			authorizationParameters[name] = value
+		}
+	}
+	d.Set("authorization_parameters", authorizationParameters)
+	d.Set("secret_authorization_parameters", secretParameters)
+	return nil
+}
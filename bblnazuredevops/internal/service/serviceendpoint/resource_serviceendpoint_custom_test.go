@@ -0,0 +1,93 @@
+package serviceendpoint
+
+import (
+	"context"
+	"testing"
+
+	"github.com/babylonhealth/terraform-provider-bblnazuredevops/bblnazuredevops/internal/utils/converter"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
+	"github.com/microsoft/azure-devops-go-api/azuredevops/v6/serviceendpoint"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_expandServiceEndpointCustom(t *testing.T) {
+	resourceData := schema.TestResourceDataRaw(t, ResourceServiceEndpointCustom().Schema, map[string]interface{}{
+		"project_id":                      testProjectID.String(),
+		"service_endpoint_name":           "artifacts",
+		"type":                            "babylon-service-endpoint-artifacts",
+		"url":                             "https://artifacts.babylonhealth.com",
+		"authorization_scheme":            "UsernamePassword",
+		"data":                            map[string]interface{}{"repository": "releases"},
+		"authorization_parameters":        map[string]interface{}{"username": "deployer"},
+		"secret_authorization_parameters": map[string]interface{}{"password": "secret"},
+	})
+
+	got, projectID, err := expandServiceEndpointCustom(resourceData)
+	require.NoError(t, err)
+	require.Equal(t, testProjectID, *projectID)
+	require.Equal(t, "babylon-service-endpoint-artifacts", *got.Type)
+	require.Equal(t, "https://artifacts.babylonhealth.com", *got.Url)
+	require.Equal(t, &map[string]string{"repository": "releases"}, got.Data)
+	require.Equal(t, &serviceendpoint.EndpointAuthorization{
+		Parameters: &map[string]string{"username": "deployer", "password": "secret"},
+		Scheme:     converter.String("UsernamePassword"),
+	}, got.Authorization)
+}
+
+// The confidential parameters are memoized, their values are not returned by Azure DevOps
+func Test_flattenServiceEndpointCustom(t *testing.T) {
+	resourceData := schema.TestResourceDataRaw(t, ResourceServiceEndpointCustom().Schema, map[string]interface{}{
+		"project_id":                      testProjectID.String(),
+		"service_endpoint_name":           "artifacts",
+		"type":                            "babylon-service-endpoint-artifacts",
+		"url":                             "https://artifacts.babylonhealth.com",
+		"authorization_parameters":        map[string]interface{}{"username": "deployer"},
+		"secret_authorization_parameters": map[string]interface{}{"password": "secret", "token": "other-secret"},
+		"secret_authorization_parameters_vault_ref": map[string]interface{}{"apiKey": "secret/data/azdo#key"},
+	})
+
+	require.NoError(t, flattenServiceEndpointCustom(resourceData, &serviceendpoint.ServiceEndpoint{
+		Id:   converter.UUID("1ceae7ff-565c-4cdf-9214-6e2246cba764"),
+		Name: converter.String("artifacts"),
+		Type: converter.String("babylon-service-endpoint-artifacts"),
+		Url:  converter.String("https://artifacts.babylonhealth.com"),
+		Data: &map[string]string{"repository": "releases"},
+		Authorization: &serviceendpoint.EndpointAuthorization{
+			Parameters: &map[string]string{"username": "deployer", "apiKey": ""},
+			Scheme:     converter.String("UsernamePassword"),
+		},
+	}, &testProjectID))
+
+	require.Equal(t, map[string]interface{}{"repository": "releases"}, resourceData.Get("data"))
+	require.Equal(t, "UsernamePassword", resourceData.Get("authorization_scheme"))
+	require.Equal(t, map[string]interface{}{"username": "deployer"}, resourceData.Get("authorization_parameters"))
+	require.Equal(t, map[string]interface{}{"password": "", "token": ""}, resourceData.Get("secret_authorization_parameters"))
+
+	memos := resourceData.Get("secret_authorization_parameters_hash").(map[string]interface{})
+	require.Len(t, memos, 2)
+	require.NotEmpty(t, memos["password"])
+	require.NotEmpty(t, memos["token"])
+}
+
+func Test_customizeDiffCustomAuthorizationParameters(t *testing.T) {
+	config := map[string]interface{}{
+		"project_id":                      testProjectID.String(),
+		"service_endpoint_name":           "artifacts",
+		"type":                            "babylon-service-endpoint-artifacts",
+		"url":                             "https://artifacts.babylonhealth.com",
+		"authorization_parameters":        map[string]interface{}{"username": "deployer"},
+		"secret_authorization_parameters": map[string]interface{}{"password": "secret"},
+	}
+	_, err := ResourceServiceEndpointCustom().Diff(context.Background(), nil, terraform.NewResourceConfigRaw(config), nil)
+	require.NoError(t, err)
+
+	config["secret_authorization_parameters"] = map[string]interface{}{"username": "secret"}
+	_, err = ResourceServiceEndpointCustom().Diff(context.Background(), nil, terraform.NewResourceConfigRaw(config), nil)
+	require.EqualError(t, err, "the authorization parameter username is set in both authorization_parameters and secret_authorization_parameters")
+
+	config["secret_authorization_parameters"] = map[string]interface{}{"password": "secret"}
+	config["secret_authorization_parameters_vault_ref"] = map[string]interface{}{"password": "secret/data/azdo#password"}
+	_, err = ResourceServiceEndpointCustom().Diff(context.Background(), nil, terraform.NewResourceConfigRaw(config), nil)
+	require.EqualError(t, err, "the authorization parameter password is set in both secret_authorization_parameters and secret_authorization_parameters_vault_ref")
+}
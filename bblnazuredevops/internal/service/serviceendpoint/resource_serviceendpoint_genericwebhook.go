@@ -1,16 +1,42 @@
 package serviceendpoint
 
 import (
+	"context"
+	"fmt"
+	"regexp"
+
 	"github.com/babylonhealth/terraform-provider-bblnazuredevops/bblnazuredevops/internal/utils/converter"
 	"github.com/babylonhealth/terraform-provider-bblnazuredevops/bblnazuredevops/internal/utils/tfhelper"
 	"github.com/google/uuid"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/customdiff"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
 	"github.com/microsoft/azure-devops-go-api/azuredevops/v6/serviceendpoint"
 )
 
+const GENERIC_SERVICE_CONNECTION_TYPE string = "generic"
+
+// The authorization schemes of the generic webhook service endpoints
+const (
+	GENERIC_WEBHOOK_SCHEME_USERNAME_PASSWORD string = "UsernamePassword"
+	GENERIC_WEBHOOK_SCHEME_TOKEN             string = "Token"
+	GENERIC_WEBHOOK_SCHEME_API_KEY           string = "ApiKey"
+	GENERIC_WEBHOOK_SCHEME_NONE              string = "None"
+)
+
+// httpHeaderNameRegexp matches the names of HTTP headers, see the token rule of RFC 7230
+var httpHeaderNameRegexp = regexp.MustCompile("^[!#$%&'*+\\-.^_`|~0-9A-Za-z]+$")
+
+// genericWebhookVaultRefs maps the authorization parameters read from Vault to the secret setting them
+var genericWebhookVaultRefs = vaultRefsOf(map[string]string{
+	"password": "password",
+	"apitoken": "token.0.value",
+	"apiKey":   "api_key.0.value",
+})
+
 // ResourceServiceEndpointGenericWebhook schema and implementation for docker registry service endpoint resource
 func ResourceServiceEndpointGenericWebhook() *schema.Resource {
-	r := genBaseServiceEndpointResource(flattenServiceEndpointGenericWebhook, expandServiceEndpointGenericWebhook)
+	r := genBaseServiceEndpointResource(GENERIC_SERVICE_CONNECTION_TYPE, flattenServiceEndpointGenericWebhook, expandServiceEndpointGenericWebhook, genericWebhookInputs, genericWebhookVaultRefs)
 	r.Schema["url"] = &schema.Schema{
 		Type:        schema.TypeString,
 		Required:    true,
@@ -30,36 +56,229 @@ func ResourceServiceEndpointGenericWebhook() *schema.Resource {
 		Description:      "The Password for the endpoint",
 		Sensitive:        true,
 		DiffSuppressFunc: tfhelper.DiffFuncSuppressSecretChanged,
+		ConflictsWith:    []string{"password_wo", "password_vault_ref"},
 	}
+	for key, value := range tfhelper.GenerateWriteOnlySecretSchema("password") {
+		r.Schema[key] = value
+	}
+	vaultRefKey, vaultRefSchema := tfhelper.GenerateVaultRefSchema("password")
+	r.Schema[vaultRefKey] = vaultRefSchema
 	secretHashKey, secretHashSchema := tfhelper.GenerateSecreteMemoSchema("password")
 	r.Schema[secretHashKey] = secretHashSchema
+
+	// the UsernamePassword authorization scheme is configured by the attributes above and used when no block of
+	// another scheme is set, the blocks conflict with each other and with the password
+	r.Schema["token"] = &schema.Schema{
+		Type:          schema.TypeList,
+		Optional:      true,
+		MaxItems:      1,
+		ConflictsWith: genericWebhookSchemeConflicts("token"),
+		Elem:          &schema.Resource{Schema: genGenericWebhookSecretSchema("token", "The bearer token for the endpoint")},
+		Description:   "Calls the webhook with a bearer token, the Token authorization scheme",
+	}
+
+	apiKeySchema := genGenericWebhookSecretSchema("api_key", "The API key for the endpoint")
+	apiKeySchema["header"] = &schema.Schema{
+		Type:         schema.TypeString,
+		Required:     true,
+		ValidateFunc: validation.StringMatch(httpHeaderNameRegexp, "expected the name of an HTTP header"),
+		Description:  "The HTTP header the API key is sent in",
+	}
+	r.Schema["api_key"] = &schema.Schema{
+		Type:          schema.TypeList,
+		Optional:      true,
+		MaxItems:      1,
+		ConflictsWith: genericWebhookSchemeConflicts("api_key"),
+		Elem:          &schema.Resource{Schema: apiKeySchema},
+		Description:   "Calls the webhook with an API key header, the ApiKey authorization scheme",
+	}
+
+	r.Schema["none"] = &schema.Schema{
+		Type:          schema.TypeList,
+		Optional:      true,
+		MaxItems:      1,
+		ConflictsWith: genericWebhookSchemeConflicts("none"),
+		Elem:          &schema.Resource{Schema: map[string]*schema.Schema{}},
+		Description:   "Calls the webhook without authorization, the None authorization scheme",
+	}
+
+	r.SchemaVersion = 1
+	r.StateUpgraders = []schema.StateUpgrader{tfhelper.SecretMemoStateUpgrader(r, "password")}
+	r.CustomizeDiff = customdiff.All(r.CustomizeDiff, customizeDiffGenericWebhookScheme)
 	return r
 }
 
+// genGenericWebhookSecretSchema returns the schema of the secret `value` of the block of an authorization scheme,
+// which is set exactly once, either in the state, write-only or read from Vault
+func genGenericWebhookSecretSchema(blockKey string, description string) map[string]*schema.Schema {
+	secretKey := blockKey + ".0.value"
+	out := map[string]*schema.Schema{
+		"value": {
+			Type:             schema.TypeString,
+			Optional:         true,
+			Description:      description,
+			Sensitive:        true,
+			DiffSuppressFunc: tfhelper.DiffFuncSuppressSecretChanged,
+			ExactlyOneOf:     []string{secretKey, secretKey + "_wo", secretKey + "_vault_ref"},
+		},
+	}
+	for key, value := range tfhelper.GenerateWriteOnlySecretSchema(secretKey) {
+		out[key] = value
+	}
+	vaultRefKey, vaultRefSchema := tfhelper.GenerateVaultRefSchema(secretKey)
+	out[vaultRefKey] = vaultRefSchema
+	secretHashKey, secretHashSchema := tfhelper.GenerateSecreteMemoSchema(secretKey)
+	out[secretHashKey] = secretHashSchema
+	return out
+}
+
+// genericWebhookSchemeConflicts returns the attributes the block of an authorization scheme conflicts with, the
+// blocks of the other schemes and the password. The password read from the environment is ignored by them.
+func genericWebhookSchemeConflicts(blockKey string) []string {
+	conflicts := []string{"password", "password_wo", "password_vault_ref"}
+	for _, key := range []string{"token", "api_key", "none"} {
+		if key != blockKey {
+			conflicts = append(conflicts, key)
+		}
+	}
+	return conflicts
+}
+
+// customizeDiffGenericWebhookScheme requires a password when no block selects another authorization scheme. The
+// blocks of the schemes conflict with each other and with the password, so exactly one scheme is configured. The
+// password is checked here, rather than by ExactlyOneOf, as it may be read from the environment.
+func customizeDiffGenericWebhookScheme(_ context.Context, d *schema.ResourceDiff, _ interface{}) error {
+	for _, key := range []string{"token", "api_key", "none"} {
+		if !d.NewValueKnown(key) {
+			return nil
+		}
+	}
+	if genericWebhookAuthorizationScheme(d) != GENERIC_WEBHOOK_SCHEME_USERNAME_PASSWORD {
+		return nil
+	}
+	if plannedSecretInput(d, "password", "password_version", "password_vault_ref") == "" {
+		return fmt.Errorf("one of password, password_wo, password_vault_ref, token, api_key or none is required")
+	}
+	return nil
+}
+
+// genericWebhookAuthorizationScheme returns the authorization scheme selected by the blocks of the schemes
+func genericWebhookAuthorizationScheme(d interface{ Get(string) interface{} }) string {
+	switch {
+	case len(d.Get("token").([]interface{})) > 0:
+		return GENERIC_WEBHOOK_SCHEME_TOKEN
+	case len(d.Get("api_key").([]interface{})) > 0:
+		return GENERIC_WEBHOOK_SCHEME_API_KEY
+	case len(d.Get("none").([]interface{})) > 0:
+		return GENERIC_WEBHOOK_SCHEME_NONE
+	}
+	return GENERIC_WEBHOOK_SCHEME_USERNAME_PASSWORD
+}
+
+// genericWebhookInputs returns the authorization scheme, the data and the authorization parameters planned for the
+// service endpoint, as expandServiceEndpointGenericWebhook sends them
+func genericWebhookInputs(d *schema.ResourceDiff) (string, map[string]string, map[string]string, bool) {
+	for _, key := range []string{"token", "api_key", "none"} {
+		if !d.NewValueKnown(key) {
+			return "", nil, nil, false
+		}
+	}
+	scheme := genericWebhookAuthorizationScheme(d)
+
+	parameters := map[string]string{}
+	switch scheme {
+	case GENERIC_WEBHOOK_SCHEME_USERNAME_PASSWORD:
+		parameters["username"] = plannedInput(d, "username")
+		parameters["password"] = plannedSecretInput(d, "password", "password_version", "password_vault_ref")
+	case GENERIC_WEBHOOK_SCHEME_TOKEN:
+		parameters["apitoken"] = plannedSecretInput(d, "token.0.value", "token.0.value_version", "token.0.value_vault_ref")
+	case GENERIC_WEBHOOK_SCHEME_API_KEY:
+		parameters["headerName"] = plannedInput(d, "api_key.0.header")
+		parameters["apiKey"] = plannedSecretInput(d, "api_key.0.value", "api_key.0.value_version", "api_key.0.value_vault_ref")
+	}
+	return scheme, map[string]string{}, parameters, true
+}
+
 // Convert internal Terraform data structure to an AzDO data structure
 func expandServiceEndpointGenericWebhook(d *schema.ResourceData) (*serviceendpoint.ServiceEndpoint, *uuid.UUID, error) {
 	serviceEndpoint, projectID := doBaseExpansion(d)
+
+	scheme := genericWebhookAuthorizationScheme(d)
+	parameters := map[string]string{}
+	switch scheme {
+	case GENERIC_WEBHOOK_SCHEME_USERNAME_PASSWORD:
+		parameters["username"] = d.Get("username").(string)
+		parameters["password"] = tfhelper.GetSecret(d, "password")
+	case GENERIC_WEBHOOK_SCHEME_TOKEN:
+		parameters["apitoken"] = tfhelper.GetSecret(d, "token.0.value")
+	case GENERIC_WEBHOOK_SCHEME_API_KEY:
+		parameters["headerName"] = d.Get("api_key.0.header").(string)
+		parameters["apiKey"] = tfhelper.GetSecret(d, "api_key.0.value")
+	}
 	serviceEndpoint.Authorization = &serviceendpoint.EndpointAuthorization{
-		Parameters: &map[string]string{
-			"username": d.Get("username").(string),
-			"password": d.Get("password").(string),
-		},
-		Scheme: converter.String("UsernamePassword"),
+		Parameters: &parameters,
+		Scheme:     converter.String(scheme),
 	}
 	serviceEndpoint.Data = &map[string]string{}
-	serviceEndpoint.Type = converter.String("generic")
+	serviceEndpoint.Type = converter.String(GENERIC_SERVICE_CONNECTION_TYPE)
 	urlString := d.Get("url").(string)
 	serviceEndpoint.Url = &urlString
 	return serviceEndpoint, projectID, nil
 }
 
 // Convert AzDO data structure to internal Terraform data structure
-func flattenServiceEndpointGenericWebhook(d *schema.ResourceData, serviceEndpoint *serviceendpoint.ServiceEndpoint, projectID *uuid.UUID) {
+func flattenServiceEndpointGenericWebhook(d *schema.ResourceData, serviceEndpoint *serviceendpoint.ServiceEndpoint, projectID *uuid.UUID) error {
 	doBaseFlattening(d, serviceEndpoint, projectID)
 	tfhelper.HelpFlattenSecret(d, "password")
 
+	scheme := converter.ToString(serviceEndpoint.Authorization.Scheme, GENERIC_WEBHOOK_SCHEME_USERNAME_PASSWORD)
+	parameters := map[string]string{}
+	if serviceEndpoint.Authorization.Parameters != nil {
+		parameters = *serviceEndpoint.Authorization.Parameters
+	}
+
 	// ToDo: test with CLI tool if behavior differs from env var and file input password
-	d.Set("username", (*serviceEndpoint.Authorization.Parameters)["username"])
-	d.Set("password", (*serviceEndpoint.Authorization.Parameters)["password"])
+	blocks := map[string][]interface{}{"token": nil, "api_key": nil, "none": nil}
+	switch scheme {
+	case GENERIC_WEBHOOK_SCHEME_USERNAME_PASSWORD:
+		d.Set("username", parameters["username"])
+		if !tfhelper.IsSecretKeptOutOfState(d, "password") {
+			d.Set("password", parameters["password"])
+		}
+	case GENERIC_WEBHOOK_SCHEME_TOKEN:
+		blocks["token"] = []interface{}{flattenGenericWebhookSecret(d, "token", parameters["apitoken"])}
+	case GENERIC_WEBHOOK_SCHEME_API_KEY:
+		block := flattenGenericWebhookSecret(d, "api_key", parameters["apiKey"])
+		block["header"] = parameters["headerName"]
+		blocks["api_key"] = []interface{}{block}
+	case GENERIC_WEBHOOK_SCHEME_NONE:
+		blocks["none"] = []interface{}{map[string]interface{}{}}
+	}
+	for key, value := range blocks {
+		d.Set(key, value)
+	}
 	d.Set("url", *serviceEndpoint.Url)
+	return nil
+}
+
+// flattenGenericWebhookSecret returns the block of an authorization scheme with its secret memoized, see
+// tfhelper.HelpFlattenSecret. Write-only secrets and secrets read from Vault are cleared from it instead, together
+// with their memo.
+func flattenGenericWebhookSecret(d *schema.ResourceData, blockKey string, secret string) map[string]interface{} {
+	secretKey := blockKey + ".0.value"
+	block := map[string]interface{}{
+		"value":           "",
+		"value_hash":      "",
+		"value_version":   d.Get(secretKey + "_version"),
+		"value_vault_ref": d.Get(secretKey + "_vault_ref"),
+	}
+	if tfhelper.IsSecretKeptOutOfState(d, secretKey) {
+		return block
+	}
+	block["value_hash"], _ = tfhelper.HelpFlattenSecretNested(d, blockKey, map[string]interface{}{
+		"value":      d.Get(secretKey),
+		"value_hash": d.Get(secretKey + "_hash"),
+	}, "value")
+	block["value"] = secret
+	return block
 }
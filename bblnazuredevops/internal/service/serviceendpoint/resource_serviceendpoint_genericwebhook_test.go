@@ -1,15 +1,20 @@
 package serviceendpoint
 
 import (
+	"context"
 	"fmt"
 	"github.com/babylonhealth/terraform-provider-bblnazuredevops/bblnazuredevops/internal/utils/converter"
 	"github.com/babylonhealth/terraform-provider-bblnazuredevops/bblnazuredevops/internal/utils/tfhelper"
 	"github.com/go-test/deep"
+	"github.com/golang/mock/gomock"
 	"github.com/google/uuid"
 	"github.com/hashicorp/go-multierror"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
+	"github.com/microsoft/azure-devops-go-api/azuredevops/v6/forminput"
 	"github.com/microsoft/azure-devops-go-api/azuredevops/v6/serviceendpoint"
+	"github.com/stretchr/testify/require"
 	"golang.org/x/crypto/bcrypt"
 	"os"
 	"testing"
@@ -42,12 +47,15 @@ func TestResourceServiceEndpointGenericWebhook(t *testing.T) {
 					Description:      "The Password for the endpoint",
 					Sensitive:        true,
 					DiffSuppressFunc: tfhelper.DiffFuncSuppressSecretChanged,
+					ConflictsWith:    []string{"password_wo", "password_vault_ref"},
 				},
 				"project_id": {
 					Type:         schema.TypeString,
-					Required:     true,
+					Optional:     true,
+					Computed:     true,
 					ForceNew:     true,
 					ValidateFunc: validation.StringIsNotWhiteSpace,
+					Description:  "The name or ID of the project. Defaults to the default_project of the provider.",
 				},
 				"service_endpoint_name": {
 					Type:         schema.TypeString,
@@ -61,6 +69,67 @@ func TestResourceServiceEndpointGenericWebhook(t *testing.T) {
 					Default:      "Managed by Terraform",
 					ValidateFunc: validation.StringIsNotWhiteSpace,
 				},
+				"shared_project_ids": {
+					Type:     schema.TypeSet,
+					Optional: true,
+					Computed: true,
+					Elem: &schema.Schema{
+						Type:         schema.TypeString,
+						ValidateFunc: validation.StringIsNotWhiteSpace,
+					},
+					Description: "The names or IDs of the other projects the service endpoint is shared with, the IDs are stored in the state.",
+				},
+				"shared_project_override": {
+					Type:     schema.TypeSet,
+					Optional: true,
+					Computed: true,
+					Elem: &schema.Resource{
+						Schema: map[string]*schema.Schema{
+							"project_id": {
+								Type:         schema.TypeString,
+								Required:     true,
+								ValidateFunc: validation.StringIsNotWhiteSpace,
+								Description:  "The name or ID of a project of shared_project_ids.",
+							},
+							"service_endpoint_name": {
+								Type:         schema.TypeString,
+								Optional:     true,
+								ValidateFunc: validation.StringIsNotWhiteSpace,
+								Description:  "The name of the service endpoint in the project, when it differs from service_endpoint_name.",
+							},
+							"description": {
+								Type:         schema.TypeString,
+								Optional:     true,
+								ValidateFunc: validation.StringLenBetween(0, 1024),
+								Description:  "The description of the service endpoint in the project, when it differs from description.",
+							},
+						},
+					},
+					Description: "The name and description of the service endpoint in some of the projects of shared_project_ids.",
+				},
+				"verify": {
+					Type:     schema.TypeList,
+					Optional: true,
+					MaxItems: 1,
+					Elem: &schema.Resource{
+						Schema: map[string]*schema.Schema{
+							"data_source": {
+								Type:         schema.TypeString,
+								Optional:     true,
+								Default:      "TestConnection",
+								ValidateFunc: validation.StringIsNotWhiteSpace,
+								Description:  "The data source of the service endpoint type executed to verify the service endpoint.",
+							},
+							"rollback": {
+								Type:        schema.TypeBool,
+								Optional:    true,
+								Default:     false,
+								Description: "Whether the service endpoint is deleted when it fails the verification after being created.",
+							},
+						},
+					},
+					Description: "Verifies the service endpoint against the service it connects to after it is created or updated, the apply fails when the verification fails.",
+				},
 				"authorization": {
 					Type:         schema.TypeMap,
 					Optional:     true,
@@ -70,13 +139,70 @@ func TestResourceServiceEndpointGenericWebhook(t *testing.T) {
 						Type: schema.TypeString,
 					},
 				},
+				"password_wo": {
+					Type:          schema.TypeString,
+					Optional:      true,
+					WriteOnly:     true,
+					Sensitive:     true,
+					ConflictsWith: []string{"password"},
+					RequiredWith:  []string{"password_version"},
+					Description:   "The write-only variant of the attribute 'password', it is never stored in the state",
+				},
+				"password_version": {
+					Type:         schema.TypeInt,
+					Optional:     true,
+					RequiredWith: []string{"password_wo"},
+					Description:  "The version of the attribute 'password_wo', change it to update the secret",
+				},
+				"password_vault_ref": {
+					Type:          schema.TypeString,
+					Optional:      true,
+					ConflictsWith: []string{"password", "password_wo"},
+					Description:   "The Vault secret holding the value of the attribute 'password', as <path>#<key>. It is read from the vault of the provider when the resource is applied",
+				},
 				"password_hash": {
 					Type:        schema.TypeString,
 					Computed:    true,
 					Default:     nil,
-					Description: fmt.Sprintf("A bcrypted hash of the attribute '%s'", "password"),
+					Description: fmt.Sprintf("A memo of the attribute '%s', computed by the secret_memo of the provider", "password"),
 					Sensitive:   true,
 				},
+				"token": {
+					Type:          schema.TypeList,
+					Optional:      true,
+					MaxItems:      1,
+					ConflictsWith: []string{"password", "password_wo", "password_vault_ref", "api_key", "none"},
+					Elem: &schema.Resource{
+						Schema: testGenericWebhookSecretSchema("token", "The bearer token for the endpoint"),
+					},
+					Description: "Calls the webhook with a bearer token, the Token authorization scheme",
+				},
+				"api_key": {
+					Type:          schema.TypeList,
+					Optional:      true,
+					MaxItems:      1,
+					ConflictsWith: []string{"password", "password_wo", "password_vault_ref", "token", "none"},
+					Elem: &schema.Resource{
+						Schema: func() map[string]*schema.Schema {
+							out := testGenericWebhookSecretSchema("api_key", "The API key for the endpoint")
+							out["header"] = &schema.Schema{
+								Type:        schema.TypeString,
+								Required:    true,
+								Description: "The HTTP header the API key is sent in",
+							}
+							return out
+						}(),
+					},
+					Description: "Calls the webhook with an API key header, the ApiKey authorization scheme",
+				},
+				"none": {
+					Type:          schema.TypeList,
+					Optional:      true,
+					MaxItems:      1,
+					ConflictsWith: []string{"password", "password_wo", "password_vault_ref", "token", "api_key"},
+					Elem:          &schema.Resource{Schema: map[string]*schema.Schema{}},
+					Description:   "Calls the webhook without authorization, the None authorization scheme",
+				},
 			},
 		},
 	}
@@ -93,6 +219,49 @@ func TestResourceServiceEndpointGenericWebhook(t *testing.T) {
 	}
 }
 
+// testGenericWebhookSecretSchema returns the expected schema of the secret of the block of an authorization scheme
+func testGenericWebhookSecretSchema(blockKey string, description string) map[string]*schema.Schema {
+	secretKey := blockKey + ".0.value"
+	return map[string]*schema.Schema{
+		"value": {
+			Type:             schema.TypeString,
+			Optional:         true,
+			Description:      description,
+			Sensitive:        true,
+			DiffSuppressFunc: tfhelper.DiffFuncSuppressSecretChanged,
+			ExactlyOneOf:     []string{secretKey, secretKey + "_wo", secretKey + "_vault_ref"},
+		},
+		"value_wo": {
+			Type:          schema.TypeString,
+			Optional:      true,
+			WriteOnly:     true,
+			Sensitive:     true,
+			ConflictsWith: []string{secretKey},
+			RequiredWith:  []string{secretKey + "_version"},
+			Description:   fmt.Sprintf("The write-only variant of the attribute '%s', it is never stored in the state", secretKey),
+		},
+		"value_version": {
+			Type:         schema.TypeInt,
+			Optional:     true,
+			RequiredWith: []string{secretKey + "_wo"},
+			Description:  fmt.Sprintf("The version of the attribute '%s_wo', change it to update the secret", secretKey),
+		},
+		"value_vault_ref": {
+			Type:          schema.TypeString,
+			Optional:      true,
+			ConflictsWith: []string{secretKey, secretKey + "_wo"},
+			Description:   fmt.Sprintf("The Vault secret holding the value of the attribute '%s', as <path>#<key>. It is read from the vault of the provider when the resource is applied", secretKey),
+		},
+		"value_hash": {
+			Type:        schema.TypeString,
+			Computed:    true,
+			Default:     nil,
+			Description: fmt.Sprintf("A memo of the attribute '%s', computed by the secret_memo of the provider", secretKey),
+			Sensitive:   true,
+		},
+	}
+}
+
 func Test_expandServiceEndpointGenericWebhook(t *testing.T) {
 	type args struct {
 		username string
@@ -218,15 +387,20 @@ func Test_flattenServiceEndpointGenericWebhook(t *testing.T) {
 				projectID: converter.UUID("3c49c3b6-a06d-424d-a6b6-0cd375ee9261"),
 			},
 			expected: map[string]string{
-				"id":                    "1ceae7ff-565c-4cdf-9214-6e2246cba764",
-				"authorization.%":       "1",
-				"authorization.scheme":  "UsernamePassword",
-				"description":           "",
-				"password":              "password1",
-				"project_id":            "3c49c3b6-a06d-424d-a6b6-0cd375ee9261",
-				"service_endpoint_name": "",
-				"url":                   "http://http.cat",
-				"username":              "user1",
+				"id":                        "1ceae7ff-565c-4cdf-9214-6e2246cba764",
+				"authorization.%":           "1",
+				"authorization.scheme":      "UsernamePassword",
+				"shared_project_ids.#":      "0",
+				"shared_project_override.#": "0",
+				"api_key.#":                 "0",
+				"description":               "",
+				"none.#":                    "0",
+				"password":                  "password1",
+				"project_id":                "3c49c3b6-a06d-424d-a6b6-0cd375ee9261",
+				"service_endpoint_name":     "",
+				"token.#":                   "0",
+				"url":                       "http://http.cat",
+				"username":                  "user1",
 			},
 		},
 	}
@@ -240,7 +414,7 @@ func Test_flattenServiceEndpointGenericWebhook(t *testing.T) {
 
 			resourceData := schema.TestResourceDataRaw(t, r.Schema, nil)
 
-			flattenServiceEndpointGenericWebhook(resourceData, tt.args.serviceEndpoint, tt.args.projectID)
+			require.NoError(t, flattenServiceEndpointGenericWebhook(resourceData, tt.args.serviceEndpoint, tt.args.projectID))
 			state := resourceData.State()
 
 			err := bcrypt.CompareHashAndPassword([]byte(state.Attributes["password_hash"]), []byte("env_input"))
@@ -256,3 +430,244 @@ func Test_flattenServiceEndpointGenericWebhook(t *testing.T) {
 		})
 	}
 }
+
+func Test_expandServiceEndpointGenericWebhook_AuthorizationSchemes(t *testing.T) {
+	tests := []struct {
+		name   string
+		config map[string]interface{}
+		want   *serviceendpoint.EndpointAuthorization
+	}{
+		{
+			name:   "token",
+			config: map[string]interface{}{"token": []interface{}{map[string]interface{}{"value": "secret-token"}}},
+			want: &serviceendpoint.EndpointAuthorization{
+				Parameters: &map[string]string{"apitoken": "secret-token"},
+				Scheme:     converter.String("Token"),
+			},
+		},
+		{
+			name:   "api key",
+			config: map[string]interface{}{"api_key": []interface{}{map[string]interface{}{"value": "secret-key", "header": "X-Api-Key"}}},
+			want: &serviceendpoint.EndpointAuthorization{
+				Parameters: &map[string]string{"apiKey": "secret-key", "headerName": "X-Api-Key"},
+				Scheme:     converter.String("ApiKey"),
+			},
+		},
+		{
+			name:   "none",
+			config: map[string]interface{}{"none": []interface{}{map[string]interface{}{}}},
+			want: &serviceendpoint.EndpointAuthorization{
+				Parameters: &map[string]string{},
+				Scheme:     converter.String("None"),
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.config["project_id"] = testProjectID.String()
+			tt.config["url"] = "http://http.cat"
+			resourceData := schema.TestResourceDataRaw(t, ResourceServiceEndpointGenericWebhook().Schema, tt.config)
+
+			got, _, err := expandServiceEndpointGenericWebhook(resourceData)
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got.Authorization)
+		})
+	}
+}
+
+// The token and the API key are memoized, and read back with the header the API key is sent in
+func Test_flattenServiceEndpointGenericWebhook_ApiKey(t *testing.T) {
+	resourceData := schema.TestResourceDataRaw(t, ResourceServiceEndpointGenericWebhook().Schema, map[string]interface{}{
+		"project_id": testProjectID.String(),
+		"url":        "http://http.cat",
+		"api_key":    []interface{}{map[string]interface{}{"value": "secret-key", "header": "X-Api-Key"}},
+	})
+
+	require.NoError(t, flattenServiceEndpointGenericWebhook(resourceData, &serviceendpoint.ServiceEndpoint{
+		Id:  converter.UUID("1ceae7ff-565c-4cdf-9214-6e2246cba764"),
+		Url: converter.String("http://http.cat"),
+		Authorization: &serviceendpoint.EndpointAuthorization{
+			Parameters: &map[string]string{"headerName": "X-Auth-Key"},
+			Scheme:     converter.String("ApiKey"),
+		},
+	}, &testProjectID))
+
+	require.Equal(t, "X-Auth-Key", resourceData.Get("api_key.0.header"))
+	require.Equal(t, "", resourceData.Get("api_key.0.value"))
+	require.NotEmpty(t, resourceData.Get("api_key.0.value_hash"))
+	require.Empty(t, resourceData.Get("token"))
+}
+
+// Exactly one secret is set in the block of an authorization scheme, and the blocks conflict with each other and
+// with the password of the UsernamePassword scheme
+func TestResourceServiceEndpointGenericWebhook_Validate(t *testing.T) {
+	require.NoError(t, ResourceServiceEndpointGenericWebhook().InternalValidate(nil, true))
+
+	tests := []struct {
+		name    string
+		config  map[string]interface{}
+		wantErr bool
+	}{
+		{
+			name:   "username and password",
+			config: map[string]interface{}{"username": "user", "password": "password"},
+		},
+		{
+			name:   "token",
+			config: map[string]interface{}{"token": []interface{}{map[string]interface{}{"value": "secret-token"}}},
+		},
+		{
+			name:   "write-only token",
+			config: map[string]interface{}{"token": []interface{}{map[string]interface{}{"value_wo": "secret-token", "value_version": 1}}},
+		},
+		{
+			name:   "api key",
+			config: map[string]interface{}{"api_key": []interface{}{map[string]interface{}{"value": "secret-key", "header": "X-Api-Key"}}},
+		},
+		{
+			name:   "none",
+			config: map[string]interface{}{"none": []interface{}{map[string]interface{}{}}},
+		},
+		{
+			name:    "missing token",
+			config:  map[string]interface{}{"token": []interface{}{map[string]interface{}{}}},
+			wantErr: true,
+		},
+		{
+			name:    "token and write-only token",
+			config:  map[string]interface{}{"token": []interface{}{map[string]interface{}{"value": "secret-token", "value_wo": "secret-token", "value_version": 1}}},
+			wantErr: true,
+		},
+		{
+			name:    "missing api key header",
+			config:  map[string]interface{}{"api_key": []interface{}{map[string]interface{}{"value": "secret-key"}}},
+			wantErr: true,
+		},
+		{
+			name:    "invalid api key header",
+			config:  map[string]interface{}{"api_key": []interface{}{map[string]interface{}{"value": "secret-key", "header": "X Api Key"}}},
+			wantErr: true,
+		},
+		{
+			name:    "password and another scheme",
+			config:  map[string]interface{}{"password": "password", "none": []interface{}{map[string]interface{}{}}},
+			wantErr: true,
+		},
+		{
+			name: "two schemes",
+			config: map[string]interface{}{
+				"token": []interface{}{map[string]interface{}{"value": "secret-token"}},
+				"none":  []interface{}{map[string]interface{}{}},
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.config["service_endpoint_name"] = "webhook"
+			tt.config["url"] = "http://http.cat"
+			diags := ResourceServiceEndpointGenericWebhook().Validate(terraform.NewResourceConfigRaw(tt.config))
+			require.Equal(t, tt.wantErr, diags.HasError(), "unexpected diagnostics: %v", diags)
+		})
+	}
+}
+
+// Exactly one authorization scheme is configured, the password may be read from the environment
+func Test_customizeDiffGenericWebhookScheme(t *testing.T) {
+	tests := []struct {
+		name     string
+		config   map[string]interface{}
+		password string
+		wantErr  string
+	}{
+		{
+			name:   "password",
+			config: map[string]interface{}{"username": "user", "password": "password"},
+		},
+		{
+			name:     "password read from the environment",
+			config:   map[string]interface{}{"username": "user"},
+			password: "password",
+		},
+		{
+			name:   "password read from Vault",
+			config: map[string]interface{}{"username": "user", "password_vault_ref": "secret/data/azdo#password"},
+		},
+		{
+			name:   "none",
+			config: map[string]interface{}{"none": []interface{}{map[string]interface{}{}}},
+		},
+		{
+			name:    "no scheme",
+			config:  map[string]interface{}{"username": "user"},
+			wantErr: "one of password, password_wo, password_vault_ref, token, api_key or none is required",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("AZDO_GENERIC_WEBHOOK_PASSWORD", tt.password)
+			tt.config["project_id"] = testProjectID.String()
+			tt.config["service_endpoint_name"] = "webhook"
+			tt.config["url"] = "http://http.cat"
+			_, err := ResourceServiceEndpointGenericWebhook().Diff(context.Background(), nil, terraform.NewResourceConfigRaw(tt.config), nil)
+			if tt.wantErr == "" {
+				require.NoError(t, err)
+			} else {
+				require.EqualError(t, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// The authorization of the service endpoint is checked against the inputs of its type
+func Test_customizeDiffGenericWebhookTypeInputs(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	clients := newTestEndpointTypeClients(ctrl, serviceendpoint.ServiceEndpointType{
+		Name: converter.String(GENERIC_SERVICE_CONNECTION_TYPE),
+		AuthenticationSchemes: &[]serviceendpoint.ServiceEndpointAuthenticationScheme{
+			{
+				Scheme:           converter.String("UsernamePassword"),
+				InputDescriptors: &[]forminput.InputDescriptor{newTestInput("username", false), newTestInput("password", true)},
+			},
+			{
+				Scheme:           converter.String("Token"),
+				InputDescriptors: &[]forminput.InputDescriptor{newTestInput("apitoken", true)},
+			},
+		},
+	})
+
+	tests := []struct {
+		name    string
+		config  map[string]interface{}
+		wantErr string
+	}{
+		{
+			name:   "username and password",
+			config: map[string]interface{}{"username": "user", "password": "password"},
+		},
+		{
+			name:   "write-only token",
+			config: map[string]interface{}{"token": []interface{}{map[string]interface{}{"value_wo": "secret-token", "value_version": 1}}},
+		},
+		{
+			name:    "scheme the type does not support",
+			config:  map[string]interface{}{"api_key": []interface{}{map[string]interface{}{"value": "secret-key", "header": "X-Api-Key"}}},
+			wantErr: "the authorization scheme ApiKey is not supported by the service endpoint type generic, expected one of [UsernamePassword, Token]",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.config["project_id"] = testProjectID.String()
+			tt.config["service_endpoint_name"] = "webhook"
+			tt.config["url"] = "http://http.cat"
+			_, err := ResourceServiceEndpointGenericWebhook().Diff(context.Background(), nil, terraform.NewResourceConfigRaw(tt.config), clients)
+			if tt.wantErr == "" {
+				require.NoError(t, err)
+			} else {
+				require.EqualError(t, err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,87 @@
+package serviceendpoint
+
+import (
+	"regexp"
+
+	"github.com/babylonhealth/terraform-provider-bblnazuredevops/bblnazuredevops/internal/utils/converter"
+	"github.com/babylonhealth/terraform-provider-bblnazuredevops/bblnazuredevops/internal/utils/tfhelper"
+	"github.com/google/uuid"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+	"github.com/microsoft/azure-devops-go-api/azuredevops/v6/serviceendpoint"
+)
+
+const INCOMING_WEBHOOK_SERVICE_CONNECTION_TYPE string = "incomingwebhook"
+
+var webhookNameRegexp = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+
+// incomingWebhookVaultRefs maps the authorization parameters read from Vault to the secret setting them
+var incomingWebhookVaultRefs = vaultRefsOf(map[string]string{"secret": "secret"})
+
+// ResourceServiceEndpointIncomingWebhook schema and implementation for the incoming webhook service endpoints, which
+// trigger the pipelines declaring them as webhook resources. The payloads are authenticated by their HMAC checksum.
+func ResourceServiceEndpointIncomingWebhook() *schema.Resource {
+	r := genBaseServiceEndpointResource(INCOMING_WEBHOOK_SERVICE_CONNECTION_TYPE, flattenServiceEndpointIncomingWebhook, expandServiceEndpointIncomingWebhook, nil, incomingWebhookVaultRefs)
+	r.Schema["webhook_name"] = &schema.Schema{
+		Type:         schema.TypeString,
+		Required:     true,
+		ValidateFunc: validation.StringMatch(webhookNameRegexp, "expected letters, digits, dots, dashes and underscores"),
+		Description:  "The name of the webhook, the last segment of the URL the payloads are posted to",
+	}
+	r.Schema["http_header"] = &schema.Schema{
+		Type:         schema.TypeString,
+		Required:     true,
+		ValidateFunc: validation.StringMatch(httpHeaderNameRegexp, "expected the name of an HTTP header"),
+		Description:  "The HTTP header holding the HMAC checksum of the payloads, e.g. X-Hub-Signature",
+	}
+	r.Schema["secret"] = &schema.Schema{
+		Type:             schema.TypeString,
+		Optional:         true,
+		Description:      "The secret the HMAC checksum of the payloads is computed with",
+		Sensitive:        true,
+		DiffSuppressFunc: tfhelper.DiffFuncSuppressSecretChanged,
+		ExactlyOneOf:     []string{"secret", "secret_wo", "secret_vault_ref"},
+	}
+	for key, value := range tfhelper.GenerateWriteOnlySecretSchema("secret") {
+		r.Schema[key] = value
+	}
+	vaultRefKey, vaultRefSchema := tfhelper.GenerateVaultRefSchema("secret")
+	r.Schema[vaultRefKey] = vaultRefSchema
+	secretHashKey, secretHashSchema := tfhelper.GenerateSecreteMemoSchema("secret")
+	r.Schema[secretHashKey] = secretHashSchema
+	return r
+}
+
+// Convert internal Terraform data structure to an AzDO data structure
+func expandServiceEndpointIncomingWebhook(d *schema.ResourceData) (*serviceendpoint.ServiceEndpoint, *uuid.UUID, error) {
+	serviceEndpoint, projectID := doBaseExpansion(d)
+	serviceEndpoint.Authorization = &serviceendpoint.EndpointAuthorization{
+		Parameters: &map[string]string{
+			"webhookName": d.Get("webhook_name").(string),
+			"headerName":  d.Get("http_header").(string),
+			"secret":      tfhelper.GetSecret(d, "secret"),
+		},
+		Scheme: converter.String("None"),
+	}
+	serviceEndpoint.Data = &map[string]string{}
+	serviceEndpoint.Type = converter.String(INCOMING_WEBHOOK_SERVICE_CONNECTION_TYPE)
+	// the URL of the service endpoint is the one of the organization, see defaultServiceEndpointURL
+	return serviceEndpoint, projectID, nil
+}
+
+// Convert AzDO data structure to internal Terraform data structure
+func flattenServiceEndpointIncomingWebhook(d *schema.ResourceData, serviceEndpoint *serviceendpoint.ServiceEndpoint, projectID *uuid.UUID) error {
+	doBaseFlattening(d, serviceEndpoint, projectID)
+	tfhelper.HelpFlattenSecret(d, "secret")
+
+	parameters := map[string]string{}
+	if serviceEndpoint.Authorization != nil && serviceEndpoint.Authorization.Parameters != nil {
+		parameters = *serviceEndpoint.Authorization.Parameters
+	}
+	d.Set("webhook_name", parameters["webhookName"])
+	d.Set("http_header", parameters["headerName"])
+	if !tfhelper.IsSecretKeptOutOfState(d, "secret") {
+		d.Set("secret", parameters["secret"])
+	}
+	return nil
+}
@@ -0,0 +1,114 @@
+package serviceendpoint
+
+import (
+	"testing"
+
+	"github.com/babylonhealth/terraform-provider-bblnazuredevops/bblnazuredevops/internal/client"
+	"github.com/babylonhealth/terraform-provider-bblnazuredevops/bblnazuredevops/internal/utils/converter"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
+	"github.com/microsoft/azure-devops-go-api/azuredevops/v6/serviceendpoint"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_expandServiceEndpointIncomingWebhook(t *testing.T) {
+	resourceData := schema.TestResourceDataRaw(t, ResourceServiceEndpointIncomingWebhook().Schema, map[string]interface{}{
+		"project_id":            testProjectID.String(),
+		"service_endpoint_name": "releases",
+		"webhook_name":          "releases",
+		"http_header":           "X-Hub-Signature",
+		"secret":                "hmac-secret",
+	})
+
+	got, projectID, err := expandServiceEndpointIncomingWebhook(resourceData)
+	require.NoError(t, err)
+	require.Equal(t, testProjectID, *projectID)
+	require.Equal(t, INCOMING_WEBHOOK_SERVICE_CONNECTION_TYPE, *got.Type)
+	require.Equal(t, &serviceendpoint.EndpointAuthorization{
+		Parameters: &map[string]string{
+			"webhookName": "releases",
+			"headerName":  "X-Hub-Signature",
+			"secret":      "hmac-secret",
+		},
+		Scheme: converter.String("None"),
+	}, got.Authorization)
+
+	require.Nil(t, got.Url)
+	defaultServiceEndpointURL(&client.AggregatedClient{OrganizationURL: "https://dev.azure.com/babylonhealth"}, got)
+	require.Equal(t, "https://dev.azure.com/babylonhealth", *got.Url)
+}
+
+// The secret is memoized, its value is not returned by Azure DevOps
+func Test_flattenServiceEndpointIncomingWebhook(t *testing.T) {
+	resourceData := schema.TestResourceDataRaw(t, ResourceServiceEndpointIncomingWebhook().Schema, map[string]interface{}{
+		"project_id":   testProjectID.String(),
+		"webhook_name": "releases",
+		"http_header":  "X-Hub-Signature",
+		"secret":       "hmac-secret",
+	})
+
+	require.NoError(t, flattenServiceEndpointIncomingWebhook(resourceData, &serviceendpoint.ServiceEndpoint{
+		Id:   converter.UUID("1ceae7ff-565c-4cdf-9214-6e2246cba764"),
+		Name: converter.String("releases"),
+		Type: converter.String(INCOMING_WEBHOOK_SERVICE_CONNECTION_TYPE),
+		Url:  converter.String("https://dev.azure.com/babylonhealth"),
+		Authorization: &serviceendpoint.EndpointAuthorization{
+			Parameters: &map[string]string{"webhookName": "deployments", "headerName": "X-Signature"},
+			Scheme:     converter.String("None"),
+		},
+	}, &testProjectID))
+
+	require.Equal(t, "deployments", resourceData.Get("webhook_name"))
+	require.Equal(t, "X-Signature", resourceData.Get("http_header"))
+	require.Equal(t, "", resourceData.Get("secret"))
+	require.NotEmpty(t, resourceData.Get("secret_hash"))
+}
+
+// Exactly one variant of the secret is set
+func TestResourceServiceEndpointIncomingWebhook_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  map[string]interface{}
+		wantErr bool
+	}{
+		{
+			name:   "secret",
+			config: map[string]interface{}{"secret": "hmac-secret"},
+		},
+		{
+			name:   "write-only secret",
+			config: map[string]interface{}{"secret_wo": "hmac-secret", "secret_version": 1},
+		},
+		{
+			name:   "secret read from Vault",
+			config: map[string]interface{}{"secret_vault_ref": "secret/data/azdo/webhooks#releases"},
+		},
+		{
+			name:    "no secret",
+			config:  map[string]interface{}{},
+			wantErr: true,
+		},
+		{
+			name:    "two secrets",
+			config:  map[string]interface{}{"secret": "hmac-secret", "secret_vault_ref": "secret/data/azdo/webhooks#releases"},
+			wantErr: true,
+		},
+		{
+			name:    "invalid header",
+			config:  map[string]interface{}{"secret": "hmac-secret", "http_header": "X Signature"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.config["service_endpoint_name"] = "releases"
+			tt.config["webhook_name"] = "releases"
+			if _, ok := tt.config["http_header"]; !ok {
+				tt.config["http_header"] = "X-Hub-Signature"
+			}
+
+			diags := ResourceServiceEndpointIncomingWebhook().Validate(terraform.NewResourceConfigRaw(tt.config))
+			require.Equal(t, tt.wantErr, diags.HasError(), "unexpected diagnostics: %v", diags)
+		})
+	}
+}
@@ -0,0 +1,159 @@
+package cache
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/babylonhealth/terraform-provider-bblnazuredevops/bblnazuredevops/internal/client/logging"
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+)
+
+// LoaderFunc loads the value of a key when it is not cached
+type LoaderFunc func() (interface{}, error)
+
+type entry struct {
+	value   interface{}
+	expires time.Time
+}
+
+// call tracks a load which is in progress, so that concurrent lookups of the same key wait for it
+// instead of loading the value again
+type call struct {
+	wg         sync.WaitGroup
+	value      interface{}
+	err        error
+	invalidate bool
+}
+
+// Cache is a TTL bounded, concurrency safe cache shared by all resources of a provider instance.
+// Concurrent lookups of a key that is not cached are de-duplicated, only one of them calls the loader.
+//
+// A nil *Cache is valid and never caches anything.
+type Cache struct {
+	ttl time.Duration
+	now func() time.Time
+
+	mu       sync.Mutex
+	entries  map[string]entry
+	inflight map[string]*call
+}
+
+// New creates a Cache which keeps values for ttl. Returns nil, which disables caching, when ttl is not positive.
+func New(ttl time.Duration) *Cache {
+	if ttl <= 0 {
+		return nil
+	}
+	return &Cache{
+		ttl:      ttl,
+		now:      time.Now,
+		entries:  map[string]entry{},
+		inflight: map[string]*call{},
+	}
+}
+
+// GetOrLoad returns the cached value of key, calling loader when the key is not cached or has expired.
+// Errors returned by loader are not cached. Callers waiting for a load which fails call their own loader in turn,
+// so a transient failure only fails the caller whose loader returned it. Lookups are logged to the cache subsystem
+// of the logger of ctx.
+func (c *Cache) GetOrLoad(ctx context.Context, key string, loader LoaderFunc) (interface{}, error) {
+	if c == nil {
+		return loader()
+	}
+	ctx = logging.WithSubsystem(ctx, logging.SubsystemCache)
+	fields := map[string]interface{}{"cache_key": key}
+
+	for {
+		c.mu.Lock()
+		if e, ok := c.entries[key]; ok {
+			if c.now().Before(e.expires) {
+				c.mu.Unlock()
+				tflog.SubsystemTrace(ctx, logging.SubsystemCache, "Cache hit", fields)
+				return e.value, nil
+			}
+			delete(c.entries, key)
+		}
+		cl, ok := c.inflight[key]
+		if !ok {
+			return c.load(ctx, key, loader)
+		}
+		c.mu.Unlock()
+
+		tflog.SubsystemTrace(ctx, logging.SubsystemCache, "Waiting for the in-flight load", fields)
+		cl.wg.Wait()
+		if cl.err == nil {
+			return cl.value, nil
+		}
+		tflog.SubsystemTrace(ctx, logging.SubsystemCache, "The in-flight load failed, loading again", fields)
+	}
+}
+
+// load calls loader for key and hands the result to the callers waiting for it. It is called with c.mu held and
+// releases it while loading.
+func (c *Cache) load(ctx context.Context, key string, loader LoaderFunc) (interface{}, error) {
+	cl := new(call)
+	cl.wg.Add(1)
+	c.inflight[key] = cl
+	c.mu.Unlock()
+
+	tflog.SubsystemTrace(ctx, logging.SubsystemCache, "Cache miss", map[string]interface{}{"cache_key": key})
+	cl.value, cl.err = loader()
+
+	c.mu.Lock()
+	// an invalidated load is already replaced by the next one, see Invalidate
+	if c.inflight[key] == cl {
+		delete(c.inflight, key)
+	}
+	// a write that happened while loading makes the loaded value stale, so it is handed to the callers already
+	// waiting for it but not kept
+	if cl.err == nil && !cl.invalidate {
+		c.entries[key] = entry{value: cl.value, expires: c.now().Add(c.ttl)}
+	}
+	c.mu.Unlock()
+	cl.wg.Done()
+
+	return cl.value, cl.err
+}
+
+// Invalidate removes key from the cache. A load of key in progress is detached from it, its callers still get its
+// result but later lookups start a fresh load which sees the write.
+func (c *Cache) Invalidate(key string) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.entries, key)
+	if cl, ok := c.inflight[key]; ok {
+		cl.invalidate = true
+		delete(c.inflight, key)
+	}
+}
+
+// InvalidatePrefix removes all keys starting with prefix from the cache, see Invalidate
+func (c *Cache) InvalidatePrefix(prefix string) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for key := range c.entries {
+		if strings.HasPrefix(key, prefix) {
+			delete(c.entries, key)
+		}
+	}
+	for key, cl := range c.inflight {
+		if strings.HasPrefix(key, prefix) {
+			cl.invalidate = true
+			delete(c.inflight, key)
+		}
+	}
+}
+
+// Key joins the parts of a cache key, e.g. Key("checks", projectID, resourceID)
+func Key(parts ...string) string {
+	return strings.Join(parts, "/")
+}
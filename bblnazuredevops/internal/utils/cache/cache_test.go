@@ -0,0 +1,214 @@
+package cache
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-log/tflogtest"
+	"github.com/stretchr/testify/require"
+)
+
+func counter(calls *int32, value interface{}) LoaderFunc {
+	return func() (interface{}, error) {
+		atomic.AddInt32(calls, 1)
+		return value, nil
+	}
+}
+
+func TestCache_GetOrLoad_CachesUntilExpired(t *testing.T) {
+	now := time.Now()
+	c := New(time.Minute)
+	c.now = func() time.Time { return now }
+
+	var calls int32
+	for i := 0; i < 3; i++ {
+		value, err := c.GetOrLoad(context.Background(), "checks/project/resource", counter(&calls, "value"))
+		require.NoError(t, err)
+		require.Equal(t, "value", value)
+	}
+	require.Equal(t, int32(1), calls)
+
+	now = now.Add(2 * time.Minute)
+	_, err := c.GetOrLoad(context.Background(), "checks/project/resource", counter(&calls, "value"))
+	require.NoError(t, err)
+	require.Equal(t, int32(2), calls)
+}
+
+func TestCache_GetOrLoad_DoesNotCacheErrors(t *testing.T) {
+	c := New(time.Minute)
+
+	_, err := c.GetOrLoad(context.Background(), "key", func() (interface{}, error) { return nil, errors.New("boom") })
+	require.Error(t, err)
+
+	var calls int32
+	value, err := c.GetOrLoad(context.Background(), "key", counter(&calls, "value"))
+	require.NoError(t, err)
+	require.Equal(t, "value", value)
+	require.Equal(t, int32(1), calls)
+}
+
+func TestCache_GetOrLoad_DeduplicatesConcurrentLoads(t *testing.T) {
+	c := New(time.Minute)
+
+	var calls int32
+	release := make(chan struct{})
+	loader := func() (interface{}, error) {
+		atomic.AddInt32(&calls, 1)
+		<-release
+		return "value", nil
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			value, err := c.GetOrLoad(context.Background(), "key", loader)
+			require.NoError(t, err)
+			require.Equal(t, "value", value)
+		}()
+	}
+	// give the goroutines time to queue up behind the first load
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	require.Equal(t, int32(1), calls)
+}
+
+func TestCache_GetOrLoad_RetriesFailedConcurrentLoad(t *testing.T) {
+	c := New(time.Minute)
+
+	release := make(chan struct{})
+	failing := func() (interface{}, error) {
+		<-release
+		return nil, errors.New("boom")
+	}
+	go func() {
+		_, err := c.GetOrLoad(context.Background(), "key", failing)
+		require.Error(t, err)
+	}()
+	// give the failing load time to start
+	time.Sleep(50 * time.Millisecond)
+
+	var calls int32
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		value, err := c.GetOrLoad(context.Background(), "key", counter(&calls, "value"))
+		require.NoError(t, err)
+		require.Equal(t, "value", value)
+	}()
+	// give the second lookup time to queue up behind the failing load
+	time.Sleep(50 * time.Millisecond)
+	require.Equal(t, int32(0), atomic.LoadInt32(&calls))
+	close(release)
+	<-done
+
+	require.Equal(t, int32(1), calls)
+}
+
+func TestCache_Invalidate(t *testing.T) {
+	c := New(time.Minute)
+
+	var calls int32
+	_, _ = c.GetOrLoad(context.Background(), Key("checks", "p1", "r1"), counter(&calls, "value"))
+	_, _ = c.GetOrLoad(context.Background(), Key("checks", "p1", "r2"), counter(&calls, "value"))
+	_, _ = c.GetOrLoad(context.Background(), Key("checks", "p2", "r1"), counter(&calls, "value"))
+	require.Equal(t, int32(3), calls)
+
+	c.Invalidate(Key("checks", "p1", "r1"))
+	_, _ = c.GetOrLoad(context.Background(), Key("checks", "p1", "r1"), counter(&calls, "value"))
+	require.Equal(t, int32(4), calls)
+
+	c.InvalidatePrefix(Key("checks", "p1") + "/")
+	_, _ = c.GetOrLoad(context.Background(), Key("checks", "p1", "r1"), counter(&calls, "value"))
+	_, _ = c.GetOrLoad(context.Background(), Key("checks", "p1", "r2"), counter(&calls, "value"))
+	_, _ = c.GetOrLoad(context.Background(), Key("checks", "p2", "r1"), counter(&calls, "value"))
+	require.Equal(t, int32(6), calls)
+}
+
+func TestCache_Invalidate_DuringLoad(t *testing.T) {
+	c := New(time.Minute)
+
+	var calls int32
+	_, _ = c.GetOrLoad(context.Background(), "key", func() (interface{}, error) {
+		atomic.AddInt32(&calls, 1)
+		c.Invalidate("key")
+		return "stale", nil
+	})
+
+	value, _ := c.GetOrLoad(context.Background(), "key", counter(&calls, "fresh"))
+	require.Equal(t, "fresh", value)
+	require.Equal(t, int32(2), calls)
+}
+
+// A lookup after a write does not join a load which started before it
+func TestCache_Invalidate_DuringBlockedLoad(t *testing.T) {
+	c := New(time.Minute)
+
+	var calls int32
+	release := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		value, err := c.GetOrLoad(context.Background(), "key", func() (interface{}, error) {
+			atomic.AddInt32(&calls, 1)
+			<-release
+			return "stale", nil
+		})
+		require.NoError(t, err)
+		require.Equal(t, "stale", value)
+	}()
+	// give the first load time to start
+	time.Sleep(50 * time.Millisecond)
+
+	c.Invalidate("key")
+	value, err := c.GetOrLoad(context.Background(), "key", counter(&calls, "fresh"))
+	require.NoError(t, err)
+	require.Equal(t, "fresh", value)
+	require.Equal(t, int32(2), atomic.LoadInt32(&calls))
+
+	close(release)
+	<-done
+	// the stale value neither replaces the fresh one nor removes the next load
+	value, _ = c.GetOrLoad(context.Background(), "key", counter(&calls, "other"))
+	require.Equal(t, "fresh", value)
+	require.Equal(t, int32(2), atomic.LoadInt32(&calls))
+}
+
+func TestCache_Nil(t *testing.T) {
+	var c *Cache
+	require.Nil(t, New(0))
+
+	var calls int32
+	_, _ = c.GetOrLoad(context.Background(), "key", counter(&calls, "value"))
+	_, _ = c.GetOrLoad(context.Background(), "key", counter(&calls, "value"))
+	c.Invalidate("key")
+	c.InvalidatePrefix("k")
+	require.Equal(t, int32(2), calls)
+}
+
+func TestCache_GetOrLoad_LogsLookups(t *testing.T) {
+	var output bytes.Buffer
+	ctx := tflogtest.RootLogger(context.Background(), &output)
+	c := New(time.Minute)
+
+	var calls int32
+	_, _ = c.GetOrLoad(ctx, "key", counter(&calls, "value"))
+	_, _ = c.GetOrLoad(ctx, "key", counter(&calls, "value"))
+
+	entries, err := tflogtest.MultilineJSONDecode(&output)
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	for i, message := range []string{"Cache miss", "Cache hit"} {
+		require.Equal(t, message, entries[i]["@message"])
+		require.Equal(t, "key", entries[i]["cache_key"])
+		require.Equal(t, "provider.cache", entries[i]["@module"])
+	}
+}
@@ -0,0 +1,41 @@
+package fwhelper
+
+import (
+	"fmt"
+
+	"github.com/babylonhealth/terraform-provider-bblnazuredevops/bblnazuredevops/internal/client"
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+// Clients returns the client the provider passes to the Configure method of resources and data sources. It is
+// nil until the provider has been configured.
+func Clients(providerData interface{}, diags *diag.Diagnostics) *client.AggregatedClient {
+	if providerData == nil {
+		return nil
+	}
+	clients, ok := providerData.(*client.AggregatedClient)
+	if !ok {
+		diags.AddError("Unexpected provider data", fmt.Sprintf("Expected *client.AggregatedClient, got %T. This is a bug in the provider.", providerData))
+		return nil
+	}
+	return clients
+}
+
+// StringOrNull returns a null value for the empty string, which Azure DevOps returns for optional fields that
+// are not set
+func StringOrNull(value string) types.String {
+	if value == "" {
+		return types.StringNull()
+	}
+	return types.StringValue(value)
+}
+
+// OptionalString returns the value of an optional attribute read from Azure DevOps. The empty string is null like
+// for StringOrNull, unless the state holds the empty string, which the configuration then sets explicitly.
+func OptionalString(state types.String, value string) types.String {
+	if value == "" && !state.IsNull() && !state.IsUnknown() && state.ValueString() == "" {
+		return state
+	}
+	return StringOrNull(value)
+}
@@ -0,0 +1,101 @@
+package fwhelper
+
+import (
+	"context"
+	"regexp"
+
+	"github.com/babylonhealth/terraform-provider-bblnazuredevops/bblnazuredevops/internal/client"
+	"github.com/babylonhealth/terraform-provider-bblnazuredevops/bblnazuredevops/internal/utils/tfhelper"
+	"github.com/hashicorp/terraform-plugin-framework-validators/stringvalidator"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+var projectIDPath = path.Root("project_id")
+
+// ProjectIDAttribute returns the schema of the project_id of a resource, the framework counterpart of
+// tfhelper.ProjectIDSchema. Terraform does not allow planning a value which differs from the configuration, other
+// than the value in the state, so a project given by name is written to the state as is on create and replaced by
+// its ID when the resource is read. Resources using it must call ModifyPlanProjectID from their ModifyPlan, resolve
+// the project with ProjectID and store its ID in Read.
+func ProjectIDAttribute() schema.StringAttribute {
+	return schema.StringAttribute{
+		Optional: true,
+		Computed: true,
+		Validators: []validator.String{
+			stringvalidator.RegexMatches(regexp.MustCompile(`\S`), "must not be empty or consist of whitespace only"),
+		},
+		Description: tfhelper.ProjectIDSchema().Description,
+	}
+}
+
+// ProjectID returns the ID of the project named by projectNameOrID
+func ProjectID(clients *client.AggregatedClient, projectNameOrID types.String) (string, error) {
+	return tfhelper.GetRealProjectId(projectNameOrID.ValueString(), clients)
+}
+
+// ModifyPlanProjectID plans the ID of the default_project of the provider when project_id is not set, and
+// replaces the resource only when project_id names a different project than the one in the state. The ID in the
+// state is kept when project_id names the same project, so that a project given by name plans no change.
+func ModifyPlanProjectID(ctx context.Context, clients *client.AggregatedClient, req resource.ModifyPlanRequest, resp *resource.ModifyPlanResponse) {
+	// nothing to plan when the resource is destroyed or the provider is not configured yet
+	if req.Plan.Raw.IsNull() || clients == nil {
+		return
+	}
+
+	var configured, state types.String
+	resp.Diagnostics.Append(req.Config.GetAttribute(ctx, projectIDPath, &configured)...)
+	if !req.State.Raw.IsNull() {
+		resp.Diagnostics.Append(req.State.GetAttribute(ctx, projectIDPath, &state)...)
+	}
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	planned := configured
+	if configured.IsNull() {
+		switch {
+		case clients.DefaultProject != "":
+			projectID, err := tfhelper.GetRealProjectId(clients.DefaultProject, clients)
+			if err != nil {
+				resp.Diagnostics.AddAttributeError(projectIDPath, "Error resolving the default project", err.Error())
+				return
+			}
+			planned = types.StringValue(projectID)
+		case !state.IsNull():
+			// keep the project of the existing resource
+			planned = state
+		default:
+			resp.Diagnostics.AddAttributeError(projectIDPath, "Missing project", tfhelper.ErrMsgProjectRequired)
+			return
+		}
+		resp.Diagnostics.Append(resp.Plan.SetAttribute(ctx, projectIDPath, planned)...)
+	}
+
+	if state.IsNull() || planned.Equal(state) {
+		return
+	}
+	if planned.IsUnknown() {
+		resp.RequiresReplace = append(resp.RequiresReplace, projectIDPath)
+		return
+	}
+
+	plannedID, err := ProjectID(clients, planned)
+	if err != nil {
+		resp.Diagnostics.AddAttributeError(projectIDPath, "Error resolving the project", err.Error())
+		return
+	}
+	stateID, err := ProjectID(clients, state)
+	if err != nil {
+		resp.Diagnostics.AddAttributeError(projectIDPath, "Error resolving the project", err.Error())
+		return
+	}
+	if plannedID != stateID {
+		resp.RequiresReplace = append(resp.RequiresReplace, projectIDPath)
+		return
+	}
+	resp.Diagnostics.Append(resp.Plan.SetAttribute(ctx, projectIDPath, state)...)
+}
@@ -0,0 +1,112 @@
+package fwhelper
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/babylonhealth/terraform-provider-bblnazuredevops/azdosdkmocks"
+	"github.com/babylonhealth/terraform-provider-bblnazuredevops/bblnazuredevops/internal/client"
+	"github.com/babylonhealth/terraform-provider-bblnazuredevops/bblnazuredevops/internal/utils/cache"
+	"github.com/golang/mock/gomock"
+	"github.com/google/uuid"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/tfsdk"
+	"github.com/hashicorp/terraform-plugin-go/tftypes"
+	"github.com/microsoft/azure-devops-go-api/azuredevops/v6/core"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	testProjectID      = uuid.MustParse("3c49c3b6-a06d-424d-a6b6-0cd375ee9261")
+	testOtherProjectID = uuid.MustParse("0b4a8fb4-8a4d-4b8e-a2e6-3e0a4f0b7f55")
+	projectTestSchema  = schema.Schema{Attributes: map[string]schema.Attribute{"project_id": ProjectIDAttribute()}}
+	projectTestType    = tftypes.Object{AttributeTypes: map[string]tftypes.Type{"project_id": tftypes.String}}
+)
+
+func projectTestValue(projectID interface{}) tftypes.Value {
+	return tftypes.NewValue(projectTestType, map[string]tftypes.Value{"project_id": tftypes.NewValue(tftypes.String, projectID)})
+}
+
+func TestModifyPlanProjectID(t *testing.T) {
+	tests := []struct {
+		name           string
+		configured     interface{}
+		state          interface{}
+		wantPlanned    interface{}
+		wantReplace    bool
+		defaultProject string
+	}{
+		{
+			name:        "name of the project in the state",
+			configured:  "projectName",
+			state:       testProjectID.String(),
+			wantPlanned: testProjectID.String(),
+		},
+		{
+			name:        "name of another project",
+			configured:  "otherProject",
+			state:       testProjectID.String(),
+			wantPlanned: "otherProject",
+			wantReplace: true,
+		},
+		{
+			name:        "name on create",
+			configured:  "projectName",
+			wantPlanned: "projectName",
+		},
+		{
+			name:           "default project",
+			state:          testProjectID.String(),
+			wantPlanned:    testProjectID.String(),
+			defaultProject: "projectName",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			coreClient := azdosdkmocks.NewMockCoreClient(ctrl)
+			clients := &client.AggregatedClient{
+				CoreClient:     coreClient,
+				Cache:          cache.New(time.Minute),
+				Ctx:            context.Background(),
+				DefaultProject: tt.defaultProject,
+			}
+			coreClient.EXPECT().
+				GetProject(clients.Ctx, gomock.Any()).
+				DoAndReturn(func(_ context.Context, args core.GetProjectArgs) (*core.TeamProject, error) {
+					if *args.ProjectId == "otherProject" {
+						return &core.TeamProject{Id: &testOtherProjectID}, nil
+					}
+					return &core.TeamProject{Id: &testProjectID}, nil
+				}).
+				AnyTimes()
+
+			state := tfsdk.State{Schema: projectTestSchema, Raw: tftypes.NewValue(projectTestType, nil)}
+			if tt.state != nil {
+				state.Raw = projectTestValue(tt.state)
+			}
+			config := tfsdk.Config{Schema: projectTestSchema, Raw: projectTestValue(tt.configured)}
+			req := resource.ModifyPlanRequest{
+				Config: config,
+				State:  state,
+				Plan:   tfsdk.Plan{Schema: projectTestSchema, Raw: config.Raw},
+			}
+			resp := &resource.ModifyPlanResponse{Plan: req.Plan}
+
+			ModifyPlanProjectID(context.Background(), clients, req, resp)
+			require.False(t, resp.Diagnostics.HasError(), "%v", resp.Diagnostics)
+
+			require.True(t, resp.Plan.Raw.Equal(projectTestValue(tt.wantPlanned)), "planned %v", resp.Plan.Raw)
+			if tt.wantReplace {
+				require.Equal(t, path.Paths{projectIDPath}, resp.RequiresReplace)
+			} else {
+				require.Empty(t, resp.RequiresReplace)
+			}
+		})
+	}
+}
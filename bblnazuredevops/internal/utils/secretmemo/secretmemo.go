@@ -1,7 +1,12 @@
 package secretmemo
 
 import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
 	"strings"
+	"sync"
 
 	"golang.org/x/crypto/bcrypt"
 )
@@ -10,43 +15,179 @@ const isUpdating = true
 const isNotUpdating = false
 const isErr = false
 
+// Names of the memo strategies
+const (
+	StrategyBcrypt     = "bcrypt"
+	StrategyHMACSHA256 = "hmac-sha256"
+	StrategyNone       = "none"
+)
+
+// hmacMemoPrefix tells the memos of the hmac-sha256 strategy apart from bcrypt hashes
+const hmacMemoPrefix = StrategyHMACSHA256 + ":"
+
+// minHMACKeyLength is the minimum length of the key of the hmac-sha256 strategy
+const minHMACKeyLength = 16
+
+// Strategy computes the memo of a secret, which is stored in `tfstate` next to the secret to detect when the
+// secret is changed without storing the secret itself
+type Strategy interface {
+	// Name of the strategy
+	Name() string
+	// Memo returns the memo of the secret, "" when the strategy does not store memos
+	Memo(secret string) (string, error)
+	// Matches returns whether memo is the memo of the secret
+	Matches(secret, memo string) bool
+	// Owns returns whether memo was computed by the strategy. Memos of other strategies never match.
+	Owns(memo string) bool
+}
+
+// Strategies returns the names of all memo strategies
+func Strategies() []string {
+	return []string{StrategyBcrypt, StrategyHMACSHA256, StrategyNone}
+}
+
+// New returns the strategy with the given name. The hmacKey is only used, and then required, by hmac-sha256.
+func New(name string, hmacKey string) (Strategy, error) {
+	switch name {
+	case "", StrategyBcrypt:
+		return bcryptStrategy{}, nil
+	case StrategyHMACSHA256:
+		if len(hmacKey) < minHMACKeyLength {
+			return nil, fmt.Errorf("the %s secret memo requires a key of at least %d characters", StrategyHMACSHA256, minHMACKeyLength)
+		}
+		return hmacStrategy{key: []byte(hmacKey)}, nil
+	case StrategyNone:
+		return noneStrategy{}, nil
+	}
+	return nil, fmt.Errorf("unsupported secret memo %q, expected one of %s", name, strings.Join(Strategies(), ", "))
+}
+
+var (
+	mu       sync.RWMutex
+	strategy Strategy = bcryptStrategy{}
+)
+
+// SetStrategy sets the strategy used by IsUpdating and Migrate. Memos are computed by diff suppression functions,
+// which have no access to the provider configuration, so the strategy is shared by the provider process. Terraform
+// starts a process for each provider configuration.
+func SetStrategy(s Strategy) {
+	mu.Lock()
+	defer mu.Unlock()
+	strategy = s
+}
+
+// CurrentStrategy returns the strategy set by SetStrategy, bcrypt by default
+func CurrentStrategy() Strategy {
+	mu.RLock()
+	defer mu.RUnlock()
+	return strategy
+}
+
 func isBlank(s string) bool {
 	return len(strings.TrimSpace(s)) == 0
 }
 
-func calcMementoForSecret(secret, memento string) (string, error) {
-	secretAsBytes := []byte(secret)
-	hash, err := bcrypt.GenerateFromPassword(secretAsBytes, bcrypt.MinCost)
+type bcryptStrategy struct{}
+
+func (bcryptStrategy) Name() string {
+	return StrategyBcrypt
+}
+
+func (bcryptStrategy) Memo(secret string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(secret), bcrypt.MinCost)
 	if err != nil {
 		return "", err
 	}
 	return string(hash), nil
 }
 
-func doesMemoMatchSecret(secret, memento string) bool {
-	if isBlank(memento) {
+func (bcryptStrategy) Matches(secret, memo string) bool {
+	if isBlank(memo) {
+		return false
+	}
+	return bcrypt.CompareHashAndPassword([]byte(memo), []byte(secret)) == nil
+}
+
+func (bcryptStrategy) Owns(memo string) bool {
+	return strings.HasPrefix(memo, "$2")
+}
+
+type hmacStrategy struct {
+	key []byte
+}
+
+func (hmacStrategy) Name() string {
+	return StrategyHMACSHA256
+}
+
+func (s hmacStrategy) Memo(secret string) (string, error) {
+	return hmacMemoPrefix + hex.EncodeToString(s.sum(secret)), nil
+}
+
+func (s hmacStrategy) Matches(secret, memo string) bool {
+	sum, err := hex.DecodeString(strings.TrimPrefix(memo, hmacMemoPrefix))
+	if err != nil || !s.Owns(memo) {
 		return false
 	}
-	secretAsBytes := []byte(secret)
-	mementoAsBytes := []byte(memento)
-	err := bcrypt.CompareHashAndPassword(mementoAsBytes, secretAsBytes)
-	return err == nil
+	return hmac.Equal(sum, s.sum(secret))
+}
+
+func (hmacStrategy) Owns(memo string) bool {
+	return strings.HasPrefix(memo, hmacMemoPrefix)
+}
+
+func (s hmacStrategy) sum(secret string) []byte {
+	mac := hmac.New(sha256.New, s.key)
+	mac.Write([]byte(secret))
+	return mac.Sum(nil)
 }
 
-// IsUpdating is used to determine if the secret getting updated?
+// noneStrategy stores no memo, so changes of a secret cannot be detected. Secrets kept in the configuration are then
+// sent again on every apply rather than silently ignoring their changes, write-only secrets are only sent again
+// when their version changes.
+type noneStrategy struct{}
+
+func (noneStrategy) Name() string {
+	return StrategyNone
+}
+
+func (noneStrategy) Memo(string) (string, error) {
+	return "", nil
+}
+
+func (noneStrategy) Matches(string, string) bool {
+	return false
+}
+
+func (noneStrategy) Owns(memo string) bool {
+	return memo == ""
+}
+
+// IsUpdating is used to determine if the secret getting updated? The memo of the secret is computed by the
+// current strategy, a memo computed by another strategy never matches so that it is replaced on the next update.
 func IsUpdating(secret, oldMemo string) (bool, string, error) {
 	if isBlank(secret) {
 		return isNotUpdating, oldMemo, nil
 	}
 
-	if doesMemoMatchSecret(secret, oldMemo) {
+	s := CurrentStrategy()
+	if s.Owns(oldMemo) && s.Matches(secret, oldMemo) {
 		return isNotUpdating, oldMemo, nil
 	}
 
-	newMemo, err := calcMementoForSecret(secret, oldMemo)
+	newMemo, err := s.Memo(secret)
 	if err != nil {
 		return isErr, "", err
 	}
 
 	return isUpdating, newMemo, nil
 }
+
+// Migrate returns the memo if it was computed by the current strategy, and "" otherwise. Memos of other
+// strategies, e.g. the bcrypt hashes stored by previous versions of the provider, are dropped from `tfstate`.
+func Migrate(memo string) string {
+	if memo == "" || CurrentStrategy().Owns(memo) {
+		return memo
+	}
+	return ""
+}
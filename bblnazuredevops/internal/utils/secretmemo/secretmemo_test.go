@@ -67,3 +67,95 @@ func isValidMemo(memo string) bool {
 	}
 	return false
 }
+
+func useStrategy(t *testing.T, name string, hmacKey string) Strategy {
+	previous := CurrentStrategy()
+	t.Cleanup(func() { SetStrategy(previous) })
+
+	s, err := New(name, hmacKey)
+	require.Nil(t, err)
+	SetStrategy(s)
+	return s
+}
+
+func TestNewStrategy(t *testing.T) {
+	for _, name := range []string{"", StrategyBcrypt, StrategyNone} {
+		_, err := New(name, "")
+		require.Nil(t, err, name)
+	}
+
+	_, err := New(StrategyHMACSHA256, "too short")
+	require.NotNil(t, err)
+
+	_, err = New("md5", "")
+	require.NotNil(t, err)
+}
+
+func TestHMACStrategy(t *testing.T) {
+	s := useStrategy(t, StrategyHMACSHA256, "0123456789abcdef")
+
+	firstResult, firstMemo, err := IsUpdating("mysecret", "")
+	require.True(t, firstResult)
+	require.True(t, strings.HasPrefix(firstMemo, "hmac-sha256:"))
+	require.Nil(t, err)
+
+	secondResult, secondMemo, err := IsUpdating("mysecret", firstMemo)
+	require.False(t, secondResult)
+	require.Equal(t, firstMemo, secondMemo)
+	require.Nil(t, err)
+
+	thirdResult, thirdMemo, err := IsUpdating("mychange", firstMemo)
+	require.True(t, thirdResult)
+	require.NotEqual(t, firstMemo, thirdMemo)
+	require.Nil(t, err)
+
+	other, err := New(StrategyHMACSHA256, "fedcba9876543210")
+	require.Nil(t, err)
+	require.False(t, other.Matches("mysecret", firstMemo))
+	require.True(t, s.Matches("mysecret", firstMemo))
+}
+
+func TestHMACStrategy_ReplacesBcryptMemo(t *testing.T) {
+	_, bcryptMemo, err := IsUpdating("mysecret", "")
+	require.Nil(t, err)
+	require.True(t, isValidMemo(bcryptMemo))
+
+	useStrategy(t, StrategyHMACSHA256, "0123456789abcdef")
+	result, memo, err := IsUpdating("mysecret", bcryptMemo)
+	require.True(t, result)
+	require.True(t, strings.HasPrefix(memo, "hmac-sha256:"))
+	require.Nil(t, err)
+
+	require.Equal(t, "", Migrate(bcryptMemo))
+	require.Equal(t, memo, Migrate(memo))
+}
+
+func TestNoneStrategy(t *testing.T) {
+	_, bcryptMemo, err := IsUpdating("mysecret", "")
+	require.Nil(t, err)
+
+	useStrategy(t, StrategyNone, "")
+	result, memo, err := IsUpdating("mysecret", "")
+	require.True(t, result)
+	require.Equal(t, "", memo)
+	require.Nil(t, err)
+
+	result, memo, err = IsUpdating("mysecret", bcryptMemo)
+	require.True(t, result)
+	require.Equal(t, "", memo)
+	require.Nil(t, err)
+
+	result, memo, err = IsUpdating("", "")
+	require.False(t, result)
+	require.Equal(t, "", memo)
+	require.Nil(t, err)
+
+	require.Equal(t, "", Migrate(bcryptMemo))
+}
+
+func TestMigrate_KeepsMemoOfCurrentStrategy(t *testing.T) {
+	_, bcryptMemo, err := IsUpdating("mysecret", "")
+	require.Nil(t, err)
+	require.Equal(t, bcryptMemo, Migrate(bcryptMemo))
+	require.Equal(t, "", Migrate(""))
+}
@@ -0,0 +1,105 @@
+package tfhelper
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/babylonhealth/terraform-provider-bblnazuredevops/bblnazuredevops/internal/client"
+	"github.com/hashicorp/go-cty/cty"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+)
+
+// ErrMsgProjectRequired is returned when a resource has no project_id and the provider no default_project
+const ErrMsgProjectRequired = "project_id is required when the provider has no default_project"
+
+// ProjectIDSchema returns the schema of the project_id of a resource. It accepts the name or the ID of the
+// project and defaults to the default_project of the provider, the ID of the project is stored in the state.
+// Resources using it must set CustomizeDiffProjectID as their CustomizeDiff.
+func ProjectIDSchema() *schema.Schema {
+	return &schema.Schema{
+		Type:         schema.TypeString,
+		Optional:     true,
+		Computed:     true,
+		ForceNew:     true,
+		ValidateFunc: validation.StringIsNotWhiteSpace,
+		Description:  "The name or ID of the project. Defaults to the default_project of the provider.",
+	}
+}
+
+// DataSourceProjectIDSchema returns the schema of the project_id of a data source, see ProjectIDSchema. Data
+// sources resolve it with ResolveProjectID when they are read.
+func DataSourceProjectIDSchema() *schema.Schema {
+	return &schema.Schema{
+		Type:         schema.TypeString,
+		Optional:     true,
+		Computed:     true,
+		ValidateFunc: validation.StringIsNotWhiteSpace,
+		Description:  "The name or ID of the project. Defaults to the default_project of the provider.",
+	}
+}
+
+// CustomizeDiffProjectID plans the ID of the project named by project_id, or by the default_project of the
+// provider when project_id is not set, so that referring to a project by name does not cause a diff.
+func CustomizeDiffProjectID(_ context.Context, d *schema.ResourceDiff, meta interface{}) error {
+	clients, ok := meta.(*client.AggregatedClient)
+	if !ok {
+		return nil
+	}
+
+	var configured cty.Value
+	if config := d.GetRawConfig(); !config.IsNull() && config.IsKnown() {
+		configured = config.GetAttr("project_id")
+	} else if !d.NewValueKnown("project_id") {
+		// without the raw configuration, e.g. in unit tests, an unset project can not be told apart from
+		// an unknown one
+		configured = cty.UnknownVal(cty.String)
+	} else if projectNameOrID := d.Get("project_id").(string); projectNameOrID != "" {
+		configured = cty.StringVal(projectNameOrID)
+	} else {
+		configured = cty.NullVal(cty.String)
+	}
+
+	var projectNameOrID string
+	switch {
+	case !configured.IsKnown():
+		// resolved by ResolveProjectID once the value is known
+		return nil
+	case !configured.IsNull():
+		projectNameOrID = configured.AsString()
+	case clients.DefaultProject != "":
+		projectNameOrID = clients.DefaultProject
+	case d.Id() != "":
+		// keep the project of the existing resource
+		return nil
+	default:
+		return fmt.Errorf(ErrMsgProjectRequired)
+	}
+
+	projectID, err := GetRealProjectId(projectNameOrID, meta)
+	if err != nil {
+		return err
+	}
+	return d.SetNew("project_id", projectID)
+}
+
+// ResolveProjectID returns the ID of the project of the resource, falling back to the default_project of the
+// provider, and stores it in project_id. Used when creating resources, as the project may not be known when planning.
+func ResolveProjectID(d *schema.ResourceData, meta interface{}) (string, error) {
+	clients := meta.(*client.AggregatedClient)
+
+	projectNameOrID := d.Get("project_id").(string)
+	if projectNameOrID == "" {
+		projectNameOrID = clients.DefaultProject
+	}
+	if projectNameOrID == "" {
+		return "", fmt.Errorf(ErrMsgProjectRequired)
+	}
+
+	projectID, err := GetRealProjectId(projectNameOrID, meta)
+	if err != nil {
+		return "", err
+	}
+	d.Set("project_id", projectID)
+	return projectID, nil
+}
@@ -0,0 +1,93 @@
+package tfhelper
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/babylonhealth/terraform-provider-bblnazuredevops/azdosdkmocks"
+	"github.com/babylonhealth/terraform-provider-bblnazuredevops/bblnazuredevops/internal/client"
+	"github.com/babylonhealth/terraform-provider-bblnazuredevops/bblnazuredevops/internal/utils/cache"
+	"github.com/babylonhealth/terraform-provider-bblnazuredevops/bblnazuredevops/internal/utils/converter"
+	"github.com/golang/mock/gomock"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
+	"github.com/microsoft/azure-devops-go-api/azuredevops/v6/core"
+	"github.com/stretchr/testify/require"
+)
+
+func projectTestResource() *schema.Resource {
+	return &schema.Resource{
+		Schema: map[string]*schema.Schema{
+			"project_id": ProjectIDSchema(),
+		},
+		CustomizeDiff: CustomizeDiffProjectID,
+	}
+}
+
+func expectGetProject(coreClient *azdosdkmocks.MockCoreClient, clients *client.AggregatedClient, projectName string) *gomock.Call {
+	return coreClient.EXPECT().GetProject(clients.Ctx, core.GetProjectArgs{
+		ProjectId:           converter.String(projectName),
+		IncludeCapabilities: converter.Bool(true),
+		IncludeHistory:      converter.Bool(false),
+	}).Return(&testProject, nil)
+}
+
+func TestGetRealProjectId_CachesProjectNames(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	coreClient := azdosdkmocks.NewMockCoreClient(ctrl)
+	clients := &client.AggregatedClient{CoreClient: coreClient, Cache: cache.New(time.Minute), Ctx: context.Background()}
+	expectGetProject(coreClient, clients, "projectName").Times(1)
+
+	for _, name := range []string{"projectName", "PROJECTNAME"} {
+		projectID, err := GetRealProjectId(name, clients)
+		require.NoError(t, err)
+		require.Equal(t, testID.String(), projectID)
+	}
+}
+
+func TestResolveProjectID_DefaultProject(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	coreClient := azdosdkmocks.NewMockCoreClient(ctrl)
+	clients := &client.AggregatedClient{CoreClient: coreClient, DefaultProject: "projectName", Ctx: context.Background()}
+	expectGetProject(coreClient, clients, "projectName").Times(1)
+
+	d := schema.TestResourceDataRaw(t, projectTestResource().Schema, map[string]interface{}{})
+	projectID, err := ResolveProjectID(d, clients)
+	require.NoError(t, err)
+	require.Equal(t, testID.String(), projectID)
+	require.Equal(t, testID.String(), d.Get("project_id"))
+
+	clients.DefaultProject = ""
+	d = schema.TestResourceDataRaw(t, projectTestResource().Schema, map[string]interface{}{})
+	_, err = ResolveProjectID(d, clients)
+	require.EqualError(t, err, ErrMsgProjectRequired)
+}
+
+func TestCustomizeDiffProjectID_PlansProjectID(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	coreClient := azdosdkmocks.NewMockCoreClient(ctrl)
+	clients := &client.AggregatedClient{CoreClient: coreClient, Cache: cache.New(time.Minute), Ctx: context.Background()}
+	expectGetProject(coreClient, clients, "projectName").Times(1)
+
+	config := terraform.NewResourceConfigRaw(map[string]interface{}{"project_id": "projectName"})
+
+	diff, err := projectTestResource().Diff(context.Background(), nil, config, clients)
+	require.NoError(t, err)
+	require.Equal(t, testID.String(), diff.Attributes["project_id"].New)
+
+	// the name of the project in the state does not cause a diff
+	state := &terraform.InstanceState{
+		ID:         "id",
+		Attributes: map[string]string{"id": "id", "project_id": testID.String()},
+	}
+	diff, err = projectTestResource().Diff(context.Background(), state, config, clients)
+	require.NoError(t, err)
+	require.True(t, diff.Empty(), "unexpected diff: %v", diff)
+}
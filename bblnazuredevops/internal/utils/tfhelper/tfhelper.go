@@ -1,16 +1,22 @@
 package tfhelper
 
 import (
+	"context"
 	"fmt"
-	"log"
+	"regexp"
 	"strconv"
 	"strings"
 
 	"github.com/babylonhealth/terraform-provider-bblnazuredevops/bblnazuredevops/internal/client"
+	"github.com/babylonhealth/terraform-provider-bblnazuredevops/bblnazuredevops/internal/client/logging"
+	"github.com/babylonhealth/terraform-provider-bblnazuredevops/bblnazuredevops/internal/utils/cache"
 	"github.com/babylonhealth/terraform-provider-bblnazuredevops/bblnazuredevops/internal/utils/converter"
 	"github.com/babylonhealth/terraform-provider-bblnazuredevops/bblnazuredevops/internal/utils/secretmemo"
+	"github.com/hashicorp/go-cty/cty"
 	"github.com/hashicorp/go-uuid"
+	"github.com/hashicorp/terraform-plugin-log/tflog"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
 	"github.com/microsoft/azure-devops-go-api/azuredevops/v6/core"
 )
 
@@ -18,12 +24,41 @@ func calcSecretHashKey(secretKey string) string {
 	return secretKey + "_hash"
 }
 
+func calcWriteOnlyKey(secretKey string) string {
+	return secretKey + "_wo"
+}
+
+func calcWriteOnlyVersionKey(secretKey string) string {
+	return secretKey + "_version"
+}
+
+func calcVaultRefKey(secretKey string) string {
+	return secretKey + "_vault_ref"
+}
+
+// logSecrets logs to the secrets subsystem. The helpers of secrets are called without a context, e.g. as the
+// DiffSuppressFunc of an attribute, so the logger of the provider is used. Secrets and their memos are never logged.
+func logSecrets(level func(context.Context, string, string, ...map[string]interface{}), msg string, fields map[string]interface{}) {
+	ctx := logging.WithSubsystem(logging.ProviderContext(), logging.SubsystemSecrets)
+	level(ctx, logging.SubsystemSecrets, msg, fields)
+}
+
+// calcAttributeName returns the name of the attribute at the path `key`, e.g. `secret` for `block.0.secret`
+func calcAttributeName(key string) string {
+	return key[strings.LastIndex(key, ".")+1:]
+}
+
 // DiffFuncSuppressSecretChanged is used to suppress unneeded `apply` updates to a resource.
 //
 // It returns `true` when `new` appears to be the same value
-// as a previously stored and bcrypt'd value stored in state during a previous `apply`.
-// Relies on flatten/expand logic to help store that hash. See FlattenSecret, below.*/
+// as a previously stored and memoized value stored in state during a previous `apply`, see secretmemo.Strategy.
+// Relies on flatten/expand logic to help store that memo. See FlattenSecret, below.*/
 func DiffFuncSuppressSecretChanged(k, old, new string, d *schema.ResourceData) bool {
+	// secrets are always sent when the resource is created, whatever the memo strategy
+	if d.Id() == "" {
+		return false
+	}
+
 	memoKey := calcSecretHashKey(k)
 	memoValue := d.Get(memoKey).(string)
 
@@ -31,12 +66,11 @@ func DiffFuncSuppressSecretChanged(k, old, new string, d *schema.ResourceData) b
 	isUnchanged := !isUpdating
 
 	if nil != err {
-		log.Printf("Change forced. Swallowing err while using secret hashing: %s", err)
+		logSecrets(tflog.SubsystemWarn, "Failed to memoize the secret, its change is forced", map[string]interface{}{"secret_key": k, "error": err.Error()})
 		return false
 	}
 
-	log.Printf("\nk: %s, old: %s, new: %s, memoKey: %s, memoValue: %s, isUnchanged: %t\n",
-		k, old, new, memoKey, memoValue, isUnchanged)
+	logSecrets(tflog.SubsystemTrace, "Compared the secret to its memo", map[string]interface{}{"secret_key": k, "secret_unchanged": isUnchanged})
 	return isUnchanged
 }
 
@@ -48,45 +82,267 @@ func HelpFlattenSecretNested(d *schema.ResourceData, parentKey string, d2 map[st
 	}
 	oldHash := d2[hashKey].(string)
 	if !d.HasChange(parentKey) {
-		log.Printf("key %s didn't get updated.", parentKey)
-		return oldHash, hashKey
+		logSecrets(tflog.SubsystemTrace, "The secret is unchanged", map[string]interface{}{"secret_key": parentKey})
+		return secretmemo.Migrate(oldHash), hashKey
 	}
 	newSecret := d2[secretKey].(string)
 	_, newHash, err := secretmemo.IsUpdating(newSecret, oldHash)
 	if nil != err {
-		log.Printf("Swallowing err while using secret hashing: %s", err)
+		logSecrets(tflog.SubsystemWarn, "Failed to memoize the secret", map[string]interface{}{"secret_key": parentKey, "error": err.Error()})
 	}
-	log.Printf("Secret has changed. It's new hash value is %s.", newHash)
+	logSecrets(tflog.SubsystemTrace, "The secret has changed, storing its new memo", map[string]interface{}{"secret_key": parentKey, "memo_key": hashKey})
 	return newHash, hashKey
 }
 
-// HelpFlattenSecret is used to store a hashed secret value into `tfstate`
+// HelpFlattenSecret is used to store a hashed secret value into `tfstate`. Secrets kept out of `tfstate`, see
+// IsSecretKeptOutOfState, are cleared from it instead, together with their hash.
 func HelpFlattenSecret(d *schema.ResourceData, secretKey string) {
-	if !d.HasChange(secretKey) {
-		log.Printf("Secret key %s didn't get updated.", secretKey)
+	if IsSecretKeptOutOfState(d, secretKey) {
+		d.Set(secretKey, "")
+		d.Set(calcSecretHashKey(secretKey), "")
 		return
 	}
 	hashKey := calcSecretHashKey(secretKey)
+	if !d.HasChange(secretKey) {
+		logSecrets(tflog.SubsystemTrace, "The secret is unchanged", map[string]interface{}{"secret_key": secretKey})
+		// memos of another strategy are dropped, the secret is then memoized again on the next update
+		if oldHash, ok := d.Get(hashKey).(string); ok && secretmemo.Migrate(oldHash) != oldHash {
+			d.Set(hashKey, "")
+		}
+		return
+	}
 	newSecret := d.Get(secretKey).(string)
 	oldHash := d.Get(hashKey).(string)
 	_, newHash, err := secretmemo.IsUpdating(newSecret, oldHash)
 	if nil != err {
-		log.Printf("Swallowing err while using secret hashing: %s", err)
+		logSecrets(tflog.SubsystemWarn, "Failed to memoize the secret", map[string]interface{}{"secret_key": secretKey, "error": err.Error()})
 	}
-	log.Printf("Secret key %s is updated. It's new hash key and value is %s and %s.", secretKey, hashKey, newHash)
+	logSecrets(tflog.SubsystemTrace, "The secret has changed, storing its new memo", map[string]interface{}{"secret_key": secretKey, "memo_key": hashKey})
 	d.Set(hashKey, newHash)
 }
 
-// GenerateSecreteMemoSchema is used to create Schema defs to house the hashed secret in `tfstate`
+// DiffFuncSuppressSecretMapChanged is the DiffSuppressFunc of a map of secrets, see DiffFuncSuppressSecretChanged.
+// The memo of each secret of the map is stored under the same key in the memo map of the secrets, see
+// GenerateSecretMapMemoSchema.
+func DiffFuncSuppressSecretMapChanged(k, old, new string, d *schema.ResourceData) bool {
+	if d.Id() == "" {
+		return false
+	}
+
+	// k is <secretKey>.<key of the secret>, or <secretKey>.% when secrets are added or removed
+	parts := strings.SplitN(k, ".", 2)
+	if len(parts) != 2 || parts[1] == "%" {
+		return false
+	}
+	memos, _ := d.Get(calcSecretHashKey(parts[0])).(map[string]interface{})
+	memo, _ := memos[parts[1]].(string)
+
+	isUpdating, _, err := secretmemo.IsUpdating(new, memo)
+	if nil != err {
+		logSecrets(tflog.SubsystemWarn, "Failed to memoize the secret, its change is forced", map[string]interface{}{"secret_key": k, "error": err.Error()})
+		return false
+	}
+
+	logSecrets(tflog.SubsystemTrace, "Compared the secret to its memo", map[string]interface{}{"secret_key": k, "secret_unchanged": !isUpdating})
+	return !isUpdating
+}
+
+// HelpFlattenSecretMap stores the memo of each secret of the map `<secretKey>` in the map `<secretKey>_hash`, see
+// HelpFlattenSecretNested.
+func HelpFlattenSecretMap(d *schema.ResourceData, secretKey string) {
+	hashKey := calcSecretHashKey(secretKey)
+	oldHashes, _ := d.Get(hashKey).(map[string]interface{})
+
+	hashes := map[string]interface{}{}
+	for key, secret := range d.Get(secretKey).(map[string]interface{}) {
+		oldHash, _ := oldHashes[key].(string)
+		hash, _ := HelpFlattenSecretNested(d, secretKey, map[string]interface{}{
+			key:                    secret,
+			calcSecretHashKey(key): oldHash,
+		}, key)
+		if hash != "" {
+			hashes[key] = hash
+		}
+	}
+	d.Set(hashKey, hashes)
+}
+
+// GenerateSecreteMemoSchema is used to create Schema defs to house the hashed secret in `tfstate`. The secret may be
+// nested in a block of a single element, e.g. `block.0.secret`, the returned key is then the one of the block.
 func GenerateSecreteMemoSchema(secretKey string) (string, *schema.Schema) {
 	out := schema.Schema{
 		Type:        schema.TypeString,
 		Computed:    true,
 		Default:     nil,
-		Description: fmt.Sprintf("A bcrypted hash of the attribute '%s'", secretKey),
+		Description: fmt.Sprintf("A memo of the attribute '%s', computed by the secret_memo of the provider", secretKey),
+		Sensitive:   true,
+	}
+	return calcSecretHashKey(calcAttributeName(secretKey)), &out
+}
+
+// GenerateSecretMapMemoSchema is used to create the Schema def of the map housing the memos of a map of secrets
+// in `tfstate`, see HelpFlattenSecretMap
+func GenerateSecretMapMemoSchema(secretKey string) (string, *schema.Schema) {
+	out := schema.Schema{
+		Type:     schema.TypeMap,
+		Computed: true,
+		Elem: &schema.Schema{
+			Type: schema.TypeString,
+		},
+		Description: fmt.Sprintf("The memos of the secrets of the attribute '%s', computed by the secret_memo of the provider", secretKey),
 		Sensitive:   true,
 	}
-	return calcSecretHashKey(secretKey), &out
+	return calcSecretHashKey(calcAttributeName(secretKey)), &out
+}
+
+// SecretMemoStateUpgrader returns the StateUpgrader of a resource from the version 0 of its schema, which always
+// stored bcrypt hashes of the secrets. Memos which were not computed by the current secretmemo.Strategy are dropped
+// from `tfstate`. It must be created once the schema of the resource is complete.
+func SecretMemoStateUpgrader(r *schema.Resource, secretKeys ...string) schema.StateUpgrader {
+	return schema.StateUpgrader{
+		Version: 0,
+		Type:    r.CoreConfigSchema().ImpliedType(),
+		Upgrade: func(_ context.Context, rawState map[string]interface{}, _ interface{}) (map[string]interface{}, error) {
+			for _, secretKey := range secretKeys {
+				hashKey := calcSecretHashKey(secretKey)
+				if memo, ok := rawState[hashKey].(string); ok {
+					rawState[hashKey] = secretmemo.Migrate(memo)
+				}
+			}
+			return rawState, nil
+		},
+	}
+}
+
+// GenerateWriteOnlySecretSchema is used to create Schema defs for the write-only variant of a secret, `<secretKey>_wo`,
+// and its `<secretKey>_version` trigger. Write-only values are never stored in `tfstate`, so the secret is only
+// sent to Azure DevOps when the version changes or when another attribute is updated. The secret may be nested in a
+// block of a single element, e.g. `block.0.secret`, the returned keys are then the ones of the block.
+func GenerateWriteOnlySecretSchema(secretKey string) map[string]*schema.Schema {
+	writeOnlyKey := calcWriteOnlyKey(secretKey)
+	versionKey := calcWriteOnlyVersionKey(secretKey)
+	return map[string]*schema.Schema{
+		calcAttributeName(writeOnlyKey): {
+			Type:          schema.TypeString,
+			Optional:      true,
+			WriteOnly:     true,
+			Sensitive:     true,
+			ConflictsWith: []string{secretKey},
+			RequiredWith:  []string{versionKey},
+			Description:   fmt.Sprintf("The write-only variant of the attribute '%s', it is never stored in the state", secretKey),
+		},
+		calcAttributeName(versionKey): {
+			Type:         schema.TypeInt,
+			Optional:     true,
+			RequiredWith: []string{writeOnlyKey},
+			ValidateFunc: validation.IntAtLeast(1),
+			Description:  fmt.Sprintf("The version of the attribute '%s', change it to update the secret", writeOnlyKey),
+		},
+	}
+}
+
+// IsWriteOnlySecret returns whether the secret is configured through its write-only attribute
+func IsWriteOnlySecret(d *schema.ResourceData, secretKey string) bool {
+	_, ok := d.GetOk(calcWriteOnlyVersionKey(secretKey))
+	return ok
+}
+
+// vaultRefRegexp matches the references to the value of a Vault secret, <path>#<key>
+var vaultRefRegexp = regexp.MustCompile(`^[^#]+#[^#]+$`)
+
+// GenerateVaultRefSchema is used to create the Schema def of `<secretKey>_vault_ref`, a reference to the Vault secret
+// holding the value of the secret. The value is read from Vault when the resource is applied and never stored in
+// `tfstate`. The resource must also have the write-only attribute of the secret, see GenerateWriteOnlySecretSchema.
+// The secret may be nested in a block of a single element, e.g. `block.0.secret`, the returned key is then the one
+// of the block.
+func GenerateVaultRefSchema(secretKey string) (string, *schema.Schema) {
+	out := schema.Schema{
+		Type:          schema.TypeString,
+		Optional:      true,
+		ConflictsWith: []string{secretKey, calcWriteOnlyKey(secretKey)},
+		ValidateFunc:  validation.StringMatch(vaultRefRegexp, "expected a reference like <path>#<key>"),
+		Description:   fmt.Sprintf("The Vault secret holding the value of the attribute '%s', as <path>#<key>. It is read from the vault of the provider when the resource is applied", secretKey),
+	}
+	return calcAttributeName(calcVaultRefKey(secretKey)), &out
+}
+
+// GetVaultRef returns the reference to the Vault secret holding the value of the secret, or "" when the value of
+// the secret is not read from Vault
+func GetVaultRef(d *schema.ResourceData, secretKey string) string {
+	if ref, ok := d.GetOk(calcVaultRefKey(secretKey)); ok {
+		return ref.(string)
+	}
+	return ""
+}
+
+// GenerateVaultRefMapSchema is used to create the Schema def of `<secretKey>_vault_ref`, the references to the Vault
+// secrets holding the values of secrets of the map `<secretKey>`, under their key in the map, see
+// GenerateVaultRefSchema
+func GenerateVaultRefMapSchema(secretKey string) (string, *schema.Schema) {
+	out := schema.Schema{
+		Type:     schema.TypeMap,
+		Optional: true,
+		Elem: &schema.Schema{
+			Type: schema.TypeString,
+		},
+		ValidateDiagFunc: validation.MapValueMatch(vaultRefRegexp, "expected references like <path>#<key>"),
+		Description:      fmt.Sprintf("The Vault secrets holding the values of secrets of the attribute '%s' by key, as <path>#<key>. They are read from the vault of the provider when the resource is applied", secretKey),
+	}
+	return calcAttributeName(calcVaultRefKey(secretKey)), &out
+}
+
+// GetVaultRefMap returns the references to the Vault secrets holding the values of secrets of the map `<secretKey>`,
+// by key, see GenerateVaultRefMapSchema
+func GetVaultRefMap(d *schema.ResourceData, secretKey string) map[string]string {
+	refs := map[string]string{}
+	for key, ref := range d.Get(calcVaultRefKey(secretKey)).(map[string]interface{}) {
+		refs[key] = ref.(string)
+	}
+	return refs
+}
+
+// IsSecretKeptOutOfState returns whether the value of the secret is never stored in `tfstate`, because it is
+// configured through its write-only attribute or read from Vault
+func IsSecretKeptOutOfState(d *schema.ResourceData, secretKey string) bool {
+	return IsWriteOnlySecret(d, secretKey) || GetVaultRef(d, secretKey) != ""
+}
+
+// GetSecret returns the value of the write-only attribute of the secret when it is configured, otherwise the value
+// of the secret itself. Write-only values are only available in the configuration during create and update.
+func GetSecret(d *schema.ResourceData, secretKey string) string {
+	if value, ok := getRawConfigString(d.GetRawConfig(), calcWriteOnlyKey(secretKey)); ok {
+		return value
+	}
+	return d.Get(secretKey).(string)
+}
+
+// getRawConfigString returns the string at the path `key` of the raw configuration, e.g. `block.0.attribute`, and
+// whether it is set and known
+func getRawConfigString(value cty.Value, key string) (string, bool) {
+	for _, step := range strings.Split(key, ".") {
+		if value.IsNull() || !value.IsKnown() {
+			return "", false
+		}
+		switch ty := value.Type(); {
+		case ty.IsObjectType():
+			if !ty.HasAttribute(step) {
+				return "", false
+			}
+			value = value.GetAttr(step)
+		case ty.IsListType():
+			index, err := strconv.Atoi(step)
+			if err != nil || index < 0 || index >= value.LengthInt() {
+				return "", false
+			}
+			value = value.Index(cty.NumberIntVal(int64(index)))
+		default:
+			return "", false
+		}
+	}
+	if value.IsNull() || !value.IsKnown() || !value.Type().Equals(cty.String) {
+		return "", false
+	}
+	return value.AsString(), true
 }
 
 // ParseProjectIDAndResourceID parses from the schema's resource data.
@@ -228,20 +484,31 @@ func ImportProjectQualifiedResourceUUID() *schema.ResourceImporter {
 	}
 }
 
-// Get real project ID
+// GetRealProjectId returns the ID of the project with the given name or ID. Names are resolved through
+// CoreClient.GetProject, the result is cached for all resources of the provider.
 func GetRealProjectId(projectNameOrID string, meta interface{}) (string, error) {
 	//If request params is project name, try get the project ID
 	if _, err := uuid.ParseUUID(projectNameOrID); err != nil {
 		clients := meta.(*client.AggregatedClient)
-		project, err := clients.CoreClient.GetProject(clients.Ctx, core.GetProjectArgs{
-			ProjectId:           &projectNameOrID,
-			IncludeCapabilities: converter.Bool(true),
-			IncludeHistory:      converter.Bool(false),
+		// project names are case insensitive
+		projectID, err := clients.Cache.GetOrLoad(clients.Ctx, cache.Key("projects", strings.ToLower(projectNameOrID)), func() (interface{}, error) {
+			project, err := clients.CoreClient.GetProject(clients.Ctx, core.GetProjectArgs{
+				ProjectId:           &projectNameOrID,
+				IncludeCapabilities: converter.Bool(true),
+				IncludeHistory:      converter.Bool(false),
+			})
+			if err != nil {
+				return nil, err
+			}
+			if project == nil || project.Id == nil {
+				return nil, fmt.Errorf("project %s has no ID", projectNameOrID)
+			}
+			return project.Id.String(), nil
 		})
 		if err != nil {
 			return "", fmt.Errorf(" Failed to get the project with specified projectNameOrID: %s , %+v", projectNameOrID, err)
 		}
-		return (*project.Id).String(), nil
+		return projectID.(string), nil
 	}
 	return projectNameOrID, nil
 }
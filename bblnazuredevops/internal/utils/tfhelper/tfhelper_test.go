@@ -8,8 +8,12 @@ import (
 	"github.com/babylonhealth/terraform-provider-bblnazuredevops/azdosdkmocks"
 	"github.com/babylonhealth/terraform-provider-bblnazuredevops/bblnazuredevops/internal/client"
 	"github.com/babylonhealth/terraform-provider-bblnazuredevops/bblnazuredevops/internal/utils/converter"
+	"github.com/babylonhealth/terraform-provider-bblnazuredevops/bblnazuredevops/internal/utils/secretmemo"
 	"github.com/golang/mock/gomock"
 	"github.com/google/uuid"
+	"github.com/hashicorp/go-cty/cty"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
 	"github.com/microsoft/azure-devops-go-api/azuredevops/v6/core"
 	"github.com/stretchr/testify/require"
 )
@@ -91,3 +95,245 @@ func TestGetRealProjectId(t *testing.T) {
 		require.Equal(t, tc.exceptProjectID, projectID)
 	}
 }
+
+func writeOnlySecretTestResource() *schema.Resource {
+	r := &schema.Resource{
+		Schema: map[string]*schema.Schema{
+			"password": {
+				Type:      schema.TypeString,
+				Optional:  true,
+				Sensitive: true,
+			},
+		},
+	}
+	for key, value := range GenerateWriteOnlySecretSchema("password") {
+		r.Schema[key] = value
+	}
+	vaultRefKey, vaultRefSchema := GenerateVaultRefSchema("password")
+	r.Schema[vaultRefKey] = vaultRefSchema
+	secretHashKey, secretHashSchema := GenerateSecreteMemoSchema("password")
+	r.Schema[secretHashKey] = secretHashSchema
+	return r
+}
+
+func TestGenerateWriteOnlySecretSchema(t *testing.T) {
+	r := writeOnlySecretTestResource()
+	require.NoError(t, r.InternalValidate(nil, true))
+	require.True(t, r.Schema["password_wo"].WriteOnly)
+	require.Equal(t, []string{"password_version"}, r.Schema["password_wo"].RequiredWith)
+	require.Equal(t, []string{"password_wo"}, r.Schema["password_version"].RequiredWith)
+}
+
+func TestGetSecret_PrefersWriteOnlyValue(t *testing.T) {
+	r := writeOnlySecretTestResource()
+	d := r.Data(&terraform.InstanceState{
+		ID: "id",
+		Attributes: map[string]string{
+			"password":         "",
+			"password_version": "1",
+		},
+		RawConfig: cty.ObjectVal(map[string]cty.Value{
+			"password":           cty.NullVal(cty.String),
+			"password_wo":        cty.StringVal("write-only"),
+			"password_version":   cty.NumberIntVal(1),
+			"password_vault_ref": cty.NullVal(cty.String),
+		}),
+	})
+	require.True(t, IsWriteOnlySecret(d, "password"))
+	require.Equal(t, "write-only", GetSecret(d, "password"))
+
+	d = schema.TestResourceDataRaw(t, r.Schema, map[string]interface{}{"password": "secret"})
+	require.False(t, IsWriteOnlySecret(d, "password"))
+	require.Equal(t, "secret", GetSecret(d, "password"))
+}
+
+// Secrets nested in a block of a single element are generated for the block and read from its configuration
+func TestGetSecret_NestedWriteOnlyValue(t *testing.T) {
+	block := &schema.Resource{
+		Schema: map[string]*schema.Schema{
+			"value": {
+				Type:      schema.TypeString,
+				Optional:  true,
+				Sensitive: true,
+			},
+		},
+	}
+	for key, value := range GenerateWriteOnlySecretSchema("token.0.value") {
+		block.Schema[key] = value
+	}
+	r := &schema.Resource{
+		Schema: map[string]*schema.Schema{
+			"token": {
+				Type:     schema.TypeList,
+				Optional: true,
+				MaxItems: 1,
+				Elem:     block,
+			},
+		},
+	}
+	require.NoError(t, r.InternalValidate(nil, true))
+	require.Equal(t, []string{"token.0.value"}, block.Schema["value_wo"].ConflictsWith)
+	require.Equal(t, []string{"token.0.value_version"}, block.Schema["value_wo"].RequiredWith)
+
+	d := r.Data(&terraform.InstanceState{
+		ID: "id",
+		Attributes: map[string]string{
+			"token.#":               "1",
+			"token.0.value":         "",
+			"token.0.value_version": "1",
+		},
+		RawConfig: cty.ObjectVal(map[string]cty.Value{
+			"token": cty.ListVal([]cty.Value{cty.ObjectVal(map[string]cty.Value{
+				"value":         cty.NullVal(cty.String),
+				"value_wo":      cty.StringVal("write-only"),
+				"value_version": cty.NumberIntVal(1),
+			})}),
+		}),
+	})
+	require.True(t, IsWriteOnlySecret(d, "token.0.value"))
+	require.Equal(t, "write-only", GetSecret(d, "token.0.value"))
+
+	d = schema.TestResourceDataRaw(t, r.Schema, map[string]interface{}{
+		"token": []interface{}{map[string]interface{}{"value": "secret"}},
+	})
+	require.False(t, IsWriteOnlySecret(d, "token.0.value"))
+	require.Equal(t, "secret", GetSecret(d, "token.0.value"))
+}
+
+func TestHelpFlattenSecret_ClearsWriteOnlySecret(t *testing.T) {
+	r := writeOnlySecretTestResource()
+	d := r.Data(&terraform.InstanceState{
+		ID: "id",
+		Attributes: map[string]string{
+			"password":         "secret",
+			"password_hash":    "hash",
+			"password_version": "2",
+		},
+	})
+
+	HelpFlattenSecret(d, "password")
+	require.Equal(t, "", d.Get("password"))
+	require.Equal(t, "", d.Get("password_hash"))
+}
+
+func TestHelpFlattenSecret_ClearsVaultSecret(t *testing.T) {
+	r := writeOnlySecretTestResource()
+	d := r.Data(&terraform.InstanceState{
+		ID: "id",
+		Attributes: map[string]string{
+			"password":           "secret",
+			"password_hash":      "hash",
+			"password_vault_ref": "secret/data/azdo#password",
+		},
+	})
+
+	require.False(t, IsWriteOnlySecret(d, "password"))
+	require.True(t, IsSecretKeptOutOfState(d, "password"))
+	require.Equal(t, "secret/data/azdo#password", GetVaultRef(d, "password"))
+
+	HelpFlattenSecret(d, "password")
+	require.Equal(t, "", d.Get("password"))
+	require.Equal(t, "", d.Get("password_hash"))
+}
+
+func TestGetVaultRefMap(t *testing.T) {
+	vaultRefKey, vaultRefSchema := GenerateVaultRefMapSchema("secrets")
+	require.Equal(t, "secrets_vault_ref", vaultRefKey)
+	r := &schema.Resource{Schema: map[string]*schema.Schema{vaultRefKey: vaultRefSchema}}
+	require.NoError(t, r.InternalValidate(nil, true))
+
+	d := schema.TestResourceDataRaw(t, r.Schema, map[string]interface{}{
+		"secrets_vault_ref": map[string]interface{}{"apiKey": "secret/data/azdo#key"},
+	})
+	require.Equal(t, map[string]string{"apiKey": "secret/data/azdo#key"}, GetVaultRefMap(d, "secrets"))
+
+	diags := vaultRefSchema.ValidateDiagFunc(map[string]interface{}{"apiKey": "secret/data/azdo"}, nil)
+	require.True(t, diags.HasError())
+}
+
+func useSecretMemo(t *testing.T, name string, hmacKey string) {
+	previous := secretmemo.CurrentStrategy()
+	t.Cleanup(func() { secretmemo.SetStrategy(previous) })
+
+	strategy, err := secretmemo.New(name, hmacKey)
+	require.NoError(t, err)
+	secretmemo.SetStrategy(strategy)
+}
+
+func TestSecretMemoStateUpgrader(t *testing.T) {
+	_, bcryptMemo, err := secretmemo.IsUpdating("secret", "")
+	require.NoError(t, err)
+
+	upgrader := SecretMemoStateUpgrader(writeOnlySecretTestResource(), "password")
+	require.Equal(t, 0, upgrader.Version)
+
+	state, err := upgrader.Upgrade(context.Background(), map[string]interface{}{"password_hash": bcryptMemo}, nil)
+	require.NoError(t, err)
+	require.Equal(t, bcryptMemo, state["password_hash"])
+
+	useSecretMemo(t, secretmemo.StrategyHMACSHA256, "0123456789abcdef")
+
+	state, err = upgrader.Upgrade(context.Background(), map[string]interface{}{"password_hash": bcryptMemo}, nil)
+	require.NoError(t, err)
+	require.Equal(t, "", state["password_hash"])
+}
+
+func TestHelpFlattenSecret_DropsMemoOfOtherStrategy(t *testing.T) {
+	_, bcryptMemo, err := secretmemo.IsUpdating("secret", "")
+	require.NoError(t, err)
+
+	useSecretMemo(t, secretmemo.StrategyNone, "")
+
+	r := writeOnlySecretTestResource()
+	d := r.Data(&terraform.InstanceState{
+		ID: "id",
+		Attributes: map[string]string{
+			"password_hash": bcryptMemo,
+		},
+	})
+
+	HelpFlattenSecret(d, "password")
+	require.Equal(t, "", d.Get("password_hash"))
+}
+
+func TestDiffFuncSuppressSecretChanged_NoneNeverSuppressesSecrets(t *testing.T) {
+	useSecretMemo(t, secretmemo.StrategyNone, "")
+
+	r := writeOnlySecretTestResource()
+	require.False(t, DiffFuncSuppressSecretChanged("password", "", "secret", r.Data(nil)))
+	require.False(t, DiffFuncSuppressSecretChanged("password", "", "secret", r.Data(&terraform.InstanceState{ID: "id"})))
+	// write-only secrets leave the plain attribute blank, they are sent again when their version changes
+	require.True(t, DiffFuncSuppressSecretChanged("password", "", "", r.Data(&terraform.InstanceState{ID: "id"})))
+}
+
+func TestSecretMap_MemoizesEachSecret(t *testing.T) {
+	useSecretMemo(t, secretmemo.StrategyHMACSHA256, "0123456789abcdef")
+
+	memoKey, memoSchema := GenerateSecretMapMemoSchema("secrets")
+	r := &schema.Resource{
+		Schema: map[string]*schema.Schema{
+			"secrets": {
+				Type:             schema.TypeMap,
+				Optional:         true,
+				Sensitive:        true,
+				DiffSuppressFunc: DiffFuncSuppressSecretMapChanged,
+				Elem:             &schema.Schema{Type: schema.TypeString},
+			},
+			memoKey: memoSchema,
+		},
+	}
+
+	d := schema.TestResourceDataRaw(t, r.Schema, map[string]interface{}{
+		"secrets": map[string]interface{}{"token": "secret-1", "key": "secret-2"},
+	})
+	HelpFlattenSecretMap(d, "secrets")
+	memos := d.Get("secrets_hash").(map[string]interface{})
+	require.Len(t, memos, 2)
+	require.NotEqual(t, memos["token"], memos["key"])
+
+	d.SetId("id")
+	require.True(t, DiffFuncSuppressSecretMapChanged("secrets.token", "", "secret-1", d))
+	require.False(t, DiffFuncSuppressSecretMapChanged("secrets.token", "", "secret-2", d))
+	require.False(t, DiffFuncSuppressSecretMapChanged("secrets.other", "", "secret-1", d))
+	require.False(t, DiffFuncSuppressSecretMapChanged("secrets.%", "2", "3", d))
+}
@@ -0,0 +1,23 @@
+package validate
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// AwsIamRoleArnRegExp matches the ARN of an IAM role, in any AWS partition.
+var AwsIamRoleArnRegExp = regexp.MustCompile(`^arn:aws(-[a-z]+)*:iam::\d{12}:role/[\w+=,.@/-]{1,512}$`)
+
+// AwsIamRoleArn validates that the string is the ARN of an IAM role, e.g. arn:aws:iam::123456789012:role/deployer
+func AwsIamRoleArn(i interface{}, k string) (warnings []string, errors []error) {
+	v, ok := i.(string)
+	if !ok {
+		errors = append(errors, fmt.Errorf("expected type of %q to be string", k))
+		return
+	}
+
+	if !AwsIamRoleArnRegExp.MatchString(v) {
+		errors = append(errors, fmt.Errorf("%q must be the ARN of an IAM role like arn:aws:iam::123456789012:role/name, got %q", k, v))
+	}
+	return warnings, errors
+}
@@ -0,0 +1,56 @@
+//go:build all || utils || arn
+// +build all utils arn
+
+package validate
+
+import (
+	"testing"
+)
+
+func TestAwsIamRoleArnValidation(t *testing.T) {
+	cases := []struct {
+		Value    string
+		TestName string
+		ErrCount int
+	}{
+		{
+			Value:    "arn:aws:iam::123456789012:role/deployer",
+			TestName: "Role",
+			ErrCount: 0,
+		},
+		{
+			Value:    "arn:aws-us-gov:iam::123456789012:role/teams/platform/deployer",
+			TestName: "Role With Path In Another Partition",
+			ErrCount: 0,
+		},
+		{
+			Value:    "",
+			TestName: "Empty",
+			ErrCount: 1,
+		},
+		{
+			Value:    "arn:aws:iam::123456789012:user/deployer",
+			TestName: "User",
+			ErrCount: 1,
+		},
+		{
+			Value:    "arn:aws:iam::1234:role/deployer",
+			TestName: "Invalid Account",
+			ErrCount: 1,
+		},
+		{
+			Value:    "arn:aws:sts::123456789012:assumed-role/deployer/session",
+			TestName: "Assumed Role",
+			ErrCount: 1,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.TestName, func(t *testing.T) {
+			_, errors := AwsIamRoleArn(tc.Value, tc.TestName)
+			if len(errors) != tc.ErrCount {
+				t.Fatalf("Expected TestAwsIamRoleArnValidation to have %d not %d errors for %q", tc.ErrCount, len(errors), tc.TestName)
+			}
+		})
+	}
+}
@@ -2,66 +2,250 @@ package bblnazuredevops
 
 import (
 	"context"
-	"github.com/babylonhealth/terraform-provider-bblnazuredevops/bblnazuredevops/internal/client"
-	exclusivelock "github.com/babylonhealth/terraform-provider-bblnazuredevops/bblnazuredevops/internal/service/checks/exclusivelock/resource"
-	invokerestapi "github.com/babylonhealth/terraform-provider-bblnazuredevops/bblnazuredevops/internal/service/checks/invokerestapi/resource"
-	manualapproval "github.com/babylonhealth/terraform-provider-bblnazuredevops/bblnazuredevops/internal/service/checks/manualapproval/resource"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/babylonhealth/terraform-provider-bblnazuredevops/bblnazuredevops/internal/client/apiversion"
+	"github.com/babylonhealth/terraform-provider-bblnazuredevops/bblnazuredevops/internal/client/vault"
+	"github.com/babylonhealth/terraform-provider-bblnazuredevops/bblnazuredevops/internal/service/environment"
 	"github.com/babylonhealth/terraform-provider-bblnazuredevops/bblnazuredevops/internal/service/githubapp"
 	"github.com/babylonhealth/terraform-provider-bblnazuredevops/bblnazuredevops/internal/service/permissions"
+	"github.com/babylonhealth/terraform-provider-bblnazuredevops/bblnazuredevops/internal/service/pipelineauthorization"
+	"github.com/babylonhealth/terraform-provider-bblnazuredevops/bblnazuredevops/internal/service/securityroles"
 	"github.com/babylonhealth/terraform-provider-bblnazuredevops/bblnazuredevops/internal/service/serviceendpoint"
+	"github.com/babylonhealth/terraform-provider-bblnazuredevops/bblnazuredevops/internal/utils/secretmemo"
+	"github.com/hashicorp/go-cty/cty"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
 )
 
 // Provider - The top level Azure DevOps Provider definition.
 func Provider() *schema.Provider {
+	return newProvider(newClientFactory())
+}
+
+func newProvider(clients *clientFactory) *schema.Provider {
 	p := &schema.Provider{
 		ResourcesMap: map[string]*schema.Resource{
-			"bblnazuredevops_build_permissions":              permissions.ResourcePipelinePermissions(),
-			"bblnazuredevops_check_invokerestapi":            invokerestapi.ResourceCheckInvokeRestAPI(),
-			"bblnazuredevops_check_manualapproval":           manualapproval.ResourceCheckManualApproval(),
-			"bblnazuredevops_check_exclusivelock":            exclusivelock.ResourceCheckExclusiveLock(),
-			"bblnazuredevops_serviceendpoint_genericwebhook": serviceendpoint.ResourceServiceEndpointGenericWebhook(),
-			"bblnazuredevops_serviceendpoint_babylonawsiam":  serviceendpoint.ResourceServiceEndpointBabylonAwsIam(),
-			"bblnazuredevops_serviceendpoint_babylonvault":   serviceendpoint.ResourceServiceEndpointBabylonVault(),
-			"bblnazuredevops_serviceendpoint_githubapp":      githubapp.ResourceGithubApp(),
+			"bblnazuredevops_build_permissions":               permissions.ResourcePipelinePermissions(),
+			"bblnazuredevops_serviceendpoint_permissions":     permissions.ResourceServiceEndpointPermissions(),
+			"bblnazuredevops_environment_permissions":         permissions.ResourceEnvironmentPermissions(),
+			"bblnazuredevops_security_permissions":            permissions.ResourceSecurityPermissions(),
+			"bblnazuredevops_serviceendpoint_genericwebhook":  serviceendpoint.ResourceServiceEndpointGenericWebhook(),
+			"bblnazuredevops_serviceendpoint_babylonawsiam":   serviceendpoint.ResourceServiceEndpointBabylonAwsIam(),
+			"bblnazuredevops_serviceendpoint_babylonvault":    serviceendpoint.ResourceServiceEndpointBabylonVault(),
+			"bblnazuredevops_serviceendpoint_githubapp":       githubapp.ResourceGithubApp(),
+			"bblnazuredevops_serviceendpoint_custom":          serviceendpoint.ResourceServiceEndpointCustom(),
+			"bblnazuredevops_serviceendpoint_incomingwebhook": serviceendpoint.ResourceServiceEndpointIncomingWebhook(),
+			"bblnazuredevops_pipeline_authorization":          pipelineauthorization.ResourcePipelineAuthorization(),
+			"bblnazuredevops_securityrole_assignment":         securityroles.ResourceSecurityRoleAssignment(),
+			"bblnazuredevops_environment":                     environment.ResourceEnvironment(),
 		},
-		DataSourcesMap: map[string]*schema.Resource{},
-		Schema: map[string]*schema.Schema{
-			"org_service_url": {
-				Type:        schema.TypeString,
-				Optional:    true,
-				DefaultFunc: schema.EnvDefaultFunc("AZDO_ORG_SERVICE_URL", nil),
-				Description: "The url of the Azure DevOps instance which should be used.",
-			},
-			"personal_access_token": {
-				Type:        schema.TypeString,
-				Optional:    true,
-				DefaultFunc: schema.EnvDefaultFunc("AZDO_PERSONAL_ACCESS_TOKEN", nil),
-				Description: "The personal access token which should be used.",
-				Sensitive:   true,
-			},
+		DataSourcesMap: map[string]*schema.Resource{
+			"bblnazuredevops_serviceendpoint":       serviceendpoint.DataServiceEndpoint(),
+			"bblnazuredevops_serviceendpoints":      serviceendpoint.DataServiceEndpoints(),
+			"bblnazuredevops_serviceendpoint_types": serviceendpoint.DataServiceEndpointTypes(),
 		},
+		Schema: providerSchema(),
 	}
 
-	p.ConfigureContextFunc = providerConfigure(p)
+	p.ConfigureContextFunc = providerConfigure(p, clients)
 
 	return p
 }
 
-func providerConfigure(p *schema.Provider) schema.ConfigureContextFunc {
+// providerSchema is the schema of the provider. The schema of the framework provider is derived from it, as
+// both providers served by the mux server must have the same schema.
+func providerSchema() map[string]*schema.Schema {
+	return map[string]*schema.Schema{
+		"org_service_url": {
+			Type:        schema.TypeString,
+			Optional:    true,
+			DefaultFunc: schema.EnvDefaultFunc("AZDO_ORG_SERVICE_URL", nil),
+			Description: "The url of the Azure DevOps instance which should be used. Either an Azure DevOps Services organization (https://dev.azure.com/<organization>) or an Azure DevOps Server collection (https://<server>/<collection>).",
+		},
+		"personal_access_token": {
+			Type:        schema.TypeString,
+			Optional:    true,
+			DefaultFunc: schema.EnvDefaultFunc("AZDO_PERSONAL_ACCESS_TOKEN", nil),
+			Description: "The personal access token which should be used.",
+			Sensitive:   true,
+		},
+		"default_project": {
+			Type:         schema.TypeString,
+			Optional:     true,
+			DefaultFunc:  schema.EnvDefaultFunc("AZDO_DEFAULT_PROJECT", nil),
+			ValidateFunc: validation.StringIsNotWhiteSpace,
+			Description:  "The name or ID of the project used by the resources which do not set a project_id.",
+		},
+		"api_versions": {
+			Type:     schema.TypeMap,
+			Optional: true,
+			Elem: &schema.Schema{
+				Type: schema.TypeString,
+			},
+			ValidateDiagFunc: validateAPIVersions,
+			Description:      fmt.Sprintf("Overrides the negotiated api-version of the REST resources which are not covered by the Azure DevOps SDK. Valid keys are: %s.", strings.Join(apiversion.Keys(), ", ")),
+		},
+		"cache_ttl_seconds": {
+			Type:         schema.TypeInt,
+			Optional:     true,
+			Default:      defaultCacheTTLSeconds,
+			ValidateFunc: validation.IntAtLeast(0),
+			Description:  "How long lookups shared by many resources, like the checks of a resource or the actions of a security namespace, are cached. They are invalidated whenever the provider writes to them. Set to 0 to disable caching.",
+		},
+		"max_concurrent_requests": {
+			Type:         schema.TypeInt,
+			Optional:     true,
+			Default:      defaultMaxConcurrentRequests,
+			ValidateFunc: validation.IntAtLeast(0),
+			Description:  "The maximum number of requests sent to Azure DevOps at the same time, regardless of the parallelism of Terraform. Set to 0 to disable the limit.",
+		},
+		"secret_memo": {
+			Type:         schema.TypeString,
+			Optional:     true,
+			ValidateFunc: validation.StringInSlice(secretmemo.Strategies(), false),
+			Description:  fmt.Sprintf("How changes of secrets are detected without storing them in the state. %s keeps a bcrypt hash of the secret, %s an HMAC-SHA256 of the secret keyed by secret_memo_hmac_key and %s nothing, secrets are then sent again on every apply, unless they are write-only secrets which are only sent again when their version changes. Defaults to %s.", secretmemo.StrategyBcrypt, secretmemo.StrategyHMACSHA256, secretmemo.StrategyNone, secretmemo.StrategyBcrypt),
+		},
+		"secret_memo_hmac_key": {
+			Type:        schema.TypeString,
+			Optional:    true,
+			Sensitive:   true,
+			DefaultFunc: schema.EnvDefaultFunc("AZDO_SECRET_MEMO_HMAC_KEY", nil),
+			Description: fmt.Sprintf("The key of the %s secret_memo, at least 16 characters long.", secretmemo.StrategyHMACSHA256),
+		},
+		"vault": {
+			Type:        schema.TypeList,
+			Optional:    true,
+			MaxItems:    1,
+			Description: "The Vault server the secrets referenced by the *_vault_ref arguments of resources are read from, when they are applied. These secrets are never stored in the state.",
+			Elem: &schema.Resource{
+				Schema: map[string]*schema.Schema{
+					"address": {
+						Type:         schema.TypeString,
+						Optional:     true,
+						DefaultFunc:  schema.EnvDefaultFunc("VAULT_ADDR", nil),
+						ValidateFunc: validation.IsURLWithHTTPorHTTPS,
+						Description:  "The address of the Vault server.",
+					},
+					"namespace": {
+						Type:        schema.TypeString,
+						Optional:    true,
+						DefaultFunc: schema.EnvDefaultFunc("VAULT_NAMESPACE", nil),
+						Description: "The Vault Enterprise namespace of the secrets.",
+					},
+					"auth_method": {
+						Type:         schema.TypeString,
+						Optional:     true,
+						ValidateFunc: validation.StringInSlice([]string{vault.AuthMethodToken, vault.AuthMethodAppRole}, false),
+						Description:  fmt.Sprintf("How the provider logs in to Vault, either %s or %s. Defaults to %s.", vault.AuthMethodToken, vault.AuthMethodAppRole, vault.AuthMethodToken),
+					},
+					"token": {
+						Type:        schema.TypeString,
+						Optional:    true,
+						Sensitive:   true,
+						DefaultFunc: schema.EnvDefaultFunc("VAULT_TOKEN", nil),
+						Description: fmt.Sprintf("The token used by the %s auth method.", vault.AuthMethodToken),
+					},
+					"role_id": {
+						Type:        schema.TypeString,
+						Optional:    true,
+						DefaultFunc: schema.EnvDefaultFunc("VAULT_ROLE_ID", nil),
+						Description: fmt.Sprintf("The role ID used by the %s auth method.", vault.AuthMethodAppRole),
+					},
+					"secret_id": {
+						Type:        schema.TypeString,
+						Optional:    true,
+						Sensitive:   true,
+						DefaultFunc: schema.EnvDefaultFunc("VAULT_SECRET_ID", nil),
+						Description: fmt.Sprintf("The secret ID used by the %s auth method.", vault.AuthMethodAppRole),
+					},
+					"auth_mount": {
+						Type:        schema.TypeString,
+						Optional:    true,
+						Description: fmt.Sprintf("The path the %s auth method is mounted at. Defaults to approle.", vault.AuthMethodAppRole),
+					},
+				},
+			},
+		},
+		"requests_per_second": {
+			Type:         schema.TypeFloat,
+			Optional:     true,
+			Default:      defaultRequestsPerSecond,
+			ValidateFunc: validation.FloatAtLeast(0),
+			Description:  "The average number of requests per second sent to Azure DevOps. The provider slows down below this rate while Azure DevOps reports throttling. Set to 0 to disable the limit.",
+		},
+	}
+}
+
+func providerConfigure(p *schema.Provider, clients *clientFactory) schema.ConfigureContextFunc {
 	return func(ctx context.Context, d *schema.ResourceData) (interface{}, diag.Diagnostics) {
-		terraformVersion := p.TerraformVersion
-		if terraformVersion == "" {
-			// Terraform 0.12 introduced this field to the protocol
-			// We can therefore assume that if it's missing it's 0.10 or 0.11
-			terraformVersion = "0.11+compatible"
+		apiVersions := map[string]string{}
+		for key, value := range d.Get("api_versions").(map[string]interface{}) {
+			apiVersions[key] = value.(string)
+		}
+
+		var vaultConfig *vault.Config
+		if len(d.Get("vault").([]interface{})) > 0 {
+			vaultConfig = &vault.Config{
+				Address:    d.Get("vault.0.address").(string),
+				Namespace:  d.Get("vault.0.namespace").(string),
+				AuthMethod: d.Get("vault.0.auth_method").(string),
+				Token:      d.Get("vault.0.token").(string),
+				RoleID:     d.Get("vault.0.role_id").(string),
+				SecretID:   d.Get("vault.0.secret_id").(string),
+				AuthMount:  d.Get("vault.0.auth_mount").(string),
+			}
 		}
-		azdoPAT := d.Get("personal_access_token").(string)
-		organizationURL := d.Get("org_service_url").(string)
 
-		azdoClient, err := client.GetAzdoClient(azdoPAT, organizationURL, terraformVersion)
+		azdoClient, err := clients.Client(ctx, providerConfig{
+			TerraformVersion:      p.TerraformVersion,
+			OrganizationURL:       d.Get("org_service_url").(string),
+			PersonalAccessToken:   d.Get("personal_access_token").(string),
+			DefaultProject:        d.Get("default_project").(string),
+			SecretMemo:            d.Get("secret_memo").(string),
+			SecretMemoHMACKey:     d.Get("secret_memo_hmac_key").(string),
+			APIVersions:           apiVersions,
+			CacheTTL:              time.Duration(d.Get("cache_ttl_seconds").(int)) * time.Second,
+			MaxConcurrentRequests: d.Get("max_concurrent_requests").(int),
+			RequestsPerSecond:     d.Get("requests_per_second").(float64),
+			Vault:                 vaultConfig,
+		})
+		if err != nil {
+			return nil, diag.FromErr(err)
+		}
+
+		return azdoClient, nil
+	}
+}
 
-		return azdoClient, diag.FromErr(err)
+func validateAPIVersions(i interface{}, path cty.Path) diag.Diagnostics {
+	var diags diag.Diagnostics
+	for key, value := range i.(map[string]interface{}) {
+		valid := false
+		for _, k := range apiversion.Keys() {
+			if k == key {
+				valid = true
+			}
+		}
+		if !valid {
+			diags = append(diags, diag.Diagnostic{
+				Severity:      diag.Error,
+				Summary:       fmt.Sprintf("Unsupported api_versions key %q", key),
+				Detail:        fmt.Sprintf("Valid keys are: %s", strings.Join(apiversion.Keys(), ", ")),
+				AttributePath: append(path, cty.IndexStep{Key: cty.StringVal(key)}),
+			})
+			continue
+		}
+		if err := apiversion.ValidateAPIVersion(value.(string)); err != nil {
+			diags = append(diags, diag.Diagnostic{
+				Severity:      diag.Error,
+				Summary:       err.Error(),
+				AttributePath: append(path, cty.IndexStep{Key: cty.StringVal(key)}),
+			})
+		}
 	}
+	return diags
 }
@@ -0,0 +1,233 @@
+package bblnazuredevops
+
+import (
+	"context"
+	"os"
+	"time"
+
+	"github.com/babylonhealth/terraform-provider-bblnazuredevops/bblnazuredevops/internal/client/vault"
+	exclusivelock "github.com/babylonhealth/terraform-provider-bblnazuredevops/bblnazuredevops/internal/service/checks/exclusivelock/resource"
+	invokerestapi "github.com/babylonhealth/terraform-provider-bblnazuredevops/bblnazuredevops/internal/service/checks/invokerestapi/resource"
+	manualapproval "github.com/babylonhealth/terraform-provider-bblnazuredevops/bblnazuredevops/internal/service/checks/manualapproval/resource"
+	"github.com/hashicorp/terraform-plugin-framework/datasource"
+	"github.com/hashicorp/terraform-plugin-framework/provider"
+	"github.com/hashicorp/terraform-plugin-framework/provider/schema"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	sdkschema "github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+var _ provider.Provider = &frameworkProvider{}
+
+// frameworkProvider serves the resources implemented with the plugin framework. It is muxed with the SDKv2
+// provider returned by Provider, which still serves all other resources.
+type frameworkProvider struct {
+	clients *clientFactory
+}
+
+type frameworkProviderModel struct {
+	OrgServiceURL         types.String  `tfsdk:"org_service_url"`
+	PersonalAccessToken   types.String  `tfsdk:"personal_access_token"`
+	DefaultProject        types.String  `tfsdk:"default_project"`
+	SecretMemo            types.String  `tfsdk:"secret_memo"`
+	SecretMemoHMACKey     types.String  `tfsdk:"secret_memo_hmac_key"`
+	APIVersions           types.Map     `tfsdk:"api_versions"`
+	CacheTTLSeconds       types.Int64   `tfsdk:"cache_ttl_seconds"`
+	MaxConcurrentRequests types.Int64   `tfsdk:"max_concurrent_requests"`
+	RequestsPerSecond     types.Float64 `tfsdk:"requests_per_second"`
+	Vault                 types.List    `tfsdk:"vault"`
+}
+
+type frameworkVaultModel struct {
+	Address    types.String `tfsdk:"address"`
+	Namespace  types.String `tfsdk:"namespace"`
+	AuthMethod types.String `tfsdk:"auth_method"`
+	Token      types.String `tfsdk:"token"`
+	RoleID     types.String `tfsdk:"role_id"`
+	SecretID   types.String `tfsdk:"secret_id"`
+	AuthMount  types.String `tfsdk:"auth_mount"`
+}
+
+// NewFrameworkProvider returns the plugin framework part of the provider
+func NewFrameworkProvider() provider.Provider {
+	return newFrameworkProvider(newClientFactory())
+}
+
+func newFrameworkProvider(clients *clientFactory) *frameworkProvider {
+	return &frameworkProvider{clients: clients}
+}
+
+func (p *frameworkProvider) Metadata(_ context.Context, _ provider.MetadataRequest, resp *provider.MetadataResponse) {
+	resp.TypeName = "bblnazuredevops"
+}
+
+// Schema mirrors providerSchema. The configuration is only validated by the SDKv2 provider, so that errors are
+// not reported twice.
+func (p *frameworkProvider) Schema(_ context.Context, _ provider.SchemaRequest, resp *provider.SchemaResponse) {
+	sdkSchema := providerSchema()
+	vaultSchema := sdkSchema["vault"].Elem.(*sdkschema.Resource).Schema
+
+	resp.Schema = schema.Schema{
+		Attributes: map[string]schema.Attribute{
+			"org_service_url": schema.StringAttribute{
+				Optional:    true,
+				Description: sdkSchema["org_service_url"].Description,
+			},
+			"personal_access_token": schema.StringAttribute{
+				Optional:    true,
+				Sensitive:   true,
+				Description: sdkSchema["personal_access_token"].Description,
+			},
+			"default_project": schema.StringAttribute{
+				Optional:    true,
+				Description: sdkSchema["default_project"].Description,
+			},
+			"secret_memo": schema.StringAttribute{
+				Optional:    true,
+				Description: sdkSchema["secret_memo"].Description,
+			},
+			"secret_memo_hmac_key": schema.StringAttribute{
+				Optional:    true,
+				Sensitive:   true,
+				Description: sdkSchema["secret_memo_hmac_key"].Description,
+			},
+			"api_versions": schema.MapAttribute{
+				ElementType: types.StringType,
+				Optional:    true,
+				Description: sdkSchema["api_versions"].Description,
+			},
+			"cache_ttl_seconds": schema.Int64Attribute{
+				Optional:    true,
+				Description: sdkSchema["cache_ttl_seconds"].Description,
+			},
+			"max_concurrent_requests": schema.Int64Attribute{
+				Optional:    true,
+				Description: sdkSchema["max_concurrent_requests"].Description,
+			},
+			"requests_per_second": schema.Float64Attribute{
+				Optional:    true,
+				Description: sdkSchema["requests_per_second"].Description,
+			},
+		},
+		Blocks: map[string]schema.Block{
+			"vault": schema.ListNestedBlock{
+				Description: sdkSchema["vault"].Description,
+				NestedObject: schema.NestedBlockObject{
+					Attributes: map[string]schema.Attribute{
+						"address": schema.StringAttribute{
+							Optional:    true,
+							Description: vaultSchema["address"].Description,
+						},
+						"namespace": schema.StringAttribute{
+							Optional:    true,
+							Description: vaultSchema["namespace"].Description,
+						},
+						"auth_method": schema.StringAttribute{
+							Optional:    true,
+							Description: vaultSchema["auth_method"].Description,
+						},
+						"token": schema.StringAttribute{
+							Optional:    true,
+							Sensitive:   true,
+							Description: vaultSchema["token"].Description,
+						},
+						"role_id": schema.StringAttribute{
+							Optional:    true,
+							Description: vaultSchema["role_id"].Description,
+						},
+						"secret_id": schema.StringAttribute{
+							Optional:    true,
+							Sensitive:   true,
+							Description: vaultSchema["secret_id"].Description,
+						},
+						"auth_mount": schema.StringAttribute{
+							Optional:    true,
+							Description: vaultSchema["auth_mount"].Description,
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+func (p *frameworkProvider) Configure(ctx context.Context, req provider.ConfigureRequest, resp *provider.ConfigureResponse) {
+	var model frameworkProviderModel
+	resp.Diagnostics.Append(req.Config.Get(ctx, &model)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	config := providerConfig{
+		TerraformVersion:      req.TerraformVersion,
+		OrganizationURL:       stringOrEnv(model.OrgServiceURL, "AZDO_ORG_SERVICE_URL"),
+		PersonalAccessToken:   stringOrEnv(model.PersonalAccessToken, "AZDO_PERSONAL_ACCESS_TOKEN"),
+		DefaultProject:        stringOrEnv(model.DefaultProject, "AZDO_DEFAULT_PROJECT"),
+		SecretMemo:            model.SecretMemo.ValueString(),
+		SecretMemoHMACKey:     stringOrEnv(model.SecretMemoHMACKey, "AZDO_SECRET_MEMO_HMAC_KEY"),
+		APIVersions:           map[string]string{},
+		CacheTTL:              time.Duration(defaultCacheTTLSeconds) * time.Second,
+		MaxConcurrentRequests: defaultMaxConcurrentRequests,
+		RequestsPerSecond:     defaultRequestsPerSecond,
+	}
+	if !model.APIVersions.IsNull() && !model.APIVersions.IsUnknown() {
+		resp.Diagnostics.Append(model.APIVersions.ElementsAs(ctx, &config.APIVersions, false)...)
+		if resp.Diagnostics.HasError() {
+			return
+		}
+	}
+	if !model.CacheTTLSeconds.IsNull() && !model.CacheTTLSeconds.IsUnknown() {
+		config.CacheTTL = time.Duration(model.CacheTTLSeconds.ValueInt64()) * time.Second
+	}
+	if !model.MaxConcurrentRequests.IsNull() && !model.MaxConcurrentRequests.IsUnknown() {
+		config.MaxConcurrentRequests = int(model.MaxConcurrentRequests.ValueInt64())
+	}
+	if !model.RequestsPerSecond.IsNull() && !model.RequestsPerSecond.IsUnknown() {
+		config.RequestsPerSecond = model.RequestsPerSecond.ValueFloat64()
+	}
+	if !model.Vault.IsNull() && !model.Vault.IsUnknown() && len(model.Vault.Elements()) > 0 {
+		var vaultModels []frameworkVaultModel
+		resp.Diagnostics.Append(model.Vault.ElementsAs(ctx, &vaultModels, false)...)
+		if resp.Diagnostics.HasError() {
+			return
+		}
+		config.Vault = &vault.Config{
+			Address:    stringOrEnv(vaultModels[0].Address, "VAULT_ADDR"),
+			Namespace:  stringOrEnv(vaultModels[0].Namespace, "VAULT_NAMESPACE"),
+			AuthMethod: vaultModels[0].AuthMethod.ValueString(),
+			Token:      stringOrEnv(vaultModels[0].Token, "VAULT_TOKEN"),
+			RoleID:     stringOrEnv(vaultModels[0].RoleID, "VAULT_ROLE_ID"),
+			SecretID:   stringOrEnv(vaultModels[0].SecretID, "VAULT_SECRET_ID"),
+			AuthMount:  vaultModels[0].AuthMount.ValueString(),
+		}
+	}
+
+	azdoClient, err := p.clients.Client(ctx, config)
+	if err != nil {
+		resp.Diagnostics.AddError("Error configuring the Azure DevOps client", err.Error())
+		return
+	}
+
+	resp.ResourceData = azdoClient
+	resp.DataSourceData = azdoClient
+}
+
+func (p *frameworkProvider) Resources(_ context.Context) []func() resource.Resource {
+	return []func() resource.Resource{
+		invokerestapi.NewCheckInvokeRestAPIResource,
+		manualapproval.NewCheckManualApprovalResource,
+		exclusivelock.NewCheckExclusiveLockResource,
+	}
+}
+
+func (p *frameworkProvider) DataSources(_ context.Context) []func() datasource.DataSource {
+	return nil
+}
+
+// stringOrEnv returns the configured value, falling back to the environment variable like schema.EnvDefaultFunc
+func stringOrEnv(value types.String, envVar string) string {
+	if value.IsNull() || value.IsUnknown() {
+		return os.Getenv(envVar)
+	}
+	return value.ValueString()
+}
@@ -4,6 +4,7 @@ import (
 	"os"
 	"testing"
 
+	sdkschema "github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
 	"github.com/stretchr/testify/require"
 )
 
@@ -18,6 +19,14 @@ func TestProvider_SchemaIsValid(t *testing.T) {
 	tests := []testParams{
 		{"org_service_url", false, "AZDO_ORG_SERVICE_URL", false},
 		{"personal_access_token", false, "AZDO_PERSONAL_ACCESS_TOKEN", true},
+		{"default_project", false, "AZDO_DEFAULT_PROJECT", false},
+		{"secret_memo", false, "", false},
+		{"secret_memo_hmac_key", false, "AZDO_SECRET_MEMO_HMAC_KEY", true},
+		{"api_versions", false, "", false},
+		{"cache_ttl_seconds", false, "", false},
+		{"max_concurrent_requests", false, "", false},
+		{"requests_per_second", false, "", false},
+		{"vault", false, "", false},
 	}
 
 	schema := Provider().Schema
@@ -42,3 +51,37 @@ func TestProvider_SchemaIsValid(t *testing.T) {
 		}
 	}
 }
+
+func TestProvider_VaultSchemaIsValid(t *testing.T) {
+	tests := map[string]struct {
+		defaultEnvVar string
+		sensitive     bool
+	}{
+		"address":     {"VAULT_ADDR", false},
+		"namespace":   {"VAULT_NAMESPACE", false},
+		"auth_method": {"", false},
+		"token":       {"VAULT_TOKEN", true},
+		"role_id":     {"VAULT_ROLE_ID", false},
+		"secret_id":   {"VAULT_SECRET_ID", true},
+		"auth_mount":  {"", false},
+	}
+
+	vaultSchema := Provider().Schema["vault"]
+	require.Equal(t, 1, vaultSchema.MaxItems)
+
+	schema := vaultSchema.Elem.(*sdkschema.Resource).Schema
+	require.Equal(t, len(tests), len(schema), "There are an unexpected number of properties in the vault schema")
+
+	for name, test := range tests {
+		require.Contains(t, schema, name, "An expected property was not found in the vault schema")
+		require.Equal(t, test.sensitive, schema[name].Sensitive, "A property in the vault schema has an incorrect sensitivity value")
+		require.False(t, schema[name].Required, "A property in the vault schema has an incorrect required value")
+
+		if test.defaultEnvVar != "" {
+			t.Setenv(test.defaultEnvVar, "value")
+			actualValue, err := schema[name].DefaultFunc()
+			require.NoError(t, err)
+			require.Equal(t, "value", actualValue, "The default value pulled from the environment has the wrong value")
+		}
+	}
+}
@@ -0,0 +1,27 @@
+package bblnazuredevops
+
+import (
+	"context"
+
+	"github.com/hashicorp/terraform-plugin-framework/providerserver"
+	"github.com/hashicorp/terraform-plugin-go/tfprotov5"
+	"github.com/hashicorp/terraform-plugin-mux/tf5muxserver"
+)
+
+// ProtoV5ProviderServerFactory returns the server of the provider. It muxes the SDKv2 provider with the framework
+// provider, every resource is served by exactly one of them and both share the client of the provider instance.
+func ProtoV5ProviderServerFactory(ctx context.Context) (func() tfprotov5.ProviderServer, error) {
+	clients := newClientFactory()
+
+	servers := []func() tfprotov5.ProviderServer{
+		newProvider(clients).GRPCProvider,
+		providerserver.NewProtocol5(newFrameworkProvider(clients)),
+	}
+
+	muxServer, err := tf5muxserver.NewMuxServer(ctx, servers...)
+	if err != nil {
+		return nil, err
+	}
+
+	return muxServer.ProviderServer, nil
+}
@@ -0,0 +1,171 @@
+package bblnazuredevops
+
+import (
+	"context"
+	"math/big"
+	"sort"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-go/tfprotov5"
+	"github.com/hashicorp/terraform-plugin-go/tftypes"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestProviderServer(t *testing.T) tfprotov5.ProviderServer {
+	serverFactory, err := ProtoV5ProviderServerFactory(context.Background())
+	require.NoError(t, err)
+	return serverFactory()
+}
+
+// The mux server rejects providers whose schemas differ, and resources served by both providers
+func TestProviderServer_GetProviderSchema(t *testing.T) {
+	resp, err := newTestProviderServer(t).GetProviderSchema(context.Background(), &tfprotov5.GetProviderSchemaRequest{})
+	require.NoError(t, err)
+	require.Empty(t, resp.Diagnostics)
+
+	resources := []string{}
+	for name := range resp.ResourceSchemas {
+		resources = append(resources, name)
+	}
+	sort.Strings(resources)
+
+	require.Equal(t, []string{
+		"bblnazuredevops_build_permissions",
+		"bblnazuredevops_check_exclusivelock",
+		"bblnazuredevops_check_invokerestapi",
+		"bblnazuredevops_check_manualapproval",
+		"bblnazuredevops_environment",
+		"bblnazuredevops_environment_permissions",
+		"bblnazuredevops_pipeline_authorization",
+		"bblnazuredevops_security_permissions",
+		"bblnazuredevops_securityrole_assignment",
+		"bblnazuredevops_serviceendpoint_babylonawsiam",
+		"bblnazuredevops_serviceendpoint_babylonvault",
+		"bblnazuredevops_serviceendpoint_custom",
+		"bblnazuredevops_serviceendpoint_genericwebhook",
+		"bblnazuredevops_serviceendpoint_githubapp",
+		"bblnazuredevops_serviceendpoint_incomingwebhook",
+		"bblnazuredevops_serviceendpoint_permissions",
+	}, resources)
+
+	dataSources := []string{}
+	for name := range resp.DataSourceSchemas {
+		dataSources = append(dataSources, name)
+	}
+	sort.Strings(dataSources)
+
+	require.Equal(t, []string{
+		"bblnazuredevops_serviceendpoint",
+		"bblnazuredevops_serviceendpoint_types",
+		"bblnazuredevops_serviceendpoints",
+	}, dataSources)
+}
+
+func TestProviderServer_UpgradeCheckState(t *testing.T) {
+	server := newTestProviderServer(t)
+
+	schemaResp, err := server.GetProviderSchema(context.Background(), &tfprotov5.GetProviderSchemaRequest{})
+	require.NoError(t, err)
+	checkSchema := schemaResp.ResourceSchemas["bblnazuredevops_check_invokerestapi"]
+	require.Equal(t, int64(1), checkSchema.Version)
+
+	// the state stored by the SDKv2 implementation of the resource
+	stateV0 := `{
+		"id": "42",
+		"project_id": "3c49c3b6-a06d-424d-a6b6-0cd375ee9261",
+		"resource_id": "d8b3f1a2-8f3c-4a55-9c57-2b5b1e7e3f44",
+		"type": "endpoint",
+		"timeout": 43200,
+		"service_connection_id": "0f8b6ab4-4bcd-4d4f-a1c3-4b5b7a0a4e9c",
+		"linked_variable_group": "",
+		"retry_interval": 5,
+		"display_name": "check",
+		"method": "POST",
+		"use_callback": false,
+		"body": "{}",
+		"url_suffix": "",
+		"success_criteria": "",
+		"headers": {}
+	}`
+
+	resp, err := server.UpgradeResourceState(context.Background(), &tfprotov5.UpgradeResourceStateRequest{
+		TypeName: "bblnazuredevops_check_invokerestapi",
+		Version:  0,
+		RawState: &tfprotov5.RawState{JSON: []byte(stateV0)},
+	})
+	require.NoError(t, err)
+	require.Empty(t, resp.Diagnostics)
+
+	stateType := checkSchema.ValueType()
+	upgraded, err := resp.UpgradedState.Unmarshal(stateType)
+	require.NoError(t, err)
+
+	var attributes map[string]tftypes.Value
+	require.NoError(t, upgraded.As(&attributes))
+
+	var id, body string
+	require.NoError(t, attributes["id"].As(&id))
+	require.NoError(t, attributes["body"].As(&body))
+	require.Equal(t, "42", id)
+	require.Equal(t, "{}", body)
+
+	for _, name := range []string{"linked_variable_group", "url_suffix", "success_criteria", "headers"} {
+		require.True(t, attributes[name].IsNull(), "%s should be null after the upgrade", name)
+	}
+}
+
+// Removing timeout and retry_interval from the configuration plans them back to 0, as the SDKv2 implementation did
+func TestProviderServer_PlanCheckDefaults(t *testing.T) {
+	server := newTestProviderServer(t)
+
+	schemaResp, err := server.GetProviderSchema(context.Background(), &tfprotov5.GetProviderSchemaRequest{})
+	require.NoError(t, err)
+	stateType := schemaResp.ResourceSchemas["bblnazuredevops_check_invokerestapi"].ValueType()
+
+	dynamicValue := func(json string) *tfprotov5.DynamicValue {
+		value, err := tftypes.ValueFromJSON([]byte(json), stateType)
+		require.NoError(t, err)
+		dv, err := tfprotov5.NewDynamicValue(stateType, value)
+		require.NoError(t, err)
+		return &dv
+	}
+	check := func(id, timeout, retryInterval string) string {
+		return `{
+			"id": ` + id + `,
+			"project_id": "3c49c3b6-a06d-424d-a6b6-0cd375ee9261",
+			"resource_id": "d8b3f1a2-8f3c-4a55-9c57-2b5b1e7e3f44",
+			"type": "endpoint",
+			"timeout": ` + timeout + `,
+			"service_connection_id": "0f8b6ab4-4bcd-4d4f-a1c3-4b5b7a0a4e9c",
+			"linked_variable_group": null,
+			"retry_interval": ` + retryInterval + `,
+			"display_name": "check",
+			"method": "POST",
+			"use_callback": false,
+			"body": "{}",
+			"url_suffix": null,
+			"success_criteria": null,
+			"headers": null
+		}`
+	}
+
+	resp, err := server.PlanResourceChange(context.Background(), &tfprotov5.PlanResourceChangeRequest{
+		TypeName:         "bblnazuredevops_check_invokerestapi",
+		PriorState:       dynamicValue(check(`"42"`, "43200", "5")),
+		Config:           dynamicValue(check("null", "null", "null")),
+		ProposedNewState: dynamicValue(check(`"42"`, "43200", "5")),
+	})
+	require.NoError(t, err)
+	require.Empty(t, resp.Diagnostics)
+
+	planned, err := resp.PlannedState.Unmarshal(stateType)
+	require.NoError(t, err)
+	var attributes map[string]tftypes.Value
+	require.NoError(t, planned.As(&attributes))
+
+	for _, name := range []string{"timeout", "retry_interval"} {
+		var value big.Float
+		require.NoError(t, attributes[name].As(&value))
+		require.Zero(t, value.Sign(), "%s should be planned to 0", name)
+	}
+}
@@ -1,10 +1,12 @@
 package main
 
 import (
+	"context"
 	"flag"
+	"log"
+
 	"github.com/babylonhealth/terraform-provider-bblnazuredevops/bblnazuredevops"
-	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
-	"github.com/hashicorp/terraform-plugin-sdk/v2/plugin"
+	"github.com/hashicorp/terraform-plugin-go/tfprotov5/tf5server"
 )
 
 func main() {
@@ -13,12 +15,18 @@ func main() {
 	flag.BoolVar(&debug, "debug", false, "set to true to run the provider with support for debuggers like delve")
 	flag.Parse()
 
-	opts := &plugin.ServeOpts{
-		Debug:        debug,
-		ProviderAddr: "babylonhealth.com/babylonhealth/bblnazuredevops",
-		ProviderFunc: func() *schema.Provider {
-			return bblnazuredevops.Provider()
-		},
+	serverFactory, err := bblnazuredevops.ProtoV5ProviderServerFactory(context.Background())
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var serveOpts []tf5server.ServeOpt
+	if debug {
+		serveOpts = append(serveOpts, tf5server.WithManagedDebug())
+	}
+
+	err = tf5server.Serve("babylonhealth.com/babylonhealth/bblnazuredevops", serverFactory, serveOpts...)
+	if err != nil {
+		log.Fatal(err)
 	}
-	plugin.Serve(opts)
 }